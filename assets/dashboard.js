@@ -0,0 +1,252 @@
+// Keeps the metrics table of the dashboard updated from the stream of server-sent events,
+// filters and sorts its rows and charts recent values of series on sparklines.
+(function () {
+    'use strict';
+
+    const table = document.getElementById('metrics');
+    if (!table || !window.EventSource) {
+        return;
+    }
+    const tbody = table.tBodies[0];
+    const nameFilter = document.getElementById('name-filter');
+    const typeFilter = document.getElementById('type-filter');
+    const status = document.getElementById('status');
+    const params = new URLSearchParams(window.location.search);
+
+    // number of recent values kept for sparklines, received with the ready event
+    let sparkSize = 120;
+    let sortBy = params.get('sort') || '';
+    let descending = params.get('order') === 'desc';
+    let sortPending = false;
+
+    nameFilter.value = params.get('name') || '';
+    typeFilter.value = params.get('type') || '';
+
+    function rowID(type, name) {
+        return type + ':' + name;
+    }
+
+    function parseSpark(s) {
+        return s ? s.split(',').map(Number) : [];
+    }
+
+    // rows by series, rows rendered by the server are taken as they are
+    const rows = new Map();
+    for (const tr of tbody.rows) {
+        tr.spark = parseSpark(tr.dataset.spark);
+        drawSpark(tr);
+        rows.set(rowID(tr.dataset.type, tr.dataset.name), tr);
+    }
+
+    function drawSpark(tr) {
+        const cell = tr.cells[3];
+        const values = tr.spark;
+        cell.textContent = '';
+        if (values.length < 2) {
+            return;
+        }
+        const width = 120;
+        const height = 24;
+        const min = Math.min(...values);
+        const max = Math.max(...values);
+        const span = max - min || 1;
+        const points = values.map(function (v, i) {
+            const x = i * width / (values.length - 1);
+            const y = height - 1 - (v - min) * (height - 2) / span;
+            return x.toFixed(1) + ',' + y.toFixed(1);
+        });
+        const svg = document.createElementNS('http://www.w3.org/2000/svg', 'svg');
+        svg.setAttribute('width', width);
+        svg.setAttribute('height', height);
+        svg.setAttribute('viewBox', '0 0 ' + width + ' ' + height);
+        const line = document.createElementNS('http://www.w3.org/2000/svg', 'polyline');
+        line.setAttribute('points', points.join(' '));
+        svg.appendChild(line);
+        cell.appendChild(svg);
+    }
+
+    function newRow(r) {
+        const tr = document.createElement('tr');
+        tr.dataset.name = r.name;
+        tr.dataset.type = r.type;
+        for (let i = 0; i < 5; i++) {
+            tr.insertCell();
+        }
+        tr.cells[0].textContent = r.name;
+        tr.cells[1].textContent = r.type;
+        tr.cells[3].className = 'spark';
+        tr.spark = [];
+        return tr;
+    }
+
+    // upsert updates the row of the series in place, recent values are replaced by ones sent with rows
+    // of the snapshot and updated values are appended to them
+    function upsert(r, updated) {
+        const id = rowID(r.type, r.name);
+        let tr = rows.get(id);
+        if (!tr) {
+            tr = newRow(r);
+            rows.set(id, tr);
+            tbody.appendChild(tr);
+        }
+        tr.dataset.sort = r.sort;
+        tr.cells[2].textContent = r.value;
+        tr.cells[4].textContent = r.stale ? r.updated + ' (stale)' : r.updated;
+        tr.classList.toggle('stale', r.stale);
+        if (updated) {
+            tr.spark.push(r.sort);
+            tr.spark.splice(0, tr.spark.length - sparkSize);
+            highlight(tr);
+        } else {
+            tr.spark = r.spark || [];
+        }
+        drawSpark(tr);
+        filterRow(tr);
+        scheduleSort();
+    }
+
+    function remove(r) {
+        const id = rowID(r.type, r.name);
+        const tr = rows.get(id);
+        if (tr) {
+            tr.remove();
+            rows.delete(id);
+        }
+    }
+
+    function highlight(tr) {
+        tr.classList.remove('updated');
+        // restarts the animation
+        void tr.offsetWidth;
+        tr.classList.add('updated');
+    }
+
+    function filterRow(tr) {
+        const name = nameFilter.value.trim().toLowerCase();
+        const type = typeFilter.value;
+        const visible = (!name || tr.dataset.name.toLowerCase().includes(name)) &&
+            (!type || tr.dataset.type === type);
+        tr.hidden = !visible;
+    }
+
+    function filter() {
+        rows.forEach(filterRow);
+        saveState();
+    }
+
+    function sortKey(tr) {
+        switch (sortBy) {
+            case 'value':
+                return Number(tr.dataset.sort);
+            case 'type':
+                return tr.dataset.type + ':' + tr.dataset.name;
+            case 'updated':
+                return tr.cells[4].textContent;
+            default:
+                return tr.dataset.name;
+        }
+    }
+
+    function sort() {
+        sortPending = false;
+        if (!sortBy) {
+            return;
+        }
+        const sorted = Array.from(rows.values()).sort(function (a, b) {
+            const ka = sortKey(a);
+            const kb = sortKey(b);
+            const c = ka < kb ? -1 : ka > kb ? 1 : 0;
+            return descending ? -c : c;
+        });
+        for (const tr of sorted) {
+            tbody.appendChild(tr);
+        }
+        for (const th of table.tHead.rows[0].cells) {
+            th.classList.toggle('sorted', th.dataset.sort === sortBy);
+            th.classList.toggle('descending', th.dataset.sort === sortBy && descending);
+        }
+    }
+
+    // scheduleSort sorts rows once per frame however many rows are updated
+    function scheduleSort() {
+        if (sortBy && !sortPending) {
+            sortPending = true;
+            window.requestAnimationFrame(sort);
+        }
+    }
+
+    // saveState keeps the filter and the order in the address, so the configured page can be reopened
+    function saveState() {
+        const state = new URLSearchParams();
+        if (nameFilter.value) {
+            state.set('name', nameFilter.value);
+        }
+        if (typeFilter.value) {
+            state.set('type', typeFilter.value);
+        }
+        if (sortBy) {
+            state.set('sort', sortBy);
+            if (descending) {
+                state.set('order', 'desc');
+            }
+        }
+        const query = state.toString();
+        window.history.replaceState(null, '', query ? '?' + query : window.location.pathname);
+    }
+
+    for (const th of table.tHead.rows[0].cells) {
+        if (!th.dataset.sort) {
+            continue;
+        }
+        th.addEventListener('click', function () {
+            descending = sortBy === th.dataset.sort ? !descending : false;
+            sortBy = th.dataset.sort;
+            sort();
+            saveState();
+        });
+    }
+    nameFilter.addEventListener('input', filter);
+    typeFilter.addEventListener('change', filter);
+    nameFilter.form.addEventListener('submit', function (e) {
+        e.preventDefault();
+    });
+    filter();
+    sort();
+
+    function data(e) {
+        return JSON.parse(e.data);
+    }
+
+    // series sent with rows of the snapshot of the current connection,
+    // rows of other series were deleted while the stream was reconnecting and are removed on the ready event
+    const received = new Set();
+
+    const source = new EventSource(table.dataset.stream);
+    source.addEventListener('row', function (e) {
+        const r = data(e);
+        received.add(rowID(r.type, r.name));
+        upsert(r, false);
+    });
+    source.addEventListener('update', function (e) {
+        upsert(data(e), true);
+    });
+    source.addEventListener('delete', function (e) {
+        remove(data(e));
+    });
+    source.addEventListener('ready', function (e) {
+        for (const [id, tr] of rows) {
+            if (!received.has(id)) {
+                tr.remove();
+                rows.delete(id);
+            }
+        }
+        received.clear();
+        sparkSize = data(e).spark;
+        status.textContent = 'live';
+        status.className = 'live';
+    });
+    source.addEventListener('error', function () {
+        status.textContent = 'reconnecting';
+        status.className = 'offline';
+    });
+})();
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,6 +20,7 @@ type AgentConfig struct {
 	PollInterval   time.Duration
 	ReportInterval time.Duration
 	CryptoKeyFile  string
+	Labels         map[string]string
 }
 
 type tempConfig struct {
@@ -31,6 +33,7 @@ type tempConfig struct {
 	PollInterval   int    `json:"poll_interval"`
 	ReportInterval int    `json:"report_interval"`
 	CryptoKeyFile  string `json:"crypto_key_file"`
+	Labels         string `json:"labels"`
 }
 
 type configFullness struct {
@@ -43,6 +46,7 @@ type configFullness struct {
 	PollInterval   bool
 	ReportInterval bool
 	CryptoKeyFile  bool
+	Labels         bool
 }
 
 func NewAgentConfig() *AgentConfig {
@@ -109,6 +113,10 @@ func parseEnvs(config *AgentConfig, full *configFullness) {
 		config.CryptoKeyFile = val
 		full.CryptoKeyFile = true
 	}
+	if val, ok := os.LookupEnv("LABELS"); ok {
+		config.Labels = mustParseLabels(val)
+		full.Labels = true
+	}
 }
 
 func parseFlags(config *AgentConfig, full *configFullness) {
@@ -149,6 +157,10 @@ func parseFlags(config *AgentConfig, full *configFullness) {
 		config.CryptoKeyFile = flagCfg.CryptoKeyFile
 		full.CryptoKeyFile = true
 	}
+	if !full.Labels && flagCfg.Labels != "" {
+		config.Labels = mustParseLabels(flagCfg.Labels)
+		full.Labels = true
+	}
 }
 
 func parseJSON(config *AgentConfig, full *configFullness) {
@@ -194,6 +206,10 @@ func parseJSON(config *AgentConfig, full *configFullness) {
 		config.CryptoKeyFile = JSONCfg.CryptoKeyFile
 		full.CryptoKeyFile = true
 	}
+	if !full.Labels && JSONCfg.Labels != "" {
+		config.Labels = mustParseLabels(JSONCfg.Labels)
+		full.Labels = true
+	}
 }
 
 func flags() *tempConfig {
@@ -207,6 +223,22 @@ func flags() *tempConfig {
 	flag.StringVar(&flagConfig.CryptoKeyFile, "crypto-key", "", "crypto key")
 	flag.StringVar(&flagConfig.Config, "c", "", "json config file")
 	flag.IntVar(&flagConfig.RateLimit, "l", 3, "rate limit")
+	flag.StringVar(&flagConfig.Labels, "labels", "", "labels added to all metrics, e.g. host=web1,service=api")
 	flag.Parse()
 	return flagConfig
 }
+
+func mustParseLabels(val string) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(val, ",") {
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			log.Fatalf("Failed to parse labels: invalid pair %q", pair)
+		}
+		labels[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return labels
+}
@@ -16,52 +16,125 @@ type ServerConfig struct {
 	LogLevel        string
 	FileStoragePath string
 	DatabaseDNS     string
-	Key             string
-	StoreInterval   time.Duration
-	Restore         bool
-	CryptoKeyFile   string
-	TrustedSubnet   string
+	// StorageURL - storage backend selected by the URL scheme, e.g. memory://, file:///path?interval=10s, sqlite:///path, redis://host:6379/0 or postgres://...,
+	// built from DatabaseDNS, FileStoragePath and StoreInterval if not set
+	StorageURL       string
+	Key              string
+	StoreInterval    time.Duration
+	HistoryRetention time.Duration
+	TTL              time.Duration
+	// CacheFlushInterval - interval of writing cached metrics to the storage, 0 disables the cache
+	CacheFlushInterval time.Duration
+	TTLRules           string
+	TenantKeys         string
+	SnapshotKeep       int
+	SnapshotCompress   bool
+	Restore            bool
+	CryptoKeyFile      string
+	TrustedSubnet      string
+	// ReplicateFrom - gRPC address of the replication leader, the server starts as its read-only follower if set
+	ReplicateFrom string
+	// AdminKey - key admin requests and replication followers sign with, the hash key is used if not set
+	AdminKey string
+	// ClusterPeers - comma separated gRPC addresses of all cluster nodes including this one, metrics are sharded across them if set
+	ClusterPeers string
+	// AlertRules - json file alerting rules are loaded from, it is reloaded once modified
+	AlertRules string
+	// AlertInterval - interval of evaluating alerting rules
+	AlertInterval time.Duration
+	// WebhookDeadLetter - file webhook events failed to be delivered are appended to, they are logged only if not set
+	WebhookDeadLetter string
+	// MaxTenants - maximum number of tenants with storages, 0 is unlimited
+	MaxTenants int
+	// Command - positional arguments following the flags, e.g. migrate up
+	Command []string
 }
 
 type tempConfig struct {
-	Config          string
-	RunAddr         string
-	RPCAddr         string
-	LogLevel        string
-	FileStoragePath string
-	DatabaseDNS     string
-	Key             string
-	StoreInterval   int
-	Restore         bool
-	CryptoKeyFile   string
-	TrustedSubnet   string
+	Config           string
+	RunAddr          string
+	RPCAddr          string
+	LogLevel         string
+	FileStoragePath  string
+	DatabaseDNS      string
+	StorageURL       string
+	Key              string
+	AdminKey         string
+	StoreInterval    int
+	HistoryRetention int
+	TTL              int
+	TTLRules         string
+	TenantKeys       string
+	MaxTenants       int
+	SnapshotKeep     int
+	SnapshotCompress bool
+	Restore          bool
+	CryptoKeyFile    string
+	TrustedSubnet    string
+	Command          []string
+	CacheFlush       int
+	ReplicateFrom    string
+	ClusterPeers     string
+	AlertRules       string
+	AlertInterval    int
+	DeadLetter       string
 }
 
 type jsonConfig struct {
-	RunAddr         string `json:"address"`
-	RPCAddr         string `json:"rpc_address"`
-	LogLevel        string `json:"log_level"`
-	FileStoragePath string `json:"store_file"`
-	DatabaseDNS     string `json:"database_dsn"`
-	Key             string `json:"key"`
-	StoreInterval   int    `json:"store_interval"`
-	Restore         bool   `json:"restore"`
-	CryptoKeyFile   string `json:"crypto_key"`
-	TrustedSubnet   string `json:"trusted_subnet"`
+	RunAddr          string `json:"address"`
+	RPCAddr          string `json:"rpc_address"`
+	LogLevel         string `json:"log_level"`
+	FileStoragePath  string `json:"store_file"`
+	DatabaseDNS      string `json:"database_dsn"`
+	StorageURL       string `json:"storage_url"`
+	Key              string `json:"key"`
+	AdminKey         string `json:"admin_key"`
+	StoreInterval    int    `json:"store_interval"`
+	HistoryRetention int    `json:"history_retention"`
+	TTL              int    `json:"ttl"`
+	TTLRules         string `json:"ttl_rules"`
+	TenantKeys       string `json:"tenant_keys"`
+	MaxTenants       int    `json:"max_tenants"`
+	SnapshotKeep     int    `json:"snapshot_keep"`
+	SnapshotCompress bool   `json:"snapshot_compress"`
+	Restore          bool   `json:"restore"`
+	CryptoKeyFile    string `json:"crypto_key"`
+	TrustedSubnet    string `json:"trusted_subnet"`
+	CacheFlush       int    `json:"cache_flush_interval"`
+	ReplicateFrom    string `json:"replicate_from"`
+	ClusterPeers     string `json:"cluster_peers"`
+	AlertRules       string `json:"alert_rules"`
+	AlertInterval    int    `json:"alert_interval"`
+	DeadLetter       string `json:"webhook_dead_letter"`
 }
 
 type configFullness struct {
-	Config          bool
-	RunAddr         bool
-	LogLevel        bool
-	FileStoragePath bool
-	DatabaseDNS     bool
-	Key             bool
-	CryptoKeyFile   bool
-	StoreInterval   bool
-	Restore         bool
-	TrustedSubnet   bool
-	RPCAddr         bool
+	Config           bool
+	RunAddr          bool
+	LogLevel         bool
+	FileStoragePath  bool
+	DatabaseDNS      bool
+	StorageURL       bool
+	Key              bool
+	AdminKey         bool
+	CryptoKeyFile    bool
+	StoreInterval    bool
+	HistoryRetention bool
+	TTL              bool
+	TTLRules         bool
+	TenantKeys       bool
+	MaxTenants       bool
+	SnapshotKeep     bool
+	SnapshotCompress bool
+	Restore          bool
+	TrustedSubnet    bool
+	RPCAddr          bool
+	CacheFlush       bool
+	ReplicateFrom    bool
+	ClusterPeers     bool
+	AlertRules       bool
+	AlertInterval    bool
+	DeadLetter       bool
 }
 
 func NewServerConfig() *ServerConfig {
@@ -72,11 +145,15 @@ func NewServerConfig() *ServerConfig {
 	if full.Config {
 		parseJSON(config, full)
 	}
+	if config.AdminKey == "" {
+		config.AdminKey = config.Key
+	}
 	return config
 }
 
 func parseFlags(config *ServerConfig, full *configFullness) {
 	flagCfg := parseFlagConfig()
+	config.Command = flagCfg.Command
 	if !full.Config && flagCfg.Config != "" {
 		config.Config = flagCfg.Config
 		full.Config = true
@@ -101,14 +178,74 @@ func parseFlags(config *ServerConfig, full *configFullness) {
 		config.DatabaseDNS = flagCfg.DatabaseDNS
 		full.DatabaseDNS = true
 	}
+	if !full.StorageURL && flagCfg.StorageURL != "" {
+		config.StorageURL = flagCfg.StorageURL
+		full.StorageURL = true
+	}
+	if !full.ReplicateFrom && flagCfg.ReplicateFrom != "" {
+		config.ReplicateFrom = flagCfg.ReplicateFrom
+		full.ReplicateFrom = true
+	}
+	if !full.ClusterPeers && flagCfg.ClusterPeers != "" {
+		config.ClusterPeers = flagCfg.ClusterPeers
+		full.ClusterPeers = true
+	}
+	if !full.AlertRules && flagCfg.AlertRules != "" {
+		config.AlertRules = flagCfg.AlertRules
+		full.AlertRules = true
+	}
+	if !full.DeadLetter && flagCfg.DeadLetter != "" {
+		config.WebhookDeadLetter = flagCfg.DeadLetter
+		full.DeadLetter = true
+	}
 	if !full.Key && flagCfg.Key != "" {
 		config.Key = flagCfg.Key
 		full.Key = true
 	}
+	if !full.AdminKey && flagCfg.AdminKey != "" {
+		config.AdminKey = flagCfg.AdminKey
+		full.AdminKey = true
+	}
 	if !full.StoreInterval {
 		config.StoreInterval = time.Duration(flagCfg.StoreInterval)
 		full.StoreInterval = true
 	}
+	if !full.HistoryRetention {
+		config.HistoryRetention = time.Duration(flagCfg.HistoryRetention)
+		full.HistoryRetention = true
+	}
+	if !full.TTL {
+		config.TTL = time.Duration(flagCfg.TTL)
+		full.TTL = true
+	}
+	if !full.TTLRules && flagCfg.TTLRules != "" {
+		config.TTLRules = flagCfg.TTLRules
+		full.TTLRules = true
+	}
+	if !full.TenantKeys && flagCfg.TenantKeys != "" {
+		config.TenantKeys = flagCfg.TenantKeys
+		full.TenantKeys = true
+	}
+	if !full.CacheFlush {
+		config.CacheFlushInterval = time.Duration(flagCfg.CacheFlush)
+		full.CacheFlush = true
+	}
+	if !full.AlertInterval {
+		config.AlertInterval = time.Duration(flagCfg.AlertInterval)
+		full.AlertInterval = true
+	}
+	if !full.MaxTenants {
+		config.MaxTenants = flagCfg.MaxTenants
+		full.MaxTenants = true
+	}
+	if !full.SnapshotKeep {
+		config.SnapshotKeep = flagCfg.SnapshotKeep
+		full.SnapshotKeep = true
+	}
+	if !full.SnapshotCompress {
+		config.SnapshotCompress = flagCfg.SnapshotCompress
+		full.SnapshotCompress = true
+	}
 	if !full.Restore {
 		config.Restore = flagCfg.Restore
 		full.Restore = true
@@ -130,13 +267,29 @@ func parseFlagConfig() *tempConfig {
 	flag.StringVar(&flagConfig.LogLevel, "l", "info", "log level")
 	flag.StringVar(&flagConfig.FileStoragePath, "f", "./tmp/metrics-db.json", "file storage path")
 	flag.IntVar(&flagConfig.StoreInterval, "i", 300, "store interval")
+	flag.IntVar(&flagConfig.HistoryRetention, "hr", 3600, "history retention")
+	flag.IntVar(&flagConfig.TTL, "ttl", 0, "metrics ttl in seconds, 0 disables expiry")
+	flag.StringVar(&flagConfig.TTLRules, "ttl-rules", "", "metrics ttl by name pattern, e.g. Alloc*=60,PollCount=0")
+	flag.StringVar(&flagConfig.TenantKeys, "tenant-keys", "", "keys agents of tenants sign requests with, e.g. team-a=secret,team-b=other")
+	flag.IntVar(&flagConfig.CacheFlush, "cache-flush", 0, "interval of writing cached metrics to the storage in seconds, 0 disables the cache")
+	flag.IntVar(&flagConfig.MaxTenants, "max-tenants", 100, "maximum number of tenants with storages, 0 is unlimited")
+	flag.IntVar(&flagConfig.SnapshotKeep, "snapshot-keep", 3, "number of kept dump files")
+	flag.BoolVar(&flagConfig.SnapshotCompress, "snapshot-compress", false, "compress dump files with gzip")
 	flag.BoolVar(&flagConfig.Restore, "r", true, "restore from dump file")
 	flag.StringVar(&flagConfig.DatabaseDNS, "d", "", "database dns")
+	flag.StringVar(&flagConfig.StorageURL, "storage-url", "", "storage url, e.g. memory://, file:///path?interval=10s, sqlite:///path, redis://host:6379/0 or postgres://..., overrides -d, -f and -i")
+	flag.StringVar(&flagConfig.ReplicateFrom, "replicate-from", "", "gRPC address of the replication leader to follow")
+	flag.StringVar(&flagConfig.AlertRules, "alert-rules", "", "json file with alerting rules and receivers, reloaded once modified")
+	flag.IntVar(&flagConfig.AlertInterval, "alert-interval", 15, "interval of evaluating alerting rules in seconds")
+	flag.StringVar(&flagConfig.DeadLetter, "webhook-dead-letter", "", "file webhook events failed to be delivered are appended to")
+	flag.StringVar(&flagConfig.ClusterPeers, "cluster-peers", "", "gRPC addresses of all cluster nodes including this one, e.g. node-1:9090,node-2:9090")
 	flag.StringVar(&flagConfig.Key, "k", "", "hash key")
+	flag.StringVar(&flagConfig.AdminKey, "admin-key", "", "key admin requests and replication followers sign with, the hash key is used if not set")
 	flag.StringVar(&flagConfig.CryptoKeyFile, "crypto-key", "", "crypto key")
 	flag.StringVar(&flagConfig.Config, "c", "", "json config file")
 	flag.StringVar(&flagConfig.TrustedSubnet, "t", "", "trusted subnet")
 	flag.Parse()
+	flagConfig.Command = flag.Args()
 	return flagConfig
 }
 
@@ -165,10 +318,42 @@ func parseEnvs(config *ServerConfig, full *configFullness) {
 		config.DatabaseDNS = val
 		full.DatabaseDNS = true
 	}
+	if val, ok := os.LookupEnv("STORAGE_URL"); ok {
+		config.StorageURL = val
+		full.StorageURL = true
+	}
+	if val, ok := os.LookupEnv("REPLICATE_FROM"); ok {
+		config.ReplicateFrom = val
+		full.ReplicateFrom = true
+	}
+	if val, ok := os.LookupEnv("CLUSTER_PEERS"); ok {
+		config.ClusterPeers = val
+		full.ClusterPeers = true
+	}
+	if val, ok := os.LookupEnv("ALERT_RULES"); ok {
+		config.AlertRules = val
+		full.AlertRules = true
+	}
+	if val, ok := os.LookupEnv("WEBHOOK_DEAD_LETTER"); ok {
+		config.WebhookDeadLetter = val
+		full.DeadLetter = true
+	}
+	if val, ok := os.LookupEnv("ALERT_INTERVAL"); ok {
+		interval, err := strconv.Atoi(val)
+		if err != nil {
+			log.Fatalf("Failed to parse env ALERT_INTERVAL value: %v", err)
+		}
+		config.AlertInterval = time.Duration(interval)
+		full.AlertInterval = true
+	}
 	if val, ok := os.LookupEnv("KEY"); ok {
 		config.Key = val
 		full.Key = true
 	}
+	if val, ok := os.LookupEnv("ADMIN_KEY"); ok {
+		config.AdminKey = val
+		full.AdminKey = true
+	}
 	if val, ok := os.LookupEnv("CRYPTO_KEY"); ok {
 		config.CryptoKeyFile = val
 		full.CryptoKeyFile = true
@@ -185,6 +370,62 @@ func parseEnvs(config *ServerConfig, full *configFullness) {
 		config.StoreInterval = time.Duration(storeInterval)
 		full.StoreInterval = true
 	}
+	if val, ok := os.LookupEnv("HISTORY_RETENTION"); ok {
+		historyRetention, err := strconv.Atoi(val)
+		if err != nil {
+			log.Fatalf("Failed to parse env HISTORY_RETENTION value: %v", err)
+		}
+		config.HistoryRetention = time.Duration(historyRetention)
+		full.HistoryRetention = true
+	}
+	if val, ok := os.LookupEnv("METRICS_TTL"); ok {
+		ttl, err := strconv.Atoi(val)
+		if err != nil {
+			log.Fatalf("Failed to parse env METRICS_TTL value: %v", err)
+		}
+		config.TTL = time.Duration(ttl)
+		full.TTL = true
+	}
+	if val, ok := os.LookupEnv("TTL_RULES"); ok {
+		config.TTLRules = val
+		full.TTLRules = true
+	}
+	if val, ok := os.LookupEnv("TENANT_KEYS"); ok {
+		config.TenantKeys = val
+		full.TenantKeys = true
+	}
+	if val, ok := os.LookupEnv("CACHE_FLUSH_INTERVAL"); ok {
+		flush, err := strconv.Atoi(val)
+		if err != nil {
+			log.Fatalf("Failed to parse env CACHE_FLUSH_INTERVAL value: %v", err)
+		}
+		config.CacheFlushInterval = time.Duration(flush)
+		full.CacheFlush = true
+	}
+	if val, ok := os.LookupEnv("MAX_TENANTS"); ok {
+		maxTenants, err := strconv.Atoi(val)
+		if err != nil {
+			log.Fatalf("Failed to parse env MAX_TENANTS value: %v", err)
+		}
+		config.MaxTenants = maxTenants
+		full.MaxTenants = true
+	}
+	if val, ok := os.LookupEnv("SNAPSHOT_KEEP"); ok {
+		keep, err := strconv.Atoi(val)
+		if err != nil {
+			log.Fatalf("Failed to parse env SNAPSHOT_KEEP value: %v", err)
+		}
+		config.SnapshotKeep = keep
+		full.SnapshotKeep = true
+	}
+	if val, ok := os.LookupEnv("SNAPSHOT_COMPRESS"); ok {
+		compress, err := strconv.ParseBool(val)
+		if err != nil {
+			log.Fatalf("Failed to parse env SNAPSHOT_COMPRESS value: %v", err)
+		}
+		config.SnapshotCompress = compress
+		full.SnapshotCompress = true
+	}
 	if val, ok := os.LookupEnv("RESTORE"); ok {
 		restore, err := strconv.ParseBool(val)
 		if err != nil {
@@ -226,14 +467,74 @@ func parseJSON(config *ServerConfig, full *configFullness) {
 		config.DatabaseDNS = JSONCfg.DatabaseDNS
 		full.DatabaseDNS = true
 	}
+	if !full.StorageURL && JSONCfg.StorageURL != "" {
+		config.StorageURL = JSONCfg.StorageURL
+		full.StorageURL = true
+	}
+	if !full.ReplicateFrom && JSONCfg.ReplicateFrom != "" {
+		config.ReplicateFrom = JSONCfg.ReplicateFrom
+		full.ReplicateFrom = true
+	}
+	if !full.ClusterPeers && JSONCfg.ClusterPeers != "" {
+		config.ClusterPeers = JSONCfg.ClusterPeers
+		full.ClusterPeers = true
+	}
+	if !full.AlertRules && JSONCfg.AlertRules != "" {
+		config.AlertRules = JSONCfg.AlertRules
+		full.AlertRules = true
+	}
+	if !full.DeadLetter && JSONCfg.DeadLetter != "" {
+		config.WebhookDeadLetter = JSONCfg.DeadLetter
+		full.DeadLetter = true
+	}
 	if !full.Key && JSONCfg.Key != "" {
 		config.Key = JSONCfg.Key
 		full.Key = true
 	}
+	if !full.AdminKey && JSONCfg.AdminKey != "" {
+		config.AdminKey = JSONCfg.AdminKey
+		full.AdminKey = true
+	}
 	if !full.StoreInterval {
 		config.StoreInterval = time.Duration(JSONCfg.StoreInterval)
 		full.StoreInterval = true
 	}
+	if !full.HistoryRetention {
+		config.HistoryRetention = time.Duration(JSONCfg.HistoryRetention)
+		full.HistoryRetention = true
+	}
+	if !full.TTL {
+		config.TTL = time.Duration(JSONCfg.TTL)
+		full.TTL = true
+	}
+	if !full.TTLRules && JSONCfg.TTLRules != "" {
+		config.TTLRules = JSONCfg.TTLRules
+		full.TTLRules = true
+	}
+	if !full.TenantKeys && JSONCfg.TenantKeys != "" {
+		config.TenantKeys = JSONCfg.TenantKeys
+		full.TenantKeys = true
+	}
+	if !full.CacheFlush {
+		config.CacheFlushInterval = time.Duration(JSONCfg.CacheFlush)
+		full.CacheFlush = true
+	}
+	if !full.AlertInterval {
+		config.AlertInterval = time.Duration(JSONCfg.AlertInterval)
+		full.AlertInterval = true
+	}
+	if !full.MaxTenants {
+		config.MaxTenants = JSONCfg.MaxTenants
+		full.MaxTenants = true
+	}
+	if !full.SnapshotKeep {
+		config.SnapshotKeep = JSONCfg.SnapshotKeep
+		full.SnapshotKeep = true
+	}
+	if !full.SnapshotCompress {
+		config.SnapshotCompress = JSONCfg.SnapshotCompress
+		full.SnapshotCompress = true
+	}
 	if !full.Restore {
 		config.Restore = JSONCfg.Restore
 		full.Restore = true
@@ -3,9 +3,18 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/vindosVP/metrics/cmd/server/config"
 	"github.com/vindosVP/metrics/internal/server"
+
+	// storage backends available by the storage url scheme
+	_ "github.com/vindosVP/metrics/internal/storage/driver/file"
+	_ "github.com/vindosVP/metrics/internal/storage/driver/memory"
+	_ "github.com/vindosVP/metrics/internal/storage/driver/postgres"
+	_ "github.com/vindosVP/metrics/internal/storage/driver/redis"
+	_ "github.com/vindosVP/metrics/internal/storage/driver/sqlite"
 	"github.com/vindosVP/metrics/pkg/logger"
 )
 
@@ -23,6 +32,10 @@ func main() {
 	if err != nil {
 		log.Fatalf(fmt.Sprintf("Failed to initialize logger: %v", err))
 	}
+	if len(cfg.Command) > 0 {
+		runCommand(cfg)
+		return
+	}
 	logger.Log.Info("Starting server")
 	s, err := server.New(cfg)
 	if err != nil {
@@ -31,6 +44,17 @@ func main() {
 	s.Run()
 }
 
+// runCommand runs the command given after the flags instead of starting the server.
+func runCommand(cfg *config.ServerConfig) {
+	if cfg.Command[0] != "migrate" || len(cfg.Command) != 2 {
+		logger.Log.Fatal(fmt.Sprintf("Unknown command %q, usage: server [flags] migrate up|down|status", strings.Join(cfg.Command, " ")))
+	}
+	err := server.Migrate(cfg, cfg.Command[1], os.Stdout)
+	if err != nil {
+		logger.Log.Fatal(fmt.Sprintf("Failed to migrate: %v", err))
+	}
+}
+
 func printBuildInfo() {
 	fmt.Printf("Build version: %s\n", buildVersion)
 	fmt.Printf("Build date: %s\n", buildDate)
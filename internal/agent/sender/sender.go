@@ -53,6 +53,7 @@ type Sender struct {
 	Key            string
 	ReportInterval time.Duration
 	RateLimit      int
+	Labels         map[string]string
 	UseHash        bool
 	CryptoKey      *rsa.PublicKey
 	IP             net.IP
@@ -86,6 +87,7 @@ func New(cfg *config.AgentConfig, s MetricsStorage, cryptoKey *rsa.PublicKey, IP
 		UseHash:        cfg.Key != "",
 		Key:            cfg.Key,
 		RateLimit:      cfg.RateLimit,
+		Labels:         cfg.Labels,
 		CryptoKey:      cryptoKey,
 		IP:             IP,
 	}
@@ -121,7 +123,7 @@ func (s *Sender) sendMetrics() {
 	if err != nil {
 		logger.Log.Error("Failed to get counter metrics", zap.Error(err))
 	}
-	batch := makeButch(c, g)
+	batch := makeButch(c, g, s.Labels)
 	jobs := s.generateJobs(batch)
 	results := make(chan result)
 	go listenResults(results)
@@ -243,16 +245,17 @@ func (s *Sender) send(url string, chunk []*models.Metrics, ip net.IP) error {
 	return nil
 }
 
-func makeButch(c map[string]int64, g map[string]float64) []*models.Metrics {
+func makeButch(c map[string]int64, g map[string]float64, labels map[string]string) []*models.Metrics {
 	batch := make([]*models.Metrics, len(c)+len(g))
 
 	i := 0
 	for k, v := range g {
 		val := v
 		metric := &models.Metrics{
-			ID:    k,
-			MType: models.Gauge,
-			Value: &val,
+			ID:     k,
+			MType:  models.Gauge,
+			Value:  &val,
+			Labels: labels,
 		}
 		batch[i] = metric
 		i++
@@ -261,9 +264,10 @@ func makeButch(c map[string]int64, g map[string]float64) []*models.Metrics {
 	for k, v := range c {
 		val := v
 		metric := &models.Metrics{
-			ID:    k,
-			MType: models.Counter,
-			Delta: &val,
+			ID:     k,
+			MType:  models.Counter,
+			Delta:  &val,
+			Labels: labels,
 		}
 		batch[i] = metric
 		i++
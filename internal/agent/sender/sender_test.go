@@ -31,7 +31,7 @@ func BenchmarkMakeButch(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		makeButch(c, g)
+		makeButch(c, g, nil)
 	}
 }
 
@@ -12,10 +12,12 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/vindosVP/metrics/cmd/agent/config"
 	"github.com/vindosVP/metrics/internal/models"
 	pb "github.com/vindosVP/metrics/internal/proto"
+	"github.com/vindosVP/metrics/internal/tenant"
 	"github.com/vindosVP/metrics/pkg/logger"
 )
 
@@ -44,6 +46,8 @@ type Sender struct {
 	Client         pb.MetricsClient
 	ReportInterval time.Duration
 	RateLimit      int
+	Labels         map[string]string
+	Key            string
 }
 
 type job struct {
@@ -74,6 +78,8 @@ func New(cfg *config.AgentConfig, s MetricsStorage) *Sender {
 		Storage:        s,
 		Client:         pb.NewMetricsClient(conn),
 		RateLimit:      cfg.RateLimit,
+		Labels:         cfg.Labels,
+		Key:            cfg.Key,
 	}
 }
 
@@ -107,7 +113,7 @@ func (s *Sender) sendMetrics() {
 	if err != nil {
 		logger.Log.Error("Failed to get counter metrics", zap.Error(err))
 	}
-	batch := makeButch(c, g)
+	batch := makeButch(c, g, s.Labels)
 	jobs := s.generateJobs(batch)
 	results := make(chan result)
 	go listenResults(results)
@@ -177,22 +183,28 @@ func (s *Sender) worker(jobs <-chan job, results chan<- result, wg *sync.WaitGro
 
 func (s *Sender) send(chunk []*models.Metrics) error {
 	ctx := context.Background()
+	if s.Key != "" {
+		// the server resolves the tenant from the key calls are signed with
+		ctx = metadata.AppendToOutgoingContext(ctx, tenant.HashMetadataKey, tenant.Sign("", s.Key))
+	}
 	_, err := retry.DoWithData(func() (*pb.UpdateBatchResponse, error) {
 		metrics := make([]*pb.Metric, 0, len(chunk))
 		for _, v := range chunk {
 			if v.MType == models.Gauge {
 				val := v.Value
 				metrics = append(metrics, &pb.Metric{
-					Type:  pb.MType_GAUGE,
-					Id:    v.ID,
-					Value: *val,
+					Type:   pb.MType_GAUGE,
+					Id:     v.ID,
+					Value:  *val,
+					Labels: v.Labels,
 				})
 			} else {
 				val := v.Delta
 				metrics = append(metrics, &pb.Metric{
-					Type:  pb.MType_COUNTER,
-					Id:    v.ID,
-					Delta: *val,
+					Type:   pb.MType_COUNTER,
+					Id:     v.ID,
+					Delta:  *val,
+					Labels: v.Labels,
 				})
 			}
 		}
@@ -206,16 +218,17 @@ func (s *Sender) send(chunk []*models.Metrics) error {
 	return nil
 }
 
-func makeButch(c map[string]int64, g map[string]float64) []*models.Metrics {
+func makeButch(c map[string]int64, g map[string]float64, labels map[string]string) []*models.Metrics {
 	batch := make([]*models.Metrics, len(c)+len(g))
 
 	i := 0
 	for k, v := range g {
 		val := v
 		metric := &models.Metrics{
-			ID:    k,
-			MType: models.Gauge,
-			Value: &val,
+			ID:     k,
+			MType:  models.Gauge,
+			Value:  &val,
+			Labels: labels,
 		}
 		batch[i] = metric
 		i++
@@ -224,9 +237,10 @@ func makeButch(c map[string]int64, g map[string]float64) []*models.Metrics {
 	for k, v := range c {
 		val := v
 		metric := &models.Metrics{
-			ID:    k,
-			MType: models.Counter,
-			Delta: &val,
+			ID:     k,
+			MType:  models.Counter,
+			Delta:  &val,
+			Labels: labels,
 		}
 		batch[i] = metric
 		i++
@@ -0,0 +1,367 @@
+// Package alerting evaluates alerting rules against the storage and notifies webhook receivers about alerts.
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// MetricsStorage consists methods rules are evaluated with
+type MetricsStorage interface {
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+}
+
+// TenantStorage returns tenants rules are evaluated for.
+type TenantStorage interface {
+	Tenants() []string
+}
+
+// Engine evaluates rules on interval and keeps states of their alerts.
+// Pending alerts become firing once their condition holds for the for duration of the rule,
+// firing alerts become resolved once it no longer holds, receivers are notified about both transitions.
+type Engine struct {
+	s        MetricsStorage
+	tenants  TenantStorage
+	notifier *notifier
+	active   func() bool
+	rules    *Rules
+	alerts   map[string]*models.Alert
+	modTime  time.Time
+	fileName string
+	mu       sync.RWMutex
+}
+
+// hit - series of the tenant the condition of the rule holds for
+type hit struct {
+	rule   *Rule
+	tenant string
+	series string
+	value  float64
+}
+
+// New creates Engine without rules.
+func New(s MetricsStorage, tenants TenantStorage, opts ...func(*Engine)) *Engine {
+	e := &Engine{
+		s:        s,
+		tenants:  tenants,
+		notifier: newNotifier(),
+		active:   func() bool { return true },
+		rules:    &Rules{},
+		alerts:   make(map[string]*models.Alert),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// WithRulesFile sets the json file rules are loaded from, it is reloaded once modified.
+func WithRulesFile(fileName string) func(*Engine) {
+	return func(e *Engine) {
+		e.fileName = fileName
+	}
+}
+
+// WithActive sets the function reporting whether rules should be evaluated, e.g. on the replication leader only.
+func WithActive(active func() bool) func(*Engine) {
+	return func(e *Engine) {
+		e.active = active
+	}
+}
+
+// Load method loads rules from the rules file if it was modified since the last load.
+// Invalid rules are rejected and the previous ones are kept, Load is not safe for concurrent use.
+func (e *Engine) Load() error {
+	if e.fileName == "" {
+		return nil
+	}
+	info, err := os.Stat(e.fileName)
+	if err != nil {
+		return fmt.Errorf("failed to read rules file: %w", err)
+	}
+	if info.ModTime().Equal(e.modTime) {
+		return nil
+	}
+	// invalid rules are reported once, until the file is modified again
+	e.modTime = info.ModTime()
+	rules, err := LoadRules(e.fileName)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.rules = rules
+	e.mu.Unlock()
+	logger.Log.Info("Loaded alerting rules", zap.String("file", e.fileName), zap.Int("rules", len(rules.Rules)))
+	return nil
+}
+
+// Run method evaluates rules on interval until done is closed.
+func (e *Engine) Run(interval time.Duration, done <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+			if !e.active() {
+				continue
+			}
+			if err := e.Load(); err != nil {
+				logger.Log.Error("Failed to reload alerting rules", zap.Error(err))
+			}
+			e.Evaluate(context.Background(), time.Now())
+		}
+	}
+}
+
+// Evaluate method evaluates rules for all tenants at the time and notifies receivers about state transitions.
+// Alerts of rules failed to evaluate keep their states.
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) {
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	hits := make(map[string]*hit)
+	failed := make(map[string]bool)
+	for _, id := range e.tenants.Tenants() {
+		tctx := tenant.WithTenant(ctx, id)
+		for _, rule := range rules.Rules {
+			if rule.Tenant != "" && rule.Tenant != id {
+				continue
+			}
+			found, err := e.evaluate(tctx, rule, id, now)
+			if err != nil {
+				logger.Log.Error("Failed to evaluate alerting rule", zap.String("rule", rule.Name), zap.String("tenant", id), zap.Error(err))
+				failed[ruleKey(id, rule.Name)] = true
+				continue
+			}
+			for _, h := range found {
+				hits[alertKey(id, rule.Name, h.series)] = h
+			}
+		}
+	}
+
+	notifications := e.transition(rules, hits, failed, now)
+	for _, n := range notifications {
+		e.notifier.notify(ctx, n.receivers, n.alert)
+	}
+}
+
+// notification - alert copy sent to receivers of its rule
+type notification struct {
+	alert     *models.Alert
+	receivers []*Receiver
+}
+
+// transition moves alerts to their new states and returns notifications about firing and resolved alerts.
+func (e *Engine) transition(rules *Rules, hits map[string]*hit, failed map[string]bool, now time.Time) []*notification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	res := make([]*notification, 0)
+	for key, h := range hits {
+		alert, ok := e.alerts[key]
+		if !ok {
+			alert = &models.Alert{
+				ActiveAt: now,
+				Rule:     h.rule.Name,
+				Tenant:   h.tenant,
+				Series:   h.series,
+				State:    models.AlertPending,
+				Summary:  h.rule.Summary,
+			}
+			e.alerts[key] = alert
+		}
+		alert.Value = h.value
+		if alert.State == models.AlertPending && now.Sub(alert.ActiveAt) >= h.rule.forDuration() {
+			fired := now
+			alert.State = models.AlertFiring
+			alert.FiredAt = &fired
+			res = append(res, &notification{alert: copyAlert(alert), receivers: receivers(rules, h.rule)})
+		}
+	}
+	for key, alert := range e.alerts {
+		if _, ok := hits[key]; ok || failed[ruleKey(alert.Tenant, alert.Rule)] {
+			continue
+		}
+		delete(e.alerts, key)
+		if alert.State != models.AlertFiring {
+			continue
+		}
+		resolved := now
+		alert.State = models.AlertResolved
+		alert.ResolvedAt = &resolved
+		res = append(res, &notification{alert: alert, receivers: receivers(rules, rules.rule(alert.Rule))})
+	}
+	return res
+}
+
+// Alerts method returns pending and firing alerts of the tenant of the context.
+func (e *Engine) Alerts(ctx context.Context) []*models.Alert {
+	id := tenant.FromContext(ctx)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	res := make([]*models.Alert, 0)
+	for _, alert := range e.alerts {
+		if alert.Tenant == id {
+			res = append(res, copyAlert(alert))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Rule != res[j].Rule {
+			return res[i].Rule < res[j].Rule
+		}
+		return res[i].Series < res[j].Series
+	})
+	return res
+}
+
+// evaluate returns series of the tenant the condition of the rule holds for.
+func (e *Engine) evaluate(ctx context.Context, rule *Rule, id string, now time.Time) ([]*hit, error) {
+	switch rule.Kind {
+	case KindThreshold:
+		return e.threshold(ctx, rule, id)
+	case KindRate:
+		return e.rate(ctx, rule, id, now)
+	case KindAbsent:
+		return e.absent(ctx, rule, id, now)
+	}
+	return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidRule, rule.Kind)
+}
+
+func (e *Engine) threshold(ctx context.Context, rule *Rule, id string) ([]*hit, error) {
+	values, err := e.values(ctx, rule.MType)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*hit, 0)
+	for key, v := range values {
+		if rule.matches(id, key) && comparisons[rule.Op](v, rule.Value) {
+			res = append(res, &hit{rule: rule, tenant: id, series: key, value: v})
+		}
+	}
+	return res, nil
+}
+
+// rate compares the per second increase of counters between the first and the last samples within the window.
+func (e *Engine) rate(ctx context.Context, rule *Rule, id string, now time.Time) ([]*hit, error) {
+	counters, err := e.s.GetAllCounter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*hit, 0)
+	for key := range counters {
+		if !rule.matches(id, key) {
+			continue
+		}
+		samples, err := e.s.GetHistory(ctx, models.Counter, key, now.Add(-rule.window()), now)
+		if err != nil {
+			return nil, err
+		}
+		if len(samples) < 2 {
+			continue
+		}
+		first, last := samples[0], samples[len(samples)-1]
+		if first.Delta == nil || last.Delta == nil {
+			continue
+		}
+		v := float64(*last.Delta-*first.Delta) / rule.window().Seconds()
+		if comparisons[rule.Op](v, rule.Value) {
+			res = append(res, &hit{rule: rule, tenant: id, series: key, value: v})
+		}
+	}
+	return res, nil
+}
+
+// absent reports the metric pattern of the rule if none of matching series were updated within the window,
+// the value is the number of seconds since the last update, zero if there were none.
+func (e *Engine) absent(ctx context.Context, rule *Rule, id string, now time.Time) ([]*hit, error) {
+	updated, err := e.s.GetUpdated(ctx, rule.MType)
+	if err != nil {
+		return nil, err
+	}
+	var last time.Time
+	for key, t := range updated {
+		if rule.matches(id, key) && t.After(last) {
+			last = t
+		}
+	}
+	if !last.IsZero() && now.Sub(last) <= rule.window() {
+		return nil, nil
+	}
+	v := 0.0
+	if !last.IsZero() {
+		v = now.Sub(last).Seconds()
+	}
+	return []*hit{{rule: rule, tenant: id, series: rule.Metric, value: v}}, nil
+}
+
+func (e *Engine) values(ctx context.Context, mType string) (map[string]float64, error) {
+	if mType == models.Gauge {
+		return e.s.GetAllGauge(ctx)
+	}
+	counters, err := e.s.GetAllCounter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]float64, len(counters))
+	for key, v := range counters {
+		res[key] = float64(v)
+	}
+	return res, nil
+}
+
+// rule method returns the rule with the name, nil if there is none.
+func (r *Rules) rule(name string) *Rule {
+	for _, rule := range r.Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// receivers returns receivers of the rule, none if the rule was removed.
+func receivers(rules *Rules, rule *Rule) []*Receiver {
+	if rule == nil {
+		return nil
+	}
+	res := make([]*Receiver, 0, len(rule.Receivers))
+	for _, name := range rule.Receivers {
+		for _, rcv := range rules.Receivers {
+			if rcv.Name == name {
+				res = append(res, rcv)
+			}
+		}
+	}
+	return res
+}
+
+func copyAlert(alert *models.Alert) *models.Alert {
+	c := *alert
+	return &c
+}
+
+func ruleKey(id string, rule string) string {
+	return id + "\x00" + rule
+}
+
+func alertKey(id string, rule string, series string) string {
+	return ruleKey(id, rule) + "\x00" + series
+}
@@ -0,0 +1,210 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/storage/tenantstorage"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+// receiver collects alerts posted to it.
+type receiver struct {
+	alerts []*models.Alert
+	mu     sync.Mutex
+}
+
+func (r *receiver) serve(t *testing.T) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		alert := &models.Alert{}
+		if err := json.NewDecoder(req.Body).Decode(alert); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		r.alerts = append(r.alerts, alert)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func (r *receiver) states() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]string, 0, len(r.alerts))
+	for _, alert := range r.alerts {
+		res = append(res, alert.Rule+" "+alert.State)
+	}
+	return res
+}
+
+func newStorage() *tenantstorage.Storage {
+	return tenantstorage.New(func(string) (tenantstorage.MetricsStorage, error) {
+		return memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo(), memstorage.WithHistory(repos.NewHistoryRepo(0))), nil
+	})
+}
+
+func writeRules(t *testing.T, fileName string, rules *Rules) {
+	data, err := json.Marshal(rules)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(fileName, data, 0o600))
+}
+
+func TestEngine_Threshold(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage()
+	rcv := &receiver{}
+	fileName := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, fileName, &Rules{
+		Receivers: []*Receiver{{Name: "ops", URL: rcv.serve(t)}},
+		Rules: []*Rule{
+			{Name: "HighAlloc", Kind: KindThreshold, Metric: "Alloc", MType: models.Gauge, Op: ">", Value: 10, For: 60, Receivers: []string{"ops"}},
+		},
+	})
+	e := New(s, s, WithRulesFile(fileName))
+	require.NoError(t, e.Load())
+
+	now := time.Now()
+	_, err := s.UpdateGauge(ctx, "Alloc", 20)
+	require.NoError(t, err)
+	e.Evaluate(ctx, now)
+	alerts := e.Alerts(ctx)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, models.AlertPending, alerts[0].State)
+	assert.Equal(t, "Alloc", alerts[0].Series)
+	assert.Equal(t, 20.0, alerts[0].Value)
+	assert.Empty(t, e.Alerts(tenant.WithTenant(ctx, "team-a")))
+	assert.Empty(t, rcv.states())
+
+	// the alert fires once the condition holds for the for duration
+	e.Evaluate(ctx, now.Add(time.Minute))
+	alerts = e.Alerts(ctx)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, models.AlertFiring, alerts[0].State)
+	assert.Equal(t, []string{"HighAlloc firing"}, rcv.states())
+
+	e.Evaluate(ctx, now.Add(2*time.Minute))
+	assert.Equal(t, []string{"HighAlloc firing"}, rcv.states())
+
+	_, err = s.UpdateGauge(ctx, "Alloc", 5)
+	require.NoError(t, err)
+	e.Evaluate(ctx, now.Add(3*time.Minute))
+	assert.Empty(t, e.Alerts(ctx))
+	assert.Equal(t, []string{"HighAlloc firing", "HighAlloc resolved"}, rcv.states())
+}
+
+func TestEngine_PendingResolved(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage()
+	rcv := &receiver{}
+	fileName := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, fileName, &Rules{
+		Receivers: []*Receiver{{Name: "ops", URL: rcv.serve(t)}},
+		Rules: []*Rule{
+			{Name: "ManyPolls", Kind: KindThreshold, Metric: "Poll*", MType: models.Counter, Op: ">=", Value: 3, For: 60, Receivers: []string{"ops"}},
+		},
+	})
+	e := New(s, s, WithRulesFile(fileName))
+	require.NoError(t, e.Load())
+
+	now := time.Now()
+	_, err := s.UpdateCounter(ctx, "PollCount", 3)
+	require.NoError(t, err)
+	e.Evaluate(ctx, now)
+	require.Len(t, e.Alerts(ctx), 1)
+
+	// pending alerts are dropped without notifications
+	require.NoError(t, s.Delete(ctx, models.Counter, "PollCount"))
+	e.Evaluate(ctx, now.Add(time.Minute))
+	assert.Empty(t, e.Alerts(ctx))
+	assert.Empty(t, rcv.states())
+}
+
+func TestEngine_Rate(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "team-a")
+	s := newStorage()
+	e := New(s, s)
+	e.rules = &Rules{Rules: []*Rule{
+		{Name: "FastPolls", Kind: KindRate, Metric: "PollCount", MType: models.Counter, Op: ">", Value: 1, Window: 10},
+	}}
+
+	_, err := s.UpdateCounter(ctx, "PollCount", 5)
+	require.NoError(t, err)
+	e.Evaluate(ctx, time.Now())
+	assert.Empty(t, e.Alerts(ctx))
+
+	_, err = s.UpdateCounter(ctx, "PollCount", 50)
+	require.NoError(t, err)
+	e.Evaluate(ctx, time.Now())
+	alerts := e.Alerts(ctx)
+	require.Len(t, alerts, 1)
+	// increase of 50 within 10 seconds
+	assert.Equal(t, 5.0, alerts[0].Value)
+	assert.Equal(t, models.AlertFiring, alerts[0].State)
+	assert.Equal(t, "team-a", alerts[0].Tenant)
+}
+
+func TestEngine_Absent(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage()
+	e := New(s, s)
+	e.rules = &Rules{Rules: []*Rule{
+		{Name: "AgentDown", Kind: KindAbsent, Metric: "PollCount", MType: models.Counter, Window: 60},
+	}}
+	// the storage of the default tenant is opened on start
+	_, err := s.Open(tenant.Default)
+	require.NoError(t, err)
+
+	e.Evaluate(ctx, time.Now())
+	alerts := e.Alerts(ctx)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "PollCount", alerts[0].Series)
+	assert.Equal(t, 0.0, alerts[0].Value)
+
+	_, err = s.UpdateCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+	now := time.Now()
+	e.Evaluate(ctx, now)
+	assert.Empty(t, e.Alerts(ctx))
+
+	e.Evaluate(ctx, now.Add(2*time.Minute))
+	alerts = e.Alerts(ctx)
+	require.Len(t, alerts, 1)
+	assert.InDelta(t, 120, alerts[0].Value, 1)
+}
+
+func TestEngine_Load(t *testing.T) {
+	s := newStorage()
+	fileName := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, fileName, &Rules{Rules: []*Rule{
+		{Name: "AgentDown", Kind: KindAbsent, Metric: "PollCount", MType: models.Counter, Window: 60},
+	}})
+	e := New(s, s, WithRulesFile(fileName))
+	require.NoError(t, e.Load())
+	assert.Len(t, e.rules.Rules, 1)
+
+	// invalid rules keep the previous ones
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"rules": [{"name": "r"}]}`), 0o600))
+	require.NoError(t, os.Chtimes(fileName, time.Now(), time.Now().Add(time.Second)))
+	assert.ErrorIs(t, e.Load(), ErrInvalidRule)
+	assert.Len(t, e.rules.Rules, 1)
+
+	writeRules(t, fileName, &Rules{})
+	require.NoError(t, os.Chtimes(fileName, time.Now(), time.Now().Add(2*time.Second)))
+	require.NoError(t, e.Load())
+	assert.Empty(t, e.rules.Rules)
+}
@@ -0,0 +1,66 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// notifyTimeout - time given to a receiver to accept the notification
+const notifyTimeout = 5 * time.Second
+
+// notifier posts alerts to webhook receivers in json format.
+type notifier struct {
+	client *http.Client
+}
+
+func newNotifier() *notifier {
+	return &notifier{client: &http.Client{Timeout: notifyTimeout}}
+}
+
+// notify posts the alert to all receivers, failures are logged and not retried.
+func (n *notifier) notify(ctx context.Context, receivers []*Receiver, alert *models.Alert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		logger.Log.Error("Failed to marshal alert", zap.String("rule", alert.Rule), zap.Error(err))
+		return
+	}
+	for _, rcv := range receivers {
+		if err = n.post(ctx, rcv.URL, body); err != nil {
+			logger.Log.Error("Failed to notify alert receiver",
+				zap.String("receiver", rcv.Name),
+				zap.String("rule", alert.Rule),
+				zap.Error(err))
+			continue
+		}
+		logger.Log.Info("Notified alert receiver",
+			zap.String("receiver", rcv.Name),
+			zap.String("rule", alert.Rule),
+			zap.String("state", alert.State))
+	}
+}
+
+func (n *notifier) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+const (
+	// KindThreshold - value of a gauge or counter series compared to the rule value
+	KindThreshold = "threshold"
+
+	// KindAbsent - no series matching the rule were updated within the window
+	KindAbsent = "absent"
+
+	// KindRate - per second increase of a counter series within the window compared to the rule value
+	KindRate = "rate"
+)
+
+var (
+	// ErrInvalidRule - represents that the alerting rule is inconsistent
+	ErrInvalidRule = errors.New("invalid alerting rule")
+)
+
+// comparisons - supported operators of threshold and rate rules
+var comparisons = map[string]func(v, threshold float64) bool{
+	">":  func(v, threshold float64) bool { return v > threshold },
+	">=": func(v, threshold float64) bool { return v >= threshold },
+	"<":  func(v, threshold float64) bool { return v < threshold },
+	"<=": func(v, threshold float64) bool { return v <= threshold },
+	"==": func(v, threshold float64) bool { return v == threshold },
+	"!=": func(v, threshold float64) bool { return v != threshold },
+}
+
+// Rule - condition evaluated for series of metrics with names matching the Metric pattern.
+// Pattern syntax is the one of path.Match, rules without a tenant are evaluated for all tenants.
+// Window and For are in seconds, alerts fire once the condition holds for the For duration.
+type Rule struct {
+	Name      string   `json:"name"`
+	Kind      string   `json:"kind"`
+	Metric    string   `json:"metric"`
+	MType     string   `json:"type"`
+	Tenant    string   `json:"tenant,omitempty"`
+	Op        string   `json:"op,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+	Receivers []string `json:"receivers,omitempty"`
+	Value     float64  `json:"value,omitempty"`
+	Window    int      `json:"window,omitempty"`
+	For       int      `json:"for,omitempty"`
+}
+
+// Receiver - webhook notified about firing and resolved alerts in json format.
+type Receiver struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Rules - content of the rules file.
+type Rules struct {
+	Receivers []*Receiver `json:"receivers"`
+	Rules     []*Rule     `json:"rules"`
+}
+
+// LoadRules reads and validates rules from the json file.
+func LoadRules(fileName string) (*Rules, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	rules := &Rules{}
+	if err = json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	if err = rules.Validate(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Validate method checks that rule names are unique and rules refer to known receivers.
+func (r *Rules) Validate() error {
+	receivers := make(map[string]bool, len(r.Receivers))
+	for _, rcv := range r.Receivers {
+		if rcv.Name == "" || rcv.URL == "" {
+			return fmt.Errorf("invalid receiver %q: name and url are required", rcv.Name)
+		}
+		receivers[rcv.Name] = true
+	}
+	names := make(map[string]bool, len(r.Rules))
+	for _, rule := range r.Rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("%w: duplicate rule %s", ErrInvalidRule, rule.Name)
+		}
+		names[rule.Name] = true
+		for _, name := range rule.Receivers {
+			if !receivers[name] {
+				return fmt.Errorf("%w: rule %s refers to unknown receiver %s", ErrInvalidRule, rule.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate method checks that the rule is consistent with its kind.
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	if _, err := path.Match(r.Metric, ""); err != nil || r.Metric == "" {
+		return fmt.Errorf("%w: rule %s has invalid metric pattern", ErrInvalidRule, r.Name)
+	}
+	if r.Window < 0 || r.For < 0 {
+		return fmt.Errorf("%w: rule %s has negative duration", ErrInvalidRule, r.Name)
+	}
+	switch r.Kind {
+	case KindThreshold:
+		if r.MType != models.Gauge && r.MType != models.Counter {
+			return fmt.Errorf("%w: threshold rule %s must be of gauge or counter type", ErrInvalidRule, r.Name)
+		}
+	case KindRate:
+		if r.MType != models.Counter {
+			return fmt.Errorf("%w: rate rule %s must be of counter type", ErrInvalidRule, r.Name)
+		}
+		if r.Window == 0 {
+			return fmt.Errorf("%w: rate rule %s requires window", ErrInvalidRule, r.Name)
+		}
+	case KindAbsent:
+		if !slices.Contains(models.Types, r.MType) {
+			return fmt.Errorf("%w: absence rule %s has unknown type", ErrInvalidRule, r.Name)
+		}
+		if r.Window == 0 {
+			return fmt.Errorf("%w: absence rule %s requires window", ErrInvalidRule, r.Name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: rule %s has unknown kind %q", ErrInvalidRule, r.Name, r.Kind)
+	}
+	if _, ok := comparisons[r.Op]; !ok {
+		return fmt.Errorf("%w: rule %s has unknown operator %q", ErrInvalidRule, r.Name, r.Op)
+	}
+	return nil
+}
+
+// matches method reports whether the rule covers the series of the tenant.
+func (r *Rule) matches(id string, key string) bool {
+	if r.Tenant != "" && r.Tenant != id {
+		return false
+	}
+	name, _, err := models.ParseSeriesKey(key)
+	if err != nil {
+		name = key
+	}
+	ok, _ := path.Match(r.Metric, name)
+	return ok
+}
+
+func (r *Rule) window() time.Duration {
+	return time.Duration(r.Window) * time.Second
+}
+
+func (r *Rule) forDuration() time.Duration {
+	return time.Duration(r.For) * time.Second
+}
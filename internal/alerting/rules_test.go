@@ -0,0 +1,81 @@
+package alerting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRule_Validate(t *testing.T) {
+	tests := []struct {
+		rule    *Rule
+		name    string
+		wantErr bool
+	}{
+		{name: "threshold", rule: &Rule{Name: "r", Kind: KindThreshold, Metric: "Alloc", MType: "gauge", Op: ">", Value: 1}},
+		{name: "rate", rule: &Rule{Name: "r", Kind: KindRate, Metric: "Poll*", MType: "counter", Op: ">=", Window: 60}},
+		{name: "absent", rule: &Rule{Name: "r", Kind: KindAbsent, Metric: "Latency", MType: "histogram", Window: 60}},
+		{name: "no name", rule: &Rule{Kind: KindThreshold, Metric: "Alloc", MType: "gauge", Op: ">"}, wantErr: true},
+		{name: "invalid pattern", rule: &Rule{Name: "r", Kind: KindThreshold, Metric: "[", MType: "gauge", Op: ">"}, wantErr: true},
+		{name: "unknown kind", rule: &Rule{Name: "r", Kind: "other", Metric: "Alloc", MType: "gauge", Op: ">"}, wantErr: true},
+		{name: "unknown operator", rule: &Rule{Name: "r", Kind: KindThreshold, Metric: "Alloc", MType: "gauge", Op: "=>"}, wantErr: true},
+		{name: "threshold of histogram", rule: &Rule{Name: "r", Kind: KindThreshold, Metric: "Alloc", MType: "histogram", Op: ">"}, wantErr: true},
+		{name: "rate of gauge", rule: &Rule{Name: "r", Kind: KindRate, Metric: "Alloc", MType: "gauge", Op: ">", Window: 60}, wantErr: true},
+		{name: "rate without window", rule: &Rule{Name: "r", Kind: KindRate, Metric: "Poll", MType: "counter", Op: ">"}, wantErr: true},
+		{name: "absent without window", rule: &Rule{Name: "r", Kind: KindAbsent, Metric: "Poll", MType: "counter"}, wantErr: true},
+		{name: "negative for", rule: &Rule{Name: "r", Kind: KindAbsent, Metric: "Poll", MType: "counter", Window: 60, For: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRule)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			content: `{"receivers": [{"name": "ops", "url": "http://localhost/hook"}],
+				"rules": [{"name": "HighAlloc", "kind": "threshold", "metric": "Alloc", "type": "gauge", "op": ">", "value": 10, "receivers": ["ops"]}]}`,
+		},
+		{name: "invalid json", content: `{"rules": [`, wantErr: true},
+		{
+			name:    "unknown receiver",
+			content: `{"rules": [{"name": "HighAlloc", "kind": "threshold", "metric": "Alloc", "type": "gauge", "op": ">", "receivers": ["ops"]}]}`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate rule",
+			content: `{"rules": [{"name": "r", "kind": "absent", "metric": "Alloc", "type": "gauge", "window": 60},
+				{"name": "r", "kind": "absent", "metric": "Alloc", "type": "gauge", "window": 60}]}`,
+			wantErr: true,
+		},
+		{name: "receiver without url", content: `{"receivers": [{"name": "ops"}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "rules.json")
+			require.NoError(t, os.WriteFile(fileName, []byte(tt.content), 0o600))
+			rules, err := LoadRules(fileName)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, rules.Rules, 1)
+		})
+	}
+}
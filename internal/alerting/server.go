@@ -0,0 +1,42 @@
+package alerting
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/vindosVP/metrics/internal/proto"
+)
+
+// Server serves active alerts over gRPC.
+type Server struct {
+	pb.UnimplementedAlertingServer
+	e *Engine
+}
+
+// NewServer creates Server.
+func NewServer(e *Engine) *Server {
+	return &Server{e: e}
+}
+
+// ListAlerts method returns pending and firing alerts of the tenant of the call.
+func (s *Server) ListAlerts(ctx context.Context, _ *pb.ListAlertsRequest) (*pb.ListAlertsResponse, error) {
+	alerts := s.e.Alerts(ctx)
+	resp := &pb.ListAlertsResponse{Alerts: make([]*pb.Alert, 0, len(alerts))}
+	for _, alert := range alerts {
+		a := &pb.Alert{
+			Rule:     alert.Rule,
+			Tenant:   alert.Tenant,
+			Series:   alert.Series,
+			State:    alert.State,
+			Summary:  alert.Summary,
+			Value:    alert.Value,
+			ActiveAt: timestamppb.New(alert.ActiveAt),
+		}
+		if alert.FiredAt != nil {
+			a.FiredAt = timestamppb.New(*alert.FiredAt)
+		}
+		resp.Alerts = append(resp.Alerts, a)
+	}
+	return resp, nil
+}
@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// batchTTL - time nodes remember applied batches, forwarding of a batch is retried within it
+const batchTTL = 10 * time.Minute
+
+// errBatchPending - represents that the batch with the same id is being applied by the node
+var errBatchPending = errors.New("batch is being applied")
+
+// newBatchID returns a random id of the forwarded batch.
+func newBatchID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// batches remembers ids of batches applied by the node, so batches forwarded again are applied once.
+type batches struct {
+	applied map[string]time.Time
+	pending map[string]bool
+	mu      sync.Mutex
+}
+
+func newBatches() *batches {
+	return &batches{applied: make(map[string]time.Time), pending: make(map[string]bool)}
+}
+
+// begin returns false if the batch is already applied and errBatchPending if it's being applied,
+// otherwise the batch is marked as pending until end is called.
+func (b *batches) begin(id string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	for key, t := range b.applied {
+		if now.Sub(t) > batchTTL {
+			delete(b.applied, key)
+		}
+	}
+	if _, ok := b.applied[id]; ok {
+		return false, nil
+	}
+	if b.pending[id] {
+		return false, errBatchPending
+	}
+	b.pending[id] = true
+	return true, nil
+}
+
+// end marks the pending batch as applied, the failed batch is forgotten and can be applied again.
+func (b *batches) end(id string, applied bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.pending, id)
+	if applied {
+		b.applied[id] = time.Now()
+	}
+}
@@ -0,0 +1,279 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/vindosVP/metrics/internal/convert"
+	"github.com/vindosVP/metrics/internal/models"
+	pb "github.com/vindosVP/metrics/internal/proto"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/internal/webhooks"
+)
+
+const (
+	// retryAttempts - attempts to forward the batch to the unavailable node
+	retryAttempts = 3
+	// retryDelay - initial delay between attempts, it's doubled after every attempt
+	retryDelay = 100 * time.Millisecond
+)
+
+// sentinels - errors of other nodes recognised by their text, so they can be checked with errors.Is
+var sentinels = []error{
+	storage.ErrMetricNotRegistered,
+	storage.ErrAmbiguousSeries,
+	storage.ErrReadOnly,
+	storage.ErrTenantLimit,
+	models.ErrTypeConflict,
+	models.ErrBucketsMismatch,
+	models.ErrSketchMismatch,
+	models.ErrInvalidHistogram,
+	models.ErrInvalidSummary,
+	models.ErrInvalidValue,
+}
+
+// peer calls the cluster service of another node on behalf of the tenant of the context,
+// calls are signed with the admin key.
+type peer struct {
+	c    pb.ClusterClient
+	conn *grpc.ClientConn
+	addr string
+	key  string
+}
+
+func newPeer(addr string, key string) (*peer, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client of node %s: %w", addr, err)
+	}
+	return &peer{c: pb.NewClusterClient(conn), conn: conn, addr: addr, key: key}, nil
+}
+
+// available reports whether the connection to the node is ready or gets ready before the context is done.
+func (p *peer) available(ctx context.Context) bool {
+	p.conn.Connect()
+	for {
+		state := p.conn.GetState()
+		if state == connectivity.Ready {
+			return true
+		}
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			return false
+		}
+		if !p.conn.WaitForStateChange(ctx, state) {
+			return false
+		}
+	}
+}
+
+// apply applies the change on the node and returns new values of updated metrics.
+func (p *peer) apply(ctx context.Context, op pb.ChangeOp, metrics []*models.Metrics, batch bool) ([]*models.Metrics, error) {
+	resp, err := p.c.Apply(p.outgoing(ctx), &pb.ApplyRequest{Change: p.change(ctx, op, metrics), Batch: batch})
+	if err != nil {
+		return nil, p.error(err)
+	}
+	return p.metrics(resp.Metrics), nil
+}
+
+// prepare checks the batch can be inserted on the node without inserting it.
+func (p *peer) prepare(ctx context.Context, metrics []*models.Metrics) error {
+	_, err := p.c.Apply(p.outgoing(ctx), &pb.ApplyRequest{Change: p.change(ctx, pb.ChangeOp_UPDATE, metrics), Batch: true, Prepare: true})
+	if err != nil {
+		return p.error(err)
+	}
+	return nil
+}
+
+// insertBatch inserts the batch on the node, the node inserts the batch with the same id once,
+// so calls failed because the node is unavailable are retried.
+func (p *peer) insertBatch(ctx context.Context, id string, metrics []*models.Metrics) error {
+	req := &pb.ApplyRequest{Change: p.change(ctx, pb.ChangeOp_UPDATE, metrics), Batch: true, BatchId: id}
+	err := retry.Do(func() error {
+		_, err := p.c.Apply(p.outgoing(ctx), req)
+		return err
+	},
+		retry.Context(ctx),
+		retry.RetryIf(func(err error) bool {
+			code := status.Code(err)
+			return code == codes.Unavailable || code == codes.Aborted
+		}),
+		retry.Delay(retryDelay),
+		retry.Attempts(retryAttempts),
+		retry.LastErrorOnly(true),
+	)
+	if err != nil {
+		return p.error(err)
+	}
+	return nil
+}
+
+// update updates the single metric on the node and returns its new value.
+func (p *peer) update(ctx context.Context, op pb.ChangeOp, m *models.Metrics) (*models.Metrics, error) {
+	res, err := p.apply(ctx, op, []*models.Metrics{m}, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 1 {
+		return nil, fmt.Errorf("node %s returned %d values of one metric", p.addr, len(res))
+	}
+	return res[0], nil
+}
+
+func (p *peer) registerMetadata(ctx context.Context, meta *models.Metadata) error {
+	change := &pb.Change{Op: pb.ChangeOp_METADATA, Tenant: tenant.FromContext(ctx), Metadata: convert.PBMetadata(meta)}
+	_, err := p.c.Apply(p.outgoing(ctx), &pb.ApplyRequest{Change: change})
+	if err != nil {
+		return p.error(err)
+	}
+	return nil
+}
+
+// values returns values of series of the type stored on the node, all of them if the key is empty.
+func (p *peer) values(ctx context.Context, mType string, key string) ([]*models.Metrics, error) {
+	resp, err := p.c.Values(p.outgoing(ctx), &pb.ValuesRequest{Type: convert.PBType(mType), Id: key})
+	if err != nil {
+		return nil, p.error(err)
+	}
+	return p.metrics(resp.Metrics), nil
+}
+
+// value returns the value of the series stored on the node.
+func (p *peer) value(ctx context.Context, mType string, key string) (*models.Metrics, error) {
+	res, err := p.values(ctx, mType, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 1 {
+		return nil, fmt.Errorf("node %s returned %d values of one series", p.addr, len(res))
+	}
+	return res[0], nil
+}
+
+func (p *peer) updated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	resp, err := p.c.Updated(p.outgoing(ctx), &pb.UpdatedRequest{Type: convert.PBType(mType)})
+	if err != nil {
+		return nil, p.error(err)
+	}
+	res := make(map[string]time.Time, len(resp.Updated))
+	for key, t := range resp.Updated {
+		res[key] = t.AsTime()
+	}
+	return res, nil
+}
+
+func (p *peer) history(ctx context.Context, mType string, key string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	resp, err := p.c.History(p.outgoing(ctx), &pb.GetHistoryRequest{
+		Type: convert.PBType(mType),
+		Id:   key,
+		From: timestamppb.New(from),
+		To:   timestamppb.New(to),
+	})
+	if err != nil {
+		return nil, p.error(err)
+	}
+	res := make([]*models.Sample, 0, len(resp.Samples))
+	for _, s := range resp.Samples {
+		sample := &models.Sample{Timestamp: s.Timestamp.AsTime()}
+		if mType == models.Counter {
+			delta := s.Delta
+			sample.Delta = &delta
+		} else {
+			value := s.Value
+			sample.Value = &value
+		}
+		res = append(res, sample)
+	}
+	return res, nil
+}
+
+// metadata returns metadata of the metric stored on the node, all of it if the name is empty.
+func (p *peer) metadata(ctx context.Context, name string) ([]*models.Metadata, error) {
+	resp, err := p.c.Metadata(p.outgoing(ctx), &pb.MetadataRequest{Id: name})
+	if err != nil {
+		return nil, p.error(err)
+	}
+	res := make([]*models.Metadata, 0, len(resp.Metadata))
+	for _, meta := range resp.Metadata {
+		res = append(res, convert.Metadata(meta))
+	}
+	return res, nil
+}
+
+// putWebhook copies the webhook subscription to the node.
+func (p *peer) putWebhook(ctx context.Context, sub *webhooks.Subscription) error {
+	_, err := p.c.PutWebhook(p.outgoing(ctx), &pb.PutWebhookRequest{Webhook: convert.PBWebhook(sub)})
+	if err != nil {
+		return p.error(err)
+	}
+	return nil
+}
+
+// removeWebhook removes the webhook subscription of the tenant of the context from the node.
+func (p *peer) removeWebhook(ctx context.Context, id string) error {
+	_, err := p.c.RemoveWebhook(p.outgoing(ctx), &pb.RemoveWebhookRequest{Id: id})
+	if err != nil {
+		return p.error(err)
+	}
+	return nil
+}
+
+// webhooks returns webhook subscriptions of all tenants registered on the node.
+func (p *peer) webhooks(ctx context.Context) ([]*webhooks.Subscription, error) {
+	resp, err := pb.NewReplicationClient(p.conn).Webhooks(p.outgoing(ctx), &pb.WebhooksRequest{})
+	if err != nil {
+		return nil, p.error(err)
+	}
+	res := make([]*webhooks.Subscription, 0, len(resp.Webhooks))
+	for _, w := range resp.Webhooks {
+		res = append(res, convert.Webhook(w))
+	}
+	return res, nil
+}
+
+func (p *peer) metrics(metrics []*pb.Metric) []*models.Metrics {
+	res := make([]*models.Metrics, 0, len(metrics))
+	for _, m := range metrics {
+		res = append(res, convert.Metric(m))
+	}
+	return res
+}
+
+func (p *peer) change(ctx context.Context, op pb.ChangeOp, metrics []*models.Metrics) *pb.Change {
+	change := &pb.Change{Op: op, Tenant: tenant.FromContext(ctx), Metrics: make([]*pb.Metric, 0, len(metrics))}
+	for _, m := range metrics {
+		change.Metrics = append(change.Metrics, convert.PBMetric(m))
+	}
+	return change
+}
+
+// outgoing returns the context of the call passing the tenant to the node and signing it.
+func (p *peer) outgoing(ctx context.Context) context.Context {
+	id := tenant.FromContext(ctx)
+	return metadata.AppendToOutgoingContext(ctx, tenant.MetadataKey, id, tenant.HashMetadataKey, tenant.Sign(id, p.key))
+}
+
+// error returns the error of the node wrapping the known error its message contains.
+func (p *peer) error(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("node %s: %w", p.addr, err)
+	}
+	for _, sentinel := range sentinels {
+		if strings.Contains(st.Message(), sentinel.Error()) {
+			return fmt.Errorf("node %s: %w", p.addr, sentinel)
+		}
+	}
+	return fmt.Errorf("node %s: %s", p.addr, st.Message())
+}
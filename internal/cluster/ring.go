@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// virtualNodes - number of points every node has on the ring, more points spread metric names more evenly
+const virtualNodes = 128
+
+// Ring assigns metric names to nodes by consistent hashing,
+// adding or removing a node moves only the names owned by it.
+type Ring struct {
+	owners map[uint32]string
+	points []uint32
+}
+
+// NewRing creates Ring of the nodes, the order of nodes doesn't matter.
+func NewRing(nodes []string) *Ring {
+	r := &Ring{owners: make(map[uint32]string, len(nodes)*virtualNodes)}
+	sorted := append([]string(nil), nodes...)
+	sort.Strings(sorted)
+	for _, node := range sorted {
+		for i := 0; i < virtualNodes; i++ {
+			p := hash(node + "#" + strconv.Itoa(i))
+			if _, ok := r.owners[p]; ok {
+				continue
+			}
+			r.owners[p] = node
+			r.points = append(r.points, p)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Owner method returns the node owning the metric name, it's the node of the first point after the hash of the name.
+func (r *Ring) Owner(name string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash(name)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
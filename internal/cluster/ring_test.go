@@ -0,0 +1,41 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing_Owner(t *testing.T) {
+	nodes := []string{"node-1:9090", "node-2:9090", "node-3:9090"}
+	r := NewRing(nodes)
+	// the order of nodes doesn't matter
+	reordered := NewRing([]string{nodes[2], nodes[0], nodes[1]})
+	assert.Equal(t, "", NewRing(nil).Owner("Alloc"))
+
+	owned := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		name := fmt.Sprintf("metric-%d", i)
+		owner := r.Owner(name)
+		assert.Equal(t, owner, r.Owner(name))
+		assert.Equal(t, owner, reordered.Owner(name))
+		owned[owner]++
+	}
+	for _, node := range nodes {
+		assert.Greater(t, owned[node], 500, node)
+	}
+}
+
+func TestRing_AddNode(t *testing.T) {
+	before := NewRing([]string{"node-1:9090", "node-2:9090"})
+	after := NewRing([]string{"node-1:9090", "node-2:9090", "node-3:9090"})
+
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("metric-%d", i)
+		// names move only to the added node
+		if owner := after.Owner(name); owner != "node-3:9090" {
+			assert.Equal(t, before.Owner(name), owner)
+		}
+	}
+}
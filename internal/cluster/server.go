@@ -0,0 +1,354 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/vindosVP/metrics/internal/convert"
+	"github.com/vindosVP/metrics/internal/models"
+	pb "github.com/vindosVP/metrics/internal/proto"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/internal/webhooks"
+)
+
+// Server serves calls forwarded by other nodes of the cluster from the local storage of the node.
+type Server struct {
+	pb.UnimplementedClusterServer
+	s       MetricsStorage
+	hooks   WebhookRegistry
+	batches *batches
+}
+
+// WebhookRegistry keeps webhook subscriptions copied from other nodes
+type WebhookRegistry interface {
+	Put(sub *webhooks.Subscription) error
+	Delete(tenantID string, id string)
+}
+
+// NewServer creates Server, webhook subscriptions copied from other nodes are kept in the registry.
+func NewServer(s MetricsStorage, hooks WebhookRegistry) *Server {
+	return &Server{s: s, hooks: hooks, batches: newBatches()}
+}
+
+// Apply method applies the change to the local storage.
+// Batches are applied the way InsertBatch and DeleteBatch do it, otherwise new values of updated metrics are returned
+// and deletion of a series which is not registered fails.
+// Prepared batches are only checked, inserted batches with an id are applied once.
+func (s *Server) Apply(ctx context.Context, in *pb.ApplyRequest) (*pb.ApplyResponse, error) {
+	change := in.GetChange()
+	if change == nil {
+		return nil, status.Error(codes.InvalidArgument, "change is missing")
+	}
+	metrics, err := convert.Metrics(change.Op, change.Metrics)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to apply change: %s", err)
+	}
+
+	var resp pb.ApplyResponse
+	switch change.Op {
+	case pb.ChangeOp_UPDATE:
+		if in.Prepare {
+			err = storage.CheckBatch(ctx, s.s, metrics)
+			break
+		}
+		if in.Batch {
+			err = s.insertBatch(ctx, in.BatchId, metrics)
+			break
+		}
+		for _, m := range metrics {
+			var res *models.Metrics
+			res, err = update(ctx, s.s, m)
+			if err != nil {
+				break
+			}
+			resp.Metrics = append(resp.Metrics, convert.PBMetric(res))
+		}
+	case pb.ChangeOp_SET:
+		for _, m := range metrics {
+			var v int64
+			v, err = s.s.SetCounter(ctx, m.ID, *m.Delta)
+			if err != nil {
+				break
+			}
+			resp.Metrics = append(resp.Metrics, convert.PBMetric(&models.Metrics{ID: m.ID, MType: models.Counter, Delta: &v}))
+		}
+	case pb.ChangeOp_DELETE:
+		if in.Batch {
+			err = s.s.DeleteBatch(ctx, metrics)
+			break
+		}
+		for _, m := range metrics {
+			if err = s.s.Delete(ctx, m.MType, m.ID); err != nil {
+				break
+			}
+		}
+	case pb.ChangeOp_METADATA:
+		if change.Metadata == nil {
+			return nil, status.Error(codes.InvalidArgument, "metadata is missing")
+		}
+		err = s.s.RegisterMetadata(ctx, convert.Metadata(change.Metadata))
+	}
+	if err != nil {
+		return nil, status.Errorf(code(err), "failed to apply change: %s", err)
+	}
+	return &resp, nil
+}
+
+// Values method returns values of the series of the type stored locally, all of them if the id is empty.
+func (s *Server) Values(ctx context.Context, in *pb.ValuesRequest) (*pb.ValuesResponse, error) {
+	mType := convert.MType(in.Type)
+	var metrics []*models.Metrics
+	var err error
+	if in.Id != "" {
+		var m *models.Metrics
+		m, err = value(ctx, s.s, mType, in.Id)
+		metrics = []*models.Metrics{m}
+	} else {
+		metrics, err = values(ctx, s.s, mType)
+	}
+	if err != nil {
+		return nil, status.Errorf(code(err), "failed to get values: %s", err)
+	}
+
+	resp := &pb.ValuesResponse{Metrics: make([]*pb.Metric, 0, len(metrics))}
+	for _, m := range metrics {
+		resp.Metrics = append(resp.Metrics, convert.PBMetric(m))
+	}
+	return resp, nil
+}
+
+// Updated method returns update times of the local series of the type.
+func (s *Server) Updated(ctx context.Context, in *pb.UpdatedRequest) (*pb.UpdatedResponse, error) {
+	updated, err := s.s.GetUpdated(ctx, convert.MType(in.Type))
+	if err != nil {
+		return nil, status.Errorf(code(err), "failed to get update times: %s", err)
+	}
+	resp := &pb.UpdatedResponse{Updated: make(map[string]*timestamppb.Timestamp, len(updated))}
+	for key, t := range updated {
+		resp.Updated[key] = timestamppb.New(t)
+	}
+	return resp, nil
+}
+
+// History method returns the local history of the series, its id is the series key.
+func (s *Server) History(ctx context.Context, in *pb.GetHistoryRequest) (*pb.GetHistoryResponse, error) {
+	from := time.Time{}
+	if in.From != nil {
+		from = in.From.AsTime()
+	}
+	to := time.Now()
+	if in.To != nil {
+		to = in.To.AsTime()
+	}
+	samples, err := s.s.GetHistory(ctx, convert.MType(in.Type), in.Id, from, to)
+	if err != nil {
+		return nil, status.Errorf(code(err), "failed to get history: %s", err)
+	}
+	resp := &pb.GetHistoryResponse{Samples: make([]*pb.Sample, 0, len(samples))}
+	for _, v := range samples {
+		sample := &pb.Sample{Timestamp: timestamppb.New(v.Timestamp)}
+		if v.Delta != nil {
+			sample.Delta = *v.Delta
+		}
+		if v.Value != nil {
+			sample.Value = *v.Value
+		}
+		resp.Samples = append(resp.Samples, sample)
+	}
+	return resp, nil
+}
+
+// Metadata method returns the local metadata of the metric, all of it if the id is empty.
+func (s *Server) Metadata(ctx context.Context, in *pb.MetadataRequest) (*pb.MetadataResponse, error) {
+	var resp pb.MetadataResponse
+	if in.Id != "" {
+		meta, err := s.s.GetMetadata(ctx, in.Id)
+		if err != nil {
+			return nil, status.Errorf(code(err), "failed to get metadata: %s", err)
+		}
+		resp.Metadata = append(resp.Metadata, convert.PBMetadata(meta))
+		return &resp, nil
+	}
+	all, err := s.s.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, status.Errorf(code(err), "failed to get metadata: %s", err)
+	}
+	for _, meta := range all {
+		resp.Metadata = append(resp.Metadata, convert.PBMetadata(meta))
+	}
+	return &resp, nil
+}
+
+// PutWebhook method saves the webhook subscription of the tenant of the context registered on another node.
+func (s *Server) PutWebhook(ctx context.Context, in *pb.PutWebhookRequest) (*pb.PutWebhookResponse, error) {
+	if in.Webhook == nil {
+		return nil, status.Error(codes.InvalidArgument, "webhook is missing")
+	}
+	sub := convert.Webhook(in.Webhook)
+	sub.Tenant = tenant.FromContext(ctx)
+	if err := s.hooks.Put(sub); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to put webhook: %s", err)
+	}
+	return &pb.PutWebhookResponse{}, nil
+}
+
+// RemoveWebhook method removes the webhook subscription of the tenant of the context removed on another node.
+func (s *Server) RemoveWebhook(ctx context.Context, in *pb.RemoveWebhookRequest) (*pb.RemoveWebhookResponse, error) {
+	s.hooks.Delete(tenant.FromContext(ctx), in.Id)
+	return &pb.RemoveWebhookResponse{}, nil
+}
+
+// insertBatch inserts the batch unless the batch with the same id is already inserted.
+func (s *Server) insertBatch(ctx context.Context, id string, metrics []*models.Metrics) error {
+	if id == "" {
+		return s.s.InsertBatch(ctx, metrics)
+	}
+	id = tenant.FromContext(ctx) + "/" + id
+	apply, err := s.batches.begin(id)
+	if err != nil || !apply {
+		return err
+	}
+	err = s.s.InsertBatch(ctx, metrics)
+	s.batches.end(id, err == nil)
+	return err
+}
+
+// update applies the update of the single metric and returns its new value.
+func update(ctx context.Context, s MetricsStorage, m *models.Metrics) (*models.Metrics, error) {
+	res := &models.Metrics{ID: m.ID, MType: m.MType}
+	switch m.MType {
+	case models.Counter:
+		v, err := s.UpdateCounter(ctx, m.ID, *m.Delta)
+		if err != nil {
+			return nil, err
+		}
+		res.Delta = &v
+	case models.Gauge:
+		v, err := s.UpdateGauge(ctx, m.ID, *m.Value)
+		if err != nil {
+			return nil, err
+		}
+		res.Value = &v
+	case models.Histogram:
+		if m.Histogram == nil {
+			return nil, models.ErrInvalidHistogram
+		}
+		v, err := s.UpdateHistogram(ctx, m.ID, m.Histogram)
+		if err != nil {
+			return nil, err
+		}
+		res.Histogram = v
+	case models.Summary:
+		if m.Summary == nil {
+			return nil, models.ErrInvalidSummary
+		}
+		v, err := s.UpdateSummary(ctx, m.ID, m.Summary)
+		if err != nil {
+			return nil, err
+		}
+		res.Summary = v
+	}
+	return res, nil
+}
+
+// value returns the value of the series of the type.
+func value(ctx context.Context, s MetricsStorage, mType string, key string) (*models.Metrics, error) {
+	res := &models.Metrics{ID: key, MType: mType}
+	switch mType {
+	case models.Counter:
+		v, err := s.GetCounter(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		res.Delta = &v
+	case models.Gauge:
+		v, err := s.GetGauge(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		res.Value = &v
+	case models.Histogram:
+		v, err := s.GetHistogram(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		res.Histogram = v
+	case models.Summary:
+		v, err := s.GetSummary(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		res.Summary = v
+	}
+	return res, nil
+}
+
+// values returns values of all series of the type.
+func values(ctx context.Context, s MetricsStorage, mType string) ([]*models.Metrics, error) {
+	res := make([]*models.Metrics, 0)
+	switch mType {
+	case models.Counter:
+		all, err := s.GetAllCounter(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for key, v := range all {
+			delta := v
+			res = append(res, &models.Metrics{ID: key, MType: mType, Delta: &delta})
+		}
+	case models.Gauge:
+		all, err := s.GetAllGauge(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for key, v := range all {
+			value := v
+			res = append(res, &models.Metrics{ID: key, MType: mType, Value: &value})
+		}
+	case models.Histogram:
+		all, err := s.GetAllHistogram(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for key, v := range all {
+			res = append(res, &models.Metrics{ID: key, MType: mType, Histogram: v})
+		}
+	case models.Summary:
+		all, err := s.GetAllSummary(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for key, v := range all {
+			res = append(res, &models.Metrics{ID: key, MType: mType, Summary: v})
+		}
+	}
+	return res, nil
+}
+
+// code returns grpc code for the error of the local storage, the message keeps the error text for the calling node.
+func code(err error) codes.Code {
+	switch {
+	case errors.Is(err, storage.ErrMetricNotRegistered):
+		return codes.NotFound
+	case errors.Is(err, storage.ErrReadOnly):
+		return codes.Unavailable
+	case errors.Is(err, storage.ErrTenantLimit):
+		return codes.ResourceExhausted
+	case errors.Is(err, errBatchPending):
+		return codes.Aborted
+	case errors.Is(err, models.ErrTypeConflict):
+		return codes.FailedPrecondition
+	case errors.Is(err, models.ErrBucketsMismatch), errors.Is(err, models.ErrSketchMismatch),
+		errors.Is(err, models.ErrInvalidHistogram), errors.Is(err, models.ErrInvalidSummary),
+		errors.Is(err, models.ErrInvalidValue):
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,538 @@
+// Package cluster shards metrics across servers by consistent hashing of metric names.
+//
+// Every node accepts all calls: writes of metrics owned by other nodes are forwarded to their owners,
+// reads of a metric go to its owner and reads of all metrics fan out to every node and are merged.
+// Reads of all metrics skip nodes which fail to answer in time, so they return metrics of available nodes only.
+// Nodes serve forwarded calls from their local storages with the cluster gRPC service.
+// Webhook subscriptions are copied to every node, so each node delivers updates of metrics it owns.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	pb "github.com/vindosVP/metrics/internal/proto"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/webhooks"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// peerTimeout - time other nodes have to answer reads of all metrics
+const peerTimeout = 5 * time.Second
+
+// MetricsStorage consists methods to save and get data from the storage
+type MetricsStorage interface {
+	UpdateGauge(ctx context.Context, name string, v float64) (float64, error)
+	UpdateCounter(ctx context.Context, name string, v int64) (int64, error)
+	SetCounter(ctx context.Context, name string, v int64) (int64, error)
+	GetGauge(ctx context.Context, name string) (float64, error)
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetCounter(ctx context.Context, name string) (int64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// Storage routes calls to the local storage or to nodes owning the metrics.
+type Storage struct {
+	local MetricsStorage
+	ring  *Ring
+	peers map[string]*peer
+	self  string
+}
+
+// ParsePeers parses the comma separated list of gRPC addresses of cluster nodes.
+func ParsePeers(s string) []string {
+	peers := make([]string, 0)
+	for _, addr := range strings.Split(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			peers = append(peers, addr)
+		}
+	}
+	return peers
+}
+
+// New creates Storage of the node listening on the self address, nodes are addresses of all cluster nodes including it.
+// Calls to other nodes are signed with the admin key.
+func New(self string, nodes []string, local MetricsStorage, key string) (*Storage, error) {
+	s := &Storage{
+		local: local,
+		ring:  NewRing(nodes),
+		peers: make(map[string]*peer, len(nodes)),
+		self:  self,
+	}
+	found := false
+	for _, addr := range nodes {
+		if addr == self {
+			found = true
+			continue
+		}
+		p, err := newPeer(addr, key)
+		if err != nil {
+			return nil, err
+		}
+		s.peers[addr] = p
+	}
+	if !found {
+		return nil, fmt.Errorf("node address %s is not in the cluster peers", self)
+	}
+	return s, nil
+}
+
+// Evaluator method reports whether the node is the first available node of the cluster in the order of addresses,
+// so work done once for the whole cluster, like evaluation of alerting rules, is done by one node only.
+func (s *Storage) Evaluator(ctx context.Context) bool {
+	for _, p := range s.nodes() {
+		if p.addr > s.self {
+			break
+		}
+		pctx, cancel := context.WithTimeout(ctx, peerTimeout)
+		available := p.available(pctx)
+		cancel()
+		if available {
+			return false
+		}
+	}
+	return true
+}
+
+// owner returns the node owning the metric of the series key, nil if it's this node.
+func (s *Storage) owner(key string) *peer {
+	name, _, _ := strings.Cut(key, "{")
+	return s.peers[s.ring.Owner(name)]
+}
+
+// nodes returns other nodes in the order of their addresses.
+func (s *Storage) nodes() []*peer {
+	addrs := make([]string, 0, len(s.peers))
+	for addr := range s.peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	res := make([]*peer, 0, len(addrs))
+	for _, addr := range addrs {
+		res = append(res, s.peers[addr])
+	}
+	return res
+}
+
+// UpdateGauge method updates gauge metric value on its owner.
+func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.UpdateGauge(ctx, name, v)
+	}
+	m, err := p.update(ctx, pb.ChangeOp_UPDATE, &models.Metrics{ID: name, MType: models.Gauge, Value: &v})
+	if err != nil {
+		return 0, err
+	}
+	return *m.Value, nil
+}
+
+// UpdateCounter method updates counter metric value on its owner.
+func (s *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.UpdateCounter(ctx, name, v)
+	}
+	m, err := p.update(ctx, pb.ChangeOp_UPDATE, &models.Metrics{ID: name, MType: models.Counter, Delta: &v})
+	if err != nil {
+		return 0, err
+	}
+	return *m.Delta, nil
+}
+
+// SetCounter method sets counter metric value on its owner.
+func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.SetCounter(ctx, name, v)
+	}
+	m, err := p.update(ctx, pb.ChangeOp_SET, &models.Metrics{ID: name, MType: models.Counter, Delta: &v})
+	if err != nil {
+		return 0, err
+	}
+	return *m.Delta, nil
+}
+
+// UpdateHistogram method merges observations into the histogram on its owner.
+func (s *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.UpdateHistogram(ctx, name, v)
+	}
+	m, err := p.update(ctx, pb.ChangeOp_UPDATE, &models.Metrics{ID: name, MType: models.Histogram, Histogram: v})
+	if err != nil {
+		return nil, err
+	}
+	return m.Histogram, nil
+}
+
+// UpdateSummary method merges the sketch into the summary on its owner.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.UpdateSummary(ctx, name, v)
+	}
+	m, err := p.update(ctx, pb.ChangeOp_UPDATE, &models.Metrics{ID: name, MType: models.Summary, Summary: v})
+	if err != nil {
+		return nil, err
+	}
+	return m.Summary, nil
+}
+
+// InsertBatch method splits the batch by owners of metrics and inserts the parts on them.
+// Every owner checks its part before any part is inserted, so batches which can't be merged with stored values
+// are not applied at all. Parts are forwarded with the id of the batch and owners insert the part once,
+// so forwarding to a temporarily unavailable node is retried without counting counters twice.
+func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	local, remote := s.split(batch)
+	nodes := s.nodes()
+	for _, part := range remote {
+		for i, m := range part {
+			// raw observations are sent as the sketch
+			if m.MType == models.Summary && m.Summary == nil {
+				c := *m
+				c.Summary = m.SummarySketch()
+				part[i] = &c
+			}
+		}
+	}
+
+	if err := storage.CheckBatch(ctx, s.local, local); err != nil {
+		return err
+	}
+	for _, p := range nodes {
+		if part, ok := remote[p]; ok {
+			if err := p.prepare(ctx, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(local) > 0 {
+		if err := s.local.InsertBatch(ctx, local); err != nil {
+			return err
+		}
+	}
+	id := newBatchID()
+	for _, p := range nodes {
+		if part, ok := remote[p]; ok {
+			if err := p.insertBatch(ctx, id, part); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Delete method deletes the series on its owner.
+func (s *Storage) Delete(ctx context.Context, mType string, name string) error {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.Delete(ctx, mType, name)
+	}
+	_, err := p.apply(ctx, pb.ChangeOp_DELETE, []*models.Metrics{{ID: name, MType: mType}}, false)
+	return err
+}
+
+// DeleteBatch method splits the batch by owners of metrics and deletes the parts on them.
+func (s *Storage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	local, remote := s.split(batch)
+	if len(local) > 0 {
+		if err := s.local.DeleteBatch(ctx, local); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.nodes() {
+		if part, ok := remote[p]; ok {
+			if _, err := p.apply(ctx, pb.ChangeOp_DELETE, part, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// split groups metrics of the batch by their owners.
+func (s *Storage) split(batch []*models.Metrics) ([]*models.Metrics, map[*peer][]*models.Metrics) {
+	local := make([]*models.Metrics, 0)
+	remote := make(map[*peer][]*models.Metrics)
+	for _, m := range batch {
+		p := s.owner(m.ID)
+		if p == nil {
+			local = append(local, m)
+			continue
+		}
+		remote[p] = append(remote[p], m)
+	}
+	return local, remote
+}
+
+// RegisterMetadata method saves metadata of the metric on its owner.
+func (s *Storage) RegisterMetadata(ctx context.Context, meta *models.Metadata) error {
+	p := s.owner(meta.ID)
+	if p == nil {
+		return s.local.RegisterMetadata(ctx, meta)
+	}
+	return p.registerMetadata(ctx, meta)
+}
+
+// GetGauge method returns gauge metric value from its owner.
+func (s *Storage) GetGauge(ctx context.Context, name string) (float64, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.GetGauge(ctx, name)
+	}
+	m, err := p.value(ctx, models.Gauge, name)
+	if err != nil {
+		return 0, err
+	}
+	return *m.Value, nil
+}
+
+// GetCounter method returns counter metric value from its owner.
+func (s *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.GetCounter(ctx, name)
+	}
+	m, err := p.value(ctx, models.Counter, name)
+	if err != nil {
+		return 0, err
+	}
+	return *m.Delta, nil
+}
+
+// GetHistogram method returns histogram metric value from its owner.
+func (s *Storage) GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.GetHistogram(ctx, name)
+	}
+	m, err := p.value(ctx, models.Histogram, name)
+	if err != nil {
+		return nil, err
+	}
+	if m.Histogram == nil {
+		return nil, errors.New("node returned no histogram value")
+	}
+	return m.Histogram, nil
+}
+
+// GetSummary method returns summary metric value from its owner.
+func (s *Storage) GetSummary(ctx context.Context, name string) (*models.SummaryValue, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.GetSummary(ctx, name)
+	}
+	m, err := p.value(ctx, models.Summary, name)
+	if err != nil {
+		return nil, err
+	}
+	if m.Summary == nil {
+		return nil, errors.New("node returned no summary value")
+	}
+	return m.Summary, nil
+}
+
+// GetAllGauge method returns values of gauge metrics of all nodes.
+func (s *Storage) GetAllGauge(ctx context.Context) (map[string]float64, error) {
+	return getAll(ctx, s, models.Gauge, s.local.GetAllGauge, func(m *models.Metrics) float64 { return *m.Value })
+}
+
+// GetAllCounter method returns values of counter metrics of all nodes.
+func (s *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
+	return getAll(ctx, s, models.Counter, s.local.GetAllCounter, func(m *models.Metrics) int64 { return *m.Delta })
+}
+
+// GetAllHistogram method returns values of histogram metrics of all nodes.
+func (s *Storage) GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error) {
+	return getAll(ctx, s, models.Histogram, s.local.GetAllHistogram, func(m *models.Metrics) *models.HistogramValue { return m.Histogram })
+}
+
+// GetAllSummary method returns values of summary metrics of all nodes.
+func (s *Storage) GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error) {
+	return getAll(ctx, s, models.Summary, s.local.GetAllSummary, func(m *models.Metrics) *models.SummaryValue { return m.Summary })
+}
+
+// GetHistory method returns history of the series from its owner.
+func (s *Storage) GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.GetHistory(ctx, mType, name, from, to)
+	}
+	return p.history(ctx, mType, name, from, to)
+}
+
+// GetUpdated method returns update times of series of the type of all nodes.
+func (s *Storage) GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	local, err := s.local.GetUpdated(ctx, mType)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]time.Time, len(local))
+	for key, t := range local {
+		res[key] = t
+	}
+	all := fanOut(ctx, s, func(ctx context.Context, p *peer) (map[string]time.Time, error) {
+		return p.updated(ctx, mType)
+	})
+	for _, updated := range all {
+		for key, t := range updated {
+			res[key] = t
+		}
+	}
+	return res, nil
+}
+
+// GetMetadata method returns metadata of the metric from its owner.
+func (s *Storage) GetMetadata(ctx context.Context, name string) (*models.Metadata, error) {
+	p := s.owner(name)
+	if p == nil {
+		return s.local.GetMetadata(ctx, name)
+	}
+	metadata, err := p.metadata(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata) != 1 {
+		return nil, fmt.Errorf("node %s returned %d metadata of one metric", p.addr, len(metadata))
+	}
+	return metadata[0], nil
+}
+
+// GetAllMetadata method returns metadata of all nodes.
+func (s *Storage) GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error) {
+	local, err := s.local.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*models.Metadata, len(local))
+	for name, meta := range local {
+		res[name] = meta
+	}
+	all := fanOut(ctx, s, func(ctx context.Context, p *peer) ([]*models.Metadata, error) {
+		return p.metadata(ctx, "")
+	})
+	for _, metadata := range all {
+		for _, meta := range metadata {
+			res[meta.ID] = meta
+		}
+	}
+	return res, nil
+}
+
+// PutWebhook method copies the webhook subscription to all other nodes, failing if any of them doesn't get it.
+func (s *Storage) PutWebhook(ctx context.Context, sub *webhooks.Subscription) error {
+	for _, p := range s.nodes() {
+		if err := p.putWebhook(ctx, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveWebhook method removes the webhook subscription of the tenant of the context from all other nodes.
+func (s *Storage) RemoveWebhook(ctx context.Context, id string) error {
+	errs := make([]error, 0)
+	for _, p := range s.nodes() {
+		if err := p.removeWebhook(ctx, id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Webhooks method returns webhook subscriptions registered on available nodes,
+// so the node which starts gets subscriptions registered while it was down.
+func (s *Storage) Webhooks(ctx context.Context) []*webhooks.Subscription {
+	res := make([]*webhooks.Subscription, 0)
+	seen := make(map[string]bool)
+	for _, subs := range fanOut(ctx, s, func(ctx context.Context, p *peer) ([]*webhooks.Subscription, error) {
+		return p.webhooks(ctx)
+	}) {
+		for _, sub := range subs {
+			if !seen[sub.ID] {
+				seen[sub.ID] = true
+				res = append(res, sub)
+			}
+		}
+	}
+	return res
+}
+
+// getAll merges values of series of the type stored locally and on other nodes.
+func getAll[V any](ctx context.Context, s *Storage, mType string, local func(ctx context.Context) (map[string]V, error), value func(m *models.Metrics) V) (map[string]V, error) {
+	all, err := local(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]V, len(all))
+	for key, v := range all {
+		res[key] = v
+	}
+	nodes := fanOut(ctx, s, func(ctx context.Context, p *peer) ([]*models.Metrics, error) {
+		return p.values(ctx, mType, "")
+	})
+	for _, metrics := range nodes {
+		for _, m := range metrics {
+			res[m.ID] = value(m)
+		}
+	}
+	return res, nil
+}
+
+// fanOut calls f for every other node concurrently, each call is limited by peerTimeout.
+// Results of nodes which failed are skipped and their failures are logged, so an unavailable node
+// doesn't fail reads of the whole cluster.
+func fanOut[T any](ctx context.Context, s *Storage, f func(ctx context.Context, p *peer) (T, error)) []T {
+	nodes := s.nodes()
+	results := make([]T, len(nodes))
+	failed := make([]bool, len(nodes))
+	var wg sync.WaitGroup
+	for i, p := range nodes {
+		wg.Add(1)
+		go func(i int, p *peer) {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, peerTimeout)
+			defer cancel()
+			res, err := f(pctx, p)
+			if err != nil {
+				logger.Log.Warn("Skipped unavailable node", zap.String("node", p.addr), zap.Error(err))
+				failed[i] = true
+				return
+			}
+			results[i] = res
+		}(i, p)
+	}
+	wg.Wait()
+
+	res := make([]T, 0, len(nodes))
+	for i := range nodes {
+		if !failed[i] {
+			res = append(res, results[i])
+		}
+	}
+	return res
+}
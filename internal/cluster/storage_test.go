@@ -0,0 +1,338 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/vindosVP/metrics/internal/convert"
+	"github.com/vindosVP/metrics/internal/models"
+	pb "github.com/vindosVP/metrics/internal/proto"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/storage/storagetest"
+	"github.com/vindosVP/metrics/internal/storage/tenantstorage"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/internal/webhooks"
+)
+
+type node struct {
+	s     *Storage
+	local *tenantstorage.Storage
+	hooks *webhooks.Registry
+	srv   *grpc.Server
+}
+
+// replicationServer serves webhook subscriptions of the registry like the replication service does.
+type replicationServer struct {
+	pb.UnimplementedReplicationServer
+	hooks *webhooks.Registry
+}
+
+func (s *replicationServer) Webhooks(context.Context, *pb.WebhooksRequest) (*pb.WebhooksResponse, error) {
+	resp := &pb.WebhooksResponse{}
+	for _, sub := range s.hooks.All() {
+		resp.Webhooks = append(resp.Webhooks, convert.PBWebhook(sub))
+	}
+	return resp, nil
+}
+
+// newCluster starts the cluster of n nodes serving the cluster service on local ports.
+func newCluster(t *testing.T, n int) []*node {
+	listeners := make([]net.Listener, 0, n)
+	addrs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		listen, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		listeners = append(listeners, listen)
+		addrs = append(addrs, listen.Addr().String())
+	}
+
+	nodes := make([]*node, 0, n)
+	for i, listen := range listeners {
+		local := tenantstorage.New(func(string) (tenantstorage.MetricsStorage, error) {
+			return memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo(), memstorage.WithHistory(repos.NewHistoryRepo(0))), nil
+		})
+		s, err := New(addrs[i], addrs, local, "")
+		require.NoError(t, err)
+		hooks := webhooks.NewRegistry(webhooks.WithReplicas(s))
+		g := grpc.NewServer(grpc.UnaryInterceptor(tenantInterceptor))
+		pb.RegisterClusterServer(g, NewServer(local, hooks))
+		pb.RegisterReplicationServer(g, &replicationServer{hooks: hooks})
+		go func(listen net.Listener) {
+			_ = g.Serve(listen)
+		}(listen)
+		t.Cleanup(g.Stop)
+		nodes = append(nodes, &node{s: s, local: local, hooks: hooks, srv: g})
+	}
+	return nodes
+}
+
+// tenantInterceptor puts the tenant of the calling node into the context like the gRPC server does.
+func tenantInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id := tenant.Default
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(tenant.MetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	return handler(tenant.WithTenant(ctx, id), req)
+}
+
+// owned returns a metric name owned by the node.
+func owned(t *testing.T, s *Storage, node string, prefix string) string {
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("%s%d", prefix, i)
+		if s.ring.Owner(name) == node {
+			return name
+		}
+	}
+	t.Fatalf("no name owned by %s", node)
+	return ""
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("node-3:9090", []string{"node-1:9090", "node-2:9090"}, nil, "")
+	assert.Error(t, err)
+	s, err := New("node-1:9090", []string{"node-1:9090", "node-2:9090"}, nil, "")
+	require.NoError(t, err)
+	assert.Len(t, s.peers, 1)
+}
+
+func TestParsePeers(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{name: "empty", s: "", want: []string{}},
+		{name: "one", s: "node-1:9090", want: []string{"node-1:9090"}},
+		{name: "spaces", s: " node-1:9090, node-2:9090 ,", want: []string{"node-1:9090", "node-2:9090"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParsePeers(tt.s))
+		})
+	}
+}
+
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.MetricsStorage {
+		return newCluster(t, 2)[0].s
+	})
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	teamA := tenant.WithTenant(ctx, "team-a")
+	nodes := newCluster(t, 2)
+	first, second := nodes[0], nodes[1]
+	remote := owned(t, first.s, second.s.self, "Remote")
+	local := owned(t, first.s, first.s.self, "Local")
+
+	// updates are forwarded to the owner and return its values
+	v, err := first.s.UpdateCounter(ctx, remote, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), v)
+	v, err = second.s.UpdateCounter(ctx, remote, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), v)
+	_, err = second.local.GetCounter(ctx, remote)
+	require.NoError(t, err)
+	_, err = first.local.GetCounter(ctx, remote)
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+
+	_, err = second.s.UpdateGauge(teamA, local, 1.5)
+	require.NoError(t, err)
+	g, err := first.local.GetGauge(teamA, local)
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, g)
+
+	// batches are split by owners
+	delta := int64(1)
+	value := 2.5
+	err = first.s.InsertBatch(ctx, []*models.Metrics{
+		{ID: remote, MType: models.Counter, Delta: &delta},
+		{ID: local, MType: models.Gauge, Value: &value},
+		{ID: remote + "-summary", MType: models.Summary, Observations: []float64{1, 2}},
+	})
+	require.NoError(t, err)
+
+	// reads of all metrics fan out to all nodes
+	for _, n := range nodes {
+		counters, err := n.s.GetAllCounter(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int64{remote: 6}, counters)
+		gauges, err := n.s.GetAllGauge(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]float64{local: 2.5}, gauges)
+		summaries, err := n.s.GetAllSummary(ctx)
+		require.NoError(t, err)
+		require.Contains(t, summaries, remote+"-summary")
+		assert.Equal(t, uint64(2), summaries[remote+"-summary"].Count)
+		updated, err := n.s.GetUpdated(ctx, models.Counter)
+		require.NoError(t, err)
+		assert.Contains(t, updated, remote)
+	}
+	gauges, err := first.s.GetAllGauge(teamA)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{local: 1.5}, gauges)
+
+	history, err := first.s.GetHistory(ctx, models.Counter, remote, time.Time{}, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+
+	require.NoError(t, first.s.RegisterMetadata(ctx, &models.Metadata{ID: remote, MType: models.Counter, Help: "polls"}))
+	meta, err := second.s.GetMetadata(ctx, remote)
+	require.NoError(t, err)
+	assert.Equal(t, "polls", meta.Help)
+	all, err := first.s.GetAllMetadata(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, all, remote)
+
+	// errors of other nodes keep their sentinels
+	_, err = first.s.UpdateHistogram(ctx, remote, &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1, Sum: 0.5})
+	require.NoError(t, err)
+	_, err = first.s.UpdateHistogram(ctx, remote, &models.HistogramValue{Bounds: []float64{2}, Counts: []uint64{1, 0}, Count: 1, Sum: 0.5})
+	assert.ErrorIs(t, err, models.ErrBucketsMismatch)
+	_, err = first.s.GetGauge(ctx, remote)
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	require.NoError(t, first.s.Delete(ctx, models.Counter, remote))
+	_, err = second.s.GetCounter(ctx, remote)
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+}
+
+func TestStorage_unavailableNode(t *testing.T) {
+	ctx := context.Background()
+	nodes := newCluster(t, 3)
+	first, second, third := nodes[0], nodes[1], nodes[2]
+	remote := owned(t, first.s, second.s.self, "Remote")
+	local := owned(t, first.s, first.s.self, "Local")
+	_, err := first.s.UpdateGauge(ctx, remote, 1)
+	require.NoError(t, err)
+	_, err = first.s.UpdateGauge(ctx, local, 2)
+	require.NoError(t, err)
+	require.NoError(t, first.s.RegisterMetadata(ctx, &models.Metadata{ID: local, MType: models.Gauge, Help: "local"}))
+
+	third.srv.Stop()
+	gauges, err := first.s.GetAllGauge(ctx)
+	require.NoError(t, err, "reads of all metrics must not fail because of one node")
+	assert.Equal(t, map[string]float64{remote: 1, local: 2}, gauges)
+	updated, err := first.s.GetUpdated(ctx, models.Gauge)
+	require.NoError(t, err)
+	assert.Len(t, updated, 2)
+	all, err := first.s.GetAllMetadata(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, all, local)
+}
+
+func TestStorage_Evaluator(t *testing.T) {
+	ctx := context.Background()
+	nodes := newCluster(t, 3)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].s.self < nodes[j].s.self })
+
+	evaluators := func() []bool {
+		res := make([]bool, 0, len(nodes))
+		for _, n := range nodes {
+			res = append(res, n.s.Evaluator(ctx))
+		}
+		return res
+	}
+	assert.Equal(t, []bool{true, false, false}, evaluators())
+
+	nodes[0].srv.Stop()
+	assert.Eventually(t, func() bool {
+		return nodes[1].s.Evaluator(ctx)
+	}, 5*time.Second, 50*time.Millisecond, "the next available node must take over")
+	assert.False(t, nodes[2].s.Evaluator(ctx))
+}
+
+func TestStorage_Webhooks(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "team-a")
+	nodes := newCluster(t, 3)
+
+	sub, err := nodes[0].hooks.Add(ctx, &webhooks.Subscription{URL: "http://localhost/hook", Pattern: "gauge/*", Secret: "s"})
+	require.NoError(t, err)
+	for _, n := range nodes {
+		all := n.hooks.All()
+		require.Len(t, all, 1)
+		assert.Equal(t, &webhooks.Subscription{ID: sub.ID, Tenant: "team-a", URL: "http://localhost/hook", Pattern: "gauge/*", Secret: "s"}, all[0])
+	}
+
+	// the node which starts gets subscriptions of other nodes
+	assert.Equal(t, nodes[0].hooks.All(), nodes[2].s.Webhooks(context.Background()))
+
+	require.NoError(t, nodes[1].hooks.Remove(ctx, sub.ID))
+	for _, n := range nodes {
+		assert.Empty(t, n.hooks.All())
+	}
+}
+
+func TestServer_Apply_batch(t *testing.T) {
+	ctx := context.Background()
+	local := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	srv := NewServer(local, webhooks.NewRegistry())
+	delta := int64(2)
+	change := &pb.Change{Op: pb.ChangeOp_UPDATE, Metrics: []*pb.Metric{{Id: "PollCount", Type: pb.MType_COUNTER, Delta: delta}}}
+
+	// prepared batches are not applied
+	_, err := srv.Apply(ctx, &pb.ApplyRequest{Change: change, Batch: true, Prepare: true})
+	require.NoError(t, err)
+	_, err = local.GetCounter(ctx, "PollCount")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+
+	// batches forwarded again are applied once
+	for i := 0; i < 2; i++ {
+		_, err = srv.Apply(ctx, &pb.ApplyRequest{Change: change, Batch: true, BatchId: "batch-1"})
+		require.NoError(t, err)
+	}
+	v, err := local.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, delta, v)
+	_, err = srv.Apply(ctx, &pb.ApplyRequest{Change: change, Batch: true, BatchId: "batch-2"})
+	require.NoError(t, err)
+	v, err = local.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, 2*delta, v)
+}
+
+func TestServer_Apply_invalid(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo()), webhooks.NewRegistry())
+	tests := []struct {
+		change *pb.Change
+		name   string
+	}{
+		{
+			name:   "set of gauge",
+			change: &pb.Change{Op: pb.ChangeOp_SET, Metrics: []*pb.Metric{{Id: "Alloc", Type: pb.MType_GAUGE, Value: 1}}},
+		},
+		{
+			name:   "histogram without value",
+			change: &pb.Change{Op: pb.ChangeOp_UPDATE, Metrics: []*pb.Metric{{Id: "Latency", Type: pb.MType_HISTOGRAM}}},
+		},
+		{
+			name:   "summary without value",
+			change: &pb.Change{Op: pb.ChangeOp_UPDATE, Metrics: []*pb.Metric{{Id: "Latency", Type: pb.MType_SUMMARY}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, batch := range []bool{false, true} {
+				_, err := srv.Apply(ctx, &pb.ApplyRequest{Change: tt.change, Batch: batch})
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			}
+		})
+	}
+}
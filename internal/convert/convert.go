@@ -0,0 +1,213 @@
+// Package convert converts metrics, metadata and webhook subscriptions to and from their protobuf messages.
+package convert
+
+import (
+	"fmt"
+
+	"github.com/vindosVP/metrics/internal/models"
+	pb "github.com/vindosVP/metrics/internal/proto"
+	"github.com/vindosVP/metrics/internal/webhooks"
+)
+
+// PBMetric returns the protobuf metric keyed by the series key of the metric.
+func PBMetric(m *models.Metrics) *pb.Metric {
+	res := &pb.Metric{Id: m.Key(), Type: PBType(m.MType)}
+	if m.Delta != nil {
+		res.Delta = *m.Delta
+	}
+	if m.Value != nil {
+		res.Value = *m.Value
+	}
+	res.Histogram = PBHistogram(m.Histogram)
+	res.Summary = PBSummary(m.Summary)
+	return res
+}
+
+// Metric returns the metric of the protobuf one, only the value of its type is set.
+func Metric(m *pb.Metric) *models.Metrics {
+	res := &models.Metrics{ID: m.Id, MType: MType(m.Type)}
+	switch m.Type {
+	case pb.MType_COUNTER:
+		delta := m.Delta
+		res.Delta = &delta
+	case pb.MType_GAUGE:
+		value := m.Value
+		res.Value = &value
+	case pb.MType_HISTOGRAM:
+		res.Histogram = Histogram(m.Histogram)
+	case pb.MType_SUMMARY:
+		res.Summary = Summary(m.Summary)
+	}
+	return res
+}
+
+// Metrics returns metrics of the change with the operation,
+// failing with models.ErrInvalidValue if a metric lacks the value the operation needs:
+// values of their types for updates and counter values for sets.
+func Metrics(op pb.ChangeOp, metrics []*pb.Metric) ([]*models.Metrics, error) {
+	res := make([]*models.Metrics, 0, len(metrics))
+	for _, pm := range metrics {
+		m := Metric(pm)
+		switch {
+		case op == pb.ChangeOp_SET && m.MType != models.Counter,
+			op == pb.ChangeOp_UPDATE && m.MType == models.Histogram && m.Histogram == nil,
+			op == pb.ChangeOp_UPDATE && m.MType == models.Summary && m.Summary == nil:
+			return nil, fmt.Errorf("%w: %s %s", models.ErrInvalidValue, m.MType, m.ID)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// PBMetadata returns the protobuf metadata.
+func PBMetadata(m *models.Metadata) *pb.Metadata {
+	return &pb.Metadata{
+		Id:   m.ID,
+		Type: PBType(m.MType),
+		Unit: m.Unit,
+		Help: m.Help,
+	}
+}
+
+// Metadata returns the metadata of the protobuf one.
+func Metadata(m *pb.Metadata) *models.Metadata {
+	return &models.Metadata{
+		ID:    m.Id,
+		MType: MType(m.Type),
+		Unit:  m.Unit,
+		Help:  m.Help,
+	}
+}
+
+// MType returns the metric type of the protobuf one.
+func MType(t pb.MType) string {
+	switch t {
+	case pb.MType_COUNTER:
+		return models.Counter
+	case pb.MType_HISTOGRAM:
+		return models.Histogram
+	case pb.MType_SUMMARY:
+		return models.Summary
+	default:
+		return models.Gauge
+	}
+}
+
+// PBType returns the protobuf metric type.
+func PBType(t string) pb.MType {
+	switch t {
+	case models.Counter:
+		return pb.MType_COUNTER
+	case models.Histogram:
+		return pb.MType_HISTOGRAM
+	case models.Summary:
+		return pb.MType_SUMMARY
+	default:
+		return pb.MType_GAUGE
+	}
+}
+
+// PBHistogram returns the protobuf histogram, nil if the histogram is nil.
+func PBHistogram(h *models.HistogramValue) *pb.Histogram {
+	if h == nil {
+		return nil
+	}
+	return &pb.Histogram{
+		Bounds: h.Bounds,
+		Counts: h.Counts,
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+}
+
+// Histogram returns the histogram of the protobuf one, nil if it is nil.
+func Histogram(h *pb.Histogram) *models.HistogramValue {
+	if h == nil {
+		return nil
+	}
+	return &models.HistogramValue{
+		Bounds: h.Bounds,
+		Counts: h.Counts,
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+}
+
+// PBSummary returns the protobuf summary sketch, nil if the sketch is nil.
+func PBSummary(s *models.SummaryValue) *pb.Summary {
+	if s == nil {
+		return nil
+	}
+	return &pb.Summary{
+		Accuracy: s.Accuracy,
+		Positive: s.Positive,
+		Negative: s.Negative,
+		Zero:     s.Zero,
+		Count:    s.Count,
+		Sum:      s.Sum,
+		Min:      s.Min,
+		Max:      s.Max,
+	}
+}
+
+// Summary returns the summary sketch of the protobuf one, nil if it is nil.
+func Summary(s *pb.Summary) *models.SummaryValue {
+	if s == nil {
+		return nil
+	}
+	return &models.SummaryValue{
+		Accuracy: s.Accuracy,
+		Positive: s.Positive,
+		Negative: s.Negative,
+		Zero:     s.Zero,
+		Count:    s.Count,
+		Sum:      s.Sum,
+		Min:      s.Min,
+		Max:      s.Max,
+	}
+}
+
+// PBQuantiles returns the protobuf quantiles.
+func PBQuantiles(quantiles []models.Quantile) []*pb.Quantile {
+	res := make([]*pb.Quantile, 0, len(quantiles))
+	for _, q := range quantiles {
+		res = append(res, &pb.Quantile{Q: q.Q, Value: q.Value})
+	}
+	return res
+}
+
+// MatchType returns the label match type of the protobuf one.
+func MatchType(t pb.MatchType) models.MatchType {
+	switch t {
+	case pb.MatchType_NOT_EQUAL:
+		return models.MatchNotEqual
+	case pb.MatchType_REGEXP:
+		return models.MatchRegexp
+	case pb.MatchType_NOT_REGEXP:
+		return models.MatchNotRegexp
+	default:
+		return models.MatchEqual
+	}
+}
+
+// PBWebhook returns the protobuf webhook subscription with its secret.
+func PBWebhook(sub *webhooks.Subscription) *pb.Webhook {
+	return &pb.Webhook{
+		Id:      sub.ID,
+		Tenant:  sub.Tenant,
+		Url:     sub.URL,
+		Pattern: sub.Pattern,
+		Secret:  sub.Secret,
+	}
+}
+
+// Webhook returns the webhook subscription of the protobuf one.
+func Webhook(w *pb.Webhook) *webhooks.Subscription {
+	return &webhooks.Subscription{
+		ID:      w.Id,
+		Tenant:  w.Tenant,
+		URL:     w.Url,
+		Pattern: w.Pattern,
+		Secret:  w.Secret,
+	}
+}
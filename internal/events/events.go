@@ -0,0 +1,139 @@
+// Package events delivers changes applied to the storage to subscribers in the order they were applied.
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// ErrLagged - represents that the subscriber didn't keep up with published events and was dropped.
+var ErrLagged = errors.New("subscriber lagged behind published events")
+
+// Op - kind of the change applied to the storage.
+type Op int
+
+const (
+	// OpUpdate - metrics were updated, counters by their deltas
+	OpUpdate Op = iota
+	// OpSet - counters were set to their values
+	OpSet
+	// OpDelete - series were deleted
+	OpDelete
+	// OpMetadata - metadata was registered
+	OpMetadata
+)
+
+// String returns the name of the operation.
+func (o Op) String() string {
+	switch o {
+	case OpUpdate:
+		return "update"
+	case OpSet:
+		return "set"
+	case OpDelete:
+		return "delete"
+	case OpMetadata:
+		return "metadata"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is the change applied to the storage of the tenant.
+// Events are shared by all subscribers and must not be modified.
+type Event struct {
+	Time     time.Time
+	Metadata *models.Metadata
+	Tenant   string
+	// Metrics - changed series, their IDs are series keys
+	Metrics []*models.Metrics
+	Seq     uint64
+	Op      Op
+}
+
+// Bus numbers published events and delivers them to subscribers.
+type Bus struct {
+	subs map[*Subscription]struct{}
+	seq  uint64
+	mu   sync.Mutex
+}
+
+// NewBus creates Bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish method assigns the next sequence number to the event and delivers it to all subscribers.
+// Publish never blocks, subscribers with full buffers are dropped with ErrLagged.
+func (b *Bus) Publish(e *Event) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	e.Seq = b.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	for s := range b.subs {
+		select {
+		case s.c <- e:
+		default:
+			s.err = ErrLagged
+			b.drop(s)
+		}
+	}
+	return e.Seq
+}
+
+// Seq method returns the sequence number of the last published event.
+func (b *Bus) Seq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+// Subscribe method returns the subscription to events published after the call,
+// buffer is the number of events kept for the subscriber before it's considered lagging.
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &Subscription{c: make(chan *Event, buffer), bus: b}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+func (b *Bus) drop(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.c)
+}
+
+// Subscription receives published events.
+type Subscription struct {
+	err error
+	c   chan *Event
+	bus *Bus
+}
+
+// Events method returns the channel of events, it's closed when the subscription is closed or dropped.
+func (s *Subscription) Events() <-chan *Event {
+	return s.c
+}
+
+// Err method returns ErrLagged if the subscription was dropped, it's meaningful after the events channel is closed.
+func (s *Subscription) Err() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.err
+}
+
+// Close method stops delivery of events to the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_Publish(t *testing.T) {
+	b := NewBus()
+	first := b.Subscribe(10)
+	defer first.Close()
+
+	assert.Equal(t, uint64(1), b.Publish(&Event{Tenant: "default", Op: OpUpdate}))
+	second := b.Subscribe(10)
+	defer second.Close()
+	assert.Equal(t, uint64(2), b.Publish(&Event{Tenant: "default", Op: OpDelete}))
+	assert.Equal(t, uint64(2), b.Seq())
+
+	e := <-first.Events()
+	assert.Equal(t, uint64(1), e.Seq)
+	assert.False(t, e.Time.IsZero())
+	e = <-first.Events()
+	assert.Equal(t, uint64(2), e.Seq)
+	assert.Equal(t, OpDelete, e.Op)
+
+	e = <-second.Events()
+	assert.Equal(t, uint64(2), e.Seq)
+	assert.Len(t, second.Events(), 0)
+}
+
+func TestBus_Lagged(t *testing.T) {
+	b := NewBus()
+	slow := b.Subscribe(1)
+	fast := b.Subscribe(10)
+	defer fast.Close()
+
+	b.Publish(&Event{})
+	b.Publish(&Event{})
+
+	e, ok := <-slow.Events()
+	require.True(t, ok)
+	assert.Equal(t, uint64(1), e.Seq)
+	_, ok = <-slow.Events()
+	assert.False(t, ok)
+	assert.ErrorIs(t, slow.Err(), ErrLagged)
+	assert.Len(t, fast.Events(), 2)
+	assert.NoError(t, fast.Err())
+
+	// closing the dropped subscription is a no-op
+	slow.Close()
+}
+
+func TestSubscription_Close(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(1)
+	s.Close()
+	s.Close()
+
+	b.Publish(&Event{})
+	_, ok := <-s.Events()
+	assert.False(t, ok)
+	assert.NoError(t, s.Err())
+}
@@ -0,0 +1,263 @@
+// Package exposition renders metrics in the Prometheus text exposition and OpenMetrics formats.
+package exposition
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+const (
+	// TextContentType - content type of the Prometheus text exposition format
+	TextContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// OpenMetricsContentType - content type of the OpenMetrics text format
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+	// totalSuffix - suffix of samples of OpenMetrics counters
+	totalSuffix = "_total"
+)
+
+// Format - exposition format of the response.
+type Format int
+
+const (
+	// FormatText - Prometheus text exposition format
+	FormatText Format = iota
+	// FormatOpenMetrics - OpenMetrics text format
+	FormatOpenMetrics
+)
+
+// ContentType returns the content type of the format.
+func (f Format) ContentType() string {
+	if f == FormatOpenMetrics {
+		return OpenMetricsContentType
+	}
+	return TextContentType
+}
+
+// Negotiate returns the format of the highest quality accepted by the Accept header,
+// the text format is returned if neither is accepted explicitly.
+func Negotiate(accept string) Format {
+	best, bestQ := FormatText, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		switch mediaType {
+		case "application/openmetrics-text":
+			best, bestQ = FormatOpenMetrics, q
+		case "text/plain", "text/*", "*/*":
+			best, bestQ = FormatText, q
+		}
+	}
+	return best
+}
+
+// Sample - value of the series of the family.
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Family - metric with all its series.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []*Sample
+}
+
+// Families groups counters and gauges by metric names sanitized for Prometheus,
+// help texts are taken from the metadata of metrics.
+// Series colliding with already added ones after sanitization are skipped,
+// as well as metrics whose names collide with ones of already added metrics in either format.
+func Families(counters map[string]int64, gauges map[string]float64, metadata map[string]*models.Metadata) []*Family {
+	b := &builder{families: make(map[string]*Family), series: make(map[string]bool), names: make(map[string]string), metadata: metadata}
+	for _, key := range sortedKeys(counters) {
+		b.add(key, typeCounter, float64(counters[key]))
+	}
+	for _, key := range sortedKeys(gauges) {
+		b.add(key, typeGauge, gauges[key])
+	}
+
+	res := make([]*Family, 0, len(b.families))
+	for _, f := range b.families {
+		res = append(res, f)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+type builder struct {
+	families map[string]*Family
+	series   map[string]bool
+	// names maps names families and their samples have in any format to the families
+	names    map[string]string
+	metadata map[string]*models.Metadata
+}
+
+func (b *builder) add(key string, mType string, v float64) {
+	name, labels, err := models.ParseSeriesKey(key)
+	if err != nil {
+		logger.Log.Error("Failed to parse series key", zap.String("key", key), zap.Error(err))
+		return
+	}
+	fName := SanitizeName(name)
+	sanitized := make(map[string]string, len(labels))
+	for k, lv := range labels {
+		sanitized[sanitizeLabelName(k)] = lv
+	}
+
+	f, ok := b.families[fName]
+	if !ok {
+		names := formatNames(fName, mType)
+		for _, n := range names {
+			if owner, found := b.names[n]; found {
+				logger.Log.Warn("Skipped metric colliding with another one", zap.String("key", key), zap.String("name", fName), zap.String("other", owner))
+				return
+			}
+		}
+		for _, n := range names {
+			b.names[n] = fName
+		}
+		f = &Family{Name: fName, Type: mType, Help: mType + " " + name}
+		if meta, found := b.metadata[name]; found && meta.MType == mType && meta.Help != "" {
+			f.Help = meta.Help
+		}
+		b.families[fName] = f
+	}
+	id := fName + formatLabels(sanitized)
+	if f.Type != mType || b.series[id] {
+		logger.Log.Warn("Skipped series colliding with another one", zap.String("key", key), zap.String("name", fName))
+		return
+	}
+	b.series[id] = true
+	f.Samples = append(f.Samples, &Sample{Labels: sanitized, Value: v})
+}
+
+// formatNames returns names of the family and its samples in all formats.
+func formatNames(name string, mType string) []string {
+	family, sample := openMetricsNames(name, mType)
+	if family == name {
+		return []string{name, sample}
+	}
+	return []string{name, family, sample}
+}
+
+// openMetricsNames returns names of the family and its samples in OpenMetrics,
+// where names of counter families have no _total suffix and their samples have it.
+func openMetricsNames(name string, mType string) (string, string) {
+	if mType != typeCounter {
+		return name, name
+	}
+	family := strings.TrimSuffix(name, totalSuffix)
+	return family, family + totalSuffix
+}
+
+// SanitizeName replaces characters not allowed in Prometheus metric names with underscores.
+func SanitizeName(name string) string {
+	return sanitize(name, true)
+}
+
+func sanitizeLabelName(name string) string {
+	return sanitize(name, false)
+}
+
+func sanitize(name string, colons bool) string {
+	if name == "" {
+		return "_"
+	}
+	var sb strings.Builder
+	for i, r := range name {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (colons && r == ':')
+		if i > 0 && r >= '0' && r <= '9' {
+			valid = true
+		}
+		if i == 0 && r >= '0' && r <= '9' {
+			sb.WriteRune('_')
+			valid = true
+		}
+		if valid {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+// Encode writes families in the format.
+func Encode(w io.Writer, format Format, families []*Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		name, sampleName := f.Name, f.Name
+		if format == FormatOpenMetrics {
+			name, sampleName = openMetricsNames(f.Name, f.Type)
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(f.Help, format))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, f.Type)
+		for _, s := range f.Samples {
+			fmt.Fprintf(bw, "%s%s %s\n", sampleName, formatLabels(s.Labels), strconv.FormatFloat(s.Value, 'g', -1, 64))
+		}
+	}
+	if format == FormatOpenMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// formatLabels returns labels sorted by names in braces, or nothing if there are no labels.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, k := range sortedKeys(labels) {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, labelValueEscaper.Replace(labels[k])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelValueEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	textHelpEscaper        = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	openMetricsHelpEscaper = labelValueEscaper
+)
+
+// escapeHelp escapes the help text, double quotes are escaped in OpenMetrics only.
+func escapeHelp(help string, format Format) string {
+	if format == FormatOpenMetrics {
+		return openMetricsHelpEscaper.Replace(help)
+	}
+	return textHelpEscaper.Replace(help)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
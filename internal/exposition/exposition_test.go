@@ -0,0 +1,139 @@
+package exposition
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   Format
+	}{
+		{name: "empty", accept: "", want: FormatText},
+		{name: "text", accept: "text/plain;version=0.0.4", want: FormatText},
+		{name: "openmetrics", accept: "application/openmetrics-text;version=1.0.0", want: FormatOpenMetrics},
+		{
+			name:   "prometheus",
+			accept: "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			want:   FormatOpenMetrics,
+		},
+		{name: "text preferred", accept: "application/openmetrics-text;q=0.5,text/plain", want: FormatText},
+		{name: "unknown", accept: "application/json", want: FormatText},
+		{name: "invalid", accept: "application/openmetrics-text;q=high", want: FormatText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Negotiate(tt.accept))
+		})
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "valid", in: "http:requests_total", want: "http:requests_total"},
+		{name: "dots", in: "Alloc.bytes", want: "Alloc_bytes"},
+		{name: "leading digit", in: "5xx", want: "_5xx"},
+		{name: "unicode", in: "temp°C", want: "temp_C"},
+		{name: "empty", in: "", want: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SanitizeName(tt.in))
+		})
+	}
+	assert.Equal(t, "host_name", sanitizeLabelName("host:name"))
+}
+
+func TestFamilies(t *testing.T) {
+	counters := map[string]int64{
+		"PollCount":    5,
+		"errors_total": 2,
+		"jobs":         4,
+		models.SeriesKey("requests", map[string]string{"code": "200"}): 3,
+		models.SeriesKey("requests", map[string]string{"code": "500"}): 1,
+	}
+	gauges := map[string]float64{
+		"Alloc":     1.5,
+		"PollCount": 2,
+		"poll.rate": 0.5,
+		"poll_rate": 0.7,
+		// OpenMetrics families of counters have no _total suffix and their samples have it
+		"errors":     1,
+		"jobs_total": 3,
+	}
+	metadata := map[string]*models.Metadata{
+		"Alloc":    {ID: "Alloc", MType: models.Gauge, Help: "Allocated bytes"},
+		"requests": {ID: "requests", MType: models.Gauge, Help: "Declared with another type"},
+	}
+
+	families := Families(counters, gauges, metadata)
+	assert.Equal(t, []*Family{
+		{Name: "Alloc", Help: "Allocated bytes", Type: typeGauge, Samples: []*Sample{{Labels: map[string]string{}, Value: 1.5}}},
+		{Name: "PollCount", Help: "counter PollCount", Type: typeCounter, Samples: []*Sample{{Labels: map[string]string{}, Value: 5}}},
+		{Name: "errors_total", Help: "counter errors_total", Type: typeCounter, Samples: []*Sample{{Labels: map[string]string{}, Value: 2}}},
+		{Name: "jobs", Help: "counter jobs", Type: typeCounter, Samples: []*Sample{{Labels: map[string]string{}, Value: 4}}},
+		{Name: "poll_rate", Help: "gauge poll.rate", Type: typeGauge, Samples: []*Sample{{Labels: map[string]string{}, Value: 0.5}}},
+		{Name: "requests", Help: "counter requests", Type: typeCounter, Samples: []*Sample{
+			{Labels: map[string]string{"code": "200"}, Value: 3},
+			{Labels: map[string]string{"code": "500"}, Value: 1},
+		}},
+	}, families)
+}
+
+func TestEncode(t *testing.T) {
+	families := []*Family{
+		{Name: "Alloc", Help: "Allocated \"heap\" bytes\\", Type: typeGauge, Samples: []*Sample{
+			{Value: 1.5},
+			{Labels: map[string]string{"host": "a\"b\nc", "dc": "eu"}, Value: math.Inf(1)},
+		}},
+		{Name: "requests_total", Help: "counter requests", Type: typeCounter, Samples: []*Sample{{Value: 1e6}}},
+	}
+	tests := []struct {
+		name   string
+		want   string
+		format Format
+	}{
+		{
+			name:   "text",
+			format: FormatText,
+			want: "# HELP Alloc Allocated \"heap\" bytes\\\\\n" +
+				"# TYPE Alloc gauge\n" +
+				"Alloc 1.5\n" +
+				"Alloc{dc=\"eu\",host=\"a\\\"b\\nc\"} +Inf\n" +
+				"# HELP requests_total counter requests\n" +
+				"# TYPE requests_total counter\n" +
+				"requests_total 1e+06\n",
+		},
+		{
+			name:   "openmetrics",
+			format: FormatOpenMetrics,
+			want: "# HELP Alloc Allocated \\\"heap\\\" bytes\\\\\n" +
+				"# TYPE Alloc gauge\n" +
+				"Alloc 1.5\n" +
+				"Alloc{dc=\"eu\",host=\"a\\\"b\\nc\"} +Inf\n" +
+				"# HELP requests counter requests\n" +
+				"# TYPE requests counter\n" +
+				"requests_total 1e+06\n" +
+				"# EOF\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Encode(&buf, tt.format, families))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// AlertLister returns active alerts of the tenant of the context.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=AlertLister
+type AlertLister interface {
+	Alerts(ctx context.Context) []*models.Alert
+}
+
+// ListAlerts returns pending and firing alerts of the tenant in json format.
+func ListAlerts(l AlertLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, l.Alerts(req.Context()))
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/handlers/mocks"
+	"github.com/vindosVP/metrics/internal/middleware"
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+func TestListAlerts(t *testing.T) {
+	activeAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		alerts []*models.Alert
+		body   string
+	}{
+		{
+			name:   "no alerts",
+			alerts: []*models.Alert{},
+			body:   `[]`,
+		},
+		{
+			name: "pending alert",
+			alerts: []*models.Alert{
+				{ActiveAt: activeAt, Rule: "HighAlloc", Tenant: "default", Series: "Alloc", State: models.AlertPending, Value: 2},
+			},
+			body: `[{"active_at":"2024-01-01T00:00:00Z","rule":"HighAlloc","tenant":"default","series":"Alloc","state":"pending","value":2}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := mocks.NewAlertLister(t)
+			l.On("Alerts", mock.Anything).Return(tt.alerts)
+			r := chi.NewRouter()
+			r.Use(middleware.Tenant(nil))
+			r.Get("/alerts", ListAlerts(l))
+
+			req := httptest.NewRequest(http.MethodGet, "/alerts", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.body, string(body))
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// Delete removes requested metric.
+// Query parameters are used as labels of the removed series.
+func Delete(s MetricsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		metricType := chi.URLParam(req, "type")
+		if !validBodyType(metricType) {
+			http.Error(w, "invalid type parameter value", http.StatusBadRequest)
+			return
+		}
+		metricName := chi.URLParam(req, "name")
+		if strings.ContainsAny(metricName, "{}") {
+			http.Error(w, "invalid name parameter value", http.StatusBadRequest)
+			return
+		}
+		labels, err := queryLabels(req.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		key := models.SeriesKey(metricName, labels)
+		err = s.Delete(req.Context(), metricType, key)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, storage.ErrMetricNotRegistered) {
+				status = http.StatusNotFound
+			} else {
+				logger.Log.Error("Failed to delete metric", zap.String("name", key), zap.Error(err))
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		logger.Log.Info("Deleted metric", zap.String("name", key), zap.String("type", metricType))
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// DeleteBatch removes all metrics provided in json format, metrics which are not registered are skipped.
+func DeleteBatch(s MetricsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		batch := make([]*models.Metrics, 0)
+		var buf bytes.Buffer
+		_, err := buf.ReadFrom(req.Body)
+		if err != nil {
+			logger.Log.Error("Failed to read request body")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = json.Unmarshal(buf.Bytes(), &batch); err != nil {
+			logger.Log.Error("Failed to unmarshal request body")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for i, metric := range batch {
+			ok, reason, code := validateGet(metric)
+			if !ok {
+				http.Error(w, fmt.Sprintf("bad structure number %d: %s", i, reason), code)
+				return
+			}
+		}
+
+		err = s.DeleteBatch(req.Context(), batch)
+		if err != nil {
+			logger.Log.Error("Failed to delete metrics", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+	}
+}
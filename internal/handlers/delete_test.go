@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+)
+
+func TestDelete(t *testing.T) {
+	type want struct {
+		contentType string
+		code        int
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want want
+	}{
+		{
+			name: "gauge ok",
+			url:  "/value/gauge/Alloc",
+			want: want{
+				code:        http.StatusOK,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "labeled counter ok",
+			url:  "/value/counter/PollCount?host=a",
+			want: want{
+				code:        http.StatusOK,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "not registered",
+			url:  "/value/counter/PollCount",
+			want: want{
+				code:        http.StatusNotFound,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "wrong type",
+			url:  "/value/wrong/Alloc",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+			_, err := s.UpdateGauge(ctx, "Alloc", 1)
+			require.NoError(t, err)
+			_, err = s.UpdateCounter(ctx, `PollCount{host="a"}`, 1)
+			require.NoError(t, err)
+
+			r := chi.NewRouter()
+			r.Delete("/value/{type}/{name}", Delete(s))
+
+			req := httptest.NewRequest(http.MethodDelete, tt.url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.code, res.StatusCode)
+			assert.Equal(t, tt.want.contentType, res.Header.Get("Content-Type"))
+		})
+	}
+}
+
+func TestDeleteBatch(t *testing.T) {
+	type want struct {
+		contentType string
+		code        int
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want want
+	}{
+		{
+			name: "ok",
+			body: "[{\"id\": \"Alloc\",\"type\": \"gauge\"},{\"id\": \"PollCount\",\"type\": \"counter\",\"labels\": {\"host\": \"a\"}},{\"id\": \"Unknown\",\"type\": \"gauge\"}]",
+			want: want{
+				code:        http.StatusOK,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "wrong type",
+			body: "[{\"id\": \"Alloc\",\"type\": \"wrong\"}]",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "wrong body",
+			body: "{\"id\": \"Alloc\",\"type\": \"gauge\"}",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+			_, err := s.UpdateGauge(ctx, "Alloc", 1)
+			require.NoError(t, err)
+			_, err = s.UpdateCounter(ctx, `PollCount{host="a"}`, 1)
+			require.NoError(t, err)
+
+			r := chi.NewRouter()
+			r.Post("/delete", DeleteBatch(s))
+
+			req := httptest.NewRequest(http.MethodPost, "/delete", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.code, res.StatusCode)
+			assert.Equal(t, tt.want.contentType, res.Header.Get("Content-Type"))
+			if tt.want.code == http.StatusOK {
+				all, err := s.GetAllGauge(ctx)
+				require.NoError(t, err)
+				assert.Empty(t, all)
+				_, err = s.GetCounter(ctx, `PollCount{host="a"}`)
+				assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/exposition"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// Exposition returns counters and gauges for Prometheus in the text exposition
+// or OpenMetrics format selected by the Accept header.
+func Exposition(s MetricsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		counters, err := s.GetAllCounter(req.Context())
+		if err != nil {
+			logger.Log.Error("Failed to get counters", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		gauges, err := s.GetAllGauge(req.Context())
+		if err != nil {
+			logger.Log.Error("Failed to get gauges", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		metadata, err := s.GetAllMetadata(req.Context())
+		if err != nil {
+			logger.Log.Error("Failed to get metadata", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		format := exposition.Negotiate(req.Header.Get("Accept"))
+		var buf bytes.Buffer
+		if err = exposition.Encode(&buf, format, exposition.Families(counters, gauges, metadata)); err != nil {
+			logger.Log.Error("Failed to encode metrics", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		_, err = w.Write(buf.Bytes())
+		if err != nil {
+			logger.Log.Error("Failed to write response")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
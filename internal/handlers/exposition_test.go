@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/exposition"
+	"github.com/vindosVP/metrics/internal/handlers/mocks"
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+func TestExposition(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+	tests := []struct {
+		counterErr  error
+		gaugeErr    error
+		name        string
+		accept      string
+		want        string
+		contentType string
+		code        int
+	}{
+		{
+			name:        "text",
+			code:        http.StatusOK,
+			contentType: exposition.TextContentType,
+			want: "# HELP Alloc gauge Alloc\n# TYPE Alloc gauge\nAlloc 1.5\n" +
+				"# HELP PollCount Number of polls\n# TYPE PollCount counter\nPollCount 2\n",
+		},
+		{
+			name:        "openmetrics",
+			accept:      "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5",
+			code:        http.StatusOK,
+			contentType: exposition.OpenMetricsContentType,
+			want: "# HELP Alloc gauge Alloc\n# TYPE Alloc gauge\nAlloc 1.5\n" +
+				"# HELP PollCount Number of polls\n# TYPE PollCount counter\nPollCount_total 2\n# EOF\n",
+		},
+		{
+			name:        "counters error",
+			counterErr:  unexpectedError,
+			code:        http.StatusInternalServerError,
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:        "gauges error",
+			gaugeErr:    unexpectedError,
+			code:        http.StatusInternalServerError,
+			contentType: "text/plain; charset=utf-8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewMetricsStorage(t)
+			s.On("GetAllCounter", mock.Anything).Return(map[string]int64{"PollCount": 2}, tt.counterErr)
+			if tt.counterErr == nil {
+				s.On("GetAllGauge", mock.Anything).Return(map[string]float64{"Alloc": 1.5}, tt.gaugeErr)
+			}
+			if tt.counterErr == nil && tt.gaugeErr == nil {
+				s.On("GetAllMetadata", mock.Anything).Return(map[string]*models.Metadata{
+					"PollCount": {ID: "PollCount", MType: models.Counter, Help: "Number of polls"},
+				}, nil)
+			}
+			r := chi.NewRouter()
+			r.Get("/metrics", Exposition(s))
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+			assert.Equal(t, tt.contentType, res.Header.Get("Content-Type"))
+			if tt.want != "" {
+				body, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, string(body))
+			}
+		})
+	}
+}
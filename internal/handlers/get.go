@@ -13,6 +13,7 @@ import (
 )
 
 // Get returns value of requested metric with text/plain Content-Type.
+// Query parameters are used as label matchers and must select exactly one series.
 func Get(s MetricsStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 
@@ -24,17 +25,17 @@ func Get(s MetricsStorage) http.HandlerFunc {
 
 		metricType := chi.URLParam(req, "type")
 		metricName := chi.URLParam(req, "name")
+		matchers, err := queryMatchers(req.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		switch metricType {
 		case models.Counter:
-			cvalue, err := s.GetCounter(req.Context(), metricName)
+			_, cvalue, err := storage.FindSeries(req.Context(), metricName, matchers, s.GetCounter, s.GetAllCounter)
 			if err != nil {
-
-				status := http.StatusInternalServerError
-				if errors.Is(err, storage.ErrMetricNotRegistered) {
-					status = http.StatusNotFound
-				}
-				http.Error(w, err.Error(), status)
+				http.Error(w, err.Error(), findStatus(err))
 				return
 			}
 			_, err = w.Write([]byte(strconv.FormatInt(cvalue, 10)))
@@ -43,13 +44,9 @@ func Get(s MetricsStorage) http.HandlerFunc {
 				return
 			}
 		case models.Gauge:
-			gvalue, err := s.GetGauge(req.Context(), metricName)
+			_, gvalue, err := storage.FindSeries(req.Context(), metricName, matchers, s.GetGauge, s.GetAllGauge)
 			if err != nil {
-				status := http.StatusInternalServerError
-				if errors.Is(err, storage.ErrMetricNotRegistered) {
-					status = http.StatusNotFound
-				}
-				http.Error(w, err.Error(), status)
+				http.Error(w, err.Error(), findStatus(err))
 				return
 			}
 			_, err = w.Write([]byte(strconv.FormatFloat(gvalue, 'f', -1, 64)))
@@ -63,3 +60,14 @@ func Get(s MetricsStorage) http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+func findStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrMetricNotRegistered):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrAmbiguousSeries):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -3,7 +3,6 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"net/http"
 
 	"go.uber.org/zap"
@@ -42,15 +41,15 @@ func GetBody(s MetricsStorage) http.HandlerFunc {
 			zap.String("type", metrics.MType),
 		}
 		resp := &models.Metrics{}
+		matchers := models.EqualMatchers(metrics.Labels)
 
+		var key string
 		switch metrics.MType {
 		case models.Counter:
-			val, cerr := s.GetCounter(req.Context(), metrics.ID)
+			k, val, cerr := storage.FindSeries(req.Context(), metrics.ID, matchers, s.GetCounter, s.GetAllCounter)
 			if cerr != nil {
-				status = http.StatusInternalServerError
-				if errors.Is(cerr, storage.ErrMetricNotRegistered) {
-					status = http.StatusNotFound
-				} else {
+				status = findStatus(cerr)
+				if status == http.StatusInternalServerError {
 					fields = append(fields, zap.Error(cerr))
 					logger.Log.Error("Failed to get metric value", fields...)
 				}
@@ -58,16 +57,14 @@ func GetBody(s MetricsStorage) http.HandlerFunc {
 				return
 			}
 
-			resp.ID = metrics.ID
+			key = k
 			resp.MType = models.Counter
 			resp.Delta = &val
 		case models.Gauge:
-			val, gerr := s.GetGauge(req.Context(), metrics.ID)
+			k, val, gerr := storage.FindSeries(req.Context(), metrics.ID, matchers, s.GetGauge, s.GetAllGauge)
 			if gerr != nil {
-				status = http.StatusInternalServerError
-				if errors.Is(gerr, storage.ErrMetricNotRegistered) {
-					status = http.StatusNotFound
-				} else {
+				status = findStatus(gerr)
+				if status == http.StatusInternalServerError {
 					fields = append(fields, zap.Error(gerr))
 					logger.Log.Error("Failed to get metric value", fields...)
 				}
@@ -75,9 +72,52 @@ func GetBody(s MetricsStorage) http.HandlerFunc {
 				return
 			}
 
-			resp.ID = metrics.ID
+			key = k
 			resp.MType = models.Gauge
 			resp.Value = &val
+		case models.Histogram:
+			k, val, herr := storage.FindSeries(req.Context(), metrics.ID, matchers, s.GetHistogram, s.GetAllHistogram)
+			if herr != nil {
+				status = findStatus(herr)
+				if status == http.StatusInternalServerError {
+					fields = append(fields, zap.Error(herr))
+					logger.Log.Error("Failed to get metric value", fields...)
+				}
+				http.Error(w, herr.Error(), status)
+				return
+			}
+
+			key = k
+			resp.MType = models.Histogram
+			resp.Histogram = val
+		case models.Summary:
+			k, val, serr := storage.FindSeries(req.Context(), metrics.ID, matchers, s.GetSummary, s.GetAllSummary)
+			if serr != nil {
+				status = findStatus(serr)
+				if status == http.StatusInternalServerError {
+					fields = append(fields, zap.Error(serr))
+					logger.Log.Error("Failed to get metric value", fields...)
+				}
+				http.Error(w, serr.Error(), status)
+				return
+			}
+			quantiles, serr := val.Quantiles(requestedQuantiles(metrics.Quantiles))
+			if serr != nil {
+				http.Error(w, serr.Error(), http.StatusBadRequest)
+				return
+			}
+
+			key = k
+			resp.MType = models.Summary
+			resp.Summary = val
+			resp.Quantiles = quantiles
+		}
+
+		resp.ID, resp.Labels, err = models.ParseSeriesKey(key)
+		if err != nil {
+			logger.Log.Error("Failed to parse series key", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		respData, err := json.Marshal(resp)
@@ -96,3 +136,15 @@ func GetBody(s MetricsStorage) http.HandlerFunc {
 		}
 	}
 }
+
+// requestedQuantiles returns quantiles listed in the request or models.DefaultQuantiles if there are none.
+func requestedQuantiles(quantiles []models.Quantile) []float64 {
+	if len(quantiles) == 0 {
+		return models.DefaultQuantiles
+	}
+	qs := make([]float64, 0, len(quantiles))
+	for _, q := range quantiles {
+		qs = append(qs, q.Q)
+	}
+	return qs
+}
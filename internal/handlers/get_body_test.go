@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"io"
 	"log"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/vindosVP/metrics/cmd/server/config"
 	"github.com/vindosVP/metrics/internal/handlers/mocks"
+	"github.com/vindosVP/metrics/internal/models"
 	"github.com/vindosVP/metrics/internal/repos"
 	"github.com/vindosVP/metrics/internal/storage"
 	"github.com/vindosVP/metrics/internal/storage/memstorage"
@@ -249,9 +251,15 @@ func TestGetBody(t *testing.T) {
 			mockStorage := mocks.NewMetricsStorage(t)
 			if tt.mockGauge.needed {
 				mockStorage.On("GetGauge", mock.Anything, tt.mockGauge.name).Return(tt.mockGauge.value, tt.mockGauge.err)
+				if errors.Is(tt.mockGauge.err, storage.ErrMetricNotRegistered) {
+					mockStorage.On("GetAllGauge", mock.Anything).Return(map[string]float64{}, nil)
+				}
 			}
 			if tt.mockCounter.needed {
 				mockStorage.On("GetCounter", mock.Anything, tt.mockCounter.name).Return(tt.mockCounter.value, tt.mockCounter.err)
+				if errors.Is(tt.mockCounter.err, storage.ErrMetricNotRegistered) {
+					mockStorage.On("GetAllCounter", mock.Anything).Return(map[string]int64{}, nil)
+				}
 			}
 
 			r := chi.NewRouter()
@@ -275,3 +283,64 @@ func TestGetBody(t *testing.T) {
 	}
 
 }
+
+func TestGetBodySummary(t *testing.T) {
+	s := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	r := chi.NewRouter()
+	r.Post("/update", UpdateBody(s))
+	r.Post("/value", GetBody(s))
+
+	for _, body := range []string{
+		"{\"id\":\"Latency\",\"type\":\"summary\",\"observations\":[1,2,3,4,5]}",
+		"{\"id\":\"Latency\",\"type\":\"summary\",\"observations\":[6,7,8,9,10]}",
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+		code int
+	}{
+		{
+			name: "requested quantiles",
+			body: "{\"id\":\"Latency\",\"type\":\"summary\",\"quantiles\":[{\"q\":0},{\"q\":1}]}",
+			want: "[{\"q\":0,\"value\":1},{\"q\":1,\"value\":10}]",
+			code: http.StatusOK,
+		},
+		{
+			name: "invalid quantile",
+			body: "{\"id\":\"Latency\",\"type\":\"summary\",\"quantiles\":[{\"q\":1.5}]}",
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "not registered",
+			body: "{\"id\":\"Unknown\",\"type\":\"summary\"}",
+			code: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/value", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+			if tt.code != http.StatusOK {
+				return
+			}
+			resp := &models.Metrics{}
+			require.NoError(t, json.NewDecoder(res.Body).Decode(resp))
+			assert.Equal(t, uint64(10), resp.Summary.Count)
+			data, err := json.Marshal(resp.Quantiles)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+		})
+	}
+}
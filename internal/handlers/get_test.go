@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/vindosVP/metrics/cmd/server/config"
 	"github.com/vindosVP/metrics/internal/handlers/mocks"
+	"github.com/vindosVP/metrics/internal/models"
 	"github.com/vindosVP/metrics/internal/repos"
 	"github.com/vindosVP/metrics/internal/storage"
 	"github.com/vindosVP/metrics/internal/storage/memstorage"
@@ -248,9 +250,15 @@ func TestGet(t *testing.T) {
 			mockStorage := mocks.NewMetricsStorage(t)
 			if tt.mockGauge.needed {
 				mockStorage.On("GetGauge", mock.Anything, tt.mockGauge.name).Return(tt.mockGauge.value, tt.mockGauge.err)
+				if errors.Is(tt.mockGauge.err, storage.ErrMetricNotRegistered) {
+					mockStorage.On("GetAllGauge", mock.Anything).Return(map[string]float64{}, nil)
+				}
 			}
 			if tt.mockCounter.needed {
 				mockStorage.On("GetCounter", mock.Anything, tt.mockCounter.name).Return(tt.mockCounter.value, tt.mockCounter.err)
+				if errors.Is(tt.mockCounter.err, storage.ErrMetricNotRegistered) {
+					mockStorage.On("GetAllCounter", mock.Anything).Return(map[string]int64{}, nil)
+				}
 			}
 
 			r := chi.NewRouter()
@@ -274,3 +282,76 @@ func TestGet(t *testing.T) {
 	}
 
 }
+
+func TestGetLabels(t *testing.T) {
+	type want struct {
+		body string
+		code int
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want want
+	}{
+		{
+			name: "exact series",
+			url:  "/value/gauge/Alloc?host=a",
+			want: want{code: http.StatusOK, body: "1"},
+		},
+		{
+			name: "partial labels",
+			url:  "/value/gauge/Alloc?service=api",
+			want: want{code: http.StatusOK, body: "3"},
+		},
+		{
+			name: "regexp matcher",
+			url:  "/value/gauge/Alloc?host=~b.*&service=!api",
+			want: want{code: http.StatusOK, body: "2"},
+		},
+		{
+			name: "ambiguous",
+			url:  "/value/gauge/Alloc?host=~.*",
+			want: want{code: http.StatusBadRequest},
+		},
+		{
+			name: "no series",
+			url:  "/value/gauge/Alloc?host=d",
+			want: want{code: http.StatusNotFound},
+		},
+		{
+			name: "invalid regexp",
+			url:  "/value/gauge/Alloc?host=~(",
+			want: want{code: http.StatusBadRequest},
+		},
+	}
+
+	s := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	ctx := context.Background()
+	_, err := s.UpdateGauge(ctx, models.SeriesKey("Alloc", map[string]string{"host": "a"}), 1)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, models.SeriesKey("Alloc", map[string]string{"host": "b", "service": "db"}), 2)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, models.SeriesKey("Alloc", map[string]string{"host": "c", "service": "api"}), 3)
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Get("/value/{type}/{name}", Get(s))
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.code, res.StatusCode)
+			if res.StatusCode == http.StatusOK {
+				data, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want.body, string(data))
+			}
+		})
+	}
+}
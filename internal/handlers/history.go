@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// History returns samples of requested metric collected in the from-to range in json format.
+// from and to query parameters accept RFC3339 time or unix seconds,
+// by default all stored samples up to the current moment are returned.
+// Other query parameters are used as labels of the requested series.
+func History(s MetricsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		ok, reason, code := validate(req, false)
+		if !ok {
+			http.Error(w, reason, code)
+			return
+		}
+
+		metricType := chi.URLParam(req, "type")
+		metricName := chi.URLParam(req, "name")
+
+		q := req.URL.Query()
+		from, err := parseTime(q.Get("from"), time.Time{})
+		if err != nil {
+			http.Error(w, "invalid from parameter value", http.StatusBadRequest)
+			return
+		}
+		to, err := parseTime(q.Get("to"), time.Now())
+		if err != nil {
+			http.Error(w, "invalid to parameter value", http.StatusBadRequest)
+			return
+		}
+		q.Del("from")
+		q.Del("to")
+		labels, err := queryLabels(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		samples, err := s.GetHistory(req.Context(), metricType, models.SeriesKey(metricName, labels), from, to)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, storage.ErrMetricNotRegistered) {
+				status = http.StatusNotFound
+			} else {
+				logger.Log.Error("Failed to get metric history", zap.String("name", metricName), zap.Error(err))
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		respData, err := json.Marshal(samples)
+		if err != nil {
+			logger.Log.Error("Failed to marshal response")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(respData)
+		if err != nil {
+			logger.Log.Error("Failed to write response")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func parseTime(val string, def time.Time) (time.Time, error) {
+	if val == "" {
+		return def, nil
+	}
+	if sec, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, val)
+}
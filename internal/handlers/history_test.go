@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/cmd/server/config"
+	"github.com/vindosVP/metrics/internal/handlers/mocks"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+)
+
+func ExampleHistory() {
+	// create new router
+	r := chi.NewRouter()
+
+	// init server config
+	cfg := config.NewServerConfig()
+
+	// create storage
+	s := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo(), memstorage.WithHistory(repos.NewHistoryRepo(time.Hour)))
+
+	// register handler
+	r.Get("/history/{type}/{name}", History(s))
+
+	// start server
+	log.Fatal(http.ListenAndServe(cfg.RunAddr, r))
+}
+
+func TestHistory(t *testing.T) {
+	type mockHistory struct {
+		err     error
+		samples []*models.Sample
+		from    time.Time
+		needed  bool
+	}
+	type want struct {
+		contentType string
+		samples     int
+		code        int
+	}
+	unexpectedError := errors.New("unexpected error")
+	val := 12.5
+
+	tests := []struct {
+		name        string
+		url         string
+		want        want
+		mockHistory mockHistory
+	}{
+		{
+			name:        "wrong metric type",
+			url:         "/history/test/Alloc",
+			mockHistory: mockHistory{needed: false},
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name:        "wrong from",
+			url:         "/history/gauge/Alloc?from=yesterday",
+			mockHistory: mockHistory{needed: false},
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "not registered",
+			url:  "/history/gauge/Alloc",
+			mockHistory: mockHistory{
+				needed: true,
+				err:    storage.ErrMetricNotRegistered,
+			},
+			want: want{
+				code:        http.StatusNotFound,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "unexpected error",
+			url:  "/history/gauge/Alloc",
+			mockHistory: mockHistory{
+				needed: true,
+				err:    unexpectedError,
+			},
+			want: want{
+				code:        http.StatusInternalServerError,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "ok",
+			url:  "/history/gauge/Alloc?from=1700000000",
+			mockHistory: mockHistory{
+				needed:  true,
+				from:    time.Unix(1700000000, 0),
+				samples: []*models.Sample{{Timestamp: time.Unix(1700000001, 0), Value: &val}},
+			},
+			want: want{
+				code:        http.StatusOK,
+				contentType: "application/json",
+				samples:     1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMetricsStorage(t)
+			if tt.mockHistory.needed {
+				mockStorage.On("GetHistory", mock.Anything, models.Gauge, "Alloc", tt.mockHistory.from, mock.Anything).
+					Return(tt.mockHistory.samples, tt.mockHistory.err)
+			}
+
+			r := chi.NewRouter()
+			r.Get("/history/{type}/{name}", History(mockStorage))
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.code, res.StatusCode)
+			assert.Equal(t, tt.want.contentType, res.Header.Get("Content-Type"))
+			if res.StatusCode == http.StatusOK {
+				data, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				samples := make([]*models.Sample, 0)
+				require.NoError(t, json.Unmarshal(data, &samples))
+				assert.Len(t, samples, tt.want.samples)
+			}
+		})
+	}
+}
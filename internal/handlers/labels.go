@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// queryMatchers converts request query parameters to label matchers.
+// label=value selects series with equal label value, label=!value selects series with other values,
+// label=~regexp and label=!~regexp select series whose label value matches or does not match the expression.
+func queryMatchers(q url.Values) ([]*models.LabelMatcher, error) {
+	matchers := make([]*models.LabelMatcher, 0, len(q))
+	for name, values := range q {
+		if !models.ValidLabelName(name) {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		for _, v := range values {
+			t := models.MatchEqual
+			switch {
+			case strings.HasPrefix(v, "!~"):
+				t, v = models.MatchNotRegexp, v[2:]
+			case strings.HasPrefix(v, "~"):
+				t, v = models.MatchRegexp, v[1:]
+			case strings.HasPrefix(v, "!"):
+				t, v = models.MatchNotEqual, v[1:]
+			}
+			m, err := models.NewLabelMatcher(t, name, v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid matcher for label %q: %w", name, err)
+			}
+			matchers = append(matchers, m)
+		}
+	}
+	return matchers, nil
+}
+
+// queryLabels converts request query parameters to labels.
+func queryLabels(q url.Values) (map[string]string, error) {
+	if len(q) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(q))
+	for name := range q {
+		if !models.ValidLabelName(name) {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		labels[name] = q.Get(name)
+	}
+	return labels, nil
+}
@@ -1,10 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"html"
+	"math"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/watch"
 )
 
 const htmlTemplate = `
@@ -12,26 +20,95 @@ const htmlTemplate = `
 <html lang="en">
 <head>
     <link rel="stylesheet" type="text/css" href="/assets/main.css">
+    <script src="/assets/dashboard.js" defer></script>
     <meta charset="UTF-8">
     <title>Live metrics</title>
 </head>
 <body>
-<table>
+<main>
+<form class="filters">
+    <input id="name-filter" type="search" placeholder="Filter by name">
+    <select id="type-filter">
+        <option value="">All types</option>
+        %types%
+    </select>
+    <span id="status"></span>
+</form>
+<table id="metrics" data-stream="/stream">
     <thead>
     <tr>
-        <th>Name</th>
-        <th>Value</th>
+        <th data-sort="name">Name</th>
+        <th data-sort="type">Type</th>
+        <th data-sort="value">Value</th>
+        <th>Recent</th>
+        <th data-sort="updated">Last updated</th>
     </tr>
     </thead>
     <tbody>
     %metrics%
     </tbody>
 </table>
+</main>
 </body>
 </html>`
 
-// List returns a html-table with all metrics collected.
-func List(s MetricsStorage) http.HandlerFunc {
+// RecentValues returns recent values of series of the tenant of the context charted on sparklines.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=RecentValues
+type RecentValues interface {
+	Recent(ctx context.Context) map[string][]float64
+	Size() int
+}
+
+// row - series shown on the dashboard, the value is formatted and sort is its number to sort and chart by.
+type row struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Value   string    `json:"value"`
+	Updated string    `json:"updated"`
+	Spark   []float64 `json:"spark,omitempty"`
+	Sort    float64   `json:"sort"`
+	Stale   bool      `json:"stale"`
+}
+
+// newRow creates the row of the current value of the series formatted according to the unit of its metric.
+func newRow(m *models.Metrics, metadata map[string]*models.Metadata) *row {
+	r := &row{Name: m.ID, Type: m.MType, Updated: "-", Sort: watch.Scalar(m)}
+	switch {
+	case m.Delta != nil:
+		r.Value = strconv.FormatInt(*m.Delta, 10)
+		if unit := unitOf(metadata, m.ID); unit != "" {
+			r.Value = formatValue(float64(*m.Delta), unit)
+		}
+	case m.Value != nil:
+		r.Value = formatValue(*m.Value, unitOf(metadata, m.ID))
+	case m.Histogram != nil:
+		r.Value = histogramValue(m.Histogram)
+	case m.Summary != nil:
+		r.Value = summaryValue(m.Summary)
+	}
+	return r
+}
+
+// html method renders the row, recent values are charted by the dashboard script.
+func (r *row) html() string {
+	class := ""
+	updated := r.Updated
+	if r.Stale {
+		class = ` class="stale"`
+		updated += " (stale)"
+	}
+	spark := make([]string, 0, len(r.Spark))
+	for _, v := range r.Spark {
+		spark = append(spark, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return fmt.Sprintf(`<tr%s data-name="%s" data-type="%s" data-sort="%s" data-spark="%s"><td>%s</td><td>%s</td><td>%s</td><td class="spark"></td><td>%s</td></tr>`,
+		class, html.EscapeString(r.Name), r.Type, strconv.FormatFloat(r.Sort, 'g', -1, 64), strings.Join(spark, ","),
+		html.EscapeString(r.Name), r.Type, html.EscapeString(r.Value), updated)
+}
+
+// List returns a html-table with all metrics collected, the dashboard script keeps it updated from the stream.
+func List(s MetricsStorage, recent RecentValues) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 
 		counterMetrics, err := s.GetAllCounter(req.Context())
@@ -44,17 +121,58 @@ func List(s MetricsStorage) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		histogramMetrics, err := s.GetAllHistogram(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		summaryMetrics, err := s.GetAllSummary(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-		metricLines := make([]string, 0)
-		counterLines := counterMetricLines(counterMetrics)
-		gaugeLines := gaugeMetricLines(gaugeMetrics)
-		metricLines = append(metricLines, counterLines...)
-		metricLines = append(metricLines, gaugeLines...)
+		fresh, err := newFreshnessByType(req.Context(), s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-		html := strings.Replace(htmlTemplate, "%metrics%", strings.Join(metricLines, ""), -1)
+		metadata, err := s.GetAllMetadata(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		values := make(map[string][]float64)
+		if recent != nil {
+			values = recent.Recent(req.Context())
+		}
+
+		metrics := make([]*models.Metrics, 0)
+		metrics = append(metrics, counterMetricList(counterMetrics)...)
+		metrics = append(metrics, gaugeMetricList(gaugeMetrics)...)
+		metrics = append(metrics, histogramMetricList(histogramMetrics)...)
+		metrics = append(metrics, summaryMetricList(summaryMetrics)...)
+
+		metricLines := make([]string, 0, len(metrics))
+		for _, m := range metrics {
+			r := newRow(m, metadata)
+			r.Updated, r.Stale = fresh[m.MType].status(m.ID)
+			r.Spark = values[m.ID]
+			metricLines = append(metricLines, r.html())
+		}
+
+		typeOptions := make([]string, 0, len(models.Types))
+		for _, t := range models.Types {
+			typeOptions = append(typeOptions, fmt.Sprintf("<option>%s</option>", t))
+		}
+
+		page := strings.Replace(htmlTemplate, "%metrics%", strings.Join(metricLines, ""), -1)
+		page = strings.Replace(page, "%types%", strings.Join(typeOptions, ""), -1)
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		_, err = w.Write([]byte(html))
+		_, err = w.Write([]byte(page))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -63,31 +181,147 @@ func List(s MetricsStorage) http.HandlerFunc {
 	}
 }
 
-func counterMetricLines(metrics map[string]int64) []string {
-	lines := make([]string, 0, len(metrics))
-	keys := make([]string, 0, len(metrics))
-	for k := range metrics {
-		keys = append(keys, k)
+func counterMetricList(metrics map[string]int64) []*models.Metrics {
+	list := make([]*models.Metrics, 0, len(metrics))
+	for _, key := range sortedKeys(metrics) {
+		delta := metrics[key]
+		list = append(list, &models.Metrics{ID: key, MType: models.Counter, Delta: &delta})
 	}
-	sort.Strings(keys)
+	return list
+}
+
+func gaugeMetricList(metrics map[string]float64) []*models.Metrics {
+	list := make([]*models.Metrics, 0, len(metrics))
+	for _, key := range sortedKeys(metrics) {
+		value := metrics[key]
+		list = append(list, &models.Metrics{ID: key, MType: models.Gauge, Value: &value})
+	}
+	return list
+}
 
-	for _, key := range keys {
-		line := fmt.Sprintf("<tr><td>%s</td><td>%d</td></tr>", key, metrics[key])
-		lines = append(lines, line)
+func histogramMetricList(metrics map[string]*models.HistogramValue) []*models.Metrics {
+	list := make([]*models.Metrics, 0, len(metrics))
+	for _, key := range sortedKeys(metrics) {
+		list = append(list, &models.Metrics{ID: key, MType: models.Histogram, Histogram: metrics[key]})
 	}
-	return lines
+	return list
 }
 
-func gaugeMetricLines(metrics map[string]float64) []string {
-	lines := make([]string, 0, len(metrics))
+func summaryMetricList(metrics map[string]*models.SummaryValue) []*models.Metrics {
+	list := make([]*models.Metrics, 0, len(metrics))
+	for _, key := range sortedKeys(metrics) {
+		list = append(list, &models.Metrics{ID: key, MType: models.Summary, Summary: metrics[key]})
+	}
+	return list
+}
+
+func sortedKeys[V any](metrics map[string]V) []string {
 	keys := make([]string, 0, len(metrics))
 	for k := range metrics {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
-	for _, key := range keys {
-		line := fmt.Sprintf("<tr><td>%s</td><td>%.2f</td></tr>", key, metrics[key])
-		lines = append(lines, line)
+	return keys
+}
+
+// histogramValue formats the count, the sum and cumulative bucket counts of the histogram.
+func histogramValue(h *models.HistogramValue) string {
+	cumulative := h.Cumulative()
+	buckets := make([]string, 0, len(cumulative))
+	for i, c := range cumulative {
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = strconv.FormatFloat(h.Bounds[i], 'g', -1, 64)
+		}
+		buckets = append(buckets, fmt.Sprintf("le=%s: %d", le, c))
+	}
+	return fmt.Sprintf("count=%d sum=%.2f (%s)", h.Count, h.Sum, strings.Join(buckets, ", "))
+}
+
+// summaryValue formats the count, the sum and default quantiles of the summary.
+func summaryValue(sm *models.SummaryValue) string {
+	quantiles := make([]string, 0, len(models.DefaultQuantiles))
+	for _, q := range models.DefaultQuantiles {
+		v, _ := sm.Quantile(q)
+		quantiles = append(quantiles, fmt.Sprintf("p%s=%.2f", strconv.FormatFloat(q*100, 'g', -1, 64), v))
+	}
+	return fmt.Sprintf("count=%d sum=%.2f %s", sm.Count, sm.Sum, strings.Join(quantiles, " "))
+}
+
+// byteUnits - binary prefixes of byte values from the smallest to the largest
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+// formatValue formats the metric value according to its unit,
+// byte values are shown with the largest binary prefix keeping the value above 1.
+func formatValue(v float64, unit string) string {
+	switch unit {
+	case "":
+		return fmt.Sprintf("%.2f", v)
+	case models.UnitBytes:
+		i := 0
+		for ; i < len(byteUnits)-1 && math.Abs(v) >= 1024; i++ {
+			v /= 1024
+		}
+		return fmt.Sprintf("%.2f %s", v, byteUnits[i])
+	case models.UnitSeconds:
+		return time.Duration(v * float64(time.Second)).String()
+	case models.UnitPercent:
+		return fmt.Sprintf("%.2f%%", v)
+	default:
+		return fmt.Sprintf("%.2f %s", v, html.EscapeString(unit))
+	}
+}
+
+// unitOf returns the unit declared for the metric of the series.
+func unitOf(metadata map[string]*models.Metadata, key string) string {
+	name, _, err := models.ParseSeriesKey(key)
+	if err != nil {
+		return ""
+	}
+	if meta, ok := metadata[name]; ok {
+		return meta.Unit
+	}
+	return ""
+}
+
+// staleChecker is implemented by storages applying TTL policy.
+type staleChecker interface {
+	Stale(key string, updated time.Time) bool
+}
+
+// freshness consists last update time of metrics of one type and reports stale ones.
+type freshness struct {
+	updated map[string]time.Time
+	checker staleChecker
+}
+
+func newFreshness(ctx context.Context, s MetricsStorage, mType string) (freshness, error) {
+	updated, err := s.GetUpdated(ctx, mType)
+	if err != nil {
+		return freshness{}, err
+	}
+	checker, _ := s.(staleChecker)
+	return freshness{updated: updated, checker: checker}, nil
+}
+
+// newFreshnessByType returns freshness of metrics of every type.
+func newFreshnessByType(ctx context.Context, s MetricsStorage) (map[string]freshness, error) {
+	fresh := make(map[string]freshness, len(models.Types))
+	for _, mType := range models.Types {
+		f, err := newFreshness(ctx, s, mType)
+		if err != nil {
+			return nil, err
+		}
+		fresh[mType] = f
+	}
+	return fresh, nil
+}
+
+// status method returns the formatted last update time of the series and whether it's stale.
+func (f freshness) status(key string) (string, bool) {
+	t, ok := f.updated[key]
+	if !ok {
+		return "-", false
 	}
-	return lines
+	return t.Format(time.RFC3339), f.checker != nil && f.checker.Stale(key, t)
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/vindosVP/metrics/cmd/server/config"
 	"github.com/vindosVP/metrics/internal/handlers/mocks"
+	"github.com/vindosVP/metrics/internal/models"
 	"github.com/vindosVP/metrics/internal/repos"
 	"github.com/vindosVP/metrics/internal/storage/memstorage"
 )
@@ -28,7 +30,7 @@ func ExampleList() {
 	s := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
 
 	// register handler
-	r.Get("/", List(s))
+	r.Get("/", List(s, nil))
 
 	// start server
 	log.Fatal(http.ListenAndServe(cfg.RunAddr, r))
@@ -45,6 +47,24 @@ func TestList(t *testing.T) {
 		fields map[string]int64
 		needed bool
 	}
+	type mockHistogram struct {
+		err    error
+		fields map[string]*models.HistogramValue
+		needed bool
+	}
+	type mockSummary struct {
+		err    error
+		fields map[string]*models.SummaryValue
+		needed bool
+	}
+	type mockUpdated struct {
+		err    error
+		needed bool
+	}
+	type mockMetadata struct {
+		err    error
+		needed bool
+	}
 	type want struct {
 		contentType string
 		code        int
@@ -52,11 +72,15 @@ func TestList(t *testing.T) {
 	unexpectedError := errors.New("unexpected error")
 
 	tests := []struct {
-		name        string
-		mockGauge   mockGauge
-		mockCounter mockCounter
-		method      string
-		want        want
+		name          string
+		mockGauge     mockGauge
+		mockCounter   mockCounter
+		mockHistogram mockHistogram
+		mockSummary   mockSummary
+		mockUpdated   mockUpdated
+		mockMetadata  mockMetadata
+		method        string
+		want          want
 	}{
 		{
 			name: "gauge error",
@@ -94,6 +118,57 @@ func TestList(t *testing.T) {
 				contentType: "text/plain; charset=utf-8",
 			},
 		},
+		{
+			name: "histogram error",
+			mockGauge: mockGauge{
+				needed: true,
+				fields: make(map[string]float64),
+				err:    nil,
+			},
+			mockCounter: mockCounter{
+				needed: true,
+				fields: make(map[string]int64),
+				err:    nil,
+			},
+			mockHistogram: mockHistogram{
+				needed: true,
+				fields: nil,
+				err:    unexpectedError,
+			},
+			method: http.MethodGet,
+			want: want{
+				code:        http.StatusInternalServerError,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "summary error",
+			mockGauge: mockGauge{
+				needed: true,
+				fields: make(map[string]float64),
+				err:    nil,
+			},
+			mockCounter: mockCounter{
+				needed: true,
+				fields: make(map[string]int64),
+				err:    nil,
+			},
+			mockHistogram: mockHistogram{
+				needed: true,
+				fields: make(map[string]*models.HistogramValue),
+				err:    nil,
+			},
+			mockSummary: mockSummary{
+				needed: true,
+				fields: nil,
+				err:    unexpectedError,
+			},
+			method: http.MethodGet,
+			want: want{
+				code:        http.StatusInternalServerError,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
 		{
 			name: "wrong method",
 			mockGauge: mockGauge{
@@ -112,6 +187,74 @@ func TestList(t *testing.T) {
 				contentType: "",
 			},
 		},
+		{
+			name: "updated error",
+			mockGauge: mockGauge{
+				needed: true,
+				fields: make(map[string]float64),
+				err:    nil,
+			},
+			mockCounter: mockCounter{
+				needed: true,
+				fields: make(map[string]int64),
+				err:    nil,
+			},
+			mockHistogram: mockHistogram{
+				needed: true,
+				fields: make(map[string]*models.HistogramValue),
+				err:    nil,
+			},
+			mockSummary: mockSummary{
+				needed: true,
+				fields: make(map[string]*models.SummaryValue),
+				err:    nil,
+			},
+			mockUpdated: mockUpdated{
+				needed: true,
+				err:    unexpectedError,
+			},
+			method: http.MethodGet,
+			want: want{
+				code:        http.StatusInternalServerError,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "metadata error",
+			mockGauge: mockGauge{
+				needed: true,
+				fields: make(map[string]float64),
+				err:    nil,
+			},
+			mockCounter: mockCounter{
+				needed: true,
+				fields: make(map[string]int64),
+				err:    nil,
+			},
+			mockHistogram: mockHistogram{
+				needed: true,
+				fields: make(map[string]*models.HistogramValue),
+				err:    nil,
+			},
+			mockSummary: mockSummary{
+				needed: true,
+				fields: make(map[string]*models.SummaryValue),
+				err:    nil,
+			},
+			mockUpdated: mockUpdated{
+				needed: true,
+				err:    nil,
+			},
+			mockMetadata: mockMetadata{
+				needed: true,
+				err:    unexpectedError,
+			},
+			method: http.MethodGet,
+			want: want{
+				code:        http.StatusInternalServerError,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
 		{
 			name: "ok",
 			mockGauge: mockGauge{
@@ -124,6 +267,24 @@ func TestList(t *testing.T) {
 				fields: make(map[string]int64),
 				err:    nil,
 			},
+			mockHistogram: mockHistogram{
+				needed: true,
+				fields: make(map[string]*models.HistogramValue),
+				err:    nil,
+			},
+			mockSummary: mockSummary{
+				needed: true,
+				fields: make(map[string]*models.SummaryValue),
+				err:    nil,
+			},
+			mockUpdated: mockUpdated{
+				needed: true,
+				err:    nil,
+			},
+			mockMetadata: mockMetadata{
+				needed: true,
+				err:    nil,
+			},
 			method: http.MethodGet,
 			want: want{
 				code:        http.StatusOK,
@@ -141,9 +302,25 @@ func TestList(t *testing.T) {
 			if tt.mockCounter.needed {
 				mockStorage.On("GetAllCounter", mock.Anything).Return(tt.mockCounter.fields, tt.mockCounter.err)
 			}
+			if tt.mockHistogram.needed {
+				mockStorage.On("GetAllHistogram", mock.Anything).Return(tt.mockHistogram.fields, tt.mockHistogram.err)
+			}
+			if tt.mockSummary.needed {
+				mockStorage.On("GetAllSummary", mock.Anything).Return(tt.mockSummary.fields, tt.mockSummary.err)
+			}
+			if tt.mockUpdated.needed {
+				mockStorage.On("GetUpdated", mock.Anything, mock.Anything).Return(make(map[string]time.Time), tt.mockUpdated.err)
+			}
+			if tt.mockMetadata.needed {
+				mockStorage.On("GetAllMetadata", mock.Anything).Return(make(map[string]*models.Metadata), tt.mockMetadata.err)
+			}
 
 			r := chi.NewRouter()
-			r.Get("/", List(mockStorage))
+			recent := mocks.NewRecentValues(t)
+			if tt.want.code == http.StatusOK {
+				recent.On("Recent", mock.Anything).Return(map[string][]float64{})
+			}
+			r.Get("/", List(mockStorage, recent))
 
 			req := httptest.NewRequest(tt.method, "/", nil)
 			w := httptest.NewRecorder()
@@ -157,64 +334,172 @@ func TestList(t *testing.T) {
 	}
 }
 
-func Test_counterMetricLines(t *testing.T) {
+func Test_newRow(t *testing.T) {
+	delta := int64(12)
+	value := 323423452.555
+	mem := float64(5 * 1024 * 1024)
+	latency := models.NewSummaryValue(models.DefaultSummaryAccuracy)
+	for i := 1; i <= 100; i++ {
+		latency.Observe(float64(i))
+	}
+	metadata := map[string]*models.Metadata{
+		"Alloc":  {ID: "Alloc", MType: models.Gauge, Unit: models.UnitBytes},
+		"Uptime": {ID: "Uptime", MType: models.Counter, Unit: models.UnitSeconds},
+	}
 
 	tests := []struct {
-		name    string
-		metrics map[string]int64
-		want    []string
+		metric    *models.Metrics
+		name      string
+		wantValue string
+		wantSort  float64
 	}{
 		{
-			name: "filled",
-			metrics: map[string]int64{
-				"PollCount": 12,
-				"Test":      1,
-			},
-			want: []string{"<tr><td>PollCount</td><td>12</td></tr>", "<tr><td>Test</td><td>1</td></tr>"},
+			name:      "counter",
+			metric:    &models.Metrics{ID: "PollCount", MType: models.Counter, Delta: &delta},
+			wantValue: "12",
+			wantSort:  12,
+		},
+		{
+			name:      "counter with unit",
+			metric:    &models.Metrics{ID: "Uptime", MType: models.Counter, Delta: &delta},
+			wantValue: "12s",
+			wantSort:  12,
+		},
+		{
+			name:      "gauge",
+			metric:    &models.Metrics{ID: "RandomValue", MType: models.Gauge, Value: &value},
+			wantValue: "323423452.56",
+			wantSort:  value,
 		},
 		{
-			name:    "empty",
-			metrics: make(map[string]int64),
-			want:    make([]string, 0),
+			name:      "gauge with unit",
+			metric:    &models.Metrics{ID: `Alloc{host="a"}`, MType: models.Gauge, Value: &mem},
+			wantValue: "5.00 MiB",
+			wantSort:  mem,
+		},
+		{
+			name: "histogram",
+			metric: &models.Metrics{ID: "Latency", MType: models.Histogram,
+				Histogram: &models.HistogramValue{Bounds: []float64{0.5, 1}, Counts: []uint64{1, 2, 3}, Count: 6, Sum: 7.5}},
+			wantValue: "count=6 sum=7.50 (le=0.5: 1, le=1: 3, le=+Inf: 6)",
+			wantSort:  1.25,
+		},
+		{
+			name:      "summary",
+			metric:    &models.Metrics{ID: "Latency", MType: models.Summary, Summary: latency},
+			wantValue: "count=100 sum=5050.00 p50=49.90 p95=94.64 p99=98.50",
+			wantSort:  50.5,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			lines := counterMetricLines(tt.metrics)
-			assert.ElementsMatch(t, lines, tt.want)
+			r := newRow(tt.metric, metadata)
+			assert.Equal(t, tt.metric.ID, r.Name)
+			assert.Equal(t, tt.metric.MType, r.Type)
+			assert.Equal(t, tt.wantValue, r.Value)
+			assert.Equal(t, tt.wantSort, r.Sort)
+			assert.Equal(t, "-", r.Updated)
 		})
 	}
+}
+
+func Test_row_html(t *testing.T) {
+	tests := []struct {
+		row  *row
+		name string
+		want string
+	}{
+		{
+			name: "not updated",
+			row:  &row{Name: "PollCount", Type: models.Counter, Value: "12", Updated: "-", Sort: 12},
+			want: `<tr data-name="PollCount" data-type="counter" data-sort="12" data-spark=""><td>PollCount</td><td>counter</td><td>12</td><td class="spark"></td><td>-</td></tr>`,
+		},
+		{
+			name: "recent values",
+			row:  &row{Name: `Alloc{host="a"}`, Type: models.Gauge, Value: "1.50", Updated: "2024-03-01T12:00:00Z", Sort: 1.5, Spark: []float64{1, 0.5, 1.5}},
+			want: `<tr data-name="Alloc{host=&#34;a&#34;}" data-type="gauge" data-sort="1.5" data-spark="1,0.5,1.5"><td>Alloc{host=&#34;a&#34;}</td><td>gauge</td><td>1.50</td><td class="spark"></td><td>2024-03-01T12:00:00Z</td></tr>`,
+		},
+		{
+			name: "stale",
+			row:  &row{Name: "Alloc", Type: models.Gauge, Value: "1.50", Updated: "2024-03-01T11:00:00Z", Sort: 1.5, Stale: true},
+			want: `<tr class="stale" data-name="Alloc" data-type="gauge" data-sort="1.5" data-spark=""><td>Alloc</td><td>gauge</td><td>1.50</td><td class="spark"></td><td>2024-03-01T11:00:00Z (stale)</td></tr>`,
+		},
+	}
 
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.row.html())
+		})
+	}
+}
+
+type staleAfter time.Time
+
+func (a staleAfter) Stale(_ string, updated time.Time) bool {
+	return updated.Before(time.Time(a))
 }
 
-func Test_gaugeMetricLines(t *testing.T) {
+func Test_freshness_status(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-time.Hour)
 
 	tests := []struct {
-		name    string
-		metrics map[string]float64
-		want    []string
+		name        string
+		fresh       freshness
+		wantUpdated string
+		wantStale   bool
 	}{
 		{
-			name: "filled",
-			metrics: map[string]float64{
-				"Alloc": 323423452.555,
-				"Test":  1,
-			},
-			want: []string{"<tr><td>Alloc</td><td>323423452.56</td></tr>", "<tr><td>Test</td><td>1.00</td></tr>"},
+			name:        "unknown",
+			fresh:       freshness{},
+			wantUpdated: "-",
 		},
 		{
-			name:    "empty",
-			metrics: make(map[string]float64),
-			want:    make([]string, 0),
+			name:        "fresh",
+			fresh:       freshness{updated: map[string]time.Time{"Alloc": now}, checker: staleAfter(old)},
+			wantUpdated: "2024-03-01T12:00:00Z",
+		},
+		{
+			name:        "stale",
+			fresh:       freshness{updated: map[string]time.Time{"Alloc": old}, checker: staleAfter(now)},
+			wantUpdated: "2024-03-01T11:00:00Z",
+			wantStale:   true,
+		},
+		{
+			name:        "without checker",
+			fresh:       freshness{updated: map[string]time.Time{"Alloc": old}},
+			wantUpdated: "2024-03-01T11:00:00Z",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			lines := gaugeMetricLines(tt.metrics)
-			assert.ElementsMatch(t, lines, tt.want)
+			updated, stale := tt.fresh.status("Alloc")
+			assert.Equal(t, tt.wantUpdated, updated)
+			assert.Equal(t, tt.wantStale, stale)
 		})
 	}
+}
+
+func Test_formatValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value float64
+		unit  string
+		want  string
+	}{
+		{name: "no unit", value: 1.5, unit: "", want: "1.50"},
+		{name: "bytes", value: 512, unit: models.UnitBytes, want: "512.00 B"},
+		{name: "mebibytes", value: 3 * 1024 * 1024, unit: models.UnitBytes, want: "3.00 MiB"},
+		{name: "seconds", value: 90, unit: models.UnitSeconds, want: "1m30s"},
+		{name: "percent", value: 12.345, unit: models.UnitPercent, want: "12.35%"},
+		{name: "custom", value: 2, unit: "requests", want: "2.00 requests"},
+	}
 
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatValue(tt.value, tt.unit))
+		})
+	}
 }
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// RegisterMetadata saves provided in json format metric metadata.
+// Updates of the metric with a type different from the declared one are rejected afterwards.
+func RegisterMetadata(s MetricsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		meta := &models.Metadata{}
+		var buf bytes.Buffer
+		_, err := buf.ReadFrom(req.Body)
+		if err != nil {
+			logger.Log.Error("Failed to read request body")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = json.Unmarshal(buf.Bytes(), meta); err != nil {
+			logger.Log.Error("Failed to unmarshal request body")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = meta.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = s.RegisterMetadata(req.Context(), meta)
+		if err != nil {
+			logger.Log.Error("Failed to register metric metadata", zap.String("name", meta.ID), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger.Log.Info("Registered metric metadata", zap.String("name", meta.ID), zap.String("type", meta.MType))
+
+		writeJSON(w, meta)
+	}
+}
+
+// GetMetadata returns metadata of requested metric in json format.
+func GetMetadata(s MetricsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		name := chi.URLParam(req, "name")
+		meta, err := s.GetMetadata(req.Context(), name)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, storage.ErrMetricNotRegistered) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		writeJSON(w, meta)
+	}
+}
+
+// ListMetadata returns metadata of all registered metrics in json format.
+func ListMetadata(s MetricsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		metadata, err := s.GetAllMetadata(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		list := make([]*models.Metadata, 0, len(metadata))
+		for _, meta := range metadata {
+			list = append(list, meta)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+		writeJSON(w, list)
+	}
+}
+
+// checkType returns models.ErrTypeConflict if the metric is declared with another type.
+func checkType(ctx context.Context, s MetricsStorage, name string, mType string) error {
+	meta, err := s.GetMetadata(ctx, name)
+	if errors.Is(err, storage.ErrMetricNotRegistered) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return meta.CheckType(mType)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	respData, err := json.Marshal(v)
+	if err != nil {
+		logger.Log.Error("Failed to marshal response")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(respData)
+	if err != nil {
+		logger.Log.Error("Failed to write response")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+)
+
+func TestRegisterMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{
+			name: "ok",
+			body: `{"id":"Alloc","type":"gauge","unit":"bytes","help":"Allocated heap objects"}`,
+			code: http.StatusOK,
+		},
+		{
+			name: "invalid type",
+			body: `{"id":"Alloc","type":"wrong"}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "invalid id",
+			body: `{"id":"Alloc{host=\"a\"}","type":"gauge"}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "invalid json",
+			body: `{"id":`,
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+			r := chi.NewRouter()
+			r.Post("/metadata/", RegisterMetadata(s))
+
+			req := httptest.NewRequest(http.MethodPost, "/metadata/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+			if tt.code != http.StatusOK {
+				return
+			}
+			meta, err := s.GetMetadata(context.Background(), "Alloc")
+			require.NoError(t, err)
+			assert.Equal(t, &models.Metadata{ID: "Alloc", MType: models.Gauge, Unit: "bytes", Help: "Allocated heap objects"}, meta)
+		})
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	ctx := context.Background()
+	s := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	require.NoError(t, s.RegisterMetadata(ctx, &models.Metadata{ID: "PollCount", MType: models.Counter}))
+	require.NoError(t, s.RegisterMetadata(ctx, &models.Metadata{ID: "Alloc", MType: models.Gauge, Unit: models.UnitBytes}))
+
+	r := chi.NewRouter()
+	r.Get("/metadata/", ListMetadata(s))
+	r.Get("/metadata/{name}", GetMetadata(s))
+
+	tests := []struct {
+		name string
+		url  string
+		code int
+		want string
+	}{
+		{
+			name: "get",
+			url:  "/metadata/Alloc",
+			code: http.StatusOK,
+			want: `{"id":"Alloc","type":"gauge","unit":"bytes"}`,
+		},
+		{
+			name: "not registered",
+			url:  "/metadata/Other",
+			code: http.StatusNotFound,
+		},
+		{
+			name: "list",
+			url:  "/metadata/",
+			code: http.StatusOK,
+			want: `[{"id":"Alloc","type":"gauge","unit":"bytes"},{"id":"PollCount","type":"counter"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+			if tt.want == "" {
+				return
+			}
+			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(body))
+		})
+	}
+}
+
+func TestUpdateTypeConflict(t *testing.T) {
+	ctx := context.Background()
+	s := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	require.NoError(t, s.RegisterMetadata(ctx, &models.Metadata{ID: "Alloc", MType: models.Gauge}))
+
+	r := chi.NewRouter()
+	r.Post("/update/{type}/{name}/{value}", Update(s))
+	r.Post("/update/", UpdateBody(s))
+	r.Post("/updates/", UpdateBatch(s))
+
+	delta := int64(1)
+	batch, err := json.Marshal([]*models.Metrics{{ID: "Alloc", MType: models.Counter, Delta: &delta}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		url  string
+		body string
+		code int
+	}{
+		{
+			name: "url conflict",
+			url:  "/update/counter/Alloc/1",
+			code: http.StatusConflict,
+		},
+		{
+			name: "url declared type",
+			url:  "/update/gauge/Alloc/1",
+			code: http.StatusOK,
+		},
+		{
+			name: "body conflict",
+			url:  "/update/",
+			body: `{"id":"Alloc","type":"counter","delta":1}`,
+			code: http.StatusConflict,
+		},
+		{
+			name: "body not declared",
+			url:  "/update/",
+			body: `{"id":"PollCount","type":"counter","delta":1}`,
+			code: http.StatusOK,
+		},
+		{
+			name: "batch conflict",
+			url:  "/updates/",
+			body: string(batch),
+			code: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+		})
+	}
+
+	counters, err := s.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 1}, counters)
+}
@@ -0,0 +1,47 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "github.com/vindosVP/metrics/internal/models"
+)
+
+// AlertLister is an autogenerated mock type for the AlertLister type
+type AlertLister struct {
+	mock.Mock
+}
+
+// Alerts provides a mock function with given fields: ctx
+func (_m *AlertLister) Alerts(ctx context.Context) []*models.Alert {
+	ret := _m.Called(ctx)
+
+	var r0 []*models.Alert
+	if rf, ok := ret.Get(0).(func(context.Context) []*models.Alert); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Alert)
+		}
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewAlertLister interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewAlertLister creates a new instance of AlertLister. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewAlertLister(t mockConstructorTestingTNewAlertLister) *AlertLister {
+	mock := &AlertLister{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -8,6 +8,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	models "github.com/vindosVP/metrics/internal/models"
+
+	time "time"
 )
 
 // MetricsStorage is an autogenerated mock type for the MetricsStorage type
@@ -15,6 +17,34 @@ type MetricsStorage struct {
 	mock.Mock
 }
 
+// Delete provides a mock function with given fields: ctx, mType, name
+func (_m *MetricsStorage) Delete(ctx context.Context, mType string, name string) error {
+	ret := _m.Called(ctx, mType, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, mType, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteBatch provides a mock function with given fields: ctx, batch
+func (_m *MetricsStorage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	ret := _m.Called(ctx, batch)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*models.Metrics) error); ok {
+		r0 = rf(ctx, batch)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetAllCounter provides a mock function with given fields: ctx
 func (_m *MetricsStorage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
 	ret := _m.Called(ctx)
@@ -67,6 +97,84 @@ func (_m *MetricsStorage) GetAllGauge(ctx context.Context) (map[string]float64,
 	return r0, r1
 }
 
+// GetAllHistogram provides a mock function with given fields: ctx
+func (_m *MetricsStorage) GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]*models.HistogramValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (map[string]*models.HistogramValue, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]*models.HistogramValue); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*models.HistogramValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAllMetadata provides a mock function with given fields: ctx
+func (_m *MetricsStorage) GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]*models.Metadata
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (map[string]*models.Metadata, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]*models.Metadata); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*models.Metadata)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAllSummary provides a mock function with given fields: ctx
+func (_m *MetricsStorage) GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]*models.SummaryValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (map[string]*models.SummaryValue, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]*models.SummaryValue); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*models.SummaryValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetCounter provides a mock function with given fields: ctx, name
 func (_m *MetricsStorage) GetCounter(ctx context.Context, name string) (int64, error) {
 	ret := _m.Called(ctx, name)
@@ -115,6 +223,136 @@ func (_m *MetricsStorage) GetGauge(ctx context.Context, name string) (float64, e
 	return r0, r1
 }
 
+// GetHistogram provides a mock function with given fields: ctx, name
+func (_m *MetricsStorage) GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *models.HistogramValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.HistogramValue, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.HistogramValue); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.HistogramValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetHistory provides a mock function with given fields: ctx, mType, name, from, to
+func (_m *MetricsStorage) GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	ret := _m.Called(ctx, mType, name, from, to)
+
+	var r0 []*models.Sample
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time) ([]*models.Sample, error)); ok {
+		return rf(ctx, mType, name, from, to)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time) []*models.Sample); ok {
+		r0 = rf(ctx, mType, name, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Sample)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, mType, name, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMetadata provides a mock function with given fields: ctx, name
+func (_m *MetricsStorage) GetMetadata(ctx context.Context, name string) (*models.Metadata, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *models.Metadata
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.Metadata, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Metadata); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Metadata)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSummary provides a mock function with given fields: ctx, name
+func (_m *MetricsStorage) GetSummary(ctx context.Context, name string) (*models.SummaryValue, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *models.SummaryValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.SummaryValue, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.SummaryValue); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.SummaryValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUpdated provides a mock function with given fields: ctx, mType
+func (_m *MetricsStorage) GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	ret := _m.Called(ctx, mType)
+
+	var r0 map[string]time.Time
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (map[string]time.Time, error)); ok {
+		return rf(ctx, mType)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) map[string]time.Time); ok {
+		r0 = rf(ctx, mType)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]time.Time)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, mType)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // InsertBatch provides a mock function with given fields: ctx, batch
 func (_m *MetricsStorage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
 	ret := _m.Called(ctx, batch)
@@ -129,6 +367,20 @@ func (_m *MetricsStorage) InsertBatch(ctx context.Context, batch []*models.Metri
 	return r0
 }
 
+// RegisterMetadata provides a mock function with given fields: ctx, meta
+func (_m *MetricsStorage) RegisterMetadata(ctx context.Context, meta *models.Metadata) error {
+	ret := _m.Called(ctx, meta)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Metadata) error); ok {
+		r0 = rf(ctx, meta)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetCounter provides a mock function with given fields: ctx, name, v
 func (_m *MetricsStorage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
 	ret := _m.Called(ctx, name, v)
@@ -201,6 +453,58 @@ func (_m *MetricsStorage) UpdateGauge(ctx context.Context, name string, v float6
 	return r0, r1
 }
 
+// UpdateHistogram provides a mock function with given fields: ctx, name, v
+func (_m *MetricsStorage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	ret := _m.Called(ctx, name, v)
+
+	var r0 *models.HistogramValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.HistogramValue) (*models.HistogramValue, error)); ok {
+		return rf(ctx, name, v)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.HistogramValue) *models.HistogramValue); ok {
+		r0 = rf(ctx, name, v)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.HistogramValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, *models.HistogramValue) error); ok {
+		r1 = rf(ctx, name, v)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateSummary provides a mock function with given fields: ctx, name, v
+func (_m *MetricsStorage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	ret := _m.Called(ctx, name, v)
+
+	var r0 *models.SummaryValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.SummaryValue) (*models.SummaryValue, error)); ok {
+		return rf(ctx, name, v)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.SummaryValue) *models.SummaryValue); ok {
+		r0 = rf(ctx, name, v)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.SummaryValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, *models.SummaryValue) error); ok {
+		r1 = rf(ctx, name, v)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewMetricsStorage interface {
 	mock.TestingT
 	Cleanup(func())
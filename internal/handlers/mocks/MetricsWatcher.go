@@ -0,0 +1,45 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	watch "github.com/vindosVP/metrics/internal/watch"
+)
+
+// MetricsWatcher is an autogenerated mock type for the MetricsWatcher type
+type MetricsWatcher struct {
+	mock.Mock
+}
+
+// Watch provides a mock function with given fields: ctx, f, send
+func (_m *MetricsWatcher) Watch(ctx context.Context, f *watch.Filter, send func(*watch.Update) error) error {
+	ret := _m.Called(ctx, f, send)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *watch.Filter, func(*watch.Update) error) error); ok {
+		r0 = rf(ctx, f, send)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewMetricsWatcher interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewMetricsWatcher creates a new instance of MetricsWatcher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewMetricsWatcher(t mockConstructorTestingTNewMetricsWatcher) *MetricsWatcher {
+	mock := &MetricsWatcher{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
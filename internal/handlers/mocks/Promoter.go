@@ -0,0 +1,39 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// Promoter is an autogenerated mock type for the Promoter type
+type Promoter struct {
+	mock.Mock
+}
+
+// Promote provides a mock function with given fields:
+func (_m *Promoter) Promote() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewPromoter interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewPromoter creates a new instance of Promoter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewPromoter(t mockConstructorTestingTNewPromoter) *Promoter {
+	mock := &Promoter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
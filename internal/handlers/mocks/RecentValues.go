@@ -0,0 +1,59 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// RecentValues is an autogenerated mock type for the RecentValues type
+type RecentValues struct {
+	mock.Mock
+}
+
+// Recent provides a mock function with given fields: ctx
+func (_m *RecentValues) Recent(ctx context.Context) map[string][]float64 {
+	ret := _m.Called(ctx)
+
+	var r0 map[string][]float64
+	if rf, ok := ret.Get(0).(func(context.Context) map[string][]float64); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]float64)
+		}
+	}
+
+	return r0
+}
+
+// Size provides a mock function with given fields:
+func (_m *RecentValues) Size() int {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewRecentValues interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewRecentValues creates a new instance of RecentValues. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewRecentValues(t mockConstructorTestingTNewRecentValues) *RecentValues {
+	mock := &RecentValues{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
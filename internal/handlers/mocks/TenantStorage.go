@@ -0,0 +1,57 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "github.com/vindosVP/metrics/internal/models"
+)
+
+// TenantStorage is an autogenerated mock type for the TenantStorage type
+type TenantStorage struct {
+	mock.Mock
+}
+
+// Stats provides a mock function with given fields: ctx
+func (_m *TenantStorage) Stats(ctx context.Context) ([]*models.TenantStats, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*models.TenantStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]*models.TenantStats, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []*models.TenantStats); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.TenantStats)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewTenantStorage interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewTenantStorage creates a new instance of TenantStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewTenantStorage(t mockConstructorTestingTNewTenantStorage) *TenantStorage {
+	mock := &TenantStorage{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,87 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	webhooks "github.com/vindosVP/metrics/internal/webhooks"
+)
+
+// WebhookRegistry is an autogenerated mock type for the WebhookRegistry type
+type WebhookRegistry struct {
+	mock.Mock
+}
+
+// Add provides a mock function with given fields: ctx, sub
+func (_m *WebhookRegistry) Add(ctx context.Context, sub *webhooks.Subscription) (*webhooks.Subscription, error) {
+	ret := _m.Called(ctx, sub)
+
+	var r0 *webhooks.Subscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *webhooks.Subscription) (*webhooks.Subscription, error)); ok {
+		return rf(ctx, sub)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *webhooks.Subscription) *webhooks.Subscription); ok {
+		r0 = rf(ctx, sub)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*webhooks.Subscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *webhooks.Subscription) error); ok {
+		r1 = rf(ctx, sub)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx
+func (_m *WebhookRegistry) List(ctx context.Context) []*webhooks.Subscription {
+	ret := _m.Called(ctx)
+
+	var r0 []*webhooks.Subscription
+	if rf, ok := ret.Get(0).(func(context.Context) []*webhooks.Subscription); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*webhooks.Subscription)
+		}
+	}
+
+	return r0
+}
+
+// Remove provides a mock function with given fields: ctx, id
+func (_m *WebhookRegistry) Remove(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewWebhookRegistry interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewWebhookRegistry creates a new instance of WebhookRegistry. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewWebhookRegistry(t mockConstructorTestingTNewWebhookRegistry) *WebhookRegistry {
+	mock := &WebhookRegistry{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
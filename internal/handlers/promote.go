@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// Promoter promotes the replication follower to the leader.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Promoter
+type Promoter interface {
+	Promote() bool
+}
+
+// Promote makes the replication follower the leader accepting writes,
+// it's served to requests signed with the admin key only.
+func Promote(p Promoter) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		msg := "already the leader"
+		if p.Promote() {
+			msg = "promoted to the leader"
+			logger.Log.Info("Promoted to the replication leader")
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(msg))
+		if err != nil {
+			logger.Log.Error("Failed to write response")
+		}
+	}
+}
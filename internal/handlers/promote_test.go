@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/handlers/mocks"
+)
+
+func TestPromote(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		promoted bool
+		code     int
+	}{
+		{
+			name:     "follower",
+			promoted: true,
+			code:     http.StatusOK,
+			body:     "promoted to the leader",
+		},
+		{
+			name: "leader",
+			code: http.StatusOK,
+			body: "already the leader",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := mocks.NewPromoter(t)
+			p.On("Promote").Return(tt.promoted)
+			r := chi.NewRouter()
+			r.Post("/admin/promote", Promote(p))
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/promote", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
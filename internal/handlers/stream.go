@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/watch"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+const (
+	// keepAlive - interval of comments keeping idle streams open through proxies
+	keepAlive = 15 * time.Second
+	// reconnectDelay - delay before the browser reconnects to the dropped stream
+	reconnectDelay = 3 * time.Second
+)
+
+// MetricsWatcher streams updates of metrics of the tenant of the context selected by the filter.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=MetricsWatcher
+type MetricsWatcher interface {
+	Watch(ctx context.Context, f *watch.Filter, send func(u *watch.Update) error) error
+}
+
+// ListStream streams rows of the dashboard as server-sent events.
+// Every connection starts with rows of all series with their recent values followed by the ready event
+// carrying the number of recent values kept for sparklines, then rows are sent as series are updated and deleted.
+// Connections lagging behind updates are closed, the browser reconnects and receives all rows again.
+func ListStream(s MetricsStorage, watcher MetricsWatcher, recent RecentValues) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		fresh, err := newFreshnessByType(req.Context(), s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		metadata, err := s.GetAllMetadata(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		values := recent.Recent(req.Context())
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		stream := &eventStream{w: w, flusher: flusher}
+		if err = stream.retry(reconnectDelay); err != nil {
+			return
+		}
+		stop := stream.keepAlive(keepAlive)
+		defer stop()
+
+		err = watcher.Watch(req.Context(), &watch.Filter{}, func(u *watch.Update) error {
+			switch {
+			case u.Metric == nil:
+				return stream.send("ready", map[string]int{"spark": recent.Size()})
+			case u.Deleted:
+				return stream.send("delete", &row{Name: u.Metric.ID, Type: u.Metric.MType})
+			}
+			r := newRow(u.Metric, metadata)
+			if u.Snapshot {
+				r.Updated, r.Stale = fresh[u.Metric.MType].status(u.Metric.ID)
+				r.Spark = values[u.Metric.ID]
+				return stream.send("row", r)
+			}
+			r.Updated = u.Time.Format(time.RFC3339)
+			return stream.send("update", r)
+		})
+		switch {
+		case errors.Is(err, events.ErrLagged):
+			logger.Log.Warn("Dropped dashboard stream lagging behind metric updates")
+		case err != nil && req.Context().Err() == nil:
+			logger.Log.Error("Failed to stream metrics", zap.Error(err))
+		}
+	}
+}
+
+// eventStream writes server-sent events, writes are serialized with keep-alive comments.
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	mu      sync.Mutex
+}
+
+func (s *eventStream) write(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprint(s.w, msg); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *eventStream) retry(delay time.Duration) error {
+	return s.write(fmt.Sprintf("retry: %d\n\n", delay.Milliseconds()))
+}
+
+func (s *eventStream) send(event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.write(fmt.Sprintf("event: %s\ndata: %s\n\n", event, body))
+}
+
+// keepAlive sends comments on interval until the returned function is called,
+// it returns after the last comment is written.
+func (s *eventStream) keepAlive(interval time.Duration) func() {
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+				if err := s.write(": keep-alive\n\n"); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/handlers/mocks"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/watch"
+)
+
+func TestListStream(t *testing.T) {
+	delta := int64(2)
+	value := 1.5
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := []*watch.Update{
+		{Snapshot: true, Metric: &models.Metrics{ID: "PollCount", MType: models.Counter, Delta: &delta}},
+		{Snapshot: true},
+	}
+	changes := []*watch.Update{
+		{Time: updated, Seq: 3, Metric: &models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value}},
+		{Time: updated, Seq: 4, Deleted: true, Metric: &models.Metrics{ID: "PollCount", MType: models.Counter}},
+	}
+
+	tests := []struct {
+		watchErr    error
+		updatedErr  error
+		name        string
+		want        string
+		contentType string
+		code        int
+	}{
+		{
+			name:        "streamed",
+			watchErr:    events.ErrLagged,
+			code:        http.StatusOK,
+			contentType: "text/event-stream",
+			want: "retry: 3000\n\n" +
+				"event: row\ndata: {\"name\":\"PollCount\",\"type\":\"counter\",\"value\":\"2\",\"updated\":\"2024-03-01T12:00:00Z\",\"spark\":[1,2],\"sort\":2,\"stale\":false}\n\n" +
+				"event: ready\ndata: {\"spark\":120}\n\n" +
+				"event: update\ndata: {\"name\":\"Alloc\",\"type\":\"gauge\",\"value\":\"1.50\",\"updated\":\"2024-03-01T12:00:00Z\",\"sort\":1.5,\"stale\":false}\n\n" +
+				"event: delete\ndata: {\"name\":\"PollCount\",\"type\":\"counter\",\"value\":\"\",\"updated\":\"\",\"sort\":0,\"stale\":false}\n\n",
+		},
+		{
+			name:        "storage error",
+			updatedErr:  errors.New("unexpected error"),
+			code:        http.StatusInternalServerError,
+			contentType: "text/plain; charset=utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewMetricsStorage(t)
+			watcher := mocks.NewMetricsWatcher(t)
+			recent := mocks.NewRecentValues(t)
+			s.On("GetUpdated", mock.Anything, mock.Anything).Return(map[string]time.Time{"PollCount": updated}, tt.updatedErr)
+			if tt.updatedErr == nil {
+				s.On("GetAllMetadata", mock.Anything).Return(make(map[string]*models.Metadata), nil)
+				recent.On("Recent", mock.Anything).Return(map[string][]float64{"PollCount": {1, 2}})
+				recent.On("Size").Return(120)
+				watcher.On("Watch", mock.Anything, &watch.Filter{}, mock.Anything).
+					Run(func(args mock.Arguments) {
+						send := args.Get(2).(func(u *watch.Update) error)
+						for _, u := range append(snapshot, changes...) {
+							require.NoError(t, send(u))
+						}
+					}).
+					Return(tt.watchErr)
+			}
+			r := chi.NewRouter()
+			r.Get("/stream", ListStream(s, watcher, recent))
+
+			req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+			assert.Equal(t, tt.contentType, res.Header.Get("Content-Type"))
+			if tt.want != "" {
+				body, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, string(body))
+			}
+		})
+	}
+}
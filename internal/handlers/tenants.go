@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+const tenantsTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <link rel="stylesheet" type="text/css" href="/assets/main.css">
+    <meta charset="UTF-8">
+    <title>Tenants</title>
+</head>
+<body>
+<table>
+    <thead>
+    <tr>
+        <th>Tenant</th>
+        <th>Series</th>
+    </tr>
+    </thead>
+    <tbody>
+    %tenants%
+    </tbody>
+</table>
+</body>
+</html>`
+
+// TenantStorage returns numbers of series stored for every tenant.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=TenantStorage
+type TenantStorage interface {
+	Stats(ctx context.Context) ([]*models.TenantStats, error)
+}
+
+// ListTenants returns a html-table with all tenants and numbers of their series,
+// it's served to requests signed with the admin key only.
+func ListTenants(s TenantStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		stats, err := s.Stats(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		lines := make([]string, 0, len(stats))
+		for _, st := range stats {
+			lines = append(lines, fmt.Sprintf("<tr><td>%s</td><td>%d</td></tr>", html.EscapeString(st.Tenant), st.Series))
+		}
+		page := strings.Replace(tenantsTemplate, "%tenants%", strings.Join(lines, ""), -1)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, err = w.Write([]byte(page))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/handlers/mocks"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+func TestListTenants(t *testing.T) {
+	stats := []*models.TenantStats{{Tenant: tenant.Default, Series: 3}, {Tenant: "team-a", Series: 12}}
+	tests := []struct {
+		err      error
+		name     string
+		contains []string
+		code     int
+	}{
+		{
+			name:     "tenants",
+			code:     http.StatusOK,
+			contains: []string{"<tr><td>default</td><td>3</td></tr>", "<tr><td>team-a</td><td>12</td></tr>"},
+		},
+		{
+			name: "storage error",
+			err:  errors.New("failed"),
+			code: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewTenantStorage(t)
+			s.On("Stats", mock.Anything).Return(stats, tt.err)
+			r := chi.NewRouter()
+			r.Get("/admin/tenants", ListTenants(s))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/tenants", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			for _, c := range tt.contains {
+				assert.Contains(t, string(body), c)
+			}
+		})
+	}
+}
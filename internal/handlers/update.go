@@ -12,6 +12,7 @@ import (
 )
 
 // Update updates value of provided metric.
+// Query parameters are used as labels of the updated series.
 func Update(s MetricsStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 
@@ -24,6 +25,17 @@ func Update(s MetricsStorage) http.HandlerFunc {
 		metricType := chi.URLParam(req, "type")
 		metricName := chi.URLParam(req, "name")
 		metricValue := chi.URLParam(req, "value")
+		labels, err := queryLabels(req.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = checkType(req.Context(), s, metricName, metricType)
+		if err != nil {
+			http.Error(w, err.Error(), updateStatus(err))
+			return
+		}
+		key := models.SeriesKey(metricName, labels)
 
 		switch metricType {
 		case models.Counter:
@@ -32,24 +44,24 @@ func Update(s MetricsStorage) http.HandlerFunc {
 				http.Error(w, "invalid value type", http.StatusBadRequest)
 				return
 			}
-			_, err = s.UpdateCounter(req.Context(), metricName, cval)
+			_, err = s.UpdateCounter(req.Context(), key, cval)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				http.Error(w, err.Error(), updateStatus(err))
 				return
 			}
-			logger.Log.Info("Updated metric value", zap.String("name", metricName), zap.Int64("value", cval))
+			logger.Log.Info("Updated metric value", zap.String("name", key), zap.Int64("value", cval))
 		case models.Gauge:
 			gval, err := strconv.ParseFloat(metricValue, 64)
 			if err != nil {
 				http.Error(w, "invalid value type", http.StatusBadRequest)
 				return
 			}
-			_, err = s.UpdateGauge(req.Context(), metricName, gval)
+			_, err = s.UpdateGauge(req.Context(), key, gval)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				http.Error(w, err.Error(), updateStatus(err))
 				return
 			}
-			logger.Log.Info("Updated metric value", zap.String("name", metricName), zap.Float64("value", gval))
+			logger.Log.Info("Updated metric value", zap.String("name", key), zap.Float64("value", gval))
 		}
 
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
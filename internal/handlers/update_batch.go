@@ -36,11 +36,22 @@ func UpdateBatch(s MetricsStorage) http.HandlerFunc {
 			}
 		}
 
-		err = s.InsertBatch(req.Context(), batch)
+		metadata, err := s.GetAllMetadata(req.Context())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		err = models.CheckTypes(metadata, batch)
+		if err != nil {
+			http.Error(w, err.Error(), updateStatus(err))
+			return
+		}
+
+		err = s.InsertBatch(req.Context(), batch)
+		if err != nil {
+			http.Error(w, err.Error(), updateStatus(err))
+			return
+		}
 
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
@@ -54,6 +54,15 @@ func TestUpdateBatch(t *testing.T) {
 				contentType: "text/plain; charset=utf-8",
 			},
 		},
+		{
+			name:   "histogram buckets mismatch",
+			method: http.MethodPost,
+			body:   "[{\"id\": \"test\",\"type\": \"histogram\",\"histogram\": {\"bounds\": [1],\"counts\": [1,0],\"count\": 1,\"sum\": 0.5}},{\"id\": \"test\",\"type\": \"histogram\",\"histogram\": {\"bounds\": [2],\"counts\": [1,0],\"count\": 1,\"sum\": 0.5}}]",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
 		{
 			name:   "wrong method",
 			method: http.MethodGet,
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
 	"github.com/vindosVP/metrics/pkg/logger"
 )
 
@@ -22,6 +25,19 @@ type MetricsStorage interface {
 	GetCounter(ctx context.Context, name string) (int64, error)
 	GetAllCounter(ctx context.Context) (map[string]int64, error)
 	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
 }
 
 // UpdateBody updates values of provided in json format metric.
@@ -48,25 +64,31 @@ func UpdateBody(s MetricsStorage) http.HandlerFunc {
 			return
 		}
 
+		err = checkType(req.Context(), s, metrics.ID, metrics.MType)
+		if err != nil {
+			http.Error(w, err.Error(), updateStatus(err))
+			return
+		}
+
+		key := metrics.Key()
 		fields := []zap.Field{
-			zap.String("name", metrics.ID),
+			zap.String("name", key),
 			zap.String("type", metrics.MType),
 		}
-		resp := &models.Metrics{}
+		resp := &models.Metrics{ID: metrics.ID, Labels: metrics.Labels}
 
 		switch metrics.MType {
 		case models.Counter:
 			delta := *metrics.Delta
 			fields = append(fields, zap.Int64("delta", delta))
-			val, cerr := s.UpdateCounter(req.Context(), metrics.ID, delta)
+			val, cerr := s.UpdateCounter(req.Context(), key, delta)
 			if cerr != nil {
 				fields = append(fields, zap.Error(cerr))
 				logger.Log.Error("Failed to update metric value", fields...)
-				http.Error(w, cerr.Error(), http.StatusInternalServerError)
+				http.Error(w, cerr.Error(), updateStatus(cerr))
 				return
 			}
 
-			resp.ID = metrics.ID
 			resp.MType = models.Counter
 			resp.Delta = &val
 
@@ -74,18 +96,52 @@ func UpdateBody(s MetricsStorage) http.HandlerFunc {
 		case models.Gauge:
 			value := *metrics.Value
 			fields = append(fields, zap.Float64("value", value))
-			val, gerr := s.UpdateGauge(req.Context(), metrics.ID, value)
+			val, gerr := s.UpdateGauge(req.Context(), key, value)
 			if gerr != nil {
 				fields = append(fields, zap.Error(gerr))
 				logger.Log.Error("Failed to update metric value", fields...)
-				http.Error(w, gerr.Error(), http.StatusInternalServerError)
+				http.Error(w, gerr.Error(), updateStatus(gerr))
 				return
 			}
 
-			resp.ID = metrics.ID
 			resp.MType = models.Gauge
 			resp.Value = &val
 
+			logger.Log.Info("Updated metric value", fields...)
+		case models.Histogram:
+			fields = append(fields, zap.Uint64("count", metrics.Histogram.Count))
+			val, herr := s.UpdateHistogram(req.Context(), key, metrics.Histogram)
+			if herr != nil {
+				fields = append(fields, zap.Error(herr))
+				logger.Log.Error("Failed to update metric value", fields...)
+				http.Error(w, herr.Error(), updateStatus(herr))
+				return
+			}
+
+			resp.MType = models.Histogram
+			resp.Histogram = val
+
+			logger.Log.Info("Updated metric value", fields...)
+		case models.Summary:
+			value := metrics.SummarySketch()
+			fields = append(fields, zap.Uint64("count", value.Count))
+			val, serr := s.UpdateSummary(req.Context(), key, value)
+			if serr != nil {
+				fields = append(fields, zap.Error(serr))
+				logger.Log.Error("Failed to update metric value", fields...)
+				http.Error(w, serr.Error(), updateStatus(serr))
+				return
+			}
+			quantiles, serr := val.Quantiles(models.DefaultQuantiles)
+			if serr != nil {
+				http.Error(w, serr.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			resp.MType = models.Summary
+			resp.Summary = val
+			resp.Quantiles = quantiles
+
 			logger.Log.Info("Updated metric value", fields...)
 		}
 
@@ -105,3 +161,24 @@ func UpdateBody(s MetricsStorage) http.HandlerFunc {
 		}
 	}
 }
+
+// updateStatus returns http status for the error of a metric update,
+// merging of incompatible histograms or summaries is a client error,
+// update with a type different from the declared one is a conflict,
+// followers of the replication leader don't accept updates,
+// updates of new tenants beyond the limit of tenants are forbidden.
+func updateStatus(err error) int {
+	if errors.Is(err, models.ErrBucketsMismatch) || errors.Is(err, models.ErrSketchMismatch) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, models.ErrTypeConflict) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, storage.ErrReadOnly) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, storage.ErrTenantLimit) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
@@ -71,6 +71,64 @@ func TestUpdateBody(t *testing.T) {
 				body:        "{\"delta\":125,\"id\":\"PollCount\",\"type\":\"counter\"}",
 			},
 		},
+		{
+			name:   "histogram ok",
+			method: http.MethodPost,
+			body:   "{\"id\":\"Latency\",\"type\":\"histogram\",\"histogram\":{\"bounds\":[0.5,1],\"counts\":[1,0,2],\"count\":3,\"sum\":4.25}}",
+			want: want{
+				code:        http.StatusOK,
+				contentType: "application/json",
+				wantBody:    true,
+				body:        "{\"histogram\":{\"bounds\":[0.5,1],\"counts\":[1,0,2],\"count\":3,\"sum\":4.25},\"id\":\"Latency\",\"type\":\"histogram\"}",
+			},
+		},
+		{
+			name:   "histogram inconsistent counts",
+			method: http.MethodPost,
+			body:   "{\"id\":\"Latency\",\"type\":\"histogram\",\"histogram\":{\"bounds\":[0.5,1],\"counts\":[1,0],\"count\":1,\"sum\":0.25}}",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name:   "histogram no value",
+			method: http.MethodPost,
+			body:   "{\"id\":\"Latency\",\"type\":\"histogram\"}",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name:   "summary observations ok",
+			method: http.MethodPost,
+			body:   "{\"id\":\"Latency\",\"type\":\"summary\",\"observations\":[2,2,2]}",
+			want: want{
+				code:        http.StatusOK,
+				contentType: "application/json",
+				wantBody:    true,
+				body:        "{\"summary\":{\"positive\":{\"35\":3},\"accuracy\":0.01,\"count\":3,\"sum\":6,\"min\":2,\"max\":2},\"id\":\"Latency\",\"type\":\"summary\",\"quantiles\":[{\"q\":0.5,\"value\":2},{\"q\":0.95,\"value\":2},{\"q\":0.99,\"value\":2}]}",
+			},
+		},
+		{
+			name:   "summary invalid accuracy",
+			method: http.MethodPost,
+			body:   "{\"id\":\"Latency\",\"type\":\"summary\",\"summary\":{\"accuracy\":2,\"count\":0,\"sum\":0}}",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name:   "summary no value",
+			method: http.MethodPost,
+			body:   "{\"id\":\"Latency\",\"type\":\"summary\"}",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
 		{
 			name:   "wrong method",
 			method: http.MethodGet,
@@ -20,6 +20,9 @@ func validate(req *http.Request, checkValue bool) (bool, string, int) {
 	if metricName == "" {
 		return false, "name is missing in parameters", http.StatusNotFound
 	}
+	if models.ValidateSeries(metricName, nil) != nil {
+		return false, "invalid name parameter value", http.StatusBadRequest
+	}
 	if checkValue {
 		metricValue := chi.URLParam(req, "value")
 		if metricValue == "" {
@@ -31,27 +34,47 @@ func validate(req *http.Request, checkValue bool) (bool, string, int) {
 }
 
 func validateUpdate(metrics *models.Metrics) (bool, string, int) {
-	if metrics.MType != models.Counter && metrics.MType != models.Gauge {
+	if !validBodyType(metrics.MType) {
 		return false, "invalid metric type", http.StatusBadRequest
 	}
 	if metrics.ID == "" {
 		return false, "invalid id", http.StatusNotFound
 	}
+	if err := models.ValidateSeries(metrics.ID, metrics.Labels); err != nil {
+		return false, err.Error(), http.StatusBadRequest
+	}
 	if metrics.MType == models.Counter && metrics.Delta == nil {
 		return false, "invalid delta", http.StatusBadRequest
 	}
 	if metrics.MType == models.Gauge && metrics.Value == nil {
 		return false, "invalid value", http.StatusBadRequest
 	}
+	if metrics.MType == models.Histogram && (metrics.Histogram == nil || metrics.Histogram.Validate() != nil) {
+		return false, "invalid histogram", http.StatusBadRequest
+	}
+	if metrics.MType == models.Summary {
+		if sketch := metrics.SummarySketch(); sketch == nil || sketch.Validate() != nil {
+			return false, "invalid summary", http.StatusBadRequest
+		}
+	}
 	return true, "", http.StatusOK
 }
 
 func validateGet(metrics *models.Metrics) (bool, string, int) {
-	if metrics.MType != models.Counter && metrics.MType != models.Gauge {
+	if !validBodyType(metrics.MType) {
 		return false, "invalid metric type", http.StatusBadRequest
 	}
 	if metrics.ID == "" {
 		return false, "invalid id", http.StatusNotFound
 	}
+	if err := models.ValidateSeries(metrics.ID, metrics.Labels); err != nil {
+		return false, err.Error(), http.StatusBadRequest
+	}
 	return true, "", http.StatusOK
 }
+
+// validBodyType reports whether the metric type can be passed in json body.
+// Histograms and summaries have no plain text representation, so URL endpoints accept only counters and gauges.
+func validBodyType(mType string) bool {
+	return mType == models.Counter || mType == models.Gauge || mType == models.Histogram || mType == models.Summary
+}
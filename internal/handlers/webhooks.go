@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/webhooks"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// WebhookRegistry keeps webhook subscriptions of the tenant of the context.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=WebhookRegistry
+type WebhookRegistry interface {
+	Add(ctx context.Context, sub *webhooks.Subscription) (*webhooks.Subscription, error)
+	List(ctx context.Context) []*webhooks.Subscription
+	Remove(ctx context.Context, id string) error
+}
+
+// RegisterWebhook subscribes provided in json format url to updates of metrics matching the pattern,
+// the registered subscription with its id is returned.
+func RegisterWebhook(r WebhookRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		sub := &webhooks.Subscription{}
+		if err := json.NewDecoder(req.Body).Decode(sub); err != nil {
+			logger.Log.Error("Failed to unmarshal request body")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := r.Add(req.Context(), sub)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, webhooks.ErrInvalidSubscription) {
+				status = http.StatusBadRequest
+			}
+			if errors.Is(err, storage.ErrReadOnly) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		logger.Log.Info("Registered webhook", zap.String("id", res.ID), zap.String("url", res.URL), zap.String("pattern", res.Pattern))
+
+		// headers are sent with the status
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, res)
+	}
+}
+
+// ListWebhooks returns webhook subscriptions of the tenant in json format.
+func ListWebhooks(r WebhookRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, r.List(req.Context()))
+	}
+}
+
+// DeleteWebhook removes the webhook subscription with the id.
+func DeleteWebhook(r WebhookRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		id := chi.URLParam(req, "id")
+		if err := r.Remove(req.Context(), id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, webhooks.ErrSubscriptionNotFound) {
+				status = http.StatusNotFound
+			}
+			if errors.Is(err, storage.ErrReadOnly) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		logger.Log.Info("Removed webhook", zap.String("id", id))
+		w.WriteHeader(http.StatusOK)
+	}
+}
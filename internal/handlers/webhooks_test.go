@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/handlers/mocks"
+	"github.com/vindosVP/metrics/internal/middleware"
+	"github.com/vindosVP/metrics/internal/webhooks"
+)
+
+func TestRegisterWebhook(t *testing.T) {
+	sub := &webhooks.Subscription{URL: "http://localhost/hook", Pattern: "Alloc*", Secret: "secret"}
+	registered := &webhooks.Subscription{ID: "1", Tenant: "default", URL: "http://localhost/hook", Pattern: "Alloc*"}
+	tests := []struct {
+		err  error
+		name string
+		body string
+		want string
+		code int
+		add  bool
+	}{
+		{
+			name: "registered",
+			body: `{"url":"http://localhost/hook","pattern":"Alloc*","secret":"secret"}`,
+			add:  true,
+			code: http.StatusCreated,
+			want: `{"id":"1","tenant":"default","url":"http://localhost/hook","pattern":"Alloc*"}`,
+		},
+		{
+			name: "invalid json",
+			body: `{"url":`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "invalid subscription",
+			body: `{"url":"http://localhost/hook","pattern":"Alloc*","secret":"secret"}`,
+			add:  true,
+			err:  webhooks.ErrInvalidSubscription,
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := mocks.NewWebhookRegistry(t)
+			if tt.add {
+				var res *webhooks.Subscription
+				if tt.err == nil {
+					res = registered
+				}
+				reg.On("Add", mock.Anything, sub).Return(res, tt.err)
+			}
+			r := chi.NewRouter()
+			r.Use(middleware.Tenant(nil))
+			r.Post("/webhooks/", RegisterWebhook(reg))
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+			if tt.want != "" {
+				assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+				body, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tt.want, string(body))
+			}
+		})
+	}
+}
+
+func TestListWebhooks(t *testing.T) {
+	reg := mocks.NewWebhookRegistry(t)
+	reg.On("List", mock.Anything).Return([]*webhooks.Subscription{{ID: "1", Tenant: "default", URL: "http://localhost/hook", Pattern: "*"}})
+	r := chi.NewRouter()
+	r.Use(middleware.Tenant(nil))
+	r.Get("/webhooks/", ListWebhooks(reg))
+
+	req := httptest.NewRequest(http.MethodGet, "/webhooks/", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"id":"1","tenant":"default","url":"http://localhost/hook","pattern":"*"}]`, string(body))
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	tests := []struct {
+		err  error
+		name string
+		code int
+	}{
+		{name: "removed", code: http.StatusOK},
+		{name: "not found", err: webhooks.ErrSubscriptionNotFound, code: http.StatusNotFound},
+		{name: "failed", err: errors.New("failed"), code: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := mocks.NewWebhookRegistry(t)
+			reg.On("Remove", mock.Anything, "1").Return(tt.err)
+			r := chi.NewRouter()
+			r.Use(middleware.Tenant(nil))
+			r.Delete("/webhooks/{id}", DeleteWebhook(reg))
+
+			req := httptest.NewRequest(http.MethodDelete, "/webhooks/1", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"net/http"
+	"strings"
 
 	"go.uber.org/zap"
 
@@ -17,6 +18,13 @@ func ValidateHMAC(key string) func(next http.Handler) http.Handler {
 	return h.ValidateHandler
 }
 
+// RequireHMAC returns handler to reject requests not signed with the key,
+// all requests are rejected if the key is not set.
+func RequireHMAC(key string) func(next http.Handler) http.Handler {
+	h := NewHasher(key)
+	return h.RequireHandler
+}
+
 // Sign returns sign handler.
 func Sign(key string) func(next http.Handler) http.Handler {
 	h := NewHasher(key)
@@ -29,13 +37,16 @@ type Hasher struct {
 }
 
 type responseSigner struct {
-	w   http.ResponseWriter
-	key string
-	buf bytes.Buffer
+	w      http.ResponseWriter
+	key    string
+	buf    bytes.Buffer
+	stream bool
 }
 
 func (r *responseSigner) Write(p []byte) (int, error) {
-	r.buf.Write(p)
+	if !r.stream {
+		r.buf.Write(p)
+	}
 	return r.w.Write(p)
 }
 
@@ -44,6 +55,12 @@ func (r *responseSigner) Header() http.Header {
 }
 
 func (r *responseSigner) WriteHeader(code int) {
+	// event streams are never complete to be hashed, so they are passed through unsigned
+	if strings.HasPrefix(r.w.Header().Get("Content-Type"), "text/event-stream") {
+		r.stream = true
+		r.w.WriteHeader(code)
+		return
+	}
 	responseData := r.buf.Bytes()
 	hash, err := utils.Sha256Hash(responseData, r.key)
 	if err != nil {
@@ -55,12 +72,20 @@ func (r *responseSigner) WriteHeader(code int) {
 	r.w.WriteHeader(code)
 }
 
+// Flush sends data written so far to the client, if the original ResponseWriter supports it.
+func (r *responseSigner) Flush() {
+	if f, ok := r.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // NewHasher creates Hasher.
 func NewHasher(key string) *Hasher {
 	return &Hasher{key: key}
 }
 
 // SignHandler returns handler that replaces the original ResponseWriter with the responseSigner.
+// Event streams are not signed, as they are never complete to be hashed.
 func (h *Hasher) SignHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if h.key != "" {
@@ -105,3 +130,33 @@ func (h *Hasher) ValidateHandler(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireHandler returns handler that rejects requests without HashSHA256 header matching the calculated hash.
+func (h *Hasher) RequireHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.key == "" {
+			http.Error(w, "Key is not configured", http.StatusForbidden)
+			return
+		}
+		var buf bytes.Buffer
+		_, err := io.Copy(&buf, r.Body)
+		if err != nil {
+			logger.Log.Error("Failed to read request body", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		r.Body = io.NopCloser(&buf)
+		hash, err := utils.Sha256Hash(buf.Bytes(), h.key)
+		if err != nil {
+			logger.Log.Error("Failed to compute hash", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("HashSHA256") != hash {
+			http.Error(w, "Invalid hash", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// Tenant returns handler to put the tenant of the request into its context.
+// The tenant is taken from the X-Tenant-ID header or from the key of the tenant the request is signed with,
+// if tenant keys are configured, requests of tenants other than the default one must be signed with the key of the tenant.
+// The hash of requests signed with a tenant key is already checked, so it's removed for ValidateHMAC to skip them.
+func Tenant(keys tenant.Keys) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			signer := ""
+			if hash := r.Header.Get("HashSHA256"); hash != "" && len(keys) > 0 {
+				var buf bytes.Buffer
+				_, err := io.Copy(&buf, r.Body)
+				if err != nil {
+					logger.Log.Error("Failed to read request body", zap.Error(err))
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				r.Body = io.NopCloser(&buf)
+				if id, ok := keys.Signer(buf.Bytes(), hash); ok {
+					signer = id
+					r.Header.Del("HashSHA256")
+				}
+			}
+
+			id, err := keys.Resolve(r.Header.Get(tenant.Header), signer)
+			if errors.Is(err, tenant.ErrTenantMismatch) || errors.Is(err, tenant.ErrUnsigned) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), id)))
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	// AlertPending - the condition of the rule holds for less than its for duration
+	AlertPending = "pending"
+
+	// AlertFiring - the condition of the rule holds for its for duration, receivers are notified
+	AlertFiring = "firing"
+
+	// AlertResolved - the condition of the firing alert no longer holds, receivers are notified
+	AlertResolved = "resolved"
+)
+
+// Alert - state of the alerting rule for the series of the tenant.
+// Series is the metric name pattern of the rule for absence alerts.
+type Alert struct {
+	ActiveAt   time.Time  `json:"active_at"`
+	FiredAt    *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Rule       string     `json:"rule"`
+	Tenant     string     `json:"tenant"`
+	Series     string     `json:"series"`
+	State      string     `json:"state"`
+	Summary    string     `json:"summary,omitempty"`
+	Value      float64    `json:"value"`
+}
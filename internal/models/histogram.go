@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+var (
+	// ErrBucketsMismatch - represents that histograms with different bucket bounds are merged
+	ErrBucketsMismatch = errors.New("histogram bucket bounds mismatch")
+
+	// ErrInvalidHistogram - represents that histogram structure is inconsistent
+	ErrInvalidHistogram = errors.New("invalid histogram")
+)
+
+// HistogramValue - distribution of observations over buckets.
+// Bounds are upper inclusive bounds of buckets in ascending order,
+// Counts hold the number of observations in every bucket with the last one counting observations above all bounds.
+type HistogramValue struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Count  uint64    `json:"count"`
+	Sum    float64   `json:"sum"`
+}
+
+// NewHistogramValue creates empty HistogramValue with provided bucket bounds.
+func NewHistogramValue(bounds []float64) *HistogramValue {
+	b := make([]float64, len(bounds))
+	copy(b, bounds)
+	return &HistogramValue{
+		Bounds: b,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe method adds the observation to the histogram.
+func (h *HistogramValue) Observe(v float64) {
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Counts[i]++
+	h.Count++
+	h.Sum += v
+}
+
+// Validate method checks that bounds are sorted and counts are consistent with them.
+func (h *HistogramValue) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return ErrInvalidHistogram
+	}
+	for i, b := range h.Bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) || (i > 0 && b <= h.Bounds[i-1]) {
+			return ErrInvalidHistogram
+		}
+	}
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total != h.Count {
+		return ErrInvalidHistogram
+	}
+	return nil
+}
+
+// Merge method adds observations of another histogram with the same bounds.
+func (h *HistogramValue) Merge(o *HistogramValue) error {
+	if !h.SameBounds(o) {
+		return ErrBucketsMismatch
+	}
+	for i, c := range o.Counts {
+		h.Counts[i] += c
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+	return nil
+}
+
+// SameBounds reports whether histograms have equal bucket bounds.
+func (h *HistogramValue) SameBounds(o *HistogramValue) bool {
+	if len(h.Bounds) != len(o.Bounds) {
+		return false
+	}
+	for i, b := range h.Bounds {
+		if b != o.Bounds[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Copy method returns a deep copy of the histogram.
+func (h *HistogramValue) Copy() *HistogramValue {
+	c := &HistogramValue{
+		Bounds: make([]float64, len(h.Bounds)),
+		Counts: make([]uint64, len(h.Counts)),
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+	copy(c.Bounds, h.Bounds)
+	copy(c.Counts, h.Counts)
+	return c
+}
+
+// Cumulative method returns counts of observations less or equal to every bound, the last one is the total count.
+func (h *HistogramValue) Cumulative() []uint64 {
+	res := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, c := range h.Counts {
+		total += c
+		res[i] = total
+	}
+	return res
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramValue_Observe(t *testing.T) {
+	h := NewHistogramValue([]float64{0.5, 1})
+	for _, v := range []float64{0.1, 0.5, 0.7, 3} {
+		h.Observe(v)
+	}
+	assert.Equal(t, []uint64{2, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(4), h.Count)
+	assert.InDelta(t, 4.3, h.Sum, 1e-9)
+	assert.Equal(t, []uint64{2, 3, 4}, h.Cumulative())
+	assert.NoError(t, h.Validate())
+}
+
+func TestHistogramValue_Validate(t *testing.T) {
+	tests := []struct {
+		value   *HistogramValue
+		name    string
+		wantErr bool
+	}{
+		{
+			name:    "ok",
+			value:   &HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{1, 0, 2}, Count: 3},
+			wantErr: false,
+		},
+		{
+			name:    "no bounds",
+			value:   &HistogramValue{Counts: []uint64{2}, Count: 2},
+			wantErr: false,
+		},
+		{
+			name:    "unsorted bounds",
+			value:   &HistogramValue{Bounds: []float64{2, 1}, Counts: []uint64{0, 0, 0}},
+			wantErr: true,
+		},
+		{
+			name:    "infinite bound",
+			value:   &HistogramValue{Bounds: []float64{math.Inf(1)}, Counts: []uint64{0, 0}},
+			wantErr: true,
+		},
+		{
+			name:    "counts length",
+			value:   &HistogramValue{Bounds: []float64{1}, Counts: []uint64{1}, Count: 1},
+			wantErr: true,
+		},
+		{
+			name:    "wrong count",
+			value:   &HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 1}, Count: 3},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.value.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidHistogram)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHistogramValue_Merge(t *testing.T) {
+	h := &HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 5}
+	err := h.Merge(&HistogramValue{Bounds: []float64{1}, Counts: []uint64{3, 0}, Count: 3, Sum: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, &HistogramValue{Bounds: []float64{1}, Counts: []uint64{4, 2}, Count: 6, Sum: 6}, h)
+
+	err = h.Merge(&HistogramValue{Bounds: []float64{2}, Counts: []uint64{1, 0}, Count: 1})
+	assert.ErrorIs(t, err, ErrBucketsMismatch)
+}
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidSeriesKey - represents that series key can not be parsed
+	ErrInvalidSeriesKey = errors.New("invalid series key")
+	// ErrInvalidID - represents that metric id is empty or contains braces reserved for series keys
+	ErrInvalidID = errors.New("invalid id")
+	// ErrInvalidLabelName - represents that label name is not valid
+	ErrInvalidLabelName = errors.New("invalid label name")
+)
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidLabelName reports whether the label name consists of letters, digits and underscores
+// and does not start with a digit.
+func ValidLabelName(name string) bool {
+	return labelNameRe.MatchString(name)
+}
+
+// ValidateSeries returns an error if the metric name and labels can't identify a series,
+// the name must not be empty or contain braces and label names must be valid.
+func ValidateSeries(name string, labels map[string]string) error {
+	if name == "" || strings.ContainsAny(name, "{}") {
+		return ErrInvalidID
+	}
+	for label := range labels {
+		if !ValidLabelName(label) {
+			return ErrInvalidLabelName
+		}
+	}
+	return nil
+}
+
+// SeriesKey returns the key identifying the series with provided name and labels.
+// Series without labels are identified by the metric name,
+// otherwise labels are sorted by name and appended in the name{label="value",...} form.
+func SeriesKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ParseSeriesKey splits the series key into the metric name and labels.
+func ParseSeriesKey(key string) (string, map[string]string, error) {
+	i := strings.IndexByte(key, '{')
+	if i == -1 {
+		return key, nil, nil
+	}
+	if !strings.HasSuffix(key, "}") {
+		return "", nil, fmt.Errorf("%w: %s", ErrInvalidSeriesKey, key)
+	}
+	name := key[:i]
+	rest := key[i+1 : len(key)-1]
+	labels := make(map[string]string)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq == -1 {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidSeriesKey, key)
+		}
+		lName := rest[:eq]
+		quoted, err := strconv.QuotedPrefix(rest[eq+1:])
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidSeriesKey, key)
+		}
+		lValue, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidSeriesKey, key)
+		}
+		labels[lName] = lValue
+		rest = strings.TrimPrefix(rest[eq+1+len(quoted):], ",")
+	}
+	return name, labels, nil
+}
+
+// Key returns the key identifying the metric series.
+func (m *Metrics) Key() string {
+	return SeriesKey(m.ID, m.Labels)
+}
+
+// MatchType - type of the label matcher
+type MatchType int
+
+const (
+	// MatchEqual - label value equals the matcher value
+	MatchEqual MatchType = iota
+
+	// MatchNotEqual - label value differs from the matcher value
+	MatchNotEqual
+
+	// MatchRegexp - label value matches the matcher regular expression
+	MatchRegexp
+
+	// MatchNotRegexp - label value does not match the matcher regular expression
+	MatchNotRegexp
+)
+
+// LabelMatcher - condition on the label value used to select series
+type LabelMatcher struct {
+	re    *regexp.Regexp
+	Name  string
+	Value string
+	Type  MatchType
+}
+
+// NewLabelMatcher creates LabelMatcher.
+// Regular expressions are anchored to match the whole label value.
+func NewLabelMatcher(t MatchType, name string, value string) (*LabelMatcher, error) {
+	m := &LabelMatcher{Type: t, Name: name, Value: value}
+	if t == MatchRegexp || t == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, err
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// Matches reports whether the label value satisfies the matcher.
+// Missing labels are treated as labels with empty value.
+func (m *LabelMatcher) Matches(value string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+// EqualMatchers returns equality matchers for all provided labels.
+func EqualMatchers(labels map[string]string) []*LabelMatcher {
+	matchers := make([]*LabelMatcher, 0, len(labels))
+	for k, v := range labels {
+		matchers = append(matchers, &LabelMatcher{Type: MatchEqual, Name: k, Value: v})
+	}
+	return matchers
+}
+
+// SelectSeries returns series with provided name whose labels satisfy all matchers.
+func SelectSeries[V any](series map[string]V, name string, matchers []*LabelMatcher) map[string]V {
+	res := make(map[string]V)
+	for key, v := range series {
+		sName, labels, err := ParseSeriesKey(key)
+		if err != nil || sName != name {
+			continue
+		}
+		matched := true
+		for _, m := range matchers {
+			if !m.Matches(labels[m.Name]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			res[key] = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesKey(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		name   string
+		metric string
+		want   string
+	}{
+		{
+			name:   "no labels",
+			metric: "Alloc",
+			labels: nil,
+			want:   "Alloc",
+		},
+		{
+			name:   "sorted labels",
+			metric: "Alloc",
+			labels: map[string]string{"service": "api", "host": "web1"},
+			want:   `Alloc{host="web1",service="api"}`,
+		},
+		{
+			name:   "escaped value",
+			metric: "Alloc",
+			labels: map[string]string{"path": `C:\tmp "x",y={}`},
+			want:   `Alloc{path="C:\\tmp \"x\",y={}"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := SeriesKey(tt.metric, tt.labels)
+			assert.Equal(t, tt.want, key)
+
+			name, labels, err := ParseSeriesKey(key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.metric, name)
+			if len(tt.labels) == 0 {
+				assert.Empty(t, labels)
+			} else {
+				assert.Equal(t, tt.labels, labels)
+			}
+		})
+	}
+}
+
+func TestValidateSeries(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		labels  map[string]string
+		name    string
+		metric  string
+	}{
+		{name: "valid", metric: "Alloc", labels: map[string]string{"host": "web1"}},
+		{name: "empty id", metric: "", wantErr: ErrInvalidID},
+		{name: "braces", metric: `Alloc{host="web1"}`, wantErr: ErrInvalidID},
+		{name: "invalid label", metric: "Alloc", labels: map[string]string{"1host": "web1"}, wantErr: ErrInvalidLabelName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSeries(tt.metric, tt.labels)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParseSeriesKey_Invalid(t *testing.T) {
+	keys := []string{
+		`Alloc{host="a"`,
+		`Alloc{host}`,
+		`Alloc{host=a}`,
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			_, _, err := ParseSeriesKey(key)
+			assert.ErrorIs(t, err, ErrInvalidSeriesKey)
+		})
+	}
+}
+
+func TestSelectSeries(t *testing.T) {
+	series := map[string]int{
+		"Alloc":                            0,
+		`Alloc{host="a"}`:                  1,
+		`Alloc{host="b",service="db"}`:     2,
+		`Alloc{host="c",service="api"}`:    3,
+		`HeapAlloc{host="a",service="db"}`: 4,
+	}
+	mustMatcher := func(mt MatchType, name, value string) *LabelMatcher {
+		m, err := NewLabelMatcher(mt, name, value)
+		require.NoError(t, err)
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		matchers []*LabelMatcher
+		want     []int
+	}{
+		{
+			name:     "no matchers",
+			matchers: nil,
+			want:     []int{0, 1, 2, 3},
+		},
+		{
+			name:     "equal",
+			matchers: []*LabelMatcher{mustMatcher(MatchEqual, "host", "a")},
+			want:     []int{1},
+		},
+		{
+			name:     "not equal",
+			matchers: []*LabelMatcher{mustMatcher(MatchNotEqual, "service", "")},
+			want:     []int{2, 3},
+		},
+		{
+			name:     "regexp",
+			matchers: []*LabelMatcher{mustMatcher(MatchRegexp, "host", "a|b")},
+			want:     []int{1, 2},
+		},
+		{
+			name:     "not regexp",
+			matchers: []*LabelMatcher{mustMatcher(MatchNotRegexp, "service", "d.*")},
+			want:     []int{0, 1, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]int, 0)
+			for _, v := range SelectSeries(series, "Alloc", tt.matchers) {
+				got = append(got, v)
+			}
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
@@ -1,23 +1,84 @@
 // Package models consists of models of entities
 package models
 
+import (
+	"errors"
+	"time"
+)
+
 const (
 	// Counter - counter metric type
 	Counter = "counter"
 
 	// Gauge - gauge metric type
 	Gauge = "gauge"
+
+	// Histogram - histogram metric type
+	Histogram = "histogram"
+
+	// Summary - summary metric type
+	Summary = "summary"
 )
 
+// Types - all supported metric types
+var Types = []string{Counter, Gauge, Histogram, Summary}
+
+// ErrInvalidValue - represents that metric has no value of its type
+var ErrInvalidValue = errors.New("invalid metric value")
+
 // Metrics - structure of metric
 type Metrics struct {
-	Delta *int64   `json:"delta,omitempty"`
-	Value *float64 `json:"value,omitempty"`
-	ID    string   `json:"id"`
-	MType string   `json:"type"`
+	Delta     *int64            `json:"delta,omitempty"`
+	Value     *float64          `json:"value,omitempty"`
+	Histogram *HistogramValue   `json:"histogram,omitempty"`
+	Summary   *SummaryValue     `json:"summary,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	ID        string            `json:"id"`
+	MType     string            `json:"type"`
+
+	// Observations - raw summary observations, an alternative to the sketch in Summary
+	Observations []float64 `json:"observations,omitempty"`
+
+	// Quantiles - requested quantiles of a summary, values are filled in responses
+	Quantiles []Quantile `json:"quantiles,omitempty"`
+
+	// Updated - time of the last metric update, used in dumps
+	Updated *time.Time `json:"updated,omitempty"`
+}
+
+// SummarySketch method returns the summary sketch of the metric,
+// it is built from raw observations with DefaultSummaryAccuracy when no sketch is provided.
+func (m *Metrics) SummarySketch() *SummaryValue {
+	if m.Summary != nil {
+		return m.Summary
+	}
+	if m.Observations == nil {
+		return nil
+	}
+	sketch := NewSummaryValue(DefaultSummaryAccuracy)
+	for _, v := range m.Observations {
+		sketch.Observe(v)
+	}
+	return sketch
 }
 
-// MetricsDump - structure of metrics dump
+// MetricsDump - structure of metrics dump,
+// Seq is the sequence number of the last write-ahead log record included in the dump
 type MetricsDump struct {
-	Metrics []*Metrics `json:"metrics"`
+	Metrics  []*Metrics  `json:"metrics"`
+	Metadata []*Metadata `json:"metadata,omitempty"`
+	Seq      uint64      `json:"seq,omitempty"`
+}
+
+// Sample - timestamped value of a metric
+type Sample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Delta     *int64    `json:"delta,omitempty"`
+	Value     *float64  `json:"value,omitempty"`
+}
+
+// TenantStats - number of series stored for the tenant.
+type TenantStats struct {
+	Tenant string `json:"tenant"`
+	Series int    `json:"series"`
 }
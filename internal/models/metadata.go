@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// UnitBytes - unit of metrics measured in bytes
+	UnitBytes = "bytes"
+
+	// UnitSeconds - unit of metrics measured in seconds
+	UnitSeconds = "seconds"
+
+	// UnitPercent - unit of metrics measured in percents
+	UnitPercent = "percent"
+)
+
+var (
+	// ErrTypeConflict - represents that metric type differs from the type declared in its metadata
+	ErrTypeConflict = errors.New("metric type conflicts with declared type")
+
+	// ErrInvalidMetadata - represents that metadata structure is inconsistent
+	ErrInvalidMetadata = errors.New("invalid metadata")
+)
+
+// Metadata - declared type, unit and help text of the metric with name ID
+type Metadata struct {
+	ID    string `json:"id"`
+	MType string `json:"type"`
+	Unit  string `json:"unit,omitempty"`
+	Help  string `json:"help,omitempty"`
+}
+
+// Validate method checks that metadata has a name and a known type.
+func (m *Metadata) Validate() error {
+	if m.ID == "" || strings.ContainsAny(m.ID, "{}") {
+		return fmt.Errorf("%w: invalid id", ErrInvalidMetadata)
+	}
+	for _, t := range Types {
+		if m.MType == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: invalid type", ErrInvalidMetadata)
+}
+
+// CheckType method returns ErrTypeConflict if provided type differs from the declared one.
+func (m *Metadata) CheckType(mType string) error {
+	if m.MType != mType {
+		return fmt.Errorf("%w: %s is declared as %s", ErrTypeConflict, m.ID, m.MType)
+	}
+	return nil
+}
+
+// CheckTypes returns ErrTypeConflict if type of any metric of the batch differs from its declared type,
+// metrics without metadata are not checked.
+func CheckTypes(metadata map[string]*Metadata, batch []*Metrics) error {
+	for _, metric := range batch {
+		meta, ok := metadata[metric.ID]
+		if !ok {
+			continue
+		}
+		if err := meta.CheckType(metric.MType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
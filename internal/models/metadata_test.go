@@ -0,0 +1,42 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadata_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		meta    *Metadata
+		wantErr bool
+	}{
+		{name: "ok", meta: &Metadata{ID: "Alloc", MType: Gauge, Unit: UnitBytes}},
+		{name: "summary", meta: &Metadata{ID: "Latency", MType: Summary}},
+		{name: "no id", meta: &Metadata{MType: Gauge}, wantErr: true},
+		{name: "series key", meta: &Metadata{ID: `Alloc{host="a"}`, MType: Gauge}, wantErr: true},
+		{name: "unknown type", meta: &Metadata{ID: "Alloc", MType: "wrong"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.meta.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMetadata)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCheckTypes(t *testing.T) {
+	metadata := map[string]*Metadata{
+		"Alloc": {ID: "Alloc", MType: Gauge},
+	}
+
+	assert.NoError(t, CheckTypes(metadata, []*Metrics{{ID: "Alloc", MType: Gauge}, {ID: "PollCount", MType: Counter}}))
+	assert.ErrorIs(t, CheckTypes(metadata, []*Metrics{{ID: "PollCount", MType: Counter}, {ID: "Alloc", MType: Counter}}), ErrTypeConflict)
+	assert.NoError(t, CheckTypes(nil, []*Metrics{{ID: "Alloc", MType: Counter}}))
+}
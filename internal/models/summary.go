@@ -0,0 +1,215 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// DefaultSummaryAccuracy - relative accuracy of summaries built from raw observations
+const DefaultSummaryAccuracy = 0.01
+
+// minIndexableValue - observations with smaller absolute value are counted as zeros
+const minIndexableValue = 1e-9
+
+var (
+	// ErrSketchMismatch - represents that summaries with different accuracy are merged
+	ErrSketchMismatch = errors.New("summary accuracy mismatch")
+
+	// ErrInvalidSummary - represents that summary structure is inconsistent
+	ErrInvalidSummary = errors.New("invalid summary")
+
+	// ErrInvalidQuantile - represents that requested quantile is out of [0, 1] range
+	ErrInvalidQuantile = errors.New("invalid quantile")
+)
+
+// SummaryValue - mergeable quantile sketch of observations (DDSketch).
+// Every observation is counted in the bucket with index ceil(log(|v|) / log(gamma)),
+// where gamma = (1 + Accuracy) / (1 - Accuracy), so any quantile estimate
+// is within Accuracy relative error from the real value.
+type SummaryValue struct {
+	Positive map[int32]uint64 `json:"positive,omitempty"`
+	Negative map[int32]uint64 `json:"negative,omitempty"`
+	Accuracy float64          `json:"accuracy"`
+	Zero     uint64           `json:"zero,omitempty"`
+	Count    uint64           `json:"count"`
+	Sum      float64          `json:"sum"`
+	Min      float64          `json:"min"`
+	Max      float64          `json:"max"`
+}
+
+// Quantile - estimated value of the quantile Q
+type Quantile struct {
+	Q     float64 `json:"q"`
+	Value float64 `json:"value"`
+}
+
+// DefaultQuantiles - quantiles returned when none are requested
+var DefaultQuantiles = []float64{0.5, 0.95, 0.99}
+
+// NewSummaryValue creates empty SummaryValue with provided relative accuracy.
+func NewSummaryValue(accuracy float64) *SummaryValue {
+	return &SummaryValue{
+		Positive: make(map[int32]uint64),
+		Negative: make(map[int32]uint64),
+		Accuracy: accuracy,
+	}
+}
+
+// Observe method adds the observation to the summary.
+func (s *SummaryValue) Observe(v float64) {
+	switch {
+	case v > minIndexableValue:
+		s.Positive[s.index(v)]++
+	case v < -minIndexableValue:
+		s.Negative[s.index(-v)]++
+	default:
+		s.Zero++
+	}
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+	s.Count++
+	s.Sum += v
+}
+
+// Validate method checks that accuracy is in (0, 1) range and counts are consistent with buckets.
+func (s *SummaryValue) Validate() error {
+	if math.IsNaN(s.Accuracy) || s.Accuracy <= 0 || s.Accuracy >= 1 {
+		return ErrInvalidSummary
+	}
+	total := s.Zero
+	for _, c := range s.Positive {
+		total += c
+	}
+	for _, c := range s.Negative {
+		total += c
+	}
+	if total != s.Count {
+		return ErrInvalidSummary
+	}
+	if s.Count > 0 && (math.IsNaN(s.Min) || math.IsNaN(s.Max) || s.Min > s.Max) {
+		return ErrInvalidSummary
+	}
+	return nil
+}
+
+// Merge method adds observations of another summary with the same accuracy.
+func (s *SummaryValue) Merge(o *SummaryValue) error {
+	if s.Accuracy != o.Accuracy {
+		return ErrSketchMismatch
+	}
+	if o.Count == 0 {
+		return nil
+	}
+	if s.Positive == nil {
+		s.Positive = make(map[int32]uint64)
+	}
+	if s.Negative == nil {
+		s.Negative = make(map[int32]uint64)
+	}
+	for k, c := range o.Positive {
+		s.Positive[k] += c
+	}
+	for k, c := range o.Negative {
+		s.Negative[k] += c
+	}
+	if s.Count == 0 || o.Min < s.Min {
+		s.Min = o.Min
+	}
+	if s.Count == 0 || o.Max > s.Max {
+		s.Max = o.Max
+	}
+	s.Zero += o.Zero
+	s.Count += o.Count
+	s.Sum += o.Sum
+	return nil
+}
+
+// Quantile method returns estimated value of the q quantile, 0 is returned for empty summary.
+func (s *SummaryValue) Quantile(q float64) (float64, error) {
+	if math.IsNaN(q) || q < 0 || q > 1 {
+		return 0, ErrInvalidQuantile
+	}
+	if s.Count == 0 {
+		return 0, nil
+	}
+
+	rank := uint64(q * float64(s.Count-1))
+	var seen uint64
+	negative := sortedKeys(s.Negative)
+	for i := len(negative) - 1; i >= 0; i-- {
+		seen += s.Negative[negative[i]]
+		if seen > rank {
+			return s.clamp(-s.value(negative[i])), nil
+		}
+	}
+	seen += s.Zero
+	if seen > rank {
+		return s.clamp(0), nil
+	}
+	for _, k := range sortedKeys(s.Positive) {
+		seen += s.Positive[k]
+		if seen > rank {
+			return s.clamp(s.value(k)), nil
+		}
+	}
+	return s.Max, nil
+}
+
+// Quantiles method returns estimated values of all provided quantiles.
+func (s *SummaryValue) Quantiles(qs []float64) ([]Quantile, error) {
+	res := make([]Quantile, 0, len(qs))
+	for _, q := range qs {
+		v, err := s.Quantile(q)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, Quantile{Q: q, Value: v})
+	}
+	return res, nil
+}
+
+// Copy method returns a deep copy of the summary.
+func (s *SummaryValue) Copy() *SummaryValue {
+	c := *s
+	c.Positive = make(map[int32]uint64, len(s.Positive))
+	for k, v := range s.Positive {
+		c.Positive[k] = v
+	}
+	c.Negative = make(map[int32]uint64, len(s.Negative))
+	for k, v := range s.Negative {
+		c.Negative[k] = v
+	}
+	return &c
+}
+
+func (s *SummaryValue) gamma() float64 {
+	return (1 + s.Accuracy) / (1 - s.Accuracy)
+}
+
+func (s *SummaryValue) index(v float64) int32 {
+	return int32(math.Ceil(math.Log(v) / math.Log(s.gamma())))
+}
+
+// value returns the estimate with the smallest relative error for every observation of the bucket.
+func (s *SummaryValue) value(index int32) float64 {
+	g := s.gamma()
+	return 2 * math.Pow(g, float64(index)) / (g + 1)
+}
+
+func (s *SummaryValue) clamp(v float64) float64 {
+	return math.Max(s.Min, math.Min(s.Max, v))
+}
+
+func sortedKeys(m map[int32]uint64) []int32 {
+	keys := make([]int32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummaryValue_Quantile(t *testing.T) {
+	s := NewSummaryValue(DefaultSummaryAccuracy)
+	for i := 1; i <= 1000; i++ {
+		s.Observe(float64(i))
+	}
+
+	tests := []struct {
+		name string
+		q    float64
+		want float64
+	}{
+		{name: "min", q: 0, want: 1},
+		{name: "p50", q: 0.5, want: 500},
+		{name: "p95", q: 0.95, want: 950},
+		{name: "p99", q: 0.99, want: 990},
+		{name: "max", q: 1, want: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Quantile(tt.q)
+			require.NoError(t, err)
+			assert.InEpsilon(t, tt.want, got, DefaultSummaryAccuracy)
+		})
+	}
+}
+
+func TestSummaryValue_QuantileSigned(t *testing.T) {
+	s := NewSummaryValue(0.02)
+	for _, v := range []float64{-100, -10, 0, 10, 100} {
+		s.Observe(v)
+	}
+	got, err := s.Quantiles([]float64{0, 0.25, 0.5, 0.75, 1})
+	require.NoError(t, err)
+	want := []float64{-100, -10, 0, 10, 100}
+	for i, q := range got {
+		assert.InDelta(t, want[i], q.Value, math.Abs(want[i])*0.02)
+	}
+
+	_, err = s.Quantile(1.1)
+	assert.ErrorIs(t, err, ErrInvalidQuantile)
+
+	v, err := NewSummaryValue(0.02).Quantile(0.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, v)
+}
+
+func TestSummaryValue_Merge(t *testing.T) {
+	a := NewSummaryValue(DefaultSummaryAccuracy)
+	b := NewSummaryValue(DefaultSummaryAccuracy)
+	all := NewSummaryValue(DefaultSummaryAccuracy)
+	for i := 1; i <= 100; i++ {
+		if i%2 == 0 {
+			a.Observe(float64(i))
+		} else {
+			b.Observe(float64(i))
+		}
+		all.Observe(float64(i))
+	}
+
+	require.NoError(t, a.Merge(b))
+	assert.Equal(t, all, a)
+	assert.NoError(t, a.Validate())
+
+	err := a.Merge(NewSummaryValue(0.05))
+	assert.ErrorIs(t, err, ErrSketchMismatch)
+}
+
+func TestSummaryValue_Validate(t *testing.T) {
+	tests := []struct {
+		value   *SummaryValue
+		name    string
+		wantErr bool
+	}{
+		{
+			name:    "ok",
+			value:   &SummaryValue{Accuracy: 0.01, Positive: map[int32]uint64{10: 2}, Zero: 1, Count: 3, Min: 0, Max: 1.2},
+			wantErr: false,
+		},
+		{
+			name:    "empty",
+			value:   &SummaryValue{Accuracy: 0.01},
+			wantErr: false,
+		},
+		{
+			name:    "wrong accuracy",
+			value:   &SummaryValue{Accuracy: 1},
+			wantErr: true,
+		},
+		{
+			name:    "wrong count",
+			value:   &SummaryValue{Accuracy: 0.01, Positive: map[int32]uint64{10: 2}, Count: 3, Max: 1.2},
+			wantErr: true,
+		},
+		{
+			name:    "min above max",
+			value:   &SummaryValue{Accuracy: 0.01, Zero: 1, Count: 1, Min: 1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.value.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSummary)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
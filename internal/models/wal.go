@@ -0,0 +1,24 @@
+package models
+
+const (
+	// WALUpdate - update of the metric value, counters are incremented, histograms and summaries are merged
+	WALUpdate = "update"
+
+	// WALSet - set of the counter value
+	WALSet = "set"
+
+	// WALDelete - removal of the metric
+	WALDelete = "delete"
+
+	// WALMetadata - registration of the metric metadata
+	WALMetadata = "metadata"
+)
+
+// WALRecord - single write of the storage in the write-ahead log.
+// Records are numbered by Seq, records already included in the snapshot are skipped on replay.
+type WALRecord struct {
+	Metric   *Metrics  `json:"metric,omitempty"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+	Op       string    `json:"op"`
+	Seq      uint64    `json:"seq"`
+}
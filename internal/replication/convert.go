@@ -0,0 +1,33 @@
+package replication
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/vindosVP/metrics/internal/convert"
+	"github.com/vindosVP/metrics/internal/events"
+	pb "github.com/vindosVP/metrics/internal/proto"
+)
+
+var pbOps = map[events.Op]pb.ChangeOp{
+	events.OpUpdate:   pb.ChangeOp_UPDATE,
+	events.OpSet:      pb.ChangeOp_SET,
+	events.OpDelete:   pb.ChangeOp_DELETE,
+	events.OpMetadata: pb.ChangeOp_METADATA,
+}
+
+func pbChange(e *events.Event) *pb.Change {
+	c := &pb.Change{
+		Seq:     e.Seq,
+		Tenant:  e.Tenant,
+		Op:      pbOps[e.Op],
+		Metrics: make([]*pb.Metric, 0, len(e.Metrics)),
+		Time:    timestamppb.New(e.Time),
+	}
+	for _, m := range e.Metrics {
+		c.Metrics = append(c.Metrics, convert.PBMetric(m))
+	}
+	if e.Metadata != nil {
+		c.Metadata = convert.PBMetadata(e.Metadata)
+	}
+	return c
+}
@@ -0,0 +1,256 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/vindosVP/metrics/internal/convert"
+	"github.com/vindosVP/metrics/internal/models"
+	pb "github.com/vindosVP/metrics/internal/proto"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/internal/webhooks"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+const (
+	// reconnectDelay - pause before syncing again after the stream from the leader failed
+	reconnectDelay = time.Second
+	// webhooksInterval - interval of copying webhook subscriptions of the leader
+	webhooksInterval = 10 * time.Second
+)
+
+// Follower applies snapshots and changes streamed by the leader to the storage until it's promoted.
+type Follower struct {
+	s       MetricsStorage
+	tenants TenantStorage
+	hooks   WebhookRegistry
+	node    *Node
+	addr    string
+	key     string
+	delay   time.Duration
+	every   time.Duration
+}
+
+// NewFollower creates Follower of the leader listening on the gRPC address,
+// calls to the leader are signed with the admin key. Webhook subscriptions of the leader are copied to the registry,
+// so they are kept when the server is promoted.
+func NewFollower(s MetricsStorage, tenants TenantStorage, hooks WebhookRegistry, node *Node, addr string, key string) *Follower {
+	return &Follower{
+		s:       s,
+		tenants: tenants,
+		hooks:   hooks,
+		node:    node,
+		addr:    addr,
+		key:     key,
+		delay:   reconnectDelay,
+		every:   webhooksInterval,
+	}
+}
+
+// Run method syncs with the leader, reconnecting if the stream fails, until the context is done or the server is promoted.
+func (f *Follower) Run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-f.node.Promoted():
+			logger.Log.Info("Promoted to the replication leader, stopping following", zap.String("leader", f.addr))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		err := f.sync(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Log.Error("Replication stream failed, reconnecting", zap.String("leader", f.addr), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(f.delay):
+		}
+	}
+}
+
+// sync receives snapshots and then changes from the leader until the stream fails.
+func (f *Follower) sync(ctx context.Context) error {
+	conn, err := grpc.NewClient(f.addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("failed to connect to the leader: %w", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	client := pb.NewReplicationClient(conn)
+	stream, err := client.Sync(f.outgoing(ctx), &pb.SyncRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to start sync: %w", err)
+	}
+	go f.syncWebhooks(ctx, client)
+
+	// sequence numbers of snapshots, changes up to them are already applied
+	seqs := make(map[string]uint64)
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		switch m := resp.Message.(type) {
+		case *pb.SyncResponse_Snapshot:
+			if err = f.restore(ctx, m.Snapshot); err != nil {
+				return fmt.Errorf("failed to restore snapshot of tenant %s: %w", m.Snapshot.Tenant, err)
+			}
+			seqs[m.Snapshot.Tenant] = m.Snapshot.Seq
+		case *pb.SyncResponse_SnapshotDone:
+			if err = f.clear(ctx, seqs); err != nil {
+				return err
+			}
+			logger.Log.Info("Synced from leader snapshots", zap.String("leader", f.addr), zap.Int("tenants", len(seqs)))
+		case *pb.SyncResponse_Change:
+			if m.Change.Seq <= seqs[m.Change.Tenant] {
+				continue
+			}
+			if err = f.apply(ctx, m.Change); err != nil {
+				return fmt.Errorf("failed to apply change %d: %w", m.Change.Seq, err)
+			}
+		}
+	}
+}
+
+// syncWebhooks copies webhook subscriptions of the leader to the registry on the interval until the context is done.
+func (f *Follower) syncWebhooks(ctx context.Context, client pb.ReplicationClient) {
+	ticker := time.NewTicker(f.every)
+	defer ticker.Stop()
+	for {
+		resp, err := client.Webhooks(f.outgoing(ctx), &pb.WebhooksRequest{})
+		if err == nil {
+			subs := make([]*webhooks.Subscription, 0, len(resp.Webhooks))
+			for _, w := range resp.Webhooks {
+				subs = append(subs, convert.Webhook(w))
+			}
+			f.hooks.Replace(subs)
+		} else if ctx.Err() == nil {
+			logger.Log.Error("Failed to copy webhooks of the leader", zap.String("leader", f.addr), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// outgoing returns the context of the call to the leader signed with the admin key.
+func (f *Follower) outgoing(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, tenant.HashMetadataKey, tenant.Sign("", f.key))
+}
+
+// restore replaces metrics of the tenant with the ones of the snapshot.
+// Histograms and summaries are merged on update, so they are deleted before being written.
+func (f *Follower) restore(ctx context.Context, snapshot *pb.TenantSnapshot) error {
+	ctx = withReplicated(tenant.WithTenant(ctx, snapshot.Tenant))
+	current, _, err := read(ctx, f.s)
+	if err != nil {
+		return err
+	}
+	metrics, err := convert.Metrics(pb.ChangeOp_UPDATE, snapshot.Metrics)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(metrics))
+	batch := make([]*models.Metrics, 0, len(metrics))
+	counters := make([]*models.Metrics, 0)
+	for _, m := range metrics {
+		if m.MType == models.Counter {
+			counters = append(counters, m)
+		} else {
+			batch = append(batch, m)
+		}
+		if m.MType == models.Counter || m.MType == models.Gauge {
+			keep[m.MType+m.ID] = true
+		}
+	}
+	stale := make([]*models.Metrics, 0)
+	for _, m := range current {
+		if !keep[m.MType+m.ID] {
+			stale = append(stale, &models.Metrics{ID: m.ID, MType: m.MType})
+		}
+	}
+
+	if len(stale) > 0 {
+		if err = f.s.DeleteBatch(ctx, stale); err != nil {
+			return err
+		}
+	}
+	if len(batch) > 0 {
+		if err = f.s.InsertBatch(ctx, batch); err != nil {
+			return err
+		}
+	}
+	for _, m := range counters {
+		if _, err = f.s.SetCounter(ctx, m.ID, *m.Delta); err != nil {
+			return err
+		}
+	}
+	for _, meta := range snapshot.Metadata {
+		if err = f.s.RegisterMetadata(ctx, convert.Metadata(meta)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clear deletes metrics of tenants the leader sent no snapshots of.
+func (f *Follower) clear(ctx context.Context, synced map[string]uint64) error {
+	for _, id := range f.tenants.Tenants() {
+		if _, ok := synced[id]; ok {
+			continue
+		}
+		tctx := withReplicated(tenant.WithTenant(ctx, id))
+		current, _, err := read(tctx, f.s)
+		if err != nil {
+			return fmt.Errorf("failed to read metrics of tenant %s: %w", id, err)
+		}
+		if len(current) == 0 {
+			continue
+		}
+		if err = f.s.DeleteBatch(tctx, current); err != nil {
+			return fmt.Errorf("failed to delete metrics of tenant %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// apply applies the change of the leader to the storage.
+func (f *Follower) apply(ctx context.Context, change *pb.Change) error {
+	ctx = withReplicated(tenant.WithTenant(ctx, change.Tenant))
+	metrics, err := convert.Metrics(change.Op, change.Metrics)
+	if err != nil {
+		return err
+	}
+	switch change.Op {
+	case pb.ChangeOp_UPDATE:
+		return f.s.InsertBatch(ctx, metrics)
+	case pb.ChangeOp_SET:
+		for _, m := range metrics {
+			if _, err := f.s.SetCounter(ctx, m.ID, *m.Delta); err != nil {
+				return err
+			}
+		}
+	case pb.ChangeOp_DELETE:
+		return f.s.DeleteBatch(ctx, metrics)
+	case pb.ChangeOp_METADATA:
+		if change.Metadata != nil {
+			return f.s.RegisterMetadata(ctx, convert.Metadata(change.Metadata))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/vindosVP/metrics/internal/convert"
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/models"
+	pb "github.com/vindosVP/metrics/internal/proto"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/internal/webhooks"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// syncBuffer - number of changes kept for the follower while it's receiving snapshots or applying changes,
+// the follower which falls further behind is disconnected and syncs again from snapshots
+const syncBuffer = 10000
+
+// MetricsStorage consists methods to save and get data from the storage
+type MetricsStorage interface {
+	UpdateGauge(ctx context.Context, name string, v float64) (float64, error)
+	UpdateCounter(ctx context.Context, name string, v int64) (int64, error)
+	SetCounter(ctx context.Context, name string, v int64) (int64, error)
+	GetGauge(ctx context.Context, name string) (float64, error)
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetCounter(ctx context.Context, name string) (int64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// SnapshotStorage is the storage publishing its changes,
+// it reads the state of the tenant consistent with the sequence number of published changes.
+type SnapshotStorage interface {
+	MetricsStorage
+	Snapshot(ctx context.Context, f func(seq uint64) error) error
+}
+
+// TenantStorage returns tenants with opened storages.
+type TenantStorage interface {
+	Tenants() []string
+}
+
+// WebhookRegistry keeps webhook subscriptions of all tenants
+type WebhookRegistry interface {
+	All() []*webhooks.Subscription
+	Replace(subs []*webhooks.Subscription)
+}
+
+// Server streams snapshots and changes of the leader to followers.
+type Server struct {
+	pb.UnimplementedReplicationServer
+	s       SnapshotStorage
+	tenants TenantStorage
+	hooks   WebhookRegistry
+	bus     *events.Bus
+	node    *Node
+	buffer  int
+}
+
+// NewServer creates Server, followers copy webhook subscriptions of the registry.
+func NewServer(s SnapshotStorage, tenants TenantStorage, hooks WebhookRegistry, bus *events.Bus, node *Node) *Server {
+	return &Server{
+		s:       s,
+		tenants: tenants,
+		hooks:   hooks,
+		bus:     bus,
+		node:    node,
+		buffer:  syncBuffer,
+	}
+}
+
+// Sync method sends snapshots of storages of all tenants and then every change published after the subscription.
+// Changes with sequence numbers not greater than the one of the tenant snapshot are already included in it.
+func (s *Server) Sync(_ *pb.SyncRequest, stream pb.Replication_SyncServer) error {
+	if !s.node.Leader() {
+		return status.Error(codes.FailedPrecondition, "server is not the replication leader")
+	}
+	ctx := stream.Context()
+	sub := s.bus.Subscribe(s.buffer)
+	defer sub.Close()
+
+	for _, id := range s.tenants.Tenants() {
+		snapshot, err := s.snapshot(tenant.WithTenant(ctx, id))
+		if err != nil {
+			logger.Log.Error("Failed to read replication snapshot", zap.String("tenant", id), zap.Error(err))
+			return status.Errorf(codes.Internal, "failed to read snapshot of tenant %s: %s", id, err)
+		}
+		err = stream.Send(&pb.SyncResponse{Message: &pb.SyncResponse_Snapshot{Snapshot: snapshot}})
+		if err != nil {
+			return err
+		}
+	}
+	if err := stream.Send(&pb.SyncResponse{Message: &pb.SyncResponse_SnapshotDone{SnapshotDone: true}}); err != nil {
+		return err
+	}
+	logger.Log.Info("Follower synced from snapshots, streaming changes")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-sub.Events():
+			if !ok {
+				return status.Errorf(codes.Aborted, "failed to stream changes: %s", sub.Err())
+			}
+			err := stream.Send(&pb.SyncResponse{Message: &pb.SyncResponse_Change{Change: pbChange(e)}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// snapshot reads all metrics and metadata of the tenant of the context.
+// Webhooks method returns webhook subscriptions of all tenants with their secrets.
+func (s *Server) Webhooks(_ context.Context, _ *pb.WebhooksRequest) (*pb.WebhooksResponse, error) {
+	all := s.hooks.All()
+	resp := &pb.WebhooksResponse{Webhooks: make([]*pb.Webhook, 0, len(all))}
+	for _, sub := range all {
+		resp.Webhooks = append(resp.Webhooks, convert.PBWebhook(sub))
+	}
+	return resp, nil
+}
+
+func (s *Server) snapshot(ctx context.Context) (*pb.TenantSnapshot, error) {
+	snapshot := &pb.TenantSnapshot{Tenant: tenant.FromContext(ctx)}
+	err := s.s.Snapshot(ctx, func(seq uint64) error {
+		snapshot.Seq = seq
+		metrics, metadata, err := read(ctx, s.s)
+		if err != nil {
+			return err
+		}
+		for _, m := range metrics {
+			snapshot.Metrics = append(snapshot.Metrics, convert.PBMetric(m))
+		}
+		for _, meta := range metadata {
+			snapshot.Metadata = append(snapshot.Metadata, convert.PBMetadata(meta))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// read returns all metrics keyed by their series keys and all metadata of the storage.
+func read(ctx context.Context, s MetricsStorage) ([]*models.Metrics, []*models.Metadata, error) {
+	metrics := make([]*models.Metrics, 0)
+	gauges, err := s.GetAllGauge(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get gauges: %w", err)
+	}
+	for key, v := range gauges {
+		value := v
+		metrics = append(metrics, &models.Metrics{ID: key, MType: models.Gauge, Value: &value})
+	}
+	counters, err := s.GetAllCounter(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get counters: %w", err)
+	}
+	for key, v := range counters {
+		delta := v
+		metrics = append(metrics, &models.Metrics{ID: key, MType: models.Counter, Delta: &delta})
+	}
+	histograms, err := s.GetAllHistogram(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get histograms: %w", err)
+	}
+	for key, v := range histograms {
+		metrics = append(metrics, &models.Metrics{ID: key, MType: models.Histogram, Histogram: v})
+	}
+	summaries, err := s.GetAllSummary(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get summaries: %w", err)
+	}
+	for key, v := range summaries {
+		metrics = append(metrics, &models.Metrics{ID: key, MType: models.Summary, Summary: v})
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].MType != metrics[j].MType {
+			return metrics[i].MType < metrics[j].MType
+		}
+		return metrics[i].ID < metrics[j].ID
+	})
+
+	all, err := s.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	metadata := make([]*models.Metadata, 0, len(all))
+	for _, meta := range all {
+		metadata = append(metadata, meta)
+	}
+	sort.Slice(metadata, func(i, j int) bool { return metadata[i].ID < metadata[j].ID })
+	return metrics, metadata, nil
+}
@@ -0,0 +1,71 @@
+// Package replication streams changes applied by the leader server to its followers over gRPC.
+//
+// A follower receives snapshots of storages of all tenants followed by every change published after them,
+// so it catches up after a restart or a dropped stream. Followers serve reads only until promoted to the leader.
+package replication
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vindosVP/metrics/internal/storage"
+)
+
+type ctxKey struct{}
+
+// Node is the replication role of the server, a follower becomes the leader on promotion.
+type Node struct {
+	promoted chan struct{}
+	once     sync.Once
+	leader   atomic.Bool
+}
+
+// NewNode creates Node.
+func NewNode(leader bool) *Node {
+	n := &Node{promoted: make(chan struct{})}
+	if leader {
+		n.Promote()
+	}
+	return n
+}
+
+// Leader method reports whether the server is the leader.
+func (n *Node) Leader() bool {
+	return n.leader.Load()
+}
+
+// Promote method makes the follower the leader, it reports false if the server already is the leader.
+func (n *Node) Promote() bool {
+	promoted := false
+	n.once.Do(func() {
+		n.leader.Store(true)
+		close(n.promoted)
+		promoted = true
+	})
+	return promoted
+}
+
+// Promoted method returns the channel closed when the server becomes the leader.
+func (n *Node) Promoted() <-chan struct{} {
+	return n.promoted
+}
+
+// Guard method returns storage.ErrReadOnly for changes made on the follower,
+// except the ones replicated from the leader.
+func (n *Node) Guard(ctx context.Context) error {
+	if n.Leader() || replicated(ctx) {
+		return nil
+	}
+	return storage.ErrReadOnly
+}
+
+// withReplicated returns the context of changes replicated from the leader.
+func withReplicated(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey{}, true)
+}
+
+func replicated(ctx context.Context) bool {
+	v, _ := ctx.Value(ctxKey{}).(bool)
+	return v
+}
@@ -0,0 +1,217 @@
+package replication
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/models"
+	pb "github.com/vindosVP/metrics/internal/proto"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/eventstorage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/storage/tenantstorage"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/internal/webhooks"
+)
+
+type server struct {
+	s       *eventstorage.Storage
+	tenants *tenantstorage.Storage
+	hooks   *webhooks.Registry
+	bus     *events.Bus
+	node    *Node
+}
+
+func newServer(leader bool) *server {
+	tenants := tenantstorage.New(func(string) (tenantstorage.MetricsStorage, error) {
+		return memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo(), memstorage.WithHistory(repos.NewHistoryRepo(0))), nil
+	})
+	bus := events.NewBus()
+	node := NewNode(leader)
+	return &server{
+		s:       eventstorage.New(tenants, bus, eventstorage.WithGuard(node.Guard)),
+		tenants: tenants,
+		hooks:   webhooks.NewRegistry(webhooks.WithGuard(node.Guard)),
+		bus:     bus,
+		node:    node,
+	}
+}
+
+// serve starts the replication service of the server and returns its address.
+func (s *server) serve(t *testing.T) string {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	g := grpc.NewServer()
+	pb.RegisterReplicationServer(g, NewServer(s.s, s.tenants, s.hooks, s.bus, s.node))
+	go func() {
+		_ = g.Serve(listen)
+	}()
+	t.Cleanup(g.Stop)
+	return listen.Addr().String()
+}
+
+func (s *server) state(t *testing.T, id string) ([]*models.Metrics, []*models.Metadata) {
+	metrics, metadata, err := read(tenant.WithTenant(context.Background(), id), s.s)
+	require.NoError(t, err)
+	return metrics, metadata
+}
+
+func TestReplication(t *testing.T) {
+	ctx := context.Background()
+	teamA := tenant.WithTenant(ctx, "team-a")
+	leader := newServer(true)
+	addr := leader.serve(t)
+
+	_, err := leader.s.UpdateCounter(ctx, "PollCount", 5)
+	require.NoError(t, err)
+	_, err = leader.s.UpdateGauge(teamA, "Alloc", 1.5)
+	require.NoError(t, err)
+	_, err = leader.s.UpdateHistogram(ctx, "Latency", &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1, Sum: 0.5})
+	require.NoError(t, err)
+	require.NoError(t, leader.s.RegisterMetadata(ctx, &models.Metadata{ID: "PollCount", MType: models.Counter, Help: "polls"}))
+	_, err = leader.hooks.Add(teamA, &webhooks.Subscription{URL: "http://localhost/hook", Pattern: "gauge/*", Secret: "s"})
+	require.NoError(t, err)
+
+	follower := newServer(false)
+	// stale metrics of the follower are replaced by the snapshot
+	_, err = follower.tenants.UpdateGauge(ctx, "Stale", 1)
+	require.NoError(t, err)
+	_, err = follower.tenants.UpdateHistogram(ctx, "Latency", &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{0, 3}, Count: 3, Sum: 9})
+	require.NoError(t, err)
+	_, err = follower.tenants.UpdateCounter(tenant.WithTenant(ctx, "team-b"), "Gone", 1)
+	require.NoError(t, err)
+
+	f := NewFollower(follower.s, follower.tenants, follower.hooks, follower.node, addr, "")
+	f.delay = 10 * time.Millisecond
+	f.every = 10 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		f.Run(ctx)
+		close(done)
+	}()
+
+	synced := func(id string) func() bool {
+		return func() bool {
+			lm, lmeta := leader.state(t, id)
+			fm, fmeta := follower.state(t, id)
+			return assert.ObjectsAreEqual(lm, fm) && assert.ObjectsAreEqual(lmeta, fmeta)
+		}
+	}
+	require.Eventually(t, synced(tenant.Default), time.Second, 10*time.Millisecond)
+	require.Eventually(t, synced("team-a"), time.Second, 10*time.Millisecond)
+	require.Eventually(t, synced("team-b"), time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(leader.hooks.All(), follower.hooks.All())
+	}, time.Second, 10*time.Millisecond)
+
+	// subscriptions are changed on the leader only
+	_, err = follower.hooks.Add(ctx, &webhooks.Subscription{URL: "http://localhost/hook", Pattern: "*"})
+	assert.ErrorIs(t, err, storage.ErrReadOnly)
+
+	// changes after the snapshot are streamed
+	_, err = leader.s.UpdateCounter(ctx, "PollCount", 2)
+	require.NoError(t, err)
+	_, err = leader.s.SetCounter(teamA, "Resets", 7)
+	require.NoError(t, err)
+	value := 2.5
+	require.NoError(t, leader.s.InsertBatch(teamA, []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &value, Labels: map[string]string{"host": "a"}},
+		{ID: "Size", MType: models.Summary, Observations: []float64{1, 2, 3}},
+	}))
+	require.NoError(t, leader.s.Delete(ctx, models.Histogram, "Latency"))
+	require.Eventually(t, synced(tenant.Default), time.Second, 10*time.Millisecond)
+	require.Eventually(t, synced("team-a"), time.Second, 10*time.Millisecond)
+	v, err := follower.s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), v)
+
+	// the follower serves reads only until promoted
+	_, err = follower.s.UpdateGauge(ctx, "Alloc", 1)
+	assert.ErrorIs(t, err, storage.ErrReadOnly)
+	assert.True(t, follower.node.Promote())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("follower didn't stop on promotion")
+	}
+	_, err = follower.s.UpdateGauge(ctx, "Alloc", 1)
+	assert.NoError(t, err)
+	// subscriptions copied from the leader are kept after promotion
+	assert.Len(t, follower.hooks.List(teamA), 1)
+}
+
+func TestServer_SyncFollower(t *testing.T) {
+	follower := newServer(false)
+	addr := follower.serve(t)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	stream, err := pb.NewReplicationClient(conn).Sync(context.Background(), &pb.SyncRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestServer_SyncLagged(t *testing.T) {
+	leader := newServer(true)
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	g := grpc.NewServer()
+	srv := NewServer(leader.s, leader.tenants, leader.hooks, leader.bus, leader.node)
+	srv.buffer = 1
+	pb.RegisterReplicationServer(g, srv)
+	go func() {
+		_ = g.Serve(listen)
+	}()
+	defer g.Stop()
+
+	conn, err := grpc.NewClient(listen.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	stream, err := pb.NewReplicationClient(conn).Sync(context.Background(), &pb.SyncRequest{})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.True(t, resp.GetSnapshotDone())
+
+	// changes published faster than they are sent drop the follower
+	for i := 0; i < 20000; i++ {
+		_, err = leader.s.UpdateCounter(context.Background(), "PollCount", 1)
+		require.NoError(t, err)
+	}
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestNode(t *testing.T) {
+	ctx := context.Background()
+	leader := NewNode(true)
+	assert.True(t, leader.Leader())
+	assert.NoError(t, leader.Guard(ctx))
+	assert.False(t, leader.Promote())
+
+	follower := NewNode(false)
+	assert.False(t, follower.Leader())
+	assert.ErrorIs(t, follower.Guard(ctx), storage.ErrReadOnly)
+	assert.NoError(t, follower.Guard(withReplicated(ctx)))
+	assert.True(t, follower.Promote())
+	<-follower.Promoted()
+	assert.NoError(t, follower.Guard(ctx))
+}
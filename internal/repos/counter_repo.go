@@ -2,66 +2,56 @@ package repos
 
 import (
 	"context"
-	"sync"
+	"sync/atomic"
 )
 
 // CounterRepo - repository to store metrics with counter type.
+// Metrics are sharded by name and kept in atomic values,
+// so reads never block writers and updates of different metrics run in parallel.
 type CounterRepo struct {
-	metrics map[string]int64
-	sync.Mutex
+	metrics *shardedMap[atomic.Int64]
 }
 
 // NewCounterRepo creates CounterRepo.
 func NewCounterRepo() *CounterRepo {
-	return &CounterRepo{metrics: make(map[string]int64)}
+	return &CounterRepo{metrics: newShardedMap[atomic.Int64]()}
 }
 
 // Update method updates metric with a new value.
 // new value adds to the old one.
 func (c *CounterRepo) Update(_ context.Context, name string, v int64) (int64, error) {
-	c.Lock()
-	currentV, ok := c.metrics[name]
-
-	var newV int64
-	if ok {
-		newV = currentV + v
-	} else {
-		newV = v
-	}
-	c.metrics[name] = newV
-	c.Unlock()
-	return newV, nil
-
+	return c.metrics.loadOrStore(name).Add(v), nil
 }
 
 // Get method returns counter metric value
 func (c *CounterRepo) Get(_ context.Context, name string) (int64, error) {
-	c.Lock()
-	v, ok := c.metrics[name]
+	v, ok := c.metrics.load(name)
 	if !ok {
-		c.Unlock()
 		return 0, ErrMetricNotRegistered
 	}
-	c.Unlock()
-	return v, nil
+	return v.Load(), nil
 }
 
 // GetAll method returns values for all collected counter metrics
 func (c *CounterRepo) GetAll(_ context.Context) (map[string]int64, error) {
-	c.Lock()
-	metrics := make(map[string]int64, len(c.metrics))
-	for key, val := range c.metrics {
-		metrics[key] = val
-	}
-	c.Unlock()
+	metrics := make(map[string]int64, c.metrics.len())
+	c.metrics.rangeAll(func(name string, v *atomic.Int64) {
+		metrics[name] = v.Load()
+	})
 	return metrics, nil
 }
 
 // Set method sets metric value to a new one.
 // new value replaces the old one.
 func (c *CounterRepo) Set(_ context.Context, name string, v int64) (int64, error) {
-	c.Lock()
-	c.metrics[name] = v
-	c.Unlock()
-	return c.metrics[name], nil
+	c.metrics.loadOrStore(name).Store(v)
+	return v, nil
+}
+
+// Delete method removes counter metric.
+func (c *CounterRepo) Delete(_ context.Context, name string) error {
+	if !c.metrics.delete(name) {
+		return ErrMetricNotRegistered
+	}
+	return nil
 }
@@ -3,6 +3,7 @@ package repos
 import (
 	"context"
 	"math/rand"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -58,6 +59,61 @@ func BenchmarkCounterRepo_GetAll(b *testing.B) {
 	}
 }
 
+func BenchmarkCounterRepo_UpdateParallel(b *testing.B) {
+	c := NewCounterRepo()
+	ctx := context.Background()
+	names := make([]string, 100)
+	for i := range names {
+		names[i] = RandStringRunes(10)
+	}
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(names))
+		for pb.Next() {
+			c.Update(ctx, names[i%len(names)], 1)
+			i++
+		}
+	})
+}
+
+func BenchmarkCounterRepo_GetParallel(b *testing.B) {
+	c := NewCounterRepo()
+	ctx := context.Background()
+	c.Update(ctx, "Name", rand.Int63())
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Get(ctx, "Name")
+		}
+	})
+}
+
+// BenchmarkCounterRepo_Mixed updates metrics while GetAll is being called concurrently.
+func BenchmarkCounterRepo_Mixed(b *testing.B) {
+	c := NewCounterRepo()
+	ctx := context.Background()
+	names := make([]string, 100)
+	for i := range names {
+		names[i] = RandStringRunes(10)
+		c.Update(ctx, names[i], rand.Int63())
+	}
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(names))
+		for pb.Next() {
+			if i%100 == 0 {
+				c.GetAll(ctx)
+			} else {
+				c.Update(ctx, names[i%len(names)], 1)
+			}
+			i++
+		}
+	})
+}
+
 func TestCounterRepo_Update(t *testing.T) {
 	tests := []struct {
 		errValue        error
@@ -111,14 +167,14 @@ func TestCounterRepo_Update(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := &CounterRepo{metrics: tt.existingMetrics}
+			repo := counterRepoWith(tt.existingMetrics)
 			val, err := repo.Update(context.Background(), tt.metricName, tt.metricValue)
 			if tt.wantErr {
 				assert.ErrorIs(t, err, tt.errValue)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.wantValue, val)
-				assert.Equal(t, repo.metrics[tt.metricName], tt.wantValue)
+				assert.Equal(t, tt.wantValue, counterValue(repo, tt.metricName))
 			}
 		})
 	}
@@ -178,14 +234,14 @@ func TestCounterRepo_Set(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := &CounterRepo{metrics: tt.existingMetrics}
+			repo := counterRepoWith(tt.existingMetrics)
 			val, err := repo.Set(context.Background(), tt.metricName, tt.metricValue)
 			if tt.wantErr {
 				assert.ErrorIs(t, err, tt.errValue)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.wantValue, val)
-				assert.Equal(t, repo.metrics[tt.metricName], tt.wantValue)
+				assert.Equal(t, tt.wantValue, counterValue(repo, tt.metricName))
 			}
 		})
 	}
@@ -220,7 +276,7 @@ func TestCounterRepo_Get(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := &CounterRepo{metrics: tt.existingMetrics}
+			repo := counterRepoWith(tt.existingMetrics)
 			val, err := repo.Get(context.Background(), tt.metricName)
 			if tt.wantErr {
 				assert.ErrorIs(t, err, tt.errValue)
@@ -256,9 +312,7 @@ func TestCounterRepo_GetAll(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := CounterRepo{
-				metrics: tt.metrics,
-			}
+			c := counterRepoWith(tt.metrics)
 			got, err := c.GetAll(context.Background())
 			require.NoError(t, err)
 			assert.Equal(t, tt.want, got)
@@ -273,3 +327,56 @@ func RandStringRunes(n int) string {
 	}
 	return string(b)
 }
+
+func TestCounterRepo_Delete(t *testing.T) {
+	repo := counterRepoWith(map[string]int64{"PollCount": 1})
+	ctx := context.Background()
+
+	require.NoError(t, repo.Delete(ctx, "PollCount"))
+	_, err := repo.Get(ctx, "PollCount")
+	assert.ErrorIs(t, err, ErrMetricNotRegistered)
+	assert.ErrorIs(t, repo.Delete(ctx, "PollCount"), ErrMetricNotRegistered)
+}
+
+func counterRepoWith(metrics map[string]int64) *CounterRepo {
+	c := NewCounterRepo()
+	for name, v := range metrics {
+		c.metrics.loadOrStore(name).Store(v)
+	}
+	return c
+}
+
+func counterValue(c *CounterRepo, name string) int64 {
+	v, ok := c.metrics.load(name)
+	if !ok {
+		return 0
+	}
+	return v.Load()
+}
+
+func TestCounterRepo_ConcurrentUpdate(t *testing.T) {
+	c := NewCounterRepo()
+	ctx := context.Background()
+	names := []string{"PollCount", "Requests", "Errors"}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				for _, name := range names {
+					_, err := c.Update(ctx, name, 1)
+					assert.NoError(t, err)
+				}
+				_, err := c.GetAll(ctx)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := c.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 8000, "Requests": 8000, "Errors": 8000}, got)
+}
@@ -2,49 +2,51 @@ package repos
 
 import (
 	"context"
-	"sync"
+	"math"
+	"sync/atomic"
 )
 
 // GaugeRepo - repository to store metrics with gauge type.
+// Metrics are sharded by name and kept as bits of float64 in atomic values,
+// so reads never block writers and updates of different metrics run in parallel.
 type GaugeRepo struct {
-	metrics map[string]float64
-	sync.Mutex
+	metrics *shardedMap[atomic.Uint64]
 }
 
 // NewGaugeRepo created the GaugeRepo
 func NewGaugeRepo() *GaugeRepo {
-	return &GaugeRepo{metrics: make(map[string]float64)}
+	return &GaugeRepo{metrics: newShardedMap[atomic.Uint64]()}
 }
 
 // Update method updated value of gauge metric.
 // new value replaces the old one.
 func (g *GaugeRepo) Update(_ context.Context, name string, v float64) (float64, error) {
-	g.Lock()
-	g.metrics[name] = v
-	cVal := g.metrics[name]
-	g.Unlock()
-	return cVal, nil
+	g.metrics.loadOrStore(name).Store(math.Float64bits(v))
+	return v, nil
 }
 
 // Get method returns gauge metric value
 func (g *GaugeRepo) Get(_ context.Context, name string) (float64, error) {
-	g.Lock()
-	v, ok := g.metrics[name]
+	v, ok := g.metrics.load(name)
 	if !ok {
-		g.Unlock()
 		return 0, ErrMetricNotRegistered
 	}
-	g.Unlock()
-	return v, nil
+	return math.Float64frombits(v.Load()), nil
 }
 
 // GetAll method returns values of all collected gauge metrics
 func (g *GaugeRepo) GetAll(_ context.Context) (map[string]float64, error) {
-	g.Lock()
-	metrics := make(map[string]float64, len(g.metrics))
-	for key, val := range g.metrics {
-		metrics[key] = val
-	}
-	g.Unlock()
+	metrics := make(map[string]float64, g.metrics.len())
+	g.metrics.rangeAll(func(name string, v *atomic.Uint64) {
+		metrics[name] = math.Float64frombits(v.Load())
+	})
 	return metrics, nil
 }
+
+// Delete method removes gauge metric.
+func (g *GaugeRepo) Delete(_ context.Context, name string) error {
+	if !g.metrics.delete(name) {
+		return ErrMetricNotRegistered
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package repos
 
 import (
 	"context"
+	"math"
 	"math/rand"
 	"testing"
 
@@ -45,6 +46,61 @@ func BenchmarkGaugeRepo_GetAll(b *testing.B) {
 	}
 }
 
+func BenchmarkGaugeRepo_UpdateParallel(b *testing.B) {
+	g := NewGaugeRepo()
+	ctx := context.Background()
+	names := make([]string, 100)
+	for i := range names {
+		names[i] = RandStringRunes(10)
+	}
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(names))
+		for pb.Next() {
+			g.Update(ctx, names[i%len(names)], rand.Float64())
+			i++
+		}
+	})
+}
+
+func BenchmarkGaugeRepo_GetParallel(b *testing.B) {
+	g := NewGaugeRepo()
+	ctx := context.Background()
+	g.Update(ctx, "Name", rand.Float64())
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Get(ctx, "Name")
+		}
+	})
+}
+
+// BenchmarkGaugeRepo_Mixed updates metrics while GetAll is being called concurrently.
+func BenchmarkGaugeRepo_Mixed(b *testing.B) {
+	g := NewGaugeRepo()
+	ctx := context.Background()
+	names := make([]string, 100)
+	for i := range names {
+		names[i] = RandStringRunes(10)
+		g.Update(ctx, names[i], rand.Float64())
+	}
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(names))
+		for pb.Next() {
+			if i%100 == 0 {
+				g.GetAll(ctx)
+			} else {
+				g.Update(ctx, names[i%len(names)], rand.Float64())
+			}
+			i++
+		}
+	})
+}
+
 func TestGaugeRepo_Update(t *testing.T) {
 	tests := []struct {
 		errValue        error
@@ -89,10 +145,10 @@ func TestGaugeRepo_Update(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := &GaugeRepo{metrics: tt.existingMetrics}
+			repo := gaugeRepoWith(tt.existingMetrics)
 			val, err := repo.Update(context.Background(), tt.metricName, tt.metricValue)
 			assert.Equal(t, tt.wantValue, val)
-			assert.Equal(t, repo.metrics[tt.metricName], tt.wantValue)
+			assert.Equal(t, tt.wantValue, gaugeValue(repo, tt.metricName))
 			if tt.wantErr {
 				assert.ErrorIs(t, err, tt.errValue)
 			} else {
@@ -131,7 +187,7 @@ func TestGaugeRepo_Get(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := &GaugeRepo{metrics: tt.existingMetrics}
+			repo := gaugeRepoWith(tt.existingMetrics)
 			val, err := repo.Get(context.Background(), tt.metricName)
 			if tt.wantErr {
 				assert.ErrorIs(t, err, tt.errValue)
@@ -167,12 +223,36 @@ func TestGaugeRepo_GetAll(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			g := GaugeRepo{
-				metrics: tt.metrics,
-			}
+			g := gaugeRepoWith(tt.metrics)
 			got, err := g.GetAll(context.Background())
 			require.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func TestGaugeRepo_Delete(t *testing.T) {
+	repo := gaugeRepoWith(map[string]float64{"Alloc": 1.5})
+	ctx := context.Background()
+
+	require.NoError(t, repo.Delete(ctx, "Alloc"))
+	_, err := repo.Get(ctx, "Alloc")
+	assert.ErrorIs(t, err, ErrMetricNotRegistered)
+	assert.ErrorIs(t, repo.Delete(ctx, "Alloc"), ErrMetricNotRegistered)
+}
+
+func gaugeRepoWith(metrics map[string]float64) *GaugeRepo {
+	g := NewGaugeRepo()
+	for name, v := range metrics {
+		g.metrics.loadOrStore(name).Store(math.Float64bits(v))
+	}
+	return g
+}
+
+func gaugeValue(g *GaugeRepo, name string) float64 {
+	v, ok := g.metrics.load(name)
+	if !ok {
+		return 0
+	}
+	return math.Float64frombits(v.Load())
+}
@@ -0,0 +1,68 @@
+package repos
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// HistogramRepo - repository to store metrics with histogram type.
+type HistogramRepo struct {
+	metrics map[string]*models.HistogramValue
+	sync.Mutex
+}
+
+// NewHistogramRepo creates HistogramRepo.
+func NewHistogramRepo() *HistogramRepo {
+	return &HistogramRepo{metrics: make(map[string]*models.HistogramValue)}
+}
+
+// Update method merges provided observations into the stored histogram.
+// Histograms with different bucket bounds can not be merged.
+func (h *HistogramRepo) Update(_ context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	h.Lock()
+	defer h.Unlock()
+	current, ok := h.metrics[name]
+	if !ok {
+		h.metrics[name] = v.Copy()
+		return v.Copy(), nil
+	}
+	if err := current.Merge(v); err != nil {
+		return nil, err
+	}
+	return current.Copy(), nil
+}
+
+// Get method returns histogram metric value
+func (h *HistogramRepo) Get(_ context.Context, name string) (*models.HistogramValue, error) {
+	h.Lock()
+	defer h.Unlock()
+	v, ok := h.metrics[name]
+	if !ok {
+		return nil, ErrMetricNotRegistered
+	}
+	return v.Copy(), nil
+}
+
+// GetAll method returns values of all collected histogram metrics
+func (h *HistogramRepo) GetAll(_ context.Context) (map[string]*models.HistogramValue, error) {
+	h.Lock()
+	defer h.Unlock()
+	metrics := make(map[string]*models.HistogramValue, len(h.metrics))
+	for key, val := range h.metrics {
+		metrics[key] = val.Copy()
+	}
+	return metrics, nil
+}
+
+// Delete method removes histogram metric.
+func (h *HistogramRepo) Delete(_ context.Context, name string) error {
+	h.Lock()
+	defer h.Unlock()
+	if _, ok := h.metrics[name]; !ok {
+		return ErrMetricNotRegistered
+	}
+	delete(h.metrics, name)
+	return nil
+}
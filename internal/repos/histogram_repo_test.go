@@ -0,0 +1,98 @@
+package repos
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+func BenchmarkHistogramRepo_Update(b *testing.B) {
+	h := NewHistogramRepo()
+	ctx := context.Background()
+	v := models.NewHistogramValue([]float64{0.1, 0.5, 1})
+	v.Observe(0.3)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		h.Update(ctx, "Name", v)
+	}
+}
+
+func TestHistogramRepo_Update(t *testing.T) {
+	tests := []struct {
+		errValue        error
+		existingMetrics map[string]*models.HistogramValue
+		value           *models.HistogramValue
+		want            *models.HistogramValue
+		name            string
+		wantErr         bool
+	}{
+		{
+			name:            "empty metrics",
+			existingMetrics: make(map[string]*models.HistogramValue),
+			value:           &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 10},
+			want:            &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 10},
+			wantErr:         false,
+		},
+		{
+			name: "existing metric",
+			existingMetrics: map[string]*models.HistogramValue{
+				"Latency": {Bounds: []float64{1}, Counts: []uint64{4, 0}, Count: 4, Sum: 2},
+			},
+			value:   &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 10},
+			want:    &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{5, 2}, Count: 7, Sum: 12},
+			wantErr: false,
+		},
+		{
+			name: "buckets mismatch",
+			existingMetrics: map[string]*models.HistogramValue{
+				"Latency": {Bounds: []float64{1}, Counts: []uint64{4, 0}, Count: 4, Sum: 2},
+			},
+			value:    &models.HistogramValue{Bounds: []float64{2}, Counts: []uint64{1, 2}, Count: 3, Sum: 10},
+			errValue: models.ErrBucketsMismatch,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &HistogramRepo{metrics: tt.existingMetrics}
+			val, err := repo.Update(context.Background(), "Latency", tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, tt.errValue)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, val)
+				assert.Equal(t, tt.want, repo.metrics["Latency"])
+			}
+		})
+	}
+}
+
+func TestHistogramRepo_Get(t *testing.T) {
+	ctx := context.Background()
+	repo := NewHistogramRepo()
+
+	_, err := repo.Get(ctx, "Latency")
+	assert.ErrorIs(t, err, ErrMetricNotRegistered)
+
+	v := &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1, Sum: 0.5}
+	_, err = repo.Update(ctx, "Latency", v)
+	require.NoError(t, err)
+	v.Counts[0] = 100
+
+	got, err := repo.Get(ctx, "Latency")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 0}, got.Counts)
+
+	got.Counts[1] = 100
+	all, err := repo.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*models.HistogramValue{
+		"Latency": {Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1, Sum: 0.5},
+	}, all)
+}
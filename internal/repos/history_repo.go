@@ -0,0 +1,93 @@
+package repos
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+type historyKey struct {
+	mType string
+	name  string
+}
+
+// HistoryRepo - repository to store timestamped samples of metrics.
+type HistoryRepo struct {
+	samples   map[historyKey][]*models.Sample
+	retention time.Duration
+	sync.Mutex
+}
+
+// NewHistoryRepo creates HistoryRepo.
+// Samples older than retention are dropped, zero retention keeps all samples.
+func NewHistoryRepo(retention time.Duration) *HistoryRepo {
+	return &HistoryRepo{
+		samples:   make(map[historyKey][]*models.Sample),
+		retention: retention,
+	}
+}
+
+// Append method adds a sample to the metric history.
+func (h *HistoryRepo) Append(_ context.Context, mType string, name string, s *models.Sample) error {
+	key := historyKey{mType: mType, name: name}
+	h.Lock()
+	samples := append(h.samples[key], s)
+	if h.retention != 0 {
+		border := s.Timestamp.Add(-h.retention)
+		i := 0
+		for i < len(samples) && samples[i].Timestamp.Before(border) {
+			i++
+		}
+		samples = samples[i:]
+	}
+	h.samples[key] = samples
+	h.Unlock()
+	return nil
+}
+
+// Trim method drops samples collected before the provided time, metrics left without samples are removed,
+// so history of metrics which are not updated anymore doesn't grow the repository.
+func (h *HistoryRepo) Trim(_ context.Context, before time.Time) error {
+	h.Lock()
+	defer h.Unlock()
+	for key, samples := range h.samples {
+		i := 0
+		for i < len(samples) && samples[i].Timestamp.Before(before) {
+			i++
+		}
+		if i == len(samples) {
+			delete(h.samples, key)
+			continue
+		}
+		h.samples[key] = samples[i:]
+	}
+	return nil
+}
+
+// Get method returns samples of the metric collected between from and to inclusive.
+func (h *HistoryRepo) Get(_ context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	h.Lock()
+	defer h.Unlock()
+	samples, ok := h.samples[historyKey{mType: mType, name: name}]
+	if !ok {
+		return nil, ErrMetricNotRegistered
+	}
+	res := make([]*models.Sample, 0)
+	for _, s := range samples {
+		if s.Timestamp.Before(from) || s.Timestamp.After(to) {
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// Delete method removes all samples of the metric.
+func (h *HistoryRepo) Delete(_ context.Context, mType string, name string) error {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.samples, historyKey{mType: mType, name: name})
+	return nil
+}
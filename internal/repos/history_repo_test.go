@@ -0,0 +1,174 @@
+package repos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+func BenchmarkHistoryRepo_Append(b *testing.B) {
+	h := NewHistoryRepo(time.Minute)
+	ctx := context.Background()
+	v := 1.5
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		h.Append(ctx, models.Gauge, "Name", &models.Sample{Timestamp: time.Now(), Value: &v})
+	}
+}
+
+func TestHistoryRepo_Append(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name       string
+		retention  time.Duration
+		timestamps []time.Time
+		want       int
+	}{
+		{
+			name:       "no retention",
+			retention:  0,
+			timestamps: []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour)},
+			want:       3,
+		},
+		{
+			name:       "old samples dropped",
+			retention:  time.Hour,
+			timestamps: []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Hour)},
+			want:       1,
+		},
+		{
+			name:       "samples within retention",
+			retention:  time.Hour,
+			timestamps: []time.Time{start, start.Add(time.Minute), start.Add(time.Hour)},
+			want:       3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistoryRepo(tt.retention)
+			ctx := context.Background()
+			for i, ts := range tt.timestamps {
+				v := int64(i)
+				require.NoError(t, h.Append(ctx, models.Counter, "PollCount", &models.Sample{Timestamp: ts, Delta: &v}))
+			}
+			assert.Len(t, h.samples[historyKey{mType: models.Counter, name: "PollCount"}], tt.want)
+		})
+	}
+}
+
+func TestHistoryRepo_Get(t *testing.T) {
+	start := time.Now()
+	h := NewHistoryRepo(0)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		v := float64(i)
+		require.NoError(t, h.Append(ctx, models.Gauge, "Alloc", &models.Sample{Timestamp: start.Add(time.Duration(i) * time.Minute), Value: &v}))
+	}
+
+	tests := []struct {
+		errValue   error
+		name       string
+		metricType string
+		from       time.Time
+		to         time.Time
+		want       []float64
+		wantErr    bool
+	}{
+		{
+			name:       "all samples",
+			metricType: models.Gauge,
+			from:       start,
+			to:         start.Add(time.Hour),
+			want:       []float64{0, 1, 2, 3, 4},
+		},
+		{
+			name:       "range",
+			metricType: models.Gauge,
+			from:       start.Add(time.Minute),
+			to:         start.Add(3 * time.Minute),
+			want:       []float64{1, 2, 3},
+		},
+		{
+			name:       "empty range",
+			metricType: models.Gauge,
+			from:       start.Add(time.Hour),
+			to:         start.Add(2 * time.Hour),
+			want:       []float64{},
+		},
+		{
+			name:       "not registered",
+			metricType: models.Counter,
+			from:       start,
+			to:         start.Add(time.Hour),
+			wantErr:    true,
+			errValue:   ErrMetricNotRegistered,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := h.Get(ctx, tt.metricType, "Alloc", tt.from, tt.to)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, tt.errValue)
+				return
+			}
+			require.NoError(t, err)
+			got := make([]float64, 0, len(samples))
+			for _, s := range samples {
+				got = append(got, *s.Value)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHistoryRepo_Trim(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name       string
+		timestamps []time.Time
+		want       int
+		wantKey    bool
+	}{
+		{
+			name:       "old samples dropped",
+			timestamps: []time.Time{start.Add(-2 * time.Hour), start.Add(-time.Minute), start},
+			want:       2,
+			wantKey:    true,
+		},
+		{
+			name:       "samples within retention",
+			timestamps: []time.Time{start.Add(-time.Minute), start},
+			want:       2,
+			wantKey:    true,
+		},
+		{
+			name:       "idle metric removed",
+			timestamps: []time.Time{start.Add(-3 * time.Hour), start.Add(-2 * time.Hour)},
+			want:       0,
+			wantKey:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistoryRepo(time.Hour)
+			ctx := context.Background()
+			for i, ts := range tt.timestamps {
+				v := float64(i)
+				require.NoError(t, h.Append(ctx, models.Gauge, "Alloc", &models.Sample{Timestamp: ts, Value: &v}))
+			}
+			require.NoError(t, h.Trim(ctx, start.Add(-time.Hour)))
+			samples, ok := h.samples[historyKey{mType: models.Gauge, name: "Alloc"}]
+			assert.Equal(t, tt.wantKey, ok)
+			assert.Len(t, samples, tt.want)
+		})
+	}
+}
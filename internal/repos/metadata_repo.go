@@ -0,0 +1,52 @@
+package repos
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// MetadataRepo - repository to store metrics metadata.
+type MetadataRepo struct {
+	metadata map[string]*models.Metadata
+	sync.Mutex
+}
+
+// NewMetadataRepo creates MetadataRepo.
+func NewMetadataRepo() *MetadataRepo {
+	return &MetadataRepo{metadata: make(map[string]*models.Metadata)}
+}
+
+// Set method saves metadata of the metric, previously registered metadata is replaced.
+func (m *MetadataRepo) Set(_ context.Context, meta *models.Metadata) error {
+	m.Lock()
+	defer m.Unlock()
+	c := *meta
+	m.metadata[meta.ID] = &c
+	return nil
+}
+
+// Get method returns metadata of the metric.
+func (m *MetadataRepo) Get(_ context.Context, name string) (*models.Metadata, error) {
+	m.Lock()
+	defer m.Unlock()
+	meta, ok := m.metadata[name]
+	if !ok {
+		return nil, ErrMetricNotRegistered
+	}
+	c := *meta
+	return &c, nil
+}
+
+// GetAll method returns metadata of all registered metrics.
+func (m *MetadataRepo) GetAll(_ context.Context) (map[string]*models.Metadata, error) {
+	m.Lock()
+	defer m.Unlock()
+	res := make(map[string]*models.Metadata, len(m.metadata))
+	for name, meta := range m.metadata {
+		c := *meta
+		res[name] = &c
+	}
+	return res, nil
+}
@@ -0,0 +1,32 @@
+package repos
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+func TestMetadataRepo(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetadataRepo()
+
+	_, err := m.Get(ctx, "Alloc")
+	assert.ErrorIs(t, err, ErrMetricNotRegistered)
+
+	meta := &models.Metadata{ID: "Alloc", MType: models.Gauge, Unit: models.UnitBytes}
+	require.NoError(t, m.Set(ctx, meta))
+	meta.Unit = "changed"
+
+	got, err := m.Get(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, models.UnitBytes, got.Unit)
+
+	require.NoError(t, m.Set(ctx, &models.Metadata{ID: "Alloc", MType: models.Counter}))
+	all, err := m.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*models.Metadata{"Alloc": {ID: "Alloc", MType: models.Counter}}, all)
+}
@@ -0,0 +1,103 @@
+package repos
+
+import "sync"
+
+// shardCount - number of shards the metric names are distributed over.
+const shardCount = 32
+
+// shard - part of the sharded map guarded by its own lock.
+type shard[V any] struct {
+	values map[string]*V
+	sync.RWMutex
+}
+
+// shardedMap - map of metric values split into shards by the hash of the name,
+// so updates of metrics from different shards don't wait for each other.
+// Values are stored by pointer, so updates of existing metrics only need the read lock
+// as long as the values are safe for concurrent use.
+type shardedMap[V any] struct {
+	shards [shardCount]shard[V]
+}
+
+func newShardedMap[V any]() *shardedMap[V] {
+	m := &shardedMap[V]{}
+	for i := range m.shards {
+		m.shards[i].values = make(map[string]*V)
+	}
+	return m
+}
+
+// shard returns the shard of the name using FNV-1a hash.
+func (m *shardedMap[V]) shard(name string) *shard[V] {
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
+	}
+	return &m.shards[h%shardCount]
+}
+
+// load returns the value of the name.
+func (m *shardedMap[V]) load(name string) (*V, bool) {
+	s := m.shard(name)
+	s.RLock()
+	v, ok := s.values[name]
+	s.RUnlock()
+	return v, ok
+}
+
+// loadOrStore returns the value of the name, storing a new zero value if there is none.
+func (m *shardedMap[V]) loadOrStore(name string) *V {
+	s := m.shard(name)
+	s.RLock()
+	v, ok := s.values[name]
+	s.RUnlock()
+	if ok {
+		return v
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if v, ok = s.values[name]; ok {
+		return v
+	}
+	v = new(V)
+	s.values[name] = v
+	return v
+}
+
+// delete removes the value of the name, returns false if there was none.
+func (m *shardedMap[V]) delete(name string) bool {
+	s := m.shard(name)
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.values[name]; !ok {
+		return false
+	}
+	delete(s.values, name)
+	return true
+}
+
+// rangeAll calls f for every value, locking one shard at a time.
+func (m *shardedMap[V]) rangeAll(f func(name string, v *V)) {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.RLock()
+		for name, v := range s.values {
+			f(name, v)
+		}
+		s.RUnlock()
+	}
+}
+
+// len returns the number of values stored.
+func (m *shardedMap[V]) len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.RLock()
+		n += len(s.values)
+		s.RUnlock()
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+package repos
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// SummaryRepo - repository to store metrics with summary type.
+type SummaryRepo struct {
+	metrics map[string]*models.SummaryValue
+	sync.Mutex
+}
+
+// NewSummaryRepo creates SummaryRepo.
+func NewSummaryRepo() *SummaryRepo {
+	return &SummaryRepo{metrics: make(map[string]*models.SummaryValue)}
+}
+
+// Update method merges provided observations into the stored summary.
+// Summaries with different accuracy can not be merged.
+func (s *SummaryRepo) Update(_ context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	s.Lock()
+	defer s.Unlock()
+	current, ok := s.metrics[name]
+	if !ok {
+		s.metrics[name] = v.Copy()
+		return v.Copy(), nil
+	}
+	if err := current.Merge(v); err != nil {
+		return nil, err
+	}
+	return current.Copy(), nil
+}
+
+// Get method returns summary metric value
+func (s *SummaryRepo) Get(_ context.Context, name string) (*models.SummaryValue, error) {
+	s.Lock()
+	defer s.Unlock()
+	v, ok := s.metrics[name]
+	if !ok {
+		return nil, ErrMetricNotRegistered
+	}
+	return v.Copy(), nil
+}
+
+// GetAll method returns values of all collected summary metrics
+func (s *SummaryRepo) GetAll(_ context.Context) (map[string]*models.SummaryValue, error) {
+	s.Lock()
+	defer s.Unlock()
+	metrics := make(map[string]*models.SummaryValue, len(s.metrics))
+	for key, val := range s.metrics {
+		metrics[key] = val.Copy()
+	}
+	return metrics, nil
+}
+
+// Delete method removes summary metric.
+func (s *SummaryRepo) Delete(_ context.Context, name string) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.metrics[name]; !ok {
+		return ErrMetricNotRegistered
+	}
+	delete(s.metrics, name)
+	return nil
+}
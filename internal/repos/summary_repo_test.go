@@ -0,0 +1,53 @@
+package repos
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+func BenchmarkSummaryRepo_Update(b *testing.B) {
+	s := NewSummaryRepo()
+	ctx := context.Background()
+	v := models.NewSummaryValue(models.DefaultSummaryAccuracy)
+	v.Observe(0.3)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s.Update(ctx, "Name", v)
+	}
+}
+
+func TestSummaryRepo_Update(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSummaryRepo()
+
+	_, err := repo.Get(ctx, "Latency")
+	assert.ErrorIs(t, err, ErrMetricNotRegistered)
+
+	first := models.NewSummaryValue(models.DefaultSummaryAccuracy)
+	first.Observe(1)
+	second := models.NewSummaryValue(models.DefaultSummaryAccuracy)
+	second.Observe(3)
+
+	_, err = repo.Update(ctx, "Latency", first)
+	require.NoError(t, err)
+	val, err := repo.Update(ctx, "Latency", second)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), val.Count)
+	assert.Equal(t, 1.0, val.Min)
+	assert.Equal(t, 3.0, val.Max)
+
+	_, err = repo.Update(ctx, "Latency", models.NewSummaryValue(0.05))
+	assert.ErrorIs(t, err, models.ErrSketchMismatch)
+
+	val.Positive[1000] = 100
+	all, err := repo.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), all["Latency"].Count)
+	assert.NotContains(t, all["Latency"].Positive, int32(1000))
+}
@@ -0,0 +1,61 @@
+package repos
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// UpdatedRepo - repository to store time of the last update of metrics.
+type UpdatedRepo struct {
+	updated map[string]map[string]time.Time
+	sync.Mutex
+}
+
+// NewUpdatedRepo creates UpdatedRepo.
+func NewUpdatedRepo() *UpdatedRepo {
+	return &UpdatedRepo{updated: make(map[string]map[string]time.Time)}
+}
+
+// Set method sets time of the last metric update.
+func (u *UpdatedRepo) Set(_ context.Context, mType string, name string, t time.Time) error {
+	u.Lock()
+	defer u.Unlock()
+	byName, ok := u.updated[mType]
+	if !ok {
+		byName = make(map[string]time.Time)
+		u.updated[mType] = byName
+	}
+	byName[name] = t
+	return nil
+}
+
+// Get method returns time of the last metric update.
+func (u *UpdatedRepo) Get(_ context.Context, mType string, name string) (time.Time, error) {
+	u.Lock()
+	defer u.Unlock()
+	t, ok := u.updated[mType][name]
+	if !ok {
+		return time.Time{}, ErrMetricNotRegistered
+	}
+	return t, nil
+}
+
+// GetAll method returns time of the last update of all metrics with provided type.
+func (u *UpdatedRepo) GetAll(_ context.Context, mType string) (map[string]time.Time, error) {
+	u.Lock()
+	defer u.Unlock()
+	res := make(map[string]time.Time, len(u.updated[mType]))
+	for name, t := range u.updated[mType] {
+		res[name] = t
+	}
+	return res, nil
+}
+
+// Delete method removes time of the last metric update.
+func (u *UpdatedRepo) Delete(_ context.Context, mType string, name string) error {
+	u.Lock()
+	defer u.Unlock()
+	delete(u.updated[mType], name)
+	return nil
+}
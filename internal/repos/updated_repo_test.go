@@ -0,0 +1,45 @@
+package repos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+func TestUpdatedRepo(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	u := NewUpdatedRepo()
+
+	require.NoError(t, u.Set(ctx, models.Gauge, "Alloc", now))
+	require.NoError(t, u.Set(ctx, models.Counter, "PollCount", now.Add(time.Second)))
+
+	gauges, err := u.GetAll(ctx, models.Gauge)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Time{"Alloc": now}, gauges)
+
+	updated, err := u.Get(ctx, models.Counter, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Second), updated)
+	_, err = u.Get(ctx, models.Counter, "Alloc")
+	assert.ErrorIs(t, err, ErrMetricNotRegistered)
+
+	gauges["Other"] = now
+	gauges, err = u.GetAll(ctx, models.Gauge)
+	require.NoError(t, err)
+	assert.Len(t, gauges, 1)
+
+	require.NoError(t, u.Delete(ctx, models.Gauge, "Alloc"))
+	gauges, err = u.GetAll(ctx, models.Gauge)
+	require.NoError(t, err)
+	assert.Empty(t, gauges)
+
+	counters, err := u.GetAll(ctx, models.Counter)
+	require.NoError(t, err)
+	assert.Len(t, counters, 1)
+}
@@ -2,16 +2,23 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strings"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"github.com/vindosVP/metrics/internal/models"
 	pb "github.com/vindosVP/metrics/internal/proto"
 	"github.com/vindosVP/metrics/internal/service"
+	"github.com/vindosVP/metrics/internal/tenant"
 	"github.com/vindosVP/metrics/pkg/logger"
 )
 
@@ -24,6 +31,19 @@ type MetricsStorage interface {
 	GetCounter(ctx context.Context, name string) (int64, error)
 	GetAllCounter(ctx context.Context) (map[string]int64, error)
 	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
 }
 
 type GRPCServer struct {
@@ -44,9 +64,15 @@ func (g *GRPCServer) Stop(wg *sync.WaitGroup) {
 	wg.Done()
 }
 
-func New(st MetricsStorage, addr string) (*GRPCServer, error) {
-	a := grpc.NewServer()
-	pb.RegisterMetricsServer(a, service.NewMetricsServer(st))
+// New creates GRPCServer, calls of internal services must be signed with the admin key
+// and calls of tenants with their keys if tenant keys are configured.
+func New(st MetricsStorage, w service.Watcher, addr string, keys tenant.Keys, adminKey string, opts ...func(*grpc.Server)) (*GRPCServer, error) {
+	auth := &authenticator{keys: keys, adminKey: adminKey}
+	a := grpc.NewServer(grpc.UnaryInterceptor(auth.interceptor), grpc.StreamInterceptor(auth.streamInterceptor))
+	pb.RegisterMetricsServer(a, service.NewMetricsServer(st, service.WithWatcher(w)))
+	for _, opt := range opts {
+		opt(a)
+	}
 	listen, err := net.Listen("tcp", addr)
 	logger.Log.Info(fmt.Sprintf("GRPC server listening on %s", addr))
 	if err != nil {
@@ -57,3 +83,112 @@ func New(st MetricsStorage, addr string) (*GRPCServer, error) {
 		s:      a,
 	}, nil
 }
+
+// WithReplication registers the service streaming changes to replication followers.
+func WithReplication(srv pb.ReplicationServer) func(*grpc.Server) {
+	return func(s *grpc.Server) {
+		pb.RegisterReplicationServer(s, srv)
+	}
+}
+
+// WithCluster registers the service serving calls forwarded by other nodes of the cluster.
+func WithCluster(srv pb.ClusterServer) func(*grpc.Server) {
+	return func(s *grpc.Server) {
+		pb.RegisterClusterServer(s, srv)
+	}
+}
+
+// WithAlerting registers the service listing active alerts.
+func WithAlerting(srv pb.AlertingServer) func(*grpc.Server) {
+	return func(s *grpc.Server) {
+		pb.RegisterAlertingServer(s, srv)
+	}
+}
+
+// internal - services called by other servers only
+var internal = []string{pb.Replication_ServiceDesc.ServiceName, pb.Cluster_ServiceDesc.ServiceName}
+
+// authenticator checks signatures of calls and puts their tenant into the context.
+type authenticator struct {
+	keys     tenant.Keys
+	adminKey string
+}
+
+// interceptor puts the tenant provided in the x-tenant-id metadata into the context of the call.
+func (a *authenticator) interceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.context(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor puts the tenant provided in the x-tenant-id metadata into the context of the stream.
+func (a *authenticator) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.context(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &tenantStream{ServerStream: ss, ctx: ctx})
+}
+
+// context returns the context of the tenant of the call.
+// Calls of internal services are rejected unless the tenant identifier is signed with the admin key,
+// other calls are checked like HTTP requests, with the tenant identifier signed instead of the body.
+func (a *authenticator) context(ctx context.Context, method string) (context.Context, error) {
+	id, hash := "", ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(tenant.MetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+		if values := md.Get(tenant.HashMetadataKey); len(values) > 0 {
+			hash = values[0]
+		}
+	}
+	if isInternal(method) {
+		if a.adminKey == "" {
+			return nil, status.Error(codes.PermissionDenied, "admin key is not configured")
+		}
+		if hash != tenant.Sign(id, a.adminKey) {
+			return nil, status.Error(codes.Unauthenticated, "call must be signed with the admin key")
+		}
+		id, err := tenant.Resolve(id, "")
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return tenant.WithTenant(ctx, id), nil
+	}
+
+	signer := ""
+	if hash != "" && len(a.keys) > 0 {
+		signer, _ = a.keys.Signer([]byte(id), hash)
+	}
+	id, err := a.keys.Resolve(id, signer)
+	if errors.Is(err, tenant.ErrTenantMismatch) || errors.Is(err, tenant.ErrUnsigned) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return tenant.WithTenant(ctx, id), nil
+}
+
+// isInternal reports whether the method belongs to an internal service, methods are named /service/method.
+func isInternal(method string) bool {
+	for _, name := range internal {
+		if strings.HasPrefix(method, "/"+name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantStream is the server stream with the context carrying the tenant.
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantStream) Context() context.Context {
+	return s.ctx
+}
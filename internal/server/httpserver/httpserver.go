@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chiMws "github.com/go-chi/chi/v5/middleware"
@@ -17,6 +18,9 @@ import (
 	"github.com/vindosVP/metrics/internal/handlers"
 	"github.com/vindosVP/metrics/internal/middleware"
 	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/internal/watch"
+	"github.com/vindosVP/metrics/internal/webhooks"
 	"github.com/vindosVP/metrics/pkg/encryption"
 	"github.com/vindosVP/metrics/pkg/logger"
 )
@@ -30,6 +34,52 @@ type MetricsStorage interface {
 	GetCounter(ctx context.Context, name string) (int64, error)
 	GetAllCounter(ctx context.Context) (map[string]int64, error)
 	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// TenantStorage returns numbers of series stored for every tenant.
+type TenantStorage interface {
+	Stats(ctx context.Context) ([]*models.TenantStats, error)
+}
+
+// Promoter promotes the replication follower to the leader.
+type Promoter interface {
+	Promote() bool
+}
+
+// AlertLister returns active alerts of the tenant of the context.
+type AlertLister interface {
+	Alerts(ctx context.Context) []*models.Alert
+}
+
+// WebhookRegistry keeps webhook subscriptions of the tenant of the context.
+type WebhookRegistry interface {
+	Add(ctx context.Context, sub *webhooks.Subscription) (*webhooks.Subscription, error)
+	List(ctx context.Context) []*webhooks.Subscription
+	Remove(ctx context.Context, id string) error
+}
+
+// MetricsWatcher streams updates of metrics of the tenant of the context selected by the filter.
+type MetricsWatcher interface {
+	Watch(ctx context.Context, f *watch.Filter, send func(u *watch.Update) error) error
+}
+
+// RecentValues returns recent values of series of the tenant of the context charted on sparklines.
+type RecentValues interface {
+	Recent(ctx context.Context) map[string][]float64
+	Size() int
 }
 
 type HTTPServer struct {
@@ -78,8 +128,10 @@ func configuration(c *httpServerConfig) []func(*server) {
 		withAddr(c.Addr),
 		withMw(chiMws.Logger),
 		withMw(middleware.Sign(c.Key)),
-		withRouteGroup(legacyGroup(c.Storage, c.Subnet)),
-		withRouteGroup(group(c.Storage, c.Key, c.PKey, c.Subnet)),
+		withRouteGroup(legacyGroup(c.Storage, c.Alerts, c.Webhooks, c.Watcher, c.Recent, c.TenantKeys, c.Subnet)),
+		withRouteGroup(group(c.Storage, c.Webhooks, c.Key, c.TenantKeys, c.PKey, c.Subnet)),
+		withRouteGroup(scrapeGroup(c.Storage, c.Key, c.TenantKeys, c.Subnet)),
+		withRouteGroup(adminGroup(c.Tenants, c.Node, c.AdminKey, c.Subnet)),
 	}
 }
 
@@ -102,24 +154,34 @@ func withRouteGroup(fun func(r chi.Router)) func(*server) {
 	}
 }
 
-func legacyGroup(st MetricsStorage, subnet *net.IPNet) func(r chi.Router) {
+func legacyGroup(st MetricsStorage, alerts AlertLister, hooks WebhookRegistry, watcher MetricsWatcher, recent RecentValues, keys tenant.Keys, subnet *net.IPNet) func(r chi.Router) {
 	return func(r chi.Router) {
 		if subnet != nil {
 			r.Use(middleware.CheckSubnet(*subnet))
 		}
+		r.Use(middleware.Tenant(keys))
 		r.Use(middleware.Decompress)
 		r.Use(chiMws.Compress(5))
 		r.Post("/update/{type}/{name}/{value}", handlers.Update(st))
 		r.Get("/value/{type}/{name}", handlers.Get(st))
-		r.Get("/", handlers.List(st))
+		r.Delete("/value/{type}/{name}", handlers.Delete(st))
+		r.Get("/history/{type}/{name}", handlers.History(st))
+		r.Get("/metadata/", handlers.ListMetadata(st))
+		r.Get("/metadata/{name}", handlers.GetMetadata(st))
+		r.Get("/alerts", handlers.ListAlerts(alerts))
+		r.Get("/webhooks/", handlers.ListWebhooks(hooks))
+		r.Delete("/webhooks/{id}", handlers.DeleteWebhook(hooks))
+		r.Get("/stream", handlers.ListStream(st, watcher, recent))
+		r.Get("/", handlers.List(st, recent))
 	}
 }
 
-func group(st MetricsStorage, key string, pKey *rsa.PrivateKey, subnet *net.IPNet) func(r chi.Router) {
+func group(st MetricsStorage, hooks WebhookRegistry, key string, keys tenant.Keys, pKey *rsa.PrivateKey, subnet *net.IPNet) func(r chi.Router) {
 	return func(r chi.Router) {
 		if subnet != nil {
 			r.Use(middleware.CheckSubnet(*subnet))
 		}
+		r.Use(middleware.Tenant(keys))
 		r.Use(middleware.ValidateHMAC(key))
 		if pKey != nil {
 			r.Use(middleware.Decode(pKey))
@@ -129,20 +191,62 @@ func group(st MetricsStorage, key string, pKey *rsa.PrivateKey, subnet *net.IPNe
 		r.Post("/update/", handlers.UpdateBody(st))
 		r.Post("/updates/", handlers.UpdateBatch(st))
 		r.Post("/value/", handlers.GetBody(st))
+		r.Post("/delete/", handlers.DeleteBatch(st))
+		r.Post("/metadata/", handlers.RegisterMetadata(st))
+		r.Post("/webhooks/", handlers.RegisterWebhook(hooks))
+	}
+}
+
+// scrapeGroup serves metrics to Prometheus, scrape requests have no body to decrypt.
+func scrapeGroup(st MetricsStorage, key string, keys tenant.Keys, subnet *net.IPNet) func(r chi.Router) {
+	return func(r chi.Router) {
+		if subnet != nil {
+			r.Use(middleware.CheckSubnet(*subnet))
+		}
+		r.Use(middleware.Tenant(keys))
+		r.Use(middleware.ValidateHMAC(key))
+		r.Use(chiMws.Compress(5))
+		r.Get("/metrics", handlers.Exposition(st))
+	}
+}
+
+// adminGroup serves requests managing the server, they must be signed with the admin key.
+func adminGroup(tenants TenantStorage, node Promoter, key string, subnet *net.IPNet) func(r chi.Router) {
+	return func(r chi.Router) {
+		if subnet != nil {
+			r.Use(middleware.CheckSubnet(*subnet))
+		}
+		r.Use(middleware.RequireHMAC(key))
+		r.Get("/admin/tenants", handlers.ListTenants(tenants))
+		r.Post("/admin/promote", handlers.Promote(node))
 	}
 }
 
 type httpServerConfig struct {
-	Subnet  *net.IPNet
-	Key     string
-	PKey    *rsa.PrivateKey
-	Addr    string
-	Storage MetricsStorage
+	Subnet     *net.IPNet
+	Key        string
+	AdminKey   string
+	TenantKeys tenant.Keys
+	PKey       *rsa.PrivateKey
+	Addr       string
+	Storage    MetricsStorage
+	Tenants    TenantStorage
+	Node       Promoter
+	Alerts     AlertLister
+	Webhooks   WebhookRegistry
+	Watcher    MetricsWatcher
+	Recent     RecentValues
 }
 
-func newConfig(st MetricsStorage, cfg *config.ServerConfig) (*httpServerConfig, error) {
+func newConfig(st MetricsStorage, tenants TenantStorage, node Promoter, alerts AlertLister, hooks WebhookRegistry, watcher MetricsWatcher, recent RecentValues, cfg *config.ServerConfig) (*httpServerConfig, error) {
 	c := &httpServerConfig{}
 
+	keys, err := tenant.ParseKeys(cfg.TenantKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tenant keys: %w", err)
+	}
+	c.TenantKeys = keys
+
 	if cfg.CryptoKeyFile != "" {
 		pKey, err := encryption.PrivateKeyFromFile(cfg.CryptoKeyFile)
 		if err != nil {
@@ -164,14 +268,21 @@ func newConfig(st MetricsStorage, cfg *config.ServerConfig) (*httpServerConfig,
 	}
 
 	c.Key = cfg.Key
+	c.AdminKey = cfg.AdminKey
 	c.Addr = cfg.RunAddr
 	c.Storage = st
+	c.Tenants = tenants
+	c.Node = node
+	c.Alerts = alerts
+	c.Webhooks = hooks
+	c.Watcher = watcher
+	c.Recent = recent
 
 	return c, nil
 }
 
-func New(st MetricsStorage, cfg *config.ServerConfig) (*HTTPServer, error) {
-	c, err := newConfig(st, cfg)
+func New(st MetricsStorage, tenants TenantStorage, node Promoter, alerts AlertLister, hooks WebhookRegistry, watcher MetricsWatcher, recent RecentValues, cfg *config.ServerConfig) (*HTTPServer, error) {
+	c, err := newConfig(st, tenants, node, alerts, hooks, watcher, recent, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to configure http server: %w", err)
 	}
@@ -3,12 +3,16 @@ package loader
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"io/fs"
 	"os"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/filestorage"
 	"github.com/vindosVP/metrics/pkg/logger"
 )
 
@@ -17,7 +21,13 @@ import (
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=MetricsStorage
 type MetricsStorage interface {
 	SetCounter(ctx context.Context, name string, v int64) (int64, error)
+	UpdateCounter(ctx context.Context, name string, v int64) (int64, error)
 	UpdateGauge(ctx context.Context, name string, v float64) (float64, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	SetUpdated(ctx context.Context, mType string, name string, t time.Time) error
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
 }
 
 // Loader consists data to load metrics dump
@@ -34,30 +44,121 @@ func New(filename string, storage MetricsStorage) *Loader {
 	}
 }
 
-// LoadMetrics method reads the dump file and updates metrics values in the storage
+// LoadMetrics method reads the newest valid dump and updates metrics values in the storage,
+// then replays records of the write-ahead log written after the dump.
+// It fails if there is neither the dump nor the write-ahead log.
 func (l *Loader) LoadMetrics() error {
 	ctx := context.Background()
-	data, err := os.ReadFile(l.filename)
-	if err != nil {
-		return err
+	metricsDump, err := filestorage.NewSnapshots(l.filename).Read()
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, werr := os.Stat(filestorage.WALPath(l.filename)); werr != nil {
+			return err
+		}
+		metricsDump, err = &models.MetricsDump{}, nil
 	}
-	metricsDump := &models.MetricsDump{}
-	err = json.Unmarshal(data, &metricsDump)
 	if err != nil {
 		return err
 	}
 	for _, metric := range metricsDump.Metrics {
 		if metric.MType == models.Counter {
-			_, err := l.storage.SetCounter(ctx, metric.ID, *metric.Delta)
+			_, err := l.storage.SetCounter(ctx, metric.Key(), *metric.Delta)
 			if err != nil {
 				logger.Log.Error("Failed to set counter", zap.Error(err))
 			}
-		} else if metric.MType == models.Gauge {
-			_, err := l.storage.UpdateGauge(ctx, metric.ID, *metric.Value)
-			if err != nil {
-				logger.Log.Error("Failed to update counter", zap.Error(err))
-			}
+		} else {
+			l.update(ctx, metric)
 		}
+		l.setUpdated(ctx, metric)
+	}
+	for _, meta := range metricsDump.Metadata {
+		l.registerMetadata(ctx, meta)
+	}
+
+	records, err := filestorage.ReadWAL(l.filename)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.Seq <= metricsDump.Seq {
+			continue
+		}
+		l.replay(ctx, record)
 	}
 	return nil
 }
+
+func (l *Loader) replay(ctx context.Context, record *models.WALRecord) {
+	if record.Op == models.WALMetadata {
+		if record.Metadata != nil {
+			l.registerMetadata(ctx, record.Metadata)
+		}
+		return
+	}
+	metric := record.Metric
+	if metric == nil {
+		return
+	}
+	switch record.Op {
+	case models.WALUpdate:
+		l.update(ctx, metric)
+	case models.WALSet:
+		if metric.Delta == nil {
+			return
+		}
+		_, err := l.storage.SetCounter(ctx, metric.Key(), *metric.Delta)
+		if err != nil {
+			logger.Log.Error("Failed to set counter", zap.Error(err))
+		}
+	case models.WALDelete:
+		err := l.storage.Delete(ctx, metric.MType, metric.Key())
+		if err != nil && !errors.Is(err, storage.ErrMetricNotRegistered) {
+			logger.Log.Error("Failed to delete metric", zap.Error(err))
+		}
+		return
+	}
+	l.setUpdated(ctx, metric)
+}
+
+// update adds the value to the metric, counters are incremented, gauges are set,
+// histograms and summaries are merged.
+func (l *Loader) update(ctx context.Context, metric *models.Metrics) {
+	var err error
+	if metric.MType == models.Counter && metric.Delta != nil {
+		_, err = l.storage.UpdateCounter(ctx, metric.Key(), *metric.Delta)
+		if err != nil {
+			logger.Log.Error("Failed to update counter", zap.Error(err))
+		}
+	} else if metric.MType == models.Gauge && metric.Value != nil {
+		_, err = l.storage.UpdateGauge(ctx, metric.Key(), *metric.Value)
+		if err != nil {
+			logger.Log.Error("Failed to update gauge", zap.Error(err))
+		}
+	} else if metric.MType == models.Histogram && metric.Histogram != nil {
+		_, err = l.storage.UpdateHistogram(ctx, metric.Key(), metric.Histogram)
+		if err != nil {
+			logger.Log.Error("Failed to update histogram", zap.Error(err))
+		}
+	} else if metric.MType == models.Summary && metric.Summary != nil {
+		_, err = l.storage.UpdateSummary(ctx, metric.Key(), metric.Summary)
+		if err != nil {
+			logger.Log.Error("Failed to update summary", zap.Error(err))
+		}
+	}
+}
+
+func (l *Loader) setUpdated(ctx context.Context, metric *models.Metrics) {
+	if metric.Updated == nil {
+		return
+	}
+	err := l.storage.SetUpdated(ctx, metric.MType, metric.Key(), *metric.Updated)
+	if err != nil {
+		logger.Log.Error("Failed to set metric update time", zap.Error(err))
+	}
+}
+
+func (l *Loader) registerMetadata(ctx context.Context, meta *models.Metadata) {
+	err := l.storage.RegisterMetadata(ctx, meta)
+	if err != nil {
+		logger.Log.Error("Failed to register metric metadata", zap.Error(err))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -33,12 +34,14 @@ func BenchmarkLoader_LoadMetrics(b *testing.B) {
 	fName := "./bench-db.json"
 	s := filestorage.NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fName)
 	defer os.Remove(fName)
+	defer os.Remove(filestorage.WALPath(fName))
+	defer s.Close()
 
 	for i := 0; i < 100; i++ {
 		s.UpdateCounter(ctx, RandStringRunes(10), rand.Int63())
 		s.UpdateGauge(ctx, RandStringRunes(10), rand.Float64())
 	}
-	l := New(fName, s)
+	l := New(fName, memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo()))
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -103,3 +106,158 @@ func TestLoader(t *testing.T) {
 	assert.True(t, cEqual)
 	assert.True(t, gEqual)
 }
+
+func TestLoader_Labels(t *testing.T) {
+	fileName := "./test_loader_labels.json"
+	defer os.Remove(fileName)
+	defer os.Remove(filestorage.WALPath(fileName))
+
+	cMetrics := map[string]int64{
+		"PollCount":                         3,
+		`PollCount{host="a"}`:               5,
+		`PollCount{host="b",service="api"}`: 7,
+	}
+	gMetrics := map[string]float64{
+		`Alloc{host="a \"quoted\""}`: 1.5,
+	}
+	hMetrics := map[string]*models.HistogramValue{
+		`Latency{host="a"}`: {Bounds: []float64{0.1, 1}, Counts: []uint64{2, 1, 0}, Count: 3, Sum: 1.05},
+	}
+	latency := models.NewSummaryValue(models.DefaultSummaryAccuracy)
+	latency.Observe(0.25)
+	latency.Observe(-3)
+	sMetrics := map[string]*models.SummaryValue{
+		`Latency{host="b"}`: latency,
+	}
+	ctx := context.Background()
+	source := filestorage.NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	defer source.Close()
+	for k, v := range cMetrics {
+		_, err := source.SetCounter(ctx, k, v)
+		require.NoError(t, err)
+	}
+	for k, v := range gMetrics {
+		_, err := source.UpdateGauge(ctx, k, v)
+		require.NoError(t, err)
+	}
+	for k, v := range hMetrics {
+		_, err := source.UpdateHistogram(ctx, k, v)
+		require.NoError(t, err)
+	}
+	for k, v := range sMetrics {
+		_, err := source.UpdateSummary(ctx, k, v)
+		require.NoError(t, err)
+	}
+
+	storage := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	err := New(fileName, storage).LoadMetrics()
+	require.NoError(t, err)
+
+	gotCMetrics, err := storage.GetAllCounter(ctx)
+	require.NoError(t, err)
+	gotGMetrics, err := storage.GetAllGauge(ctx)
+	require.NoError(t, err)
+	gotHMetrics, err := storage.GetAllHistogram(ctx)
+	require.NoError(t, err)
+	gotSMetrics, err := storage.GetAllSummary(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, cMetrics, gotCMetrics)
+	assert.Equal(t, gMetrics, gotGMetrics)
+	assert.Equal(t, hMetrics, gotHMetrics)
+	assert.Equal(t, sMetrics, gotSMetrics)
+}
+
+func TestLoader_Updated(t *testing.T) {
+	fileName := "./test_loader_updated.json"
+	defer os.Remove(fileName)
+	defer os.Remove(filestorage.WALPath(fileName))
+
+	ctx := context.Background()
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	source := filestorage.NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	defer source.Close()
+	_, err := source.UpdateGauge(ctx, "Old", 1)
+	require.NoError(t, err)
+	require.NoError(t, source.SetUpdated(ctx, models.Gauge, "Old", updated))
+	require.NoError(t, source.Compact(ctx))
+	_, err = source.UpdateGauge(ctx, "New", 2)
+	require.NoError(t, err)
+
+	storage := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	err = New(fileName, storage).LoadMetrics()
+	require.NoError(t, err)
+
+	got, err := storage.GetUpdated(ctx, models.Gauge)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.True(t, updated.Equal(got["Old"]))
+	assert.True(t, got["New"].After(updated))
+}
+
+func TestLoader_Metadata(t *testing.T) {
+	fileName := "./test_loader_metadata.json"
+	defer os.Remove(fileName)
+	defer os.Remove(filestorage.WALPath(fileName))
+
+	ctx := context.Background()
+	meta := &models.Metadata{ID: "Alloc", MType: models.Gauge, Unit: models.UnitBytes, Help: "Allocated heap objects"}
+	source := filestorage.NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	defer source.Close()
+	require.NoError(t, source.RegisterMetadata(ctx, meta))
+
+	storage := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	err := New(fileName, storage).LoadMetrics()
+	require.NoError(t, err)
+
+	got, err := storage.GetAllMetadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*models.Metadata{"Alloc": meta}, got)
+}
+
+func TestLoader_WAL(t *testing.T) {
+	fileName := "./test_loader_wal.json"
+	defer os.Remove(fileName)
+	defer os.Remove(filestorage.WALPath(fileName))
+
+	ctx := context.Background()
+	source := filestorage.NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	defer source.Close()
+	_, err := source.UpdateCounter(ctx, "PollCount", 5)
+	require.NoError(t, err)
+	_, err = source.UpdateGauge(ctx, "Typo", 1)
+	require.NoError(t, err)
+	require.NoError(t, source.Compact(ctx))
+
+	_, err = source.UpdateCounter(ctx, "PollCount", 2)
+	require.NoError(t, err)
+	_, err = source.SetCounter(ctx, "Reset", 10)
+	require.NoError(t, err)
+	require.NoError(t, source.Delete(ctx, models.Gauge, "Typo"))
+	require.NoError(t, source.RegisterMetadata(ctx, &models.Metadata{ID: "Alloc", MType: models.Gauge}))
+	_, err = source.UpdateGauge(ctx, "Alloc", 3.5)
+	require.NoError(t, err)
+
+	// the dump written before a crash, the log is not truncated yet
+	dump := &models.MetricsDump{Seq: 3}
+	delta := int64(7)
+	dump.Metrics = append(dump.Metrics, &models.Metrics{ID: "PollCount", MType: models.Counter, Delta: &delta})
+	require.NoError(t, filestorage.NewSnapshots(fileName).Write(dump))
+
+	storage := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	require.NoError(t, New(fileName, storage).LoadMetrics())
+
+	counters, err := storage.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 7, "Reset": 10}, counters)
+	gauges, err := storage.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Alloc": 3.5}, gauges)
+	metadata, err := storage.GetAllMetadata(ctx)
+	require.NoError(t, err)
+	assert.Len(t, metadata, 1)
+}
+
+func TestLoader_NoDump(t *testing.T) {
+	storage := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	assert.Error(t, New("./test_loader_missing.json", storage).LoadMetrics())
+}
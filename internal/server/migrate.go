@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/vindosVP/metrics/cmd/server/config"
+	"github.com/vindosVP/metrics/internal/storage/dbstorage/migrations"
+	"github.com/vindosVP/metrics/internal/storage/driver"
+)
+
+// ErrUnknownCommand is returned by Migrate for commands other than up, down and status.
+var ErrUnknownCommand = errors.New("unknown migrate command, expected up, down or status")
+
+// Migrate runs the migrate command against the configured database,
+// up applies pending migrations, down rolls back the latest one and status prints all of them to out.
+func Migrate(cfg *config.ServerConfig, command string, out io.Writer) error {
+	dsn, err := storageURL(cfg)
+	if err != nil {
+		return err
+	}
+	if scheme := driver.Scheme(dsn); scheme != "postgres" && scheme != "postgresql" {
+		return errors.New("storage is not a postgres database")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to connect to databse: %w", err)
+	}
+	defer pool.Close()
+	m, err := migrations.New(pool)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	switch command {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		return m.Down(ctx)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(out, statuses)
+	default:
+		return ErrUnknownCommand
+	}
+}
+
+func printStatus(out io.Writer, statuses []*migrations.Status) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, st := range statuses {
+		applied := "pending"
+		if st.Applied != nil {
+			applied = st.Applied.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", st.Version, st.Name, applied)
+	}
+	return w.Flush()
+}
@@ -3,24 +3,35 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
 	"github.com/vindosVP/metrics/cmd/server/config"
+	"github.com/vindosVP/metrics/internal/alerting"
+	"github.com/vindosVP/metrics/internal/cluster"
+	"github.com/vindosVP/metrics/internal/events"
 	"github.com/vindosVP/metrics/internal/models"
-	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/replication"
 	"github.com/vindosVP/metrics/internal/server/grpcserver"
 	"github.com/vindosVP/metrics/internal/server/httpserver"
-	"github.com/vindosVP/metrics/internal/server/loader"
-	"github.com/vindosVP/metrics/internal/storage/dbstorage"
-	"github.com/vindosVP/metrics/internal/storage/filestorage"
-	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/storage/cachestorage"
+	"github.com/vindosVP/metrics/internal/storage/driver"
+	"github.com/vindosVP/metrics/internal/storage/eventstorage"
+	"github.com/vindosVP/metrics/internal/storage/tenantstorage"
+	"github.com/vindosVP/metrics/internal/storage/ttlstorage"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/internal/watch"
+	"github.com/vindosVP/metrics/internal/webhooks"
 	"github.com/vindosVP/metrics/pkg/logger"
 )
 
@@ -36,6 +47,19 @@ type MetricsStorage interface {
 	GetCounter(ctx context.Context, name string) (int64, error)
 	GetAllCounter(ctx context.Context) (map[string]int64, error)
 	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
 }
 
 type pServer interface {
@@ -44,8 +68,14 @@ type pServer interface {
 }
 
 type Server struct {
-	http pServer
-	grpc pServer
+	http          pServer
+	grpc          pServer
+	flusher       *cacheFlusher
+	follower      *replication.Follower
+	alerts        *alerting.Engine
+	webhooks      *webhooks.Dispatcher
+	recorder      *watch.Recorder
+	alertInterval time.Duration
 }
 
 func (s *Server) Run() {
@@ -54,18 +84,37 @@ func (s *Server) Run() {
 	wg.Add(1)
 	sig := make(chan os.Signal, 3)
 	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	go func() {
 		<-sig
 		logger.Log.Info("Got stop signal, stopping")
+		cancel()
 		go s.http.Stop(wg)
 		go s.grpc.Stop(wg)
 	}()
 
+	if s.follower != nil {
+		go s.follower.Run(ctx)
+	}
+	if s.alerts != nil {
+		go s.alerts.Run(s.alertInterval, ctx.Done())
+	}
+	if s.webhooks != nil {
+		go s.webhooks.Run(ctx.Done())
+	}
+	if s.recorder != nil {
+		go s.recorder.Run(ctx.Done())
+	}
 	go s.http.Run(wg)
 	go s.grpc.Run(wg)
 
 	wg.Wait()
+	if s.flusher != nil {
+		logger.Log.Info("Flushing cached metrics")
+		s.flusher.Stop()
+	}
 	logger.Log.Info("Server stopped")
 }
 
@@ -81,6 +130,37 @@ func withGRPCServer(hs pServer) func(*Server) {
 	}
 }
 
+func withCacheFlusher(f *cacheFlusher) func(*Server) {
+	return func(s *Server) {
+		s.flusher = f
+	}
+}
+
+func withFollower(f *replication.Follower) func(*Server) {
+	return func(s *Server) {
+		s.follower = f
+	}
+}
+
+func withAlerting(e *alerting.Engine, interval time.Duration) func(*Server) {
+	return func(s *Server) {
+		s.alerts = e
+		s.alertInterval = interval
+	}
+}
+
+func withWebhooks(d *webhooks.Dispatcher) func(*Server) {
+	return func(s *Server) {
+		s.webhooks = d
+	}
+}
+
+func withRecorder(r *watch.Recorder) func(*Server) {
+	return func(s *Server) {
+		s.recorder = r
+	}
+}
+
 func newServer(opts ...func(*Server)) *Server {
 	s := &Server{}
 	for _, opt := range opts {
@@ -90,82 +170,252 @@ func newServer(opts ...func(*Server)) *Server {
 }
 
 func New(cfg *config.ServerConfig) (*Server, error) {
-	s, err := storage(cfg)
+	// the leader serves followers signing calls with the admin key only
+	if cfg.ReplicateFrom != "" && cfg.AdminKey == "" {
+		return nil, errors.New("failed to create server: replication follower requires the admin key")
+	}
+	// nodes serve calls of other nodes signed with the admin key only
+	if cfg.ClusterPeers != "" && cfg.AdminKey == "" {
+		return nil, errors.New("failed to create server: cluster node requires the admin key")
+	}
+	keys, err := tenant.ParseKeys(cfg.TenantKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tenant keys: %w", err)
+	}
+	flusher := newCacheFlusher(cfg.CacheFlushInterval * time.Second)
+	tenants, err := storage(cfg, flusher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	// changes are published for followers, the follower applies only changes replicated from the leader
+	bus := events.NewBus()
+	node := replication.NewNode(cfg.ReplicateFrom == "")
+	es := eventstorage.New(tenants, bus, eventstorage.WithGuard(node.Guard))
+	s, err := expiringStorage(es, tenants, node, cfg.TTL*time.Second, cfg.TTLRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	api, err := clusterStorage(cfg, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	alerts, err := alertingEngine(cfg, api, tenants, node)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create server: %w", err)
 	}
-	hs, err := httpserver.New(s, cfg)
+	hooks := webhookRegistry(api, node)
+	dispatcher, err := webhookDispatcher(cfg, bus, hooks, node)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create server: %w", err)
 	}
-	gs, err := grpcserver.New(s, cfg.RPCAddr)
+	// watchers follow changes applied to the local storage, cluster nodes stream series they own
+	hub := watch.NewHub(es, bus)
+	recorder := watch.NewRecorder(es, bus)
+	hs, err := httpserver.New(api, tenants, node, alerts, hooks, hub, recorder, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create server: %w", err)
 	}
-	return newServer(withHTTPServer(hs), withGRPCServer(gs)), nil
+	grpcOpts := []func(*grpc.Server){
+		grpcserver.WithReplication(replication.NewServer(es, tenants, hooks, bus, node)),
+		grpcserver.WithAlerting(alerting.NewServer(alerts)),
+	}
+	if _, ok := api.(*cluster.Storage); ok {
+		grpcOpts = append(grpcOpts, grpcserver.WithCluster(cluster.NewServer(s, hooks)))
+	}
+	gs, err := grpcserver.New(api, hub, cfg.RPCAddr, keys, cfg.AdminKey, grpcOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	opts := []func(*Server){withHTTPServer(hs), withGRPCServer(gs), withCacheFlusher(flusher), withWebhooks(dispatcher), withRecorder(recorder)}
+	if cfg.AlertRules != "" {
+		opts = append(opts, withAlerting(alerts, cfg.AlertInterval*time.Second))
+	}
+	if cfg.ReplicateFrom != "" {
+		logger.Log.Info("Starting as replication follower", zap.String("leader", cfg.ReplicateFrom))
+		opts = append(opts, withFollower(replication.NewFollower(es, tenants, hooks, node, cfg.ReplicateFrom, cfg.AdminKey)))
+	}
+	return newServer(opts...), nil
 }
 
-func storage(cfg *config.ServerConfig) (MetricsStorage, error) {
-	if cfg.DatabaseDNS != "" {
-		s, err := dbStorage(cfg.DatabaseDNS)
+// storage creates the storage partitioned by tenants with the backend selected by the storage URL,
+// storages of the default tenant and tenants found by the backend are opened on start.
+func storage(cfg *config.ServerConfig, flusher *cacheFlusher) (*tenantstorage.Storage, error) {
+	dsn, err := storageURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+	found, err := driver.Tenants(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tenants: %w", err)
+	}
+	// tenants first seen after the start have nothing to restore
+	restored := map[string]bool{tenant.Default: cfg.Restore}
+	for _, id := range found {
+		restored[id] = cfg.Restore
+	}
+	open := func(id string) (tenantstorage.MetricsStorage, error) {
+		s, err := driver.Open(dsn, &driver.Options{
+			Tenant:           id,
+			Retention:        cfg.HistoryRetention * time.Second,
+			SnapshotKeep:     cfg.SnapshotKeep,
+			SnapshotCompress: cfg.SnapshotCompress,
+			Restore:          restored[id],
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to create database storage: %w", err)
+			return nil, err
 		}
-		return s, nil
+		return flusher.cache(s)
 	}
-	s, err := memStorage(cfg.StoreInterval, cfg.Restore, cfg.FileStoragePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create inmemory storage: %w", err)
+
+	ts := tenantstorage.New(open, tenantstorage.WithLimit(cfg.MaxTenants))
+	for _, id := range append([]string{tenant.Default}, found...) {
+		if _, err = ts.Open(id); err != nil {
+			return nil, err
+		}
 	}
-	return s, nil
+	return ts, nil
 }
 
-func dbStorage(dsn string) (MetricsStorage, error) {
-	ctx := context.Background()
-	pool, err := pgxpool.New(ctx, dsn)
+// storageURL returns the configured storage URL.
+// Without one it is built from the database dsn, or from the dump file and the store interval if the dsn is not set either.
+func storageURL(cfg *config.ServerConfig) (string, error) {
+	if cfg.StorageURL != "" {
+		return cfg.StorageURL, nil
+	}
+	if cfg.DatabaseDNS != "" {
+		return cfg.DatabaseDNS, nil
+	}
+	fileName, err := filepath.Abs(cfg.FileStoragePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to databse: %w", err)
+		return "", fmt.Errorf("failed to resolve dump file: %w", err)
+	}
+	u := &url.URL{
+		Scheme:   "file",
+		Path:     filepath.ToSlash(fileName),
+		RawQuery: url.Values{"interval": {(cfg.StoreInterval * time.Second).String()}}.Encode(),
 	}
-	logger.Log.Info("Creating tables")
-	err = createTables(pool)
+	return u.String(), nil
+}
+
+// expiringStorage wraps the storage to hide and purge expired series if any TTL is configured.
+func expiringStorage(s *eventstorage.Storage, tenants *tenantstorage.Storage, node *replication.Node, ttl time.Duration, rules string) (MetricsStorage, error) {
+	parsed, err := ttlstorage.ParseRules(rules)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create tables: %w", err)
+		return nil, fmt.Errorf("failed to parse ttl rules: %w", err)
+	}
+	if ttl == time.Duration(0) && len(parsed) == 0 {
+		return s, nil
 	}
-	logger.Log.Info("Tables created successfully")
-	return dbstorage.New(pool), nil
+	ts := ttlstorage.New(s, &ttlstorage.Policy{Default: ttl, Rules: parsed})
+	go purgeExpired(ts, tenants, node)
+	return ts, nil
 }
 
-func memStorage(si time.Duration, restore bool, dump string) (MetricsStorage, error) {
+// clusterStorage returns the storage sharding metrics across cluster nodes if cluster peers are configured,
+// other nodes call the cluster service served from the local storage of the node.
+func clusterStorage(cfg *config.ServerConfig, s MetricsStorage) (MetricsStorage, error) {
+	if cfg.ClusterPeers == "" {
+		return s, nil
+	}
+	cs, err := cluster.New(cfg.RPCAddr, cluster.ParsePeers(cfg.ClusterPeers), s, cfg.AdminKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cluster storage: %w", err)
+	}
+	logger.Log.Info("Starting as cluster node", zap.String("peers", cfg.ClusterPeers))
+	return cs, nil
+}
 
-	var s MetricsStorage
+// alertingEngine creates the engine of alerting rules loaded from the rules file,
+// rules are evaluated by the replication leader only so receivers are not notified twice,
+// in a cluster they are evaluated against series of all nodes by the elected node only.
+func alertingEngine(cfg *config.ServerConfig, s MetricsStorage, tenants *tenantstorage.Storage, node *replication.Node) (*alerting.Engine, error) {
+	active := node.Leader
+	if cs, ok := s.(*cluster.Storage); ok {
+		active = func() bool {
+			return node.Leader() && cs.Evaluator(context.Background())
+		}
+	}
+	e := alerting.New(s, tenants, alerting.WithRulesFile(cfg.AlertRules), alerting.WithActive(active))
+	if err := e.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load alerting rules: %w", err)
+	}
+	return e, nil
+}
 
-	gRepo := repos.NewGaugeRepo()
-	cRepo := repos.NewCounterRepo()
-	if si != time.Duration(0) {
-		logger.Log.Info("Starting saver")
-		s = memstorage.New(gRepo, cRepo)
-		svr := filestorage.NewSaver(dump, si, s)
-		go svr.Run()
-	} else {
-		s = filestorage.NewFileStorage(gRepo, cRepo, dump)
+// webhookRegistry creates the registry of webhook subscriptions, only the replication leader accepts their changes.
+// In a cluster subscriptions are copied to all nodes, the node starts with subscriptions of available nodes.
+func webhookRegistry(s MetricsStorage, node *replication.Node) *webhooks.Registry {
+	cs, ok := s.(*cluster.Storage)
+	if !ok {
+		return webhooks.NewRegistry(webhooks.WithGuard(node.Guard))
 	}
-	if restore {
-		dumpLoader := loader.New(dump, s)
-		err := dumpLoader.LoadMetrics()
+	hooks := webhooks.NewRegistry(webhooks.WithGuard(node.Guard), webhooks.WithReplicas(cs))
+	hooks.Replace(cs.Webhooks(context.Background()))
+	return hooks
+}
+
+// webhookDispatcher creates the dispatcher of updates to webhook subscriptions,
+// updates are delivered by the replication leader only so subscribers don't receive them twice.
+func webhookDispatcher(cfg *config.ServerConfig, bus *events.Bus, hooks *webhooks.Registry, node *replication.Node) (*webhooks.Dispatcher, error) {
+	opts := []func(*webhooks.Dispatcher){webhooks.WithActive(node.Leader)}
+	if cfg.WebhookDeadLetter != "" {
+		f, err := os.OpenFile(cfg.WebhookDeadLetter, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load dump: %w", err)
+			return nil, fmt.Errorf("failed to open webhook dead-letter log: %w", err)
 		}
+		opts = append(opts, webhooks.WithDeadLetter(f))
 	}
-	return s, nil
+	return webhooks.New(bus, hooks, opts...), nil
 }
 
-func createTables(pool *pgxpool.Pool) error {
-	ctx := context.Background()
-	query := `CREATE TABLE IF NOT EXISTS gauges (id TEXT NOT NULL PRIMARY KEY, value DOUBLE PRECISION NOT NULL);
-			  CREATE TABLE IF NOT EXISTS counters (id TEXT NOT NULL PRIMARY KEY, value BIGINT NOT NULL)`
-	_, err := pool.Exec(ctx, query)
+// cacheFlusher writes metrics cached in front of storages of tenants on interval and on stop.
+type cacheFlusher struct {
+	done     chan struct{}
+	wg       sync.WaitGroup
+	interval time.Duration
+}
+
+func newCacheFlusher(interval time.Duration) *cacheFlusher {
+	return &cacheFlusher{done: make(chan struct{}), interval: interval}
+}
+
+// cache wraps the storage with the write-behind cache if the flush interval is set.
+func (f *cacheFlusher) cache(s driver.MetricsStorage) (tenantstorage.MetricsStorage, error) {
+	if f.interval == time.Duration(0) {
+		return s, nil
+	}
+	c, err := cachestorage.New(context.Background(), s)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to create cache: %w", err)
+	}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		c.Run(f.interval, f.done)
+	}()
+	return c, nil
+}
+
+// Stop flushes all caches and waits until they are written.
+func (f *cacheFlusher) Stop() {
+	close(f.done)
+	f.wg.Wait()
+}
+
+func purgeExpired(s *ttlstorage.Storage, tenants *tenantstorage.Storage, node *replication.Node) {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+
+	for range tick.C {
+		// expired series are purged by the leader and replicated to followers
+		if !node.Leader() {
+			continue
+		}
+		for _, id := range tenants.Tenants() {
+			err := s.Purge(tenant.WithTenant(context.Background(), id))
+			if err != nil {
+				logger.Log.Error("Failed to purge expired metrics", zap.String("tenant", id), zap.Error(err))
+			}
+		}
 	}
-	return nil
 }
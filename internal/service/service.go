@@ -3,14 +3,21 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/vindosVP/metrics/internal/convert"
+	"github.com/vindosVP/metrics/internal/events"
 	"github.com/vindosVP/metrics/internal/models"
 	pb "github.com/vindosVP/metrics/internal/proto"
 	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/watch"
 	"github.com/vindosVP/metrics/pkg/logger"
 )
 
@@ -23,61 +30,140 @@ type MetricsStorage interface {
 	GetCounter(ctx context.Context, name string) (int64, error)
 	GetAllCounter(ctx context.Context) (map[string]int64, error)
 	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// Watcher streams updates of metrics of the tenant of the context selected by the filter.
+type Watcher interface {
+	Watch(ctx context.Context, f *watch.Filter, send func(u *watch.Update) error) error
 }
 
 type MetricsServer struct {
 	pb.UnimplementedMetricsServer
 	s MetricsStorage
+	w Watcher
+}
+
+func NewMetricsServer(s MetricsStorage, opts ...func(*MetricsServer)) *MetricsServer {
+	srv := &MetricsServer{s: s}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return srv
 }
 
-func NewMetricsServer(s MetricsStorage) *MetricsServer {
-	return &MetricsServer{s: s}
+// WithWatcher sets the watcher serving Watch calls, they are unimplemented without it.
+func WithWatcher(w Watcher) func(*MetricsServer) {
+	return func(s *MetricsServer) {
+		s.w = w
+	}
 }
 
 func (s *MetricsServer) Get(ctx context.Context, in *pb.GetRequest) (*pb.GetResponse, error) {
 
 	var resp pb.GetResponse
+	resp.Metric = &pb.Metric{}
 
 	fields := []zap.Field{
 		zap.String("name", in.Id),
 		zap.String("type", in.Type.String()),
 	}
+	if err := models.ValidateSeries(in.Id, in.Labels); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	matchers, err := modelMatchers(in)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
 
+	var key string
 	switch in.Type {
 	case pb.MType_COUNTER:
-		val, cerr := s.s.GetCounter(ctx, in.Id)
+		k, val, cerr := storage.FindSeries(ctx, in.Id, matchers, s.s.GetCounter, s.s.GetAllCounter)
 		if cerr != nil {
-			code := codes.Internal
-			if errors.Is(cerr, storage.ErrMetricNotRegistered) {
-				code = codes.NotFound
-			} else {
+			code := findCode(cerr)
+			if code == codes.Internal {
 				fields = append(fields, zap.Error(cerr))
 				logger.Log.Error("Failed to get metric value", fields...)
 			}
 			return &resp, status.Errorf(code, "failed to get counter: %s", cerr)
 		}
 
-		resp.Metric.Id = in.Id
+		key = k
 		resp.Metric.Type = pb.MType_COUNTER
 		resp.Metric.Delta = val
 	case pb.MType_GAUGE:
-		val, gerr := s.s.GetGauge(ctx, in.Id)
+		k, val, gerr := storage.FindSeries(ctx, in.Id, matchers, s.s.GetGauge, s.s.GetAllGauge)
 		if gerr != nil {
-			code := codes.Internal
-			if errors.Is(gerr, storage.ErrMetricNotRegistered) {
-				code = codes.NotFound
-			} else {
+			code := findCode(gerr)
+			if code == codes.Internal {
 				fields = append(fields, zap.Error(gerr))
 				logger.Log.Error("Failed to get metric value", fields...)
 			}
 			return &resp, status.Errorf(code, "failed to get gauge: %s", gerr)
 		}
 
-		resp.Metric.Id = in.Id
+		key = k
 		resp.Metric.Type = pb.MType_GAUGE
 		resp.Metric.Value = val
+	case pb.MType_HISTOGRAM:
+		k, val, herr := storage.FindSeries(ctx, in.Id, matchers, s.s.GetHistogram, s.s.GetAllHistogram)
+		if herr != nil {
+			code := findCode(herr)
+			if code == codes.Internal {
+				fields = append(fields, zap.Error(herr))
+				logger.Log.Error("Failed to get metric value", fields...)
+			}
+			return &resp, status.Errorf(code, "failed to get histogram: %s", herr)
+		}
+
+		key = k
+		resp.Metric.Type = pb.MType_HISTOGRAM
+		resp.Metric.Histogram = convert.PBHistogram(val)
+	case pb.MType_SUMMARY:
+		k, val, serr := storage.FindSeries(ctx, in.Id, matchers, s.s.GetSummary, s.s.GetAllSummary)
+		if serr != nil {
+			code := findCode(serr)
+			if code == codes.Internal {
+				fields = append(fields, zap.Error(serr))
+				logger.Log.Error("Failed to get metric value", fields...)
+			}
+			return &resp, status.Errorf(code, "failed to get summary: %s", serr)
+		}
+		qs := in.Quantiles
+		if len(qs) == 0 {
+			qs = models.DefaultQuantiles
+		}
+		quantiles, serr := val.Quantiles(qs)
+		if serr != nil {
+			return &resp, status.Errorf(codes.InvalidArgument, "failed to get summary: %s", serr)
+		}
+
+		key = k
+		resp.Metric.Type = pb.MType_SUMMARY
+		resp.Metric.Summary = convert.PBSummary(val)
+		resp.Quantiles = convert.PBQuantiles(quantiles)
 	}
 
+	name, labels, err := models.ParseSeriesKey(key)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to parse series key: %s", err)
+	}
+	resp.Metric.Id = name
+	resp.Metric.Labels = labels
+
 	return &resp, nil
 }
 
@@ -85,24 +171,35 @@ func (s *MetricsServer) Update(ctx context.Context, in *pb.UpdateRequest) (*pb.U
 	var resp pb.UpdateResponse
 	resp.Metric = &pb.Metric{}
 
+	if in.Metric == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "metric is missing")
+	}
+	if err := models.ValidateSeries(in.Metric.Id, in.Metric.Labels); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	if err := s.checkType(ctx, in.Metric.Id, convert.MType(in.Metric.Type)); err != nil {
+		return nil, status.Errorf(updateCode(err), "failed to update metric: %s", err)
+	}
+	key := models.SeriesKey(in.Metric.Id, in.Metric.Labels)
 	fields := []zap.Field{
-		zap.String("name", in.Metric.Id),
+		zap.String("name", key),
 		zap.String("type", in.Metric.Type.String()),
 	}
+	resp.Metric.Id = in.Metric.Id
+	resp.Metric.Labels = in.Metric.Labels
 
 	switch in.Metric.Type {
 	case pb.MType_COUNTER:
 
 		delta := in.Metric.Delta
 		fields = append(fields, zap.Int64("delta", delta))
-		val, cerr := s.s.UpdateCounter(ctx, in.Metric.Id, delta)
+		val, cerr := s.s.UpdateCounter(ctx, key, delta)
 		if cerr != nil {
 			fields = append(fields, zap.Error(cerr))
 			logger.Log.Error("Failed to update metric value", fields...)
-			return nil, status.Errorf(codes.Internal, "failed to update metric: %s", cerr)
+			return nil, status.Errorf(updateCode(cerr), "failed to update metric: %s", cerr)
 		}
 
-		resp.Metric.Id = in.Metric.Id
 		resp.Metric.Type = pb.MType_COUNTER
 		resp.Metric.Delta = val
 
@@ -110,17 +207,50 @@ func (s *MetricsServer) Update(ctx context.Context, in *pb.UpdateRequest) (*pb.U
 	case pb.MType_GAUGE:
 		value := in.Metric.Value
 		fields = append(fields, zap.Float64("value", value))
-		val, gerr := s.s.UpdateGauge(ctx, in.Metric.Id, value)
+		val, gerr := s.s.UpdateGauge(ctx, key, value)
 		if gerr != nil {
 			fields = append(fields, zap.Error(gerr))
 			logger.Log.Error("Failed to update metric value", fields...)
-			return nil, status.Errorf(codes.Internal, "failed to update metric: %s", gerr)
+			return nil, status.Errorf(updateCode(gerr), "failed to update metric: %s", gerr)
 		}
 
-		resp.Metric.Id = in.Metric.Id
 		resp.Metric.Type = pb.MType_GAUGE
 		resp.Metric.Value = val
 
+		logger.Log.Info("Updated metric value", fields...)
+	case pb.MType_HISTOGRAM:
+		value, herr := modelHistogram(in.Metric.Histogram)
+		if herr != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid histogram: %s", herr)
+		}
+		fields = append(fields, zap.Uint64("count", value.Count))
+		val, herr := s.s.UpdateHistogram(ctx, key, value)
+		if herr != nil {
+			fields = append(fields, zap.Error(herr))
+			logger.Log.Error("Failed to update metric value", fields...)
+			return nil, status.Errorf(updateCode(herr), "failed to update metric: %s", herr)
+		}
+
+		resp.Metric.Type = pb.MType_HISTOGRAM
+		resp.Metric.Histogram = convert.PBHistogram(val)
+
+		logger.Log.Info("Updated metric value", fields...)
+	case pb.MType_SUMMARY:
+		value, serr := modelSummary(in.Metric)
+		if serr != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid summary: %s", serr)
+		}
+		fields = append(fields, zap.Uint64("count", value.Count))
+		val, serr := s.s.UpdateSummary(ctx, key, value)
+		if serr != nil {
+			fields = append(fields, zap.Error(serr))
+			logger.Log.Error("Failed to update metric value", fields...)
+			return nil, status.Errorf(updateCode(serr), "failed to update metric: %s", serr)
+		}
+
+		resp.Metric.Type = pb.MType_SUMMARY
+		resp.Metric.Summary = convert.PBSummary(val)
+
 		logger.Log.Info("Updated metric value", fields...)
 	}
 
@@ -133,25 +263,310 @@ func (s *MetricsServer) UpdateBatch(ctx context.Context, in *pb.UpdateBatchReque
 
 	batch := make([]*models.Metrics, 0)
 	for _, v := range in.Metrics {
-		var mType string
-		if v.Type == pb.MType_COUNTER {
-			mType = models.Counter
-		} else {
-			mType = models.Gauge
+		if err := models.ValidateSeries(v.Id, v.Labels); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s", err)
 		}
 		metric := &models.Metrics{
-			Delta: &v.Delta,
-			Value: &v.Value,
-			ID:    v.Id,
-			MType: mType,
+			Delta:  &v.Delta,
+			Value:  &v.Value,
+			Labels: v.Labels,
+			ID:     v.Id,
+			MType:  convert.MType(v.Type),
+		}
+		if v.Type == pb.MType_HISTOGRAM {
+			value, err := modelHistogram(v.Histogram)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid histogram: %s", err)
+			}
+			metric.Histogram = value
+		}
+		if v.Type == pb.MType_SUMMARY {
+			value, err := modelSummary(v)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid summary: %s", err)
+			}
+			metric.Summary = value
 		}
 		batch = append(batch, metric)
 	}
 
-	err := s.s.InsertBatch(ctx, batch)
+	metadata, err := s.s.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get metadata: %s", err)
+	}
+	if err = models.CheckTypes(metadata, batch); err != nil {
+		return nil, status.Errorf(updateCode(err), "failed to insert batch: %s", err)
+	}
+
+	err = s.s.InsertBatch(ctx, batch)
+	if err != nil {
+		return nil, status.Errorf(updateCode(err), "failed to insert batch: %s", err)
+	}
+
+	return &resp, nil
+}
+
+func (s *MetricsServer) GetHistory(ctx context.Context, in *pb.GetHistoryRequest) (*pb.GetHistoryResponse, error) {
+
+	var resp pb.GetHistoryResponse
+
+	fields := []zap.Field{
+		zap.String("name", in.Id),
+		zap.String("type", in.Type.String()),
+	}
+
+	if err := models.ValidateSeries(in.Id, in.Labels); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	from := time.Time{}
+	if in.From != nil {
+		from = in.From.AsTime()
+	}
+	to := time.Now()
+	if in.To != nil {
+		to = in.To.AsTime()
+	}
+
+	samples, err := s.s.GetHistory(ctx, convert.MType(in.Type), models.SeriesKey(in.Id, in.Labels), from, to)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to insert batch: %s", err)
+		code := codes.Internal
+		if errors.Is(err, storage.ErrMetricNotRegistered) {
+			code = codes.NotFound
+		} else {
+			fields = append(fields, zap.Error(err))
+			logger.Log.Error("Failed to get metric history", fields...)
+		}
+		return nil, status.Errorf(code, "failed to get history: %s", err)
+	}
+
+	resp.Samples = make([]*pb.Sample, 0, len(samples))
+	for _, v := range samples {
+		sample := &pb.Sample{Timestamp: timestamppb.New(v.Timestamp)}
+		if v.Delta != nil {
+			sample.Delta = *v.Delta
+		}
+		if v.Value != nil {
+			sample.Value = *v.Value
+		}
+		resp.Samples = append(resp.Samples, sample)
 	}
 
 	return &resp, nil
 }
+
+func (s *MetricsServer) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+
+	var resp pb.DeleteResponse
+
+	if err := models.ValidateSeries(in.Id, in.Labels); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	key := models.SeriesKey(in.Id, in.Labels)
+	err := s.s.Delete(ctx, convert.MType(in.Type), key)
+	if err != nil {
+		code := codes.Internal
+		if errors.Is(err, storage.ErrMetricNotRegistered) {
+			code = codes.NotFound
+		} else {
+			logger.Log.Error("Failed to delete metric", zap.String("name", key), zap.Error(err))
+		}
+		return nil, status.Errorf(code, "failed to delete metric: %s", err)
+	}
+	logger.Log.Info("Deleted metric", zap.String("name", key), zap.String("type", in.Type.String()))
+
+	return &resp, nil
+}
+
+func (s *MetricsServer) RegisterMetadata(ctx context.Context, in *pb.RegisterMetadataRequest) (*pb.RegisterMetadataResponse, error) {
+
+	var resp pb.RegisterMetadataResponse
+
+	if in.Metadata == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "metadata is missing")
+	}
+	meta := convert.Metadata(in.Metadata)
+	if err := meta.Validate(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	err := s.s.RegisterMetadata(ctx, meta)
+	if err != nil {
+		logger.Log.Error("Failed to register metric metadata", zap.String("name", meta.ID), zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "failed to register metadata: %s", err)
+	}
+	logger.Log.Info("Registered metric metadata", zap.String("name", meta.ID), zap.String("type", meta.MType))
+
+	return &resp, nil
+}
+
+func (s *MetricsServer) GetMetadata(ctx context.Context, in *pb.GetMetadataRequest) (*pb.GetMetadataResponse, error) {
+
+	var resp pb.GetMetadataResponse
+
+	meta, err := s.s.GetMetadata(ctx, in.Id)
+	if err != nil {
+		return nil, status.Errorf(findCode(err), "failed to get metadata: %s", err)
+	}
+	resp.Metadata = convert.PBMetadata(meta)
+
+	return &resp, nil
+}
+
+func (s *MetricsServer) ListMetadata(ctx context.Context, _ *pb.ListMetadataRequest) (*pb.ListMetadataResponse, error) {
+
+	var resp pb.ListMetadataResponse
+
+	metadata, err := s.s.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get metadata: %s", err)
+	}
+	resp.Metadata = make([]*pb.Metadata, 0, len(metadata))
+	for _, meta := range metadata {
+		resp.Metadata = append(resp.Metadata, convert.PBMetadata(meta))
+	}
+	sort.Slice(resp.Metadata, func(i, j int) bool { return resp.Metadata[i].Id < resp.Metadata[j].Id })
+
+	return &resp, nil
+}
+
+// Watch streams the snapshot of selected metrics followed by their values updated as changes are applied.
+// The stream is aborted with codes.ResourceExhausted if the client doesn't keep up with updates.
+func (s *MetricsServer) Watch(in *pb.WatchRequest, stream pb.Metrics_WatchServer) error {
+	if s.w == nil {
+		return status.Error(codes.Unimplemented, "watching metrics is not supported")
+	}
+
+	f := &watch.Filter{Names: in.Names}
+	for _, t := range in.Types {
+		f.Types = append(f.Types, convert.MType(t))
+	}
+	if err := f.Validate(); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid filter: %s", err)
+	}
+
+	err := s.w.Watch(stream.Context(), f, func(u *watch.Update) error {
+		resp, err := pbWatchResponse(u)
+		if err != nil {
+			return err
+		}
+		return stream.Send(resp)
+	})
+	switch {
+	case errors.Is(err, events.ErrLagged):
+		logger.Log.Warn("Dropped watcher lagging behind metric updates")
+		return status.Errorf(codes.ResourceExhausted, "failed to watch metrics: %s", err)
+	case stream.Context().Err() != nil:
+		return status.FromContextError(stream.Context().Err()).Err()
+	case err != nil:
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		logger.Log.Error("Failed to watch metrics", zap.Error(err))
+		return status.Errorf(codes.Internal, "failed to watch metrics: %s", err)
+	}
+	return nil
+}
+
+// checkType returns models.ErrTypeConflict if the metric is declared with another type.
+func (s *MetricsServer) checkType(ctx context.Context, name string, mType string) error {
+	meta, err := s.s.GetMetadata(ctx, name)
+	if errors.Is(err, storage.ErrMetricNotRegistered) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return meta.CheckType(mType)
+}
+
+func findCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, storage.ErrMetricNotRegistered):
+		return codes.NotFound
+	case errors.Is(err, storage.ErrAmbiguousSeries):
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
+
+// updateCode returns grpc code for the error of a metric update,
+// merging of incompatible histograms or summaries is a client error,
+// update with a type different from the declared one fails the precondition,
+// followers of the replication leader don't accept updates,
+// updates of new tenants beyond the limit of tenants exhaust the resource.
+func updateCode(err error) codes.Code {
+	if errors.Is(err, models.ErrBucketsMismatch) || errors.Is(err, models.ErrSketchMismatch) {
+		return codes.InvalidArgument
+	}
+	if errors.Is(err, models.ErrTypeConflict) {
+		return codes.FailedPrecondition
+	}
+	if errors.Is(err, storage.ErrReadOnly) {
+		return codes.Unavailable
+	}
+	if errors.Is(err, storage.ErrTenantLimit) {
+		return codes.ResourceExhausted
+	}
+	return codes.Internal
+}
+
+func pbWatchResponse(u *watch.Update) (*pb.WatchResponse, error) {
+	if u.Metric == nil {
+		return &pb.WatchResponse{Event: &pb.WatchResponse_SnapshotDone{SnapshotDone: true}}, nil
+	}
+	name, labels, err := models.ParseSeriesKey(u.Metric.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to parse series key: %s", err)
+	}
+	m := convert.PBMetric(u.Metric)
+	m.Id, m.Labels = name, labels
+	if u.Snapshot {
+		return &pb.WatchResponse{Event: &pb.WatchResponse_Snapshot{Snapshot: m}}, nil
+	}
+	return &pb.WatchResponse{Event: &pb.WatchResponse_Update{Update: &pb.WatchUpdate{
+		Metric:  m,
+		Deleted: u.Deleted,
+		Seq:     u.Seq,
+		Time:    timestamppb.New(u.Time),
+	}}}, nil
+}
+
+func modelHistogram(h *pb.Histogram) (*models.HistogramValue, error) {
+	if h == nil {
+		return nil, models.ErrInvalidHistogram
+	}
+	value := convert.Histogram(h)
+	if err := value.Validate(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// modelMatchers returns equality matchers of labels of the request followed by its label matchers.
+func modelMatchers(in *pb.GetRequest) ([]*models.LabelMatcher, error) {
+	matchers := models.EqualMatchers(in.Labels)
+	for _, m := range in.Matchers {
+		if !models.ValidLabelName(m.Name) {
+			return nil, fmt.Errorf("%w %q", models.ErrInvalidLabelName, m.Name)
+		}
+		matcher, err := models.NewLabelMatcher(convert.MatchType(m.Type), m.Name, m.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher for label %q: %w", m.Name, err)
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+// modelSummary returns the sketch of the metric, raw observations are used when no sketch is provided.
+func modelSummary(m *pb.Metric) (*models.SummaryValue, error) {
+	metric := &models.Metrics{Observations: m.Observations, Summary: convert.Summary(m.Summary)}
+	value := metric.SummarySketch()
+	if value == nil {
+		return nil, models.ErrInvalidSummary
+	}
+	if err := value.Validate(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
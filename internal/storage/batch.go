@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// BatchStorage consists methods to get stored values batches are merged with
+type BatchStorage interface {
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+}
+
+// CheckBatch makes sure histograms and summaries of the batch can be merged with each other and values stored in s,
+// so the batch can be applied to s.
+func CheckBatch(ctx context.Context, s BatchStorage, batch []*models.Metrics) error {
+	histograms := make(map[string]*models.HistogramValue)
+	summaries := make(map[string]*models.SummaryValue)
+	for _, metric := range batch {
+		key := metric.Key()
+		switch metric.MType {
+		case models.Histogram:
+			if metric.Histogram == nil {
+				return models.ErrInvalidHistogram
+			}
+			first, ok := histograms[key]
+			if !ok {
+				current, err := s.GetHistogram(ctx, key)
+				if err != nil && !errors.Is(err, ErrMetricNotRegistered) {
+					return err
+				}
+				first = metric.Histogram
+				if err == nil {
+					first = current
+				}
+				histograms[key] = first
+			}
+			if !first.SameBounds(metric.Histogram) {
+				return models.ErrBucketsMismatch
+			}
+		case models.Summary:
+			sketch := metric.SummarySketch()
+			if sketch == nil {
+				continue
+			}
+			first, ok := summaries[key]
+			if !ok {
+				current, err := s.GetSummary(ctx, key)
+				if err != nil && !errors.Is(err, ErrMetricNotRegistered) {
+					return err
+				}
+				first = sketch
+				if err == nil {
+					first = current
+				}
+				summaries[key] = first
+			}
+			if first.Accuracy != sketch.Accuracy {
+				return models.ErrSketchMismatch
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,414 @@
+// Package cachestorage is a metrics storage wrapper serving gauges and counters from memory
+// and writing them to the wrapped storage asynchronously in coalesced batches.
+package cachestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// MetricsStorage consists methods to save and get data from the wrapped storage
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=MetricsStorage
+type MetricsStorage interface {
+	UpdateGauge(ctx context.Context, name string, v float64) (float64, error)
+	UpdateCounter(ctx context.Context, name string, v int64) (int64, error)
+	SetCounter(ctx context.Context, name string, v int64) (int64, error)
+	GetGauge(ctx context.Context, name string) (float64, error)
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetCounter(ctx context.Context, name string) (int64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// pendingCounter - counter changes not yet written to the wrapped storage,
+// the counter is set to value plus delta if set, otherwise delta is added to it.
+type pendingCounter struct {
+	value int64
+	delta int64
+	set   bool
+}
+
+// Storage consists cached gauges and counters, update times of all metrics
+// and changes of gauges and counters not yet written to the wrapped storage.
+// Gauges, counters and update times are read from memory only, other metrics are passed to the wrapped storage as is.
+// Intermediate values of a metric updated several times between flushes don't get into its history.
+type Storage struct {
+	MetricsStorage
+	gRepo    *repos.GaugeRepo
+	cRepo    *repos.CounterRepo
+	uRepo    *repos.UpdatedRepo
+	gauges   map[string]float64
+	counters map[string]*pendingCounter
+	// mu guards pending changes together with the cache,
+	// so the cache and pending changes are always updated in the same order
+	mu sync.Mutex
+	// flushMu serializes flushes and deletes, so a flush in progress doesn't write deleted metrics back
+	flushMu sync.Mutex
+}
+
+// New creates Storage with gauges, counters and update times loaded from the wrapped storage.
+func New(ctx context.Context, s MetricsStorage) (*Storage, error) {
+	c := &Storage{
+		MetricsStorage: s,
+		gRepo:          repos.NewGaugeRepo(),
+		cRepo:          repos.NewCounterRepo(),
+		uRepo:          repos.NewUpdatedRepo(),
+		gauges:         make(map[string]float64),
+		counters:       make(map[string]*pendingCounter),
+	}
+	gauges, err := s.GetAllGauge(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gauges: %w", err)
+	}
+	for name, v := range gauges {
+		_, _ = c.gRepo.Update(ctx, name, v)
+	}
+	counters, err := s.GetAllCounter(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load counters: %w", err)
+	}
+	for name, v := range counters {
+		_, _ = c.cRepo.Set(ctx, name, v)
+	}
+	for _, mType := range models.Types {
+		updated, err := s.GetUpdated(ctx, mType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load update times: %w", err)
+		}
+		for name, t := range updated {
+			_ = c.uRepo.Set(ctx, mType, name, t)
+		}
+	}
+	return c, nil
+}
+
+// UpdateGauge method updates cached gauge metric value, the last value is written on flush.
+func (c *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gauges[name] = v
+	c.touch(ctx, models.Gauge, name)
+	return c.gRepo.Update(ctx, name, v)
+}
+
+// UpdateCounter method adds the value to the cached counter metric, deltas are summed until flush.
+func (c *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pendingCounter(name).add(v)
+	c.touch(ctx, models.Counter, name)
+	return c.cRepo.Update(ctx, name, v)
+}
+
+// SetCounter method sets cached counter metric value.
+func (c *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[name] = &pendingCounter{value: v, set: true}
+	c.touch(ctx, models.Counter, name)
+	return c.cRepo.Set(ctx, name, v)
+}
+
+// UpdateHistogram method merges observations into the histogram of the wrapped storage.
+func (c *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	val, err := c.MetricsStorage.UpdateHistogram(ctx, name, v)
+	if err != nil {
+		return nil, err
+	}
+	c.touch(ctx, models.Histogram, name)
+	return val, nil
+}
+
+// UpdateSummary method merges the sketch into the summary of the wrapped storage.
+func (c *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	val, err := c.MetricsStorage.UpdateSummary(ctx, name, v)
+	if err != nil {
+		return nil, err
+	}
+	c.touch(ctx, models.Summary, name)
+	return val, nil
+}
+
+// touch sets update time of the metric to now.
+func (c *Storage) touch(ctx context.Context, mType string, name string) {
+	_ = c.uRepo.Set(ctx, mType, name, time.Now())
+}
+
+func (c *Storage) pendingCounter(name string) *pendingCounter {
+	p, ok := c.counters[name]
+	if !ok {
+		p = &pendingCounter{}
+		c.counters[name] = p
+	}
+	return p
+}
+
+func (p *pendingCounter) add(v int64) {
+	if p.set {
+		p.value += v
+		return
+	}
+	p.delta += v
+}
+
+// InsertBatch method caches gauges and counters of the batch and passes other metrics to the wrapped storage.
+// Other metrics are written first, so the batch is not cached if they fail.
+func (c *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	rest := make([]*models.Metrics, 0)
+	for _, metric := range batch {
+		if metric.MType != models.Gauge && metric.MType != models.Counter {
+			rest = append(rest, metric)
+		}
+	}
+	if len(rest) > 0 {
+		if err := c.MetricsStorage.InsertBatch(ctx, rest); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, metric := range batch {
+		key := metric.Key()
+		c.touch(ctx, metric.MType, key)
+		switch metric.MType {
+		case models.Gauge:
+			c.gauges[key] = *metric.Value
+			_, _ = c.gRepo.Update(ctx, key, *metric.Value)
+		case models.Counter:
+			c.pendingCounter(key).add(*metric.Delta)
+			_, _ = c.cRepo.Update(ctx, key, *metric.Delta)
+		}
+	}
+	return nil
+}
+
+// GetGauge method returns cached gauge metric value.
+func (c *Storage) GetGauge(ctx context.Context, name string) (float64, error) {
+	v, err := c.gRepo.Get(ctx, name)
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return 0, storage.ErrMetricNotRegistered
+	}
+	return v, err
+}
+
+// GetAllGauge method returns cached values of all gauge metrics.
+func (c *Storage) GetAllGauge(ctx context.Context) (map[string]float64, error) {
+	return c.gRepo.GetAll(ctx)
+}
+
+// GetCounter method returns cached counter metric value.
+func (c *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
+	v, err := c.cRepo.Get(ctx, name)
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return 0, storage.ErrMetricNotRegistered
+	}
+	return v, err
+}
+
+// GetAllCounter method returns cached values of all counter metrics.
+func (c *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
+	return c.cRepo.GetAll(ctx)
+}
+
+// GetHistory method flushes pending changes and returns samples of the metric from the wrapped storage.
+func (c *Storage) GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	if err := c.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return c.MetricsStorage.GetHistory(ctx, mType, name, from, to)
+}
+
+// GetUpdated method returns cached update times of metrics with provided type.
+func (c *Storage) GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	return c.uRepo.GetAll(ctx, mType)
+}
+
+// GetUpdatedAt method returns cached update time of the metric.
+func (c *Storage) GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error) {
+	t, err := c.uRepo.Get(ctx, mType, name)
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return time.Time{}, storage.ErrMetricNotRegistered
+	}
+	return t, err
+}
+
+// Delete method removes the metric from the cache and the wrapped storage.
+func (c *Storage) Delete(ctx context.Context, mType string, name string) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	cached, err := c.forget(ctx, mType, name)
+	if err != nil {
+		return err
+	}
+	err = c.MetricsStorage.Delete(ctx, mType, name)
+	if cached && errors.Is(err, storage.ErrMetricNotRegistered) {
+		return nil
+	}
+	return err
+}
+
+// DeleteBatch method removes all provided metrics from the cache and the wrapped storage.
+func (c *Storage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	for _, metric := range batch {
+		if _, err := c.forget(ctx, metric.MType, metric.Key()); err != nil {
+			return err
+		}
+	}
+	return c.MetricsStorage.DeleteBatch(ctx, batch)
+}
+
+// forget removes the metric, its update time and pending changes from the cache,
+// reports whether the metric was cached.
+func (c *Storage) forget(ctx context.Context, mType string, name string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.uRepo.Delete(ctx, mType, name)
+	var err error
+	switch mType {
+	case models.Gauge:
+		delete(c.gauges, name)
+		err = c.gRepo.Delete(ctx, name)
+	case models.Counter:
+		delete(c.counters, name)
+		err = c.cRepo.Delete(ctx, name)
+	default:
+		return false, nil
+	}
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Flush method writes pending changes to the wrapped storage.
+// Changes which failed to be written are kept pending until the next flush.
+func (c *Storage) Flush(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	gauges, counters := c.gauges, c.counters
+	c.gauges = make(map[string]float64)
+	c.counters = make(map[string]*pendingCounter)
+	c.mu.Unlock()
+	if len(gauges) == 0 && len(counters) == 0 {
+		return nil
+	}
+
+	batch := make([]*models.Metrics, 0, len(gauges)+len(counters))
+	for name, v := range gauges {
+		v := v
+		batch = append(batch, series(name, models.Gauge, &models.Metrics{Value: &v}))
+	}
+	sets := make(map[string]int64)
+	for name, p := range counters {
+		if p.set {
+			sets[name] = p.value + p.delta
+			continue
+		}
+		delta := p.delta
+		batch = append(batch, series(name, models.Counter, &models.Metrics{Delta: &delta}))
+	}
+
+	err := c.MetricsStorage.InsertBatch(ctx, batch)
+	if err != nil {
+		c.requeue(gauges, counters)
+		return fmt.Errorf("failed to flush metrics: %w", err)
+	}
+	// only sets not written yet are left to requeue
+	for name, p := range counters {
+		if !p.set {
+			delete(counters, name)
+		}
+	}
+	for name, v := range sets {
+		if _, err = c.MetricsStorage.SetCounter(ctx, name, v); err != nil {
+			c.requeue(nil, counters)
+			return fmt.Errorf("failed to flush metrics: %w", err)
+		}
+		delete(counters, name)
+	}
+	return nil
+}
+
+// series returns the metric identified by the series key.
+func series(key string, mType string, m *models.Metrics) *models.Metrics {
+	name, labels, err := models.ParseSeriesKey(key)
+	if err != nil {
+		name, labels = key, nil
+	}
+	m.ID, m.MType, m.Labels = name, mType, labels
+	return m
+}
+
+// requeue returns changes which failed to be written to pending ones, newer changes take precedence.
+func (c *Storage) requeue(gauges map[string]float64, counters map[string]*pendingCounter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, v := range gauges {
+		if _, ok := c.gauges[name]; !ok {
+			c.gauges[name] = v
+		}
+	}
+	for name, old := range counters {
+		p, ok := c.counters[name]
+		switch {
+		case !ok:
+			c.counters[name] = old
+		case !p.set:
+			// newer deltas are applied on top of the older changes
+			old.add(p.delta)
+			c.counters[name] = old
+		}
+	}
+}
+
+// Run flushes pending changes every interval until done is closed, then flushes them for the last time.
+func (c *Storage) Run(interval time.Duration, done <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-done:
+			c.flush()
+			return
+		case <-tick.C:
+			c.flush()
+		}
+	}
+}
+
+func (c *Storage) flush() {
+	if err := c.Flush(context.Background()); err != nil {
+		logger.Log.Error("Failed to flush cached metrics", zap.Error(err))
+	}
+}
@@ -0,0 +1,273 @@
+package cachestorage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/cachestorage/mocks"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/storage/storagetest"
+)
+
+func newBacking() *memstorage.Storage {
+	return memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo(), memstorage.WithHistory(repos.NewHistoryRepo(time.Hour)))
+}
+
+// flushingStorage flushes after every write, so each update gets into the history as the suite expects.
+type flushingStorage struct {
+	*Storage
+}
+
+func (s flushingStorage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
+	v, err := s.Storage.UpdateGauge(ctx, name, v)
+	if err != nil {
+		return 0, err
+	}
+	return v, s.Flush(ctx)
+}
+
+func (s flushingStorage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
+	v, err := s.Storage.UpdateCounter(ctx, name, v)
+	if err != nil {
+		return 0, err
+	}
+	return v, s.Flush(ctx)
+}
+
+func (s flushingStorage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	if err := s.Storage.InsertBatch(ctx, batch); err != nil {
+		return err
+	}
+	return s.Flush(ctx)
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.MetricsStorage {
+		s, err := New(context.Background(), newBacking())
+		require.NoError(t, err)
+		return flushingStorage{s}
+	})
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	backing := newBacking()
+	_, err := backing.UpdateGauge(ctx, "Alloc", 1.5)
+	require.NoError(t, err)
+	_, err = backing.UpdateCounter(ctx, "PollCount", 3)
+	require.NoError(t, err)
+
+	s, err := New(ctx, backing)
+	require.NoError(t, err)
+	g, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, g)
+	c, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), c)
+	_, err = s.GetGauge(ctx, "Unknown")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+}
+
+func TestStorage_Flush(t *testing.T) {
+	ctx := context.Background()
+	backing := newBacking()
+	_, err := backing.UpdateCounter(ctx, "PollCount", 10)
+	require.NoError(t, err)
+	s, err := New(ctx, backing)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = s.UpdateCounter(ctx, "PollCount", 1)
+		require.NoError(t, err)
+		_, err = s.UpdateGauge(ctx, "Alloc", float64(i))
+		require.NoError(t, err)
+	}
+	_, err = s.UpdateCounter(ctx, `Requests{path="/"}`, 2)
+	require.NoError(t, err)
+	_, err = s.SetCounter(ctx, "Reset", 7)
+	require.NoError(t, err)
+	_, err = s.UpdateCounter(ctx, "Reset", 1)
+	require.NoError(t, err)
+
+	_, err = backing.GetGauge(ctx, "Alloc")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered, "writes must not reach the backing storage before flush")
+
+	require.NoError(t, s.Flush(ctx))
+	counters, err := backing.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 15, `Requests{path="/"}`: 2, "Reset": 8}, counters)
+	gauges, err := backing.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Alloc": 4}, gauges)
+
+	history, err := backing.GetHistory(ctx, models.Counter, "PollCount", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, history, 2, "deltas between flushes must be coalesced")
+
+	require.NoError(t, s.Flush(ctx))
+	counters, err = backing.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), counters["PollCount"], "flushed changes must not be written twice")
+}
+
+func TestStorage_FlushFailed(t *testing.T) {
+	ctx := context.Background()
+	backing := mocks.NewMetricsStorage(t)
+	backing.On("GetAllGauge", mock.Anything).Return(map[string]float64{}, nil)
+	backing.On("GetAllCounter", mock.Anything).Return(map[string]int64{}, nil)
+	backing.On("GetUpdated", mock.Anything, mock.Anything).Return(map[string]time.Time{}, nil)
+	s, err := New(ctx, backing)
+	require.NoError(t, err)
+
+	_, err = s.UpdateCounter(ctx, "PollCount", 2)
+	require.NoError(t, err)
+	backing.On("InsertBatch", mock.Anything, mock.Anything).Return(errors.New("unexpected error")).Once()
+	assert.Error(t, s.Flush(ctx))
+
+	_, err = s.UpdateCounter(ctx, "PollCount", 3)
+	require.NoError(t, err)
+	var written []*models.Metrics
+	backing.On("InsertBatch", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]*models.Metrics)
+	}).Return(nil).Once()
+	require.NoError(t, s.Flush(ctx))
+	require.Len(t, written, 1)
+	assert.Equal(t, "PollCount", written[0].ID)
+	assert.Equal(t, int64(5), *written[0].Delta, "failed changes must be written with the next flush")
+}
+
+func TestStorage_FlushSetFailed(t *testing.T) {
+	ctx := context.Background()
+	backing := mocks.NewMetricsStorage(t)
+	backing.On("GetAllGauge", mock.Anything).Return(map[string]float64{}, nil)
+	backing.On("GetAllCounter", mock.Anything).Return(map[string]int64{}, nil)
+	backing.On("GetUpdated", mock.Anything, mock.Anything).Return(map[string]time.Time{}, nil)
+	s, err := New(ctx, backing)
+	require.NoError(t, err)
+
+	_, err = s.SetCounter(ctx, "First", 1)
+	require.NoError(t, err)
+	_, err = s.SetCounter(ctx, "Second", 2)
+	require.NoError(t, err)
+	backing.On("InsertBatch", mock.Anything, mock.Anything).Return(nil)
+	backing.On("SetCounter", mock.Anything, mock.Anything, mock.Anything).Return(int64(0), errors.New("unexpected error")).Once()
+	assert.Error(t, s.Flush(ctx))
+
+	written := make(map[string]int64)
+	backing.On("SetCounter", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written[args.String(1)] = args.Get(2).(int64)
+	}).Return(int64(0), nil)
+	require.NoError(t, s.Flush(ctx))
+	assert.Equal(t, map[string]int64{"First": 1, "Second": 2}, written, "sets not written must be kept pending")
+}
+
+func TestStorage_Delete(t *testing.T) {
+	ctx := context.Background()
+	backing := newBacking()
+	s, err := New(ctx, backing)
+	require.NoError(t, err)
+
+	_, err = s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, models.Gauge, "Alloc"), "pending metrics must be deleted")
+	require.NoError(t, s.Flush(ctx))
+	_, err = backing.GetGauge(ctx, "Alloc")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	assert.ErrorIs(t, s.Delete(ctx, models.Gauge, "Alloc"), storage.ErrMetricNotRegistered)
+}
+
+func TestStorage_GetUpdatedAt(t *testing.T) {
+	ctx := context.Background()
+	backing := newBacking()
+	old := time.Now().Add(-time.Hour)
+	_, err := backing.UpdateCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+	require.NoError(t, backing.SetUpdated(ctx, models.Counter, "PollCount", old))
+	s, err := New(ctx, backing)
+	require.NoError(t, err)
+
+	updated, err := s.GetUpdatedAt(ctx, models.Counter, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, old, updated)
+	_, err = s.GetUpdatedAt(ctx, models.Gauge, "Alloc")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+
+	_, err = s.UpdateCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	updated, err = s.GetUpdatedAt(ctx, models.Counter, "PollCount")
+	require.NoError(t, err)
+	assert.True(t, updated.After(old), "pending changes must be reported as updated")
+	_, err = s.GetUpdatedAt(ctx, models.Gauge, "Alloc")
+	require.NoError(t, err)
+	_, err = backing.GetGauge(ctx, "Alloc")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered, "pending changes must not be flushed")
+}
+
+func TestStorage_GetUpdated(t *testing.T) {
+	ctx := context.Background()
+	backing := newBacking()
+	old := time.Now().Add(-time.Hour)
+	_, err := backing.UpdateCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+	require.NoError(t, backing.SetUpdated(ctx, models.Counter, "PollCount", old))
+	s, err := New(ctx, backing)
+	require.NoError(t, err)
+
+	updated, err := s.GetUpdated(ctx, models.Counter)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Time{"PollCount": old}, updated)
+
+	_, err = s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	_, err = s.UpdateHistogram(ctx, "Latency", &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1, Sum: 0.5})
+	require.NoError(t, err)
+	updated, err = s.GetUpdated(ctx, models.Gauge)
+	require.NoError(t, err)
+	assert.Contains(t, updated, "Alloc")
+	updated, err = s.GetUpdated(ctx, models.Histogram)
+	require.NoError(t, err)
+	assert.Contains(t, updated, "Latency")
+	_, err = backing.GetGauge(ctx, "Alloc")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered, "pending changes must not be flushed")
+
+	require.NoError(t, s.Delete(ctx, models.Gauge, "Alloc"))
+	updated, err = s.GetUpdated(ctx, models.Gauge)
+	require.NoError(t, err)
+	assert.Empty(t, updated)
+}
+
+func TestStorage_Run(t *testing.T) {
+	ctx := context.Background()
+	backing := newBacking()
+	s, err := New(ctx, backing)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Run(time.Hour, done)
+	}()
+	close(done)
+	wg.Wait()
+
+	v, err := backing.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), v, "pending changes must be flushed on stop")
+}
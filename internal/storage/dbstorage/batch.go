@@ -0,0 +1,137 @@
+package dbstorage
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// Counters and gauges of a batch are written by one statement each, passing ids and values as arrays,
+// ids must be unique within the arrays, so the batch is aggregated beforehand.
+const (
+	batchGaugesQuery = `with upd as (
+			insert into gauges as t (id, value) select * from unnest($1::text[], $2::double precision[])
+			on conflict (id) do update set value = excluded.value, updated_at = now() returning id, value
+		) insert into history (type, id, ts, value) select 'gauge', id, now(), value from upd`
+	batchCountersQuery = `with upd as (
+			insert into counters as t (id, value) select * from unnest($1::text[], $2::bigint[])
+			on conflict (id) do update set value = t.value + excluded.value, updated_at = now() returning id, value
+		) insert into history (type, id, ts, delta) select 'counter', id, now(), value from upd`
+)
+
+// aggregatedBatch - metrics of a batch with duplicates merged:
+// counter deltas are summed, the last gauge value wins, histograms and summaries are merged.
+type aggregatedBatch struct {
+	counters   map[string]int64
+	gauges     map[string]float64
+	histograms map[string]*models.HistogramValue
+	summaries  map[string]*models.SummaryValue
+}
+
+func aggregate(batch []*models.Metrics) (*aggregatedBatch, error) {
+	a := &aggregatedBatch{
+		counters:   make(map[string]int64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string]*models.HistogramValue),
+		summaries:  make(map[string]*models.SummaryValue),
+	}
+	for _, metric := range batch {
+		key := metric.Key()
+		switch metric.MType {
+		case models.Counter:
+			a.counters[key] += *metric.Delta
+		case models.Gauge:
+			a.gauges[key] = *metric.Value
+		case models.Histogram:
+			current, ok := a.histograms[key]
+			if !ok {
+				a.histograms[key] = metric.Histogram.Copy()
+				continue
+			}
+			if err := current.Merge(metric.Histogram); err != nil {
+				return nil, err
+			}
+		case models.Summary:
+			sketch := metric.SummarySketch()
+			if sketch == nil {
+				continue
+			}
+			current, ok := a.summaries[key]
+			if !ok {
+				a.summaries[key] = sketch.Copy()
+				continue
+			}
+			if err := current.Merge(sketch); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return a, nil
+}
+
+// sortedIDs returns sorted ids of the metrics,
+// rows are always locked in the same order, so concurrent batches don't deadlock.
+func sortedIDs[V any](metrics map[string]V) []string {
+	ids := make([]string, 0, len(metrics))
+	for id := range metrics {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// queue returns the batch of queries writing counters, gauges and histograms in one round trip.
+func (a *aggregatedBatch) queue() *pgx.Batch {
+	b := &pgx.Batch{}
+	if len(a.counters) > 0 {
+		ids := sortedIDs(a.counters)
+		values := make([]int64, 0, len(ids))
+		for _, id := range ids {
+			values = append(values, a.counters[id])
+		}
+		b.Queue(batchCountersQuery, ids, values)
+	}
+	if len(a.gauges) > 0 {
+		ids := sortedIDs(a.gauges)
+		values := make([]float64, 0, len(ids))
+		for _, id := range ids {
+			values = append(values, a.gauges[id])
+		}
+		b.Queue(batchGaugesQuery, ids, values)
+	}
+	for _, id := range sortedIDs(a.histograms) {
+		v := a.histograms[id]
+		counts := make([]int64, len(v.Counts))
+		for i, c := range v.Counts {
+			counts[i] = int64(c)
+		}
+		b.Queue(updateHistogramQuery, id, v.Bounds, counts, int64(v.Count), v.Sum).QueryRow(func(row pgx.Row) error {
+			_, err := scanHistogram(row)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.ErrBucketsMismatch
+			}
+			return err
+		})
+	}
+	return b
+}
+
+// write saves the batch in the transaction,
+// summaries are merged under the row lock, so they take a round trip each.
+func (a *aggregatedBatch) write(ctx context.Context, tx pgx.Tx) error {
+	if b := a.queue(); b.Len() > 0 {
+		if err := tx.SendBatch(ctx, b).Close(); err != nil {
+			return err
+		}
+	}
+	for _, id := range sortedIDs(a.summaries) {
+		if _, err := updateSummary(ctx, tx, id, a.summaries[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
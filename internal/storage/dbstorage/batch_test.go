@@ -0,0 +1,107 @@
+package dbstorage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage/dbstorage/migrations"
+)
+
+func counter(name string, delta int64) *models.Metrics {
+	return &models.Metrics{ID: name, MType: models.Counter, Delta: &delta}
+}
+
+func gauge(name string, value float64) *models.Metrics {
+	return &models.Metrics{ID: name, MType: models.Gauge, Value: &value}
+}
+
+func histogram(name string, bounds []float64, counts []uint64) *models.Metrics {
+	h := &models.HistogramValue{Bounds: bounds, Counts: counts}
+	for _, c := range counts {
+		h.Count += c
+	}
+	return &models.Metrics{ID: name, MType: models.Histogram, Histogram: h}
+}
+
+// largeBatch returns a batch of n metrics over n/10 counters and n/10 gauges.
+func largeBatch(n int) []*models.Metrics {
+	batch := make([]*models.Metrics, 0, n)
+	for i := 0; i < n/2; i++ {
+		batch = append(batch, counter(fmt.Sprintf("Counter%d", i%(n/10)), 1))
+		batch = append(batch, gauge(fmt.Sprintf("Gauge%d", i%(n/10)), float64(i)))
+	}
+	return batch
+}
+
+func Test_aggregate(t *testing.T) {
+	batch := []*models.Metrics{
+		counter("PollCount", 1),
+		gauge("Alloc", 1.5),
+		counter("PollCount", 2),
+		gauge("Alloc", 3),
+		histogram("Latency", []float64{1}, []uint64{1, 0}),
+		histogram("Latency", []float64{1}, []uint64{2, 3}),
+	}
+	got, err := aggregate(batch)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 3}, got.counters)
+	assert.Equal(t, map[string]float64{"Alloc": 3}, got.gauges)
+	assert.Equal(t, &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{3, 3}, Count: 6}, got.histograms["Latency"])
+	assert.Equal(t, []uint64{1, 0}, batch[4].Histogram.Counts, "batch metrics must not be modified")
+
+	_, err = aggregate([]*models.Metrics{
+		histogram("Latency", []float64{1}, []uint64{1, 0}),
+		histogram("Latency", []float64{2}, []uint64{1, 0}),
+	})
+	assert.ErrorIs(t, err, models.ErrBucketsMismatch)
+}
+
+func Test_aggregatedBatch_queue(t *testing.T) {
+	agg, err := aggregate(largeBatch(10000))
+	require.NoError(t, err)
+	require.Len(t, agg.counters, 1000)
+	assert.Equal(t, int64(5), agg.counters["Counter0"])
+
+	// counters and gauges of any batch size are sent as two statements of one round trip
+	assert.Equal(t, 2, agg.queue().Len())
+}
+
+func BenchmarkAggregate(b *testing.B) {
+	batch := largeBatch(10000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		agg, _ := aggregate(batch)
+		agg.queue()
+	}
+}
+
+// BenchmarkStorage_InsertBatch requires postgres, the dsn is provided with TEST_DATABASE_DSN.
+func BenchmarkStorage_InsertBatch(b *testing.B) {
+	dsn, ok := os.LookupEnv("TEST_DATABASE_DSN")
+	if !ok {
+		b.Skip("Skipping benchmark because TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(b, err)
+	defer pool.Close()
+	m, err := migrations.New(pool)
+	require.NoError(b, err)
+	require.NoError(b, m.Up(ctx))
+
+	s := New(pool)
+	batch := largeBatch(10000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		require.NoError(b, s.InsertBatch(ctx, batch))
+	}
+}
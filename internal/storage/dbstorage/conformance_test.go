@@ -0,0 +1,76 @@
+package dbstorage
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/dbstorage/migrations"
+	"github.com/vindosVP/metrics/internal/storage/storagetest"
+)
+
+// TestConformance requires postgres, the dsn is provided with TEST_DATABASE_DSN,
+// all metrics stored in the database are deleted.
+func TestConformance(t *testing.T) {
+	dsn, ok := os.LookupEnv("TEST_DATABASE_DSN")
+	if !ok {
+		t.Skip("Skipping test because TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
+	m, err := migrations.New(pool)
+	require.NoError(t, err)
+	require.NoError(t, m.Up(ctx))
+
+	storagetest.Run(t, func(t *testing.T) storagetest.MetricsStorage {
+		_, err := pool.Exec(ctx, "truncate gauges, counters, history, histograms, summaries, metadata")
+		require.NoError(t, err)
+		return New(pool)
+	})
+}
+
+// TestSharedSchemas requires postgres, the dsn is provided with TEST_DATABASE_DSN,
+// schemas of the test are dropped.
+func TestSharedSchemas(t *testing.T) {
+	dsn, ok := os.LookupEnv("TEST_DATABASE_DSN")
+	if !ok {
+		t.Skip("Skipping test because TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+	cfg, err := pgxpool.ParseConfig(dsn)
+	require.NoError(t, err)
+	// one connection is switched between schemas
+	cfg.MaxConns = 1
+	SharedSchemas(cfg)
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	storages := make([]*Storage, 0, 2)
+	for _, schema := range []string{"test_shared_a", "test_shared_b"} {
+		_, err = pool.Exec(ctx, "create schema if not exists "+schema)
+		require.NoError(t, err)
+		defer func(schema string) {
+			_, _ = pool.Exec(ctx, "drop schema "+schema+" cascade")
+		}(schema)
+		m, err := migrations.New(pool)
+		require.NoError(t, err)
+		require.NoError(t, m.Up(WithSchema(ctx, schema)))
+		storages = append(storages, New(pool, InSchema(schema)))
+	}
+
+	_, err = storages[0].UpdateGauge(ctx, "Alloc", 1.5)
+	require.NoError(t, err)
+	v, err := storages[0].GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, v)
+	_, err = storages[1].GetGauge(ctx, "Alloc")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+}
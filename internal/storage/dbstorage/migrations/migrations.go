@@ -0,0 +1,249 @@
+// Package migrations applies versioned schema migrations to the postgres database of dbstorage.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// lockID - key of the advisory lock held while migrating,
+// so only one of the servers starting together applies migrations.
+const lockID = 7313550612
+
+const (
+	createVersionTableQuery = `CREATE TABLE IF NOT EXISTS schema_version (
+			version INT NOT NULL PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())`
+	appliedQuery = "SELECT version, applied_at FROM schema_version"
+	insertQuery  = "INSERT INTO schema_version (version, name) VALUES ($1, $2)"
+	deleteQuery  = "DELETE FROM schema_version WHERE version = $1"
+)
+
+// ErrNoMigrations is returned by Down if there are no applied migrations to roll back.
+var ErrNoMigrations = errors.New("no applied migrations")
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Name    string
+	Up      string
+	Down    string
+	Version int
+}
+
+// Status is a migration with the time it was applied, Applied is nil for pending migrations.
+type Status struct {
+	Applied *time.Time
+	Migration
+}
+
+// Migrator applies migrations to the database.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []*Migration
+}
+
+// New creates Migrator with migrations embedded in the package.
+func New(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := Load(sqlFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// Load reads migrations from the sql directory of the filesystem.
+// Files are named as <version>_<name>.up.sql and <version>_<name>.down.sql,
+// migrations are returned sorted by version.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		version, name, direction, err := parseFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		data, err := fs.ReadFile(fsys, path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func parseFileName(fileName string) (int, string, string, error) {
+	base, ok := strings.CutSuffix(fileName, ".sql")
+	if !ok {
+		return 0, "", "", fmt.Errorf("migration %s is not an sql file", fileName)
+	}
+	dot := strings.LastIndexByte(base, '.')
+	if dot == -1 || (base[dot+1:] != "up" && base[dot+1:] != "down") {
+		return 0, "", "", fmt.Errorf("migration %s has no direction", fileName)
+	}
+	version, name, ok := strings.Cut(base[:dot], "_")
+	if !ok {
+		return 0, "", "", fmt.Errorf("migration %s has no name", fileName)
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil || v <= 0 {
+		return 0, "", "", fmt.Errorf("migration %s has invalid version", fileName)
+	}
+	return v, name, base[dot+1:], nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn, applied map[int]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			logger.Log.Info(fmt.Sprintf("Applying migration %d_%s", migration.Version, migration.Name))
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, insertQuery, migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn, applied map[int]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			logger.Log.Info(fmt.Sprintf("Rolling back migration %d_%s", migration.Version, migration.Name))
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, deleteQuery, migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to roll back migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			return nil
+		}
+		return ErrNoMigrations
+	})
+}
+
+// Status returns all known migrations with the time they were applied.
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	var statuses []*Status
+	err := m.locked(ctx, func(_ *pgxpool.Conn, applied map[int]time.Time) error {
+		statuses = status(m.migrations, applied)
+		return nil
+	})
+	return statuses, err
+}
+
+func status(migrations []*Migration, applied map[int]time.Time) []*Status {
+	statuses := make([]*Status, 0, len(migrations))
+	for _, migration := range migrations {
+		st := &Status{Migration: *migration}
+		if t, ok := applied[migration.Version]; ok {
+			st.Applied = &t
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// locked runs f holding the advisory lock on a single connection with versions of applied migrations.
+func (m *Migrator) locked(ctx context.Context, f func(conn *pgxpool.Conn, applied map[int]time.Time) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+	}()
+
+	if _, err = conn.Exec(ctx, createVersionTableQuery); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return f(conn, applied)
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int]time.Time, error) {
+	rows, err := conn.Query(ctx, appliedQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var t time.Time
+		if err = rows.Scan(&version, &t); err != nil {
+			return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+		}
+		applied[version] = t
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	return applied, nil
+}
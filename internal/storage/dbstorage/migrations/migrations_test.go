@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := Load(sqlFiles)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "migrations must be numbered without gaps")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		fsys    fstest.MapFS
+		name    string
+		want    []*Migration
+		wantErr bool
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"sql/0010_labels.up.sql":   {Data: []byte("up10")},
+				"sql/0002_init.up.sql":     {Data: []byte("up2")},
+				"sql/0002_init.down.sql":   {Data: []byte("down2")},
+				"sql/0010_labels.down.sql": {Data: []byte("down10")},
+			},
+			want: []*Migration{
+				{Version: 2, Name: "init", Up: "up2", Down: "down2"},
+				{Version: 10, Name: "labels", Up: "up10", Down: "down10"},
+			},
+		},
+		{
+			name:    "no up script",
+			fsys:    fstest.MapFS{"sql/0001_init.down.sql": {Data: []byte("down")}},
+			wantErr: true,
+		},
+		{
+			name: "different names",
+			fsys: fstest.MapFS{
+				"sql/0001_init.up.sql":    {Data: []byte("up")},
+				"sql/0001_other.down.sql": {Data: []byte("down")},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no direction",
+			fsys:    fstest.MapFS{"sql/0001_init.sql": {Data: []byte("up")}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			fsys:    fstest.MapFS{"sql/first_init.up.sql": {Data: []byte("up")}},
+			wantErr: true,
+		},
+		{
+			name:    "no name",
+			fsys:    fstest.MapFS{"sql/0001.up.sql": {Data: []byte("up")}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.fsys)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_status(t *testing.T) {
+	applied := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	migrations := []*Migration{{Version: 1, Name: "init"}, {Version: 2, Name: "labels"}}
+
+	got := status(migrations, map[int]time.Time{1: applied})
+	require.Len(t, got, 2)
+	require.NotNil(t, got[0].Applied)
+	assert.Equal(t, applied, *got[0].Applied)
+	assert.Nil(t, got[1].Applied)
+	assert.Equal(t, "labels", got[1].Name)
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS summaries;
+DROP TABLE IF EXISTS histograms;
+DROP TABLE IF EXISTS history;
+DROP TABLE IF EXISTS counters;
+DROP TABLE IF EXISTS gauges;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS gauges (id TEXT NOT NULL PRIMARY KEY, value DOUBLE PRECISION NOT NULL);
+CREATE TABLE IF NOT EXISTS counters (id TEXT NOT NULL PRIMARY KEY, value BIGINT NOT NULL);
+CREATE TABLE IF NOT EXISTS history (type TEXT NOT NULL, id TEXT NOT NULL, ts TIMESTAMPTZ NOT NULL, delta BIGINT, value DOUBLE PRECISION);
+CREATE INDEX IF NOT EXISTS history_type_id_ts_idx ON history (type, id, ts);
+CREATE TABLE IF NOT EXISTS histograms (id TEXT NOT NULL PRIMARY KEY, bounds DOUBLE PRECISION[] NOT NULL, counts BIGINT[] NOT NULL, count BIGINT NOT NULL, sum DOUBLE PRECISION NOT NULL);
+CREATE TABLE IF NOT EXISTS summaries (id TEXT NOT NULL PRIMARY KEY, data JSONB NOT NULL);
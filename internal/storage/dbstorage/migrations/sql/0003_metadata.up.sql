@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS metadata (id TEXT NOT NULL PRIMARY KEY, type TEXT NOT NULL, unit TEXT NOT NULL, help TEXT NOT NULL);
@@ -0,0 +1,76 @@
+package dbstorage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// schemaKey - key of the schema queries of the context run in
+type schemaKey struct{}
+
+// WithSchema returns the context of queries run in the schema, the default one is used if the schema is empty.
+// Connections of pools configured with SharedSchemas switch to the schema of the context on acquire.
+func WithSchema(ctx context.Context, schema string) context.Context {
+	return context.WithValue(ctx, schemaKey{}, schema)
+}
+
+// SharedSchemas configures the pool to be shared by storages of different schemas,
+// the search path of the connection is set to the schema of the context it's acquired with.
+func SharedSchemas(cfg *pgxpool.Config) {
+	var mu sync.Mutex
+	// search paths set on connections, connections not in the map use the default one
+	paths := make(map[*pgx.Conn]string)
+
+	cfg.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		schema, _ := ctx.Value(schemaKey{}).(string)
+		mu.Lock()
+		current := paths[conn]
+		mu.Unlock()
+		if schema == current {
+			return true
+		}
+		query := "SET search_path TO DEFAULT"
+		if schema != "" {
+			query = "SET search_path TO " + pgx.Identifier{schema}.Sanitize()
+		}
+		if _, err := conn.Exec(ctx, query); err != nil {
+			// the connection is destroyed and another one is acquired
+			return false
+		}
+		mu.Lock()
+		paths[conn] = schema
+		mu.Unlock()
+		return true
+	}
+	cfg.BeforeClose = func(conn *pgx.Conn) {
+		mu.Lock()
+		delete(paths, conn)
+		mu.Unlock()
+	}
+}
+
+// schemaPool runs queries of the storage in its schema.
+type schemaPool struct {
+	pool   *pgxpool.Pool
+	schema string
+}
+
+func (p *schemaPool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return p.pool.Exec(WithSchema(ctx, p.schema), sql, args...)
+}
+
+func (p *schemaPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return p.pool.Query(WithSchema(ctx, p.schema), sql, args...)
+}
+
+func (p *schemaPool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return p.pool.QueryRow(WithSchema(ctx, p.schema), sql, args...)
+}
+
+func (p *schemaPool) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	return p.pool.BeginTx(WithSchema(ctx, p.schema), opts)
+}
@@ -19,48 +19,88 @@ import (
 	"github.com/vindosVP/metrics/pkg/logger"
 )
 
+// Every write query appends the resulting value to the history table in the same statement.
+const (
+	updateGaugeQuery = `with upd as (
+			insert into gauges (id, value) values ($1, $2) on conflict (id) do update set value = $2, updated_at = now() returning id, value
+		) insert into history (type, id, ts, value) select 'gauge', id, now(), value from upd`
+	updateCounterQuery = `with upd as (
+			insert into counters as t (id, value) values ($1, $2) on conflict (id) do update set value = t.value + $2, updated_at = now() returning id, value
+		) insert into history (type, id, ts, delta) select 'counter', id, now(), value from upd returning delta`
+	setCounterQuery = `with upd as (
+			insert into counters (id, value) values ($1, $2) on conflict (id) do update set value = $2, updated_at = now() returning id, value
+		) insert into history (type, id, ts, delta) select 'counter', id, now(), value from upd`
+)
+
+// updateHistogramQuery merges bucket counts element-wise, no row is returned if bucket bounds differ.
+const updateHistogramQuery = `insert into histograms as t (id, bounds, counts, count, sum) values ($1, $2, $3, $4, $5)
+		on conflict (id) do update set
+			counts = (select array_agg(a + b order by i) from unnest(t.counts, excluded.counts) with ordinality as u(a, b, i)),
+			count = t.count + excluded.count,
+			sum = t.sum + excluded.sum,
+			updated_at = now()
+		where t.bounds = excluded.bounds
+		returning bounds, counts, count, sum`
+
+// Summary sketches are stored as json and merged by the application under the row lock.
+const (
+	insertSummaryQuery = "insert into summaries (id, data) values ($1, $2) on conflict (id) do nothing"
+	lockSummaryQuery   = "select data from summaries where id = $1 for update"
+	setSummaryQuery    = "update summaries set data = $2, updated_at = now() where id = $1"
+)
+
+// metricTables maps metric types to the tables storing their values.
+var metricTables = map[string]string{
+	models.Counter:   "counters",
+	models.Gauge:     "gauges",
+	models.Histogram: "histograms",
+	models.Summary:   "summaries",
+}
+
 var retryDelays = map[uint]time.Duration{
 	0: 1 * time.Second,
 	1: 3 * time.Second,
 	2: 5 * time.Second,
 }
 
-// Storage consists of postgres connection pool.
+// Storage consists of postgres connection pool and the schema its tables are in.
 type Storage struct {
-	db *pgxpool.Pool
+	db *schemaPool
 }
 
 // New creates the Storage.
-func New(pool *pgxpool.Pool) *Storage {
-	return &Storage{
-		db: pool,
+func New(pool *pgxpool.Pool, opts ...func(*Storage)) *Storage {
+	s := &Storage{
+		db: &schemaPool{pool: pool},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// InSchema makes the Storage keep metrics in the schema of the pool configured with SharedSchemas.
+func InSchema(schema string) func(*Storage) {
+	return func(s *Storage) {
+		s.db.schema = schema
 	}
 }
 
 // InsertBatch method saves provided metrics values to the database.
+// Duplicate metrics are aggregated, counters, gauges and histograms are written in a single round trip.
 func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	agg, err := aggregate(batch)
+	if err != nil {
+		return err
+	}
 	return retry.Do(func() error {
-		b := batch
 		tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
-		defer tx.Rollback(ctx)
 		if err != nil {
 			return err
 		}
-		gaugeQuery := "insert into gauges (id, value) values ($1, $2) on conflict (id) do update set value = $2"
-		counterQuery := "insert into counters as t (id, value) values ($1, $2) on conflict (id) do update set value = t.value + $2"
-		for _, metric := range b {
-			switch metric.MType {
-			case models.Counter:
-				val := *metric.Delta
-				if _, cerr := tx.Exec(ctx, counterQuery, metric.ID, val); cerr != nil {
-					return cerr
-				}
-			case models.Gauge:
-				val := *metric.Value
-				if _, gerr := tx.Exec(ctx, gaugeQuery, metric.ID, val); gerr != nil {
-					return gerr
-				}
-			}
+		defer tx.Rollback(ctx)
+		if err = agg.write(ctx, tx); err != nil {
+			return err
 		}
 		err = tx.Commit(ctx)
 		if err != nil {
@@ -74,8 +114,7 @@ func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) erro
 // new value replaces the old one.
 func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
 	return retry.DoWithData(func() (float64, error) {
-		query := "insert into gauges (id, value) values ($1, $2) on conflict (id) do update set value = $2"
-		_, err := s.db.Exec(ctx, query, name, v)
+		_, err := s.db.Exec(ctx, updateGaugeQuery, name, v)
 		if err != nil {
 			return 0, err
 		}
@@ -84,15 +123,15 @@ func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (floa
 }
 
 // UpdateCounter method updates counter metric value.
-// new value adds to the old one.
+// new value adds to the old one, the new total is returned.
 func (s *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
 	return retry.DoWithData(func() (int64, error) {
-		query := "insert into counters as t (id, value) values ($1, $2) on conflict (id) do update set value = t.value + $2"
-		_, err := s.db.Exec(ctx, query, name, v)
+		var total int64
+		err := s.db.QueryRow(ctx, updateCounterQuery, name, v).Scan(&total)
 		if err != nil {
 			return 0, err
 		}
-		return v, nil
+		return total, nil
 	}, retryOpts()...)
 }
 
@@ -151,7 +190,7 @@ func (s *Storage) GetAllGauge(ctx context.Context) (map[string]float64, error) {
 		}
 
 		if rows.Err() != nil {
-			return nil, err
+			return nil, rows.Err()
 		}
 		return res, nil
 	}, retryOpts()...)
@@ -178,7 +217,7 @@ func (s *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
 		}
 
 		if rows.Err() != nil {
-			return nil, err
+			return nil, rows.Err()
 		}
 		return res, nil
 	}, retryOpts()...)
@@ -188,8 +227,7 @@ func (s *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
 // new value replaces the old one.
 func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
 	return retry.DoWithData(func() (int64, error) {
-		query := "insert into counters (id, value) values ($1, $2) on conflict (id) do update set value = $2"
-		_, err := s.db.Exec(ctx, query, name, v)
+		_, err := s.db.Exec(ctx, setCounterQuery, name, v)
 		if err != nil {
 			return 0, err
 		}
@@ -197,6 +235,370 @@ func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64,
 	}, retryOpts()...)
 }
 
+// GetHistory method returns metric samples collected between from and to.
+func (s *Storage) GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	return retry.DoWithData(func() ([]*models.Sample, error) {
+		var exists bool
+		row := s.db.QueryRow(ctx, "select exists(select 1 from history where type = $1 and id = $2)", mType, name)
+		if err := row.Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, storage.ErrMetricNotRegistered
+		}
+
+		query := "select ts, delta, value from history where type = $1 and id = $2 and ts >= $3 and ts <= $4 order by ts"
+		rows, err := s.db.Query(ctx, query, mType, name, from, to)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		res := make([]*models.Sample, 0)
+		for rows.Next() {
+			sample := &models.Sample{}
+			rerr := rows.Scan(&sample.Timestamp, &sample.Delta, &sample.Value)
+			if rerr != nil {
+				return nil, rerr
+			}
+			res = append(res, sample)
+		}
+
+		if rows.Err() != nil {
+			return nil, rows.Err()
+		}
+		return res, nil
+	}, retryOpts()...)
+}
+
+// UpdateHistogram method merges provided observations into the histogram metric.
+func (s *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	return retry.DoWithData(func() (*models.HistogramValue, error) {
+		return updateHistogram(ctx, s.db, name, v)
+	}, retryOpts()...)
+}
+
+// GetHistogram method returns value of histogram metric
+func (s *Storage) GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error) {
+	return retry.DoWithData(func() (*models.HistogramValue, error) {
+		query := "select bounds, counts, count, sum from histograms where id = $1"
+		value, err := scanHistogram(s.db.QueryRow(ctx, query, name))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrMetricNotRegistered
+		}
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	}, retryOpts()...)
+}
+
+// GetAllHistogram method returns values of all collected histogram metrics
+func (s *Storage) GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error) {
+	return retry.DoWithData(func() (map[string]*models.HistogramValue, error) {
+		rows, err := s.db.Query(ctx, "select id, bounds, counts, count, sum from histograms order by id")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		res := make(map[string]*models.HistogramValue)
+		for rows.Next() {
+			var id string
+			var counts []int64
+			var count int64
+			value := &models.HistogramValue{}
+			rerr := rows.Scan(&id, &value.Bounds, &counts, &count, &value.Sum)
+			if rerr != nil {
+				return nil, rerr
+			}
+			value.Counts = toUint64(counts)
+			value.Count = uint64(count)
+			res[id] = value
+		}
+
+		if rows.Err() != nil {
+			return nil, rows.Err()
+		}
+		return res, nil
+	}, retryOpts()...)
+}
+
+// UpdateSummary method merges provided sketch into the summary metric.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	return retry.DoWithData(func() (*models.SummaryValue, error) {
+		tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback(ctx)
+		val, err := updateSummary(ctx, tx, name, v)
+		if err != nil {
+			return nil, err
+		}
+		return val, tx.Commit(ctx)
+	}, retryOpts()...)
+}
+
+// GetSummary method returns value of summary metric
+func (s *Storage) GetSummary(ctx context.Context, name string) (*models.SummaryValue, error) {
+	return retry.DoWithData(func() (*models.SummaryValue, error) {
+		value := &models.SummaryValue{}
+		err := s.db.QueryRow(ctx, "select data from summaries where id = $1", name).Scan(value)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrMetricNotRegistered
+		}
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	}, retryOpts()...)
+}
+
+// GetAllSummary method returns values of all collected summary metrics
+func (s *Storage) GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error) {
+	return retry.DoWithData(func() (map[string]*models.SummaryValue, error) {
+		rows, err := s.db.Query(ctx, "select id, data from summaries order by id")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		res := make(map[string]*models.SummaryValue)
+		for rows.Next() {
+			var id string
+			value := &models.SummaryValue{}
+			rerr := rows.Scan(&id, value)
+			if rerr != nil {
+				return nil, rerr
+			}
+			res[id] = value
+		}
+
+		if rows.Err() != nil {
+			return nil, rows.Err()
+		}
+		return res, nil
+	}, retryOpts()...)
+}
+
+func updateSummary(ctx context.Context, tx pgx.Tx, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	tag, err := tx.Exec(ctx, insertSummaryQuery, name, v)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 1 {
+		return v, nil
+	}
+
+	current := &models.SummaryValue{}
+	if err = tx.QueryRow(ctx, lockSummaryQuery, name).Scan(current); err != nil {
+		return nil, err
+	}
+	if err = current.Merge(v); err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(ctx, setSummaryQuery, name, current); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func updateHistogram(ctx context.Context, q querier, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	counts := make([]int64, len(v.Counts))
+	for i, c := range v.Counts {
+		counts[i] = int64(c)
+	}
+	value, err := scanHistogram(q.QueryRow(ctx, updateHistogramQuery, name, v.Bounds, counts, int64(v.Count), v.Sum))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.ErrBucketsMismatch
+	}
+	return value, err
+}
+
+func scanHistogram(row pgx.Row) (*models.HistogramValue, error) {
+	var counts []int64
+	var count int64
+	value := &models.HistogramValue{}
+	if err := row.Scan(&value.Bounds, &counts, &count, &value.Sum); err != nil {
+		return nil, err
+	}
+	value.Counts = toUint64(counts)
+	value.Count = uint64(count)
+	return value, nil
+}
+
+func toUint64(v []int64) []uint64 {
+	res := make([]uint64, len(v))
+	for i, c := range v {
+		res[i] = uint64(c)
+	}
+	return res
+}
+
+// Delete method removes the metric and its history.
+func (s *Storage) Delete(ctx context.Context, mType string, name string) error {
+	return retry.Do(func() error {
+		tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		if err = deleteMetric(ctx, tx, mType, name); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}, retryOpts()...)
+}
+
+// DeleteBatch method removes all provided metrics, metrics which are not registered are skipped.
+func (s *Storage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	return retry.Do(func() error {
+		tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		for _, metric := range batch {
+			derr := deleteMetric(ctx, tx, metric.MType, metric.Key())
+			if derr != nil && !errors.Is(derr, storage.ErrMetricNotRegistered) {
+				return derr
+			}
+		}
+		return tx.Commit(ctx)
+	}, retryOpts()...)
+}
+
+func deleteMetric(ctx context.Context, tx pgx.Tx, mType string, name string) error {
+	table, ok := metricTables[mType]
+	if !ok {
+		return storage.ErrMetricNotRegistered
+	}
+	tag, err := tx.Exec(ctx, fmt.Sprintf("delete from %s where id = $1", table), name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrMetricNotRegistered
+	}
+	_, err = tx.Exec(ctx, "delete from history where type = $1 and id = $2", mType, name)
+	return err
+}
+
+// GetUpdatedAt method returns time of the last update of the metric with provided type.
+func (s *Storage) GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error) {
+	table, ok := metricTables[mType]
+	if !ok {
+		return time.Time{}, storage.ErrMetricNotRegistered
+	}
+	return retry.DoWithData(func() (time.Time, error) {
+		var updated time.Time
+		err := s.db.QueryRow(ctx, fmt.Sprintf("select updated_at from %s where id = $1", table), name).Scan(&updated)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, storage.ErrMetricNotRegistered
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		return updated, nil
+	}, retryOpts()...)
+}
+
+// GetUpdated method returns time of the last update of all metrics with provided type.
+func (s *Storage) GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	table, ok := metricTables[mType]
+	if !ok {
+		return make(map[string]time.Time), nil
+	}
+	return retry.DoWithData(func() (map[string]time.Time, error) {
+		rows, err := s.db.Query(ctx, fmt.Sprintf("select id, updated_at from %s", table))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		res := make(map[string]time.Time)
+		for rows.Next() {
+			var id string
+			var updated time.Time
+			rerr := rows.Scan(&id, &updated)
+			if rerr != nil {
+				return nil, rerr
+			}
+			res[id] = updated
+		}
+
+		if rows.Err() != nil {
+			return nil, rows.Err()
+		}
+		return res, nil
+	}, retryOpts()...)
+}
+
+// RegisterMetadata method saves metadata of the metric, previously registered metadata is replaced.
+func (s *Storage) RegisterMetadata(ctx context.Context, meta *models.Metadata) error {
+	return retry.Do(func() error {
+		query := `insert into metadata (id, type, unit, help) values ($1, $2, $3, $4)
+			on conflict (id) do update set type = $2, unit = $3, help = $4`
+		_, err := s.db.Exec(ctx, query, meta.ID, meta.MType, meta.Unit, meta.Help)
+		return err
+	}, retryOpts()...)
+}
+
+// GetMetadata method returns metadata of the metric.
+func (s *Storage) GetMetadata(ctx context.Context, name string) (*models.Metadata, error) {
+	return retry.DoWithData(func() (*models.Metadata, error) {
+		query := "select id, type, unit, help from metadata where id = $1"
+		meta := &models.Metadata{}
+		err := s.db.QueryRow(ctx, query, name).Scan(&meta.ID, &meta.MType, &meta.Unit, &meta.Help)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrMetricNotRegistered
+		}
+		if err != nil {
+			return nil, err
+		}
+		return meta, nil
+	}, retryOpts()...)
+}
+
+// GetAllMetadata method returns metadata of all registered metrics.
+func (s *Storage) GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error) {
+	return retry.DoWithData(func() (map[string]*models.Metadata, error) {
+		rows, err := s.db.Query(ctx, "select id, type, unit, help from metadata")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		res := make(map[string]*models.Metadata)
+		for rows.Next() {
+			meta := &models.Metadata{}
+			rerr := rows.Scan(&meta.ID, &meta.MType, &meta.Unit, &meta.Help)
+			if rerr != nil {
+				return nil, rerr
+			}
+			res[meta.ID] = meta
+		}
+
+		if rows.Err() != nil {
+			return nil, rows.Err()
+		}
+		return res, nil
+	}, retryOpts()...)
+}
+
+// TrimHistory method deletes samples collected before the provided time.
+func (s *Storage) TrimHistory(ctx context.Context, before time.Time) error {
+	return retry.Do(func() error {
+		_, err := s.db.Exec(ctx, "delete from history where ts < $1", before)
+		return err
+	}, retryOpts()...)
+}
+
 func retryOpts() []retry.Option {
 	return []retry.Option{
 		retry.RetryIf(func(err error) bool {
@@ -0,0 +1,138 @@
+// Package driver is a registry of storage backends selected by the scheme of the storage URL,
+// e.g. memory://, file:///var/lib/metrics.json?interval=10s, sqlite:///var/lib/metrics.db,
+// redis://host:6379/0 or postgres://user@host/db.
+// Backends register themselves on import, the same way database/sql drivers do.
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// ErrUnknownScheme - represents that no backend is registered under the scheme of the storage URL.
+var ErrUnknownScheme = errors.New("unknown storage scheme")
+
+// MetricsStorage consists methods to save and get data from the storage
+type MetricsStorage interface {
+	UpdateGauge(ctx context.Context, name string, v float64) (float64, error)
+	UpdateCounter(ctx context.Context, name string, v int64) (int64, error)
+	SetCounter(ctx context.Context, name string, v int64) (int64, error)
+	GetGauge(ctx context.Context, name string) (float64, error)
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetCounter(ctx context.Context, name string) (int64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// Options - settings of the storage which are not part of the storage URL.
+type Options struct {
+	// Tenant - tenant the storage is opened for
+	Tenant string
+	// Retention - history retention, 0 keeps history forever
+	Retention time.Duration
+	// SnapshotKeep - number of kept dump files
+	SnapshotKeep int
+	// SnapshotCompress - compress dump files with gzip
+	SnapshotCompress bool
+	// Restore - load previously saved metrics
+	Restore bool
+}
+
+// Driver opens storages of one backend.
+type Driver interface {
+	// Open creates the storage of the tenant at the URL.
+	Open(url string, opts *Options) (MetricsStorage, error)
+	// Tenants returns tenants other than the default one having metrics saved at the URL.
+	Tenants(url string) ([]string, error)
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register makes the backend available under the URL scheme.
+// It panics if the driver is nil or the scheme is already registered.
+func Register(scheme string, d Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if d == nil {
+		panic("driver: Register driver is nil")
+	}
+	if _, ok := drivers[scheme]; ok {
+		panic("driver: Register called twice for scheme " + scheme)
+	}
+	drivers[scheme] = d
+}
+
+// Drivers returns sorted schemes of registered backends.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	schemes := make([]string, 0, len(drivers))
+	for scheme := range drivers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Scheme returns the scheme of the storage URL in lower case, or an empty string if it has none.
+func Scheme(url string) string {
+	scheme, _, ok := strings.Cut(url, "://")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// Lookup returns the backend registered under the scheme of the storage URL.
+func Lookup(url string) (Driver, error) {
+	scheme := Scheme(url)
+	driversMu.RLock()
+	d, ok := drivers[scheme]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q, registered: %s", ErrUnknownScheme, scheme, strings.Join(Drivers(), ", "))
+	}
+	return d, nil
+}
+
+// Open creates the storage of the tenant by the backend registered under the scheme of the storage URL.
+func Open(url string, opts *Options) (MetricsStorage, error) {
+	d, err := Lookup(url)
+	if err != nil {
+		return nil, err
+	}
+	return d.Open(url, opts)
+}
+
+// Tenants returns tenants having metrics saved at the storage URL.
+func Tenants(url string) ([]string, error) {
+	d, err := Lookup(url)
+	if err != nil {
+		return nil, err
+	}
+	return d.Tenants(url)
+}
@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDriver struct {
+	tenants []string
+}
+
+func (d *fakeDriver) Open(_ string, _ *Options) (MetricsStorage, error) {
+	return nil, nil
+}
+
+func (d *fakeDriver) Tenants(_ string) ([]string, error) {
+	return d.tenants, nil
+}
+
+func TestScheme(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "memory", url: "memory://", want: "memory"},
+		{name: "file", url: "file:///tmp/metrics.json?interval=10s", want: "file"},
+		{name: "upper case", url: "Postgres://user@localhost/db", want: "postgres"},
+		{name: "no scheme", url: "host=localhost dbname=metrics", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Scheme(tt.url))
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	d := &fakeDriver{tenants: []string{"team-a"}}
+	Register("fake", d)
+	assert.Contains(t, Drivers(), "fake")
+	assert.Panics(t, func() { Register("fake", d) })
+	assert.Panics(t, func() { Register("nil", nil) })
+
+	got, err := Lookup("FAKE://somewhere")
+	require.NoError(t, err)
+	assert.Same(t, d, got)
+	tenants, err := Tenants("fake://somewhere")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-a"}, tenants)
+
+	_, err = Open("unknown://somewhere", &Options{})
+	assert.ErrorIs(t, err, ErrUnknownScheme)
+	_, err = Lookup("/tmp/metrics.json")
+	assert.ErrorIs(t, err, ErrUnknownScheme)
+}
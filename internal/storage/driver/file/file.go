@@ -0,0 +1,174 @@
+// Package file registers the file:// storage backend keeping metrics in memory and saving them to a dump file.
+//
+// The URL is file:///path/to/dump.json?interval=10s, relative paths are written as file://path/to/dump.json.
+// With a zero interval, which is the default, every write is appended to the write-ahead log next to the dump,
+// otherwise the dump is rewritten on the interval.
+//
+// History is kept in memory only and doesn't survive a restart,
+// values restored from the dump aren't recorded as samples.
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/server/loader"
+	"github.com/vindosVP/metrics/internal/storage/driver"
+	"github.com/vindosVP/metrics/internal/storage/filestorage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// tenantsDir - directory with dumps of tenants
+const tenantsDir = "tenants"
+
+func init() {
+	driver.Register("file", Driver{})
+}
+
+// Driver opens storages saved to dump files.
+type Driver struct{}
+
+// Open creates the storage of the tenant, restoring it from the dump if asked.
+func (Driver) Open(rawURL string, opts *driver.Options) (driver.MetricsStorage, error) {
+	fileName, interval, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := &filestorage.Snapshots{
+		FileName: tenantFileName(fileName, opts.Tenant),
+		Keep:     opts.SnapshotKeep,
+		Compress: opts.SnapshotCompress,
+	}
+	s, err := memStorage(interval, opts.Restore, snapshots, opts.Retention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inmemory storage: %w", err)
+	}
+	return s, nil
+}
+
+// Tenants returns tenants with dump directories.
+func (Driver) Tenants(rawURL string) ([]string, error) {
+	fileName, _, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(fileName), tenantsDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && tenant.Valid(entry.Name()) && entry.Name() != tenant.Default {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids, nil
+}
+
+// parseURL returns the dump file and the store interval of the storage URL.
+func parseURL(rawURL string) (string, time.Duration, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to parse storage url: %w", err)
+	}
+	fileName := u.Host + u.Path
+	if fileName == "" {
+		return "", 0, errors.New("storage url has no dump file")
+	}
+	var interval time.Duration
+	if v := u.Query().Get("interval"); v != "" {
+		interval, err = time.ParseDuration(v)
+		if err != nil || interval < 0 || interval%time.Second != 0 {
+			return "", 0, fmt.Errorf("invalid store interval %q, expected whole seconds, e.g. 10s", v)
+		}
+	}
+	return fileName, interval, nil
+}
+
+// tenantFileName returns the dump file of the tenant,
+// dumps of tenants are kept in the tenants directory next to the dump of the default tenant.
+func tenantFileName(fileName string, id string) string {
+	if id == tenant.Default || id == "" {
+		return fileName
+	}
+	return filepath.Join(filepath.Dir(fileName), tenantsDir, id, filepath.Base(fileName))
+}
+
+func memStorage(si time.Duration, restore bool, snapshots *filestorage.Snapshots, retention time.Duration) (driver.MetricsStorage, error) {
+	ctx := context.Background()
+	gRepo := repos.NewGaugeRepo()
+	cRepo := repos.NewCounterRepo()
+	hRepo := repos.NewHistoryRepo(retention)
+	if err := os.MkdirAll(filepath.Dir(snapshots.FileName), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dump directory: %w", err)
+	}
+	if si != time.Duration(0) {
+		s := memstorage.New(gRepo, cRepo, memstorage.WithHistory(hRepo))
+		if err := restoreStorage(restore, snapshots.FileName, s.WithoutHistory()); err != nil {
+			return nil, err
+		}
+		if err := filestorage.Checkpoint(ctx, s, snapshots); err != nil {
+			return nil, fmt.Errorf("failed to write dump: %w", err)
+		}
+		logger.Log.Info("Starting saver")
+		// the saver takes the interval in seconds
+		svr := filestorage.NewSaver(snapshots.FileName, si/time.Second, s)
+		svr.Snapshots = snapshots
+		go svr.Run()
+		if retention != time.Duration(0) {
+			go trimHistory(hRepo, retention)
+		}
+		return s, nil
+	}
+
+	s := filestorage.NewFileStorage(gRepo, cRepo, snapshots.FileName, memstorage.WithHistory(hRepo))
+	s.Snapshots = snapshots
+	// the dump is restored bypassing the write-ahead log and then compacted with it
+	if err := restoreStorage(restore, snapshots.FileName, s.Storage.WithoutHistory()); err != nil {
+		return nil, err
+	}
+	if err := s.Compact(ctx); err != nil {
+		return nil, fmt.Errorf("failed to compact write-ahead log: %w", err)
+	}
+	if retention != time.Duration(0) {
+		go trimHistory(hRepo, retention)
+	}
+	return s, nil
+}
+
+func restoreStorage(restore bool, dump string, s loader.MetricsStorage) error {
+	if !restore {
+		return nil
+	}
+	err := loader.New(dump, s).LoadMetrics()
+	if err != nil {
+		return fmt.Errorf("failed to load dump: %w", err)
+	}
+	return nil
+}
+
+func trimHistory(h *repos.HistoryRepo, retention time.Duration) {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+
+	for range tick.C {
+		err := h.Trim(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			logger.Log.Error("Failed to trim history", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/storage/driver"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+func Test_parseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		fileName string
+		interval time.Duration
+		wantErr  bool
+	}{
+		{name: "absolute", url: "file:///var/lib/metrics.json", fileName: "/var/lib/metrics.json"},
+		{name: "relative", url: "file://tmp/metrics.json", fileName: "tmp/metrics.json"},
+		{name: "interval", url: "file:///metrics.json?interval=1m", fileName: "/metrics.json", interval: time.Minute},
+		{name: "fractional interval", url: "file:///metrics.json?interval=1.5s", wantErr: true},
+		{name: "negative interval", url: "file:///metrics.json?interval=-1s", wantErr: true},
+		{name: "no file", url: "file://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName, interval, err := parseURL(tt.url)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.fileName, fileName)
+			assert.Equal(t, tt.interval, interval)
+		})
+	}
+}
+
+func TestDriver(t *testing.T) {
+	require.NoError(t, logger.Initialize("error"))
+	ctx := context.Background()
+	dir := t.TempDir()
+	url := "file://" + filepath.ToSlash(filepath.Join(dir, "metrics.json"))
+
+	s, err := driver.Open(url, &driver.Options{Tenant: "team-a", SnapshotKeep: 1})
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Alloc", 1.5)
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, tenantsDir, "team-a"))
+	require.NoError(t, err)
+
+	tenants, err := driver.Tenants(url)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-a"}, tenants)
+
+	restored, err := driver.Open(url, &driver.Options{Tenant: "team-a", SnapshotKeep: 1, Restore: true})
+	require.NoError(t, err)
+	v, err := restored.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, v)
+}
@@ -0,0 +1,48 @@
+// Package memory registers the memory:// storage backend keeping metrics in memory only,
+// they are lost on restart.
+package memory
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage/driver"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+func init() {
+	driver.Register("memory", Driver{})
+}
+
+// Driver opens in-memory storages.
+type Driver struct{}
+
+// Open creates an empty in-memory storage.
+func (Driver) Open(_ string, opts *driver.Options) (driver.MetricsStorage, error) {
+	hRepo := repos.NewHistoryRepo(opts.Retention)
+	if opts.Retention != time.Duration(0) {
+		go trimHistory(hRepo, opts.Retention)
+	}
+	return memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo(), memstorage.WithHistory(hRepo)), nil
+}
+
+// Tenants returns no tenants, nothing survives a restart.
+func (Driver) Tenants(_ string) ([]string, error) {
+	return nil, nil
+}
+
+func trimHistory(h *repos.HistoryRepo, retention time.Duration) {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+
+	for range tick.C {
+		err := h.Trim(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			logger.Log.Error("Failed to trim history", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package postgres registers the postgres:// and postgresql:// storage backends keeping metrics in a postgres database.
+// Pending migrations are applied on open, metrics of tenants are kept in their own schemas.
+// Storages of all tenants of the database share one connection pool, connections switch to schemas of tenants on acquire.
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/storage/dbstorage"
+	"github.com/vindosVP/metrics/internal/storage/dbstorage/migrations"
+	"github.com/vindosVP/metrics/internal/storage/driver"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// tenantSchemaPrefix - prefix of database schemas of tenants
+const tenantSchemaPrefix = "tenant_"
+
+func init() {
+	driver.Register("postgres", Driver{})
+	driver.Register("postgresql", Driver{})
+}
+
+// Driver opens database storages.
+type Driver struct{}
+
+// database is the connection pool shared by storages of all tenants of the database,
+// history of their storages is trimmed by one goroutine.
+type database struct {
+	pool     *pgxpool.Pool
+	storages []*dbstorage.Storage
+	trimming bool
+	mu       sync.Mutex
+}
+
+var (
+	databasesMu sync.Mutex
+	databases   = make(map[string]*database)
+)
+
+// Open creates the storage keeping metrics of the tenant in its schema, creating the schema if needed.
+func (Driver) Open(dsn string, opts *driver.Options) (driver.MetricsStorage, error) {
+	s, err := dbStorage(dsn, tenantSchema(opts.Tenant), opts.Retention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database storage: %w", err)
+	}
+	return s, nil
+}
+
+// Tenants returns tenants with database schemas.
+func (Driver) Tenants(dsn string) ([]string, error) {
+	ctx := context.Background()
+	db, err := openDatabase(dsn)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.pool.Query(ctx, "select nspname from pg_namespace where starts_with(nspname, $1)", tenantSchemaPrefix)
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(schemas))
+	for _, schema := range schemas {
+		if id := strings.TrimPrefix(schema, tenantSchemaPrefix); tenant.Valid(id) && id != tenant.Default {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// tenantSchema returns the database schema of the tenant, metrics of the default tenant are kept in the public one.
+func tenantSchema(id string) string {
+	if id == tenant.Default || id == "" {
+		return ""
+	}
+	return tenantSchemaPrefix + id
+}
+
+// openDatabase returns the database of the dsn, connecting to it if it's not connected yet.
+func openDatabase(dsn string) (*database, error) {
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+	if db, ok := databases[dsn]; ok {
+		return db, nil
+	}
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database dsn: %w", err)
+	}
+	dbstorage.SharedSchemas(poolCfg)
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to databse: %w", err)
+	}
+	db := &database{pool: pool}
+	databases[dsn] = db
+	return db, nil
+}
+
+// closeUnused closes the pool of the database if no storage uses it, so failed opens don't leak connections.
+func closeUnused(dsn string, db *database) {
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.storages) == 0 && databases[dsn] == db {
+		delete(databases, dsn)
+		db.pool.Close()
+	}
+}
+
+// dbStorage creates the storage keeping metrics in the schema, the default one is used if the schema is empty.
+func dbStorage(dsn string, schema string, retention time.Duration) (*dbstorage.Storage, error) {
+	db, err := openDatabase(dsn)
+	if err != nil {
+		return nil, err
+	}
+	s, err := migrate(db.pool, schema)
+	if err != nil {
+		closeUnused(dsn, db)
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.storages = append(db.storages, s)
+	if retention != time.Duration(0) && !db.trimming {
+		db.trimming = true
+		go db.trimHistory(retention)
+	}
+	return s, nil
+}
+
+// migrate creates the schema if needed and applies pending migrations to it.
+func migrate(pool *pgxpool.Pool, schema string) (*dbstorage.Storage, error) {
+	ctx := dbstorage.WithSchema(context.Background(), schema)
+	if schema != "" {
+		_, err := pool.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{schema}.Sanitize())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+	logger.Log.Info("Applying migrations", zap.String("schema", schema))
+	m, err := migrations.New(pool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	err = m.Up(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	logger.Log.Info("Migrations applied successfully", zap.String("schema", schema))
+	return dbstorage.New(pool, dbstorage.InSchema(schema)), nil
+}
+
+// trimHistory trims history of all storages of the database.
+func (db *database) trimHistory(retention time.Duration) {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+
+	for range tick.C {
+		db.mu.Lock()
+		storages := append([]*dbstorage.Storage(nil), db.storages...)
+		db.mu.Unlock()
+		for _, s := range storages {
+			err := s.TrimHistory(context.Background(), time.Now().Add(-retention))
+			if err != nil {
+				logger.Log.Error("Failed to trim history", zap.Error(err))
+			}
+		}
+	}
+}
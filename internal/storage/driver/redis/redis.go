@@ -0,0 +1,116 @@
+// Package redis registers the redis:// and rediss:// storage backends keeping metrics in redis,
+// so several servers behind one load balancer share the metrics.
+//
+// The URL is redis://[user:password@]host:port/db?prefix=metrics, keys of the tenant start with <prefix>:<tenant>,
+// the prefix defaults to metrics. Other query parameters are passed to the redis client.
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/storage/driver"
+	"github.com/vindosVP/metrics/internal/storage/redisstorage"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// defaultPrefix - prefix of keys if the URL has none
+const defaultPrefix = "metrics"
+
+func init() {
+	driver.Register("redis", Driver{})
+	driver.Register("rediss", Driver{})
+}
+
+// Driver opens redis storages.
+type Driver struct{}
+
+// Open creates the storage of the tenant and adds the tenant to the set of known tenants.
+func (Driver) Open(rawURL string, opts *driver.Options) (driver.MetricsStorage, error) {
+	ctx := context.Background()
+	client, prefix, err := connect(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	id := opts.Tenant
+	if id == "" {
+		id = tenant.Default
+	}
+	if err = client.SAdd(ctx, tenantsKey(prefix), id).Err(); err != nil {
+		return nil, fmt.Errorf("failed to register tenant: %w", err)
+	}
+	s := redisstorage.New(client, prefix+":"+id)
+	if opts.Retention != time.Duration(0) {
+		go trimHistory(s, opts.Retention)
+	}
+	return s, nil
+}
+
+// Tenants returns tenants registered in redis.
+func (Driver) Tenants(rawURL string) ([]string, error) {
+	client, prefix, err := connect(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	members, err := client.SMembers(context.Background(), tenantsKey(prefix)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenants: %w", err)
+	}
+	ids := make([]string, 0, len(members))
+	for _, id := range members {
+		if tenant.Valid(id) && id != tenant.Default {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// tenantsKey returns the key of the set of tenants.
+func tenantsKey(prefix string) string {
+	return prefix + ":tenants"
+}
+
+// connect creates the client of the URL and returns it with the key prefix.
+func connect(rawURL string) (*redis.Client, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse storage url: %w", err)
+	}
+	query := u.Query()
+	prefix := query.Get("prefix")
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	// the client rejects unknown parameters
+	query.Del("prefix")
+	u.RawQuery = query.Encode()
+	redisOpts, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse storage url: %w", err)
+	}
+	client := redis.NewClient(redisOpts)
+	if err = client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
+		return nil, "", fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return client, prefix, nil
+}
+
+func trimHistory(s *redisstorage.Storage, retention time.Duration) {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+
+	for range tick.C {
+		err := s.TrimHistory(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			logger.Log.Error("Failed to trim history", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/storage/driver"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+func TestDriver(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	url := "redis://" + mr.Addr() + "/0?prefix=test"
+
+	s, err := driver.Open(url, &driver.Options{Tenant: tenant.Default})
+	require.NoError(t, err)
+	_, err = s.UpdateCounter(ctx, "PollCount", 2)
+	require.NoError(t, err)
+	_, err = driver.Open(url, &driver.Options{Tenant: "team-a"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2", mr.HGet("test:default:counter", "PollCount"))
+	tenants, err := driver.Tenants(url)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-a"}, tenants)
+
+	_, err = driver.Open("redis://"+mr.Addr()+"/0?unknown=1", &driver.Options{})
+	assert.Error(t, err)
+}
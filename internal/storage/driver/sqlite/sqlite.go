@@ -0,0 +1,108 @@
+// Package sqlite registers the sqlite:// storage backend keeping metrics in an embedded sqlite database file.
+//
+// The URL is sqlite:///path/to/metrics.db, relative paths are written as sqlite://path/to/metrics.db.
+// Databases of tenants are kept in the tenants directory next to the database of the default tenant.
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/storage/driver"
+	"github.com/vindosVP/metrics/internal/storage/sqlitestorage"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// tenantsDir - directory with databases of tenants
+const tenantsDir = "tenants"
+
+func init() {
+	driver.Register("sqlite", Driver{})
+}
+
+// Driver opens sqlite storages.
+type Driver struct{}
+
+// Open creates the storage of the tenant, metrics saved to its database before are kept.
+func (Driver) Open(rawURL string, opts *driver.Options) (driver.MetricsStorage, error) {
+	fileName, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	fileName = tenantFileName(fileName, opts.Tenant)
+	if err = os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+	s, err := sqlitestorage.New(context.Background(), fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sqlite storage: %w", err)
+	}
+	if opts.Retention != time.Duration(0) {
+		go trimHistory(s, opts.Retention)
+	}
+	return s, nil
+}
+
+// Tenants returns tenants with database directories.
+func (Driver) Tenants(rawURL string) ([]string, error) {
+	fileName, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(fileName), tenantsDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && tenant.Valid(entry.Name()) && entry.Name() != tenant.Default {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids, nil
+}
+
+// parseURL returns the database file of the storage URL.
+func parseURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse storage url: %w", err)
+	}
+	fileName := u.Host + u.Path
+	if fileName == "" {
+		return "", errors.New("storage url has no database file")
+	}
+	return fileName, nil
+}
+
+// tenantFileName returns the database file of the tenant.
+func tenantFileName(fileName string, id string) string {
+	if id == tenant.Default || id == "" {
+		return fileName
+	}
+	return filepath.Join(filepath.Dir(fileName), tenantsDir, id, filepath.Base(fileName))
+}
+
+func trimHistory(s *sqlitestorage.Storage, retention time.Duration) {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+
+	for range tick.C {
+		err := s.TrimHistory(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			logger.Log.Error("Failed to trim history", zap.Error(err))
+		}
+	}
+}
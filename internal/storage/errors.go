@@ -5,4 +5,13 @@ import "errors"
 var (
 	// ErrMetricNotRegistered - represents that metric with provided name is not registered
 	ErrMetricNotRegistered = errors.New("metric with this name not registered")
+
+	// ErrAmbiguousSeries - represents that label matchers select more than one series
+	ErrAmbiguousSeries = errors.New("label matchers select more than one series")
+
+	// ErrReadOnly - represents that the server is a replication follower and doesn't accept writes
+	ErrReadOnly = errors.New("storage is read-only on a replication follower")
+
+	// ErrTenantLimit - represents that the write would open the storage of a new tenant beyond the limit of tenants
+	ErrTenantLimit = errors.New("limit of tenants is reached")
 )
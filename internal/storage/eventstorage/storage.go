@@ -0,0 +1,325 @@
+// Package eventstorage is a metrics storage wrapper publishing every applied change to the event bus.
+//
+// Changes of one series are applied and published one at a time,
+// so subscribers see them in the order the wrapped storage applied them,
+// changes of different series are applied concurrently.
+package eventstorage
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+// MetricsStorage consists methods to save and get data from the wrapped storage
+type MetricsStorage interface {
+	UpdateGauge(ctx context.Context, name string, v float64) (float64, error)
+	UpdateCounter(ctx context.Context, name string, v int64) (int64, error)
+	SetCounter(ctx context.Context, name string, v int64) (int64, error)
+	GetGauge(ctx context.Context, name string) (float64, error)
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetCounter(ctx context.Context, name string) (int64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// stripes - number of locks ordering changes of series, series sharing a lock are changed one at a time
+const stripes = 256
+
+// GuardFunc returns an error if the change must not be applied in the context.
+type GuardFunc func(ctx context.Context) error
+
+// Storage consists wrapped storage and the bus changes are published to.
+// Reads are passed to the wrapped storage as is.
+type Storage struct {
+	MetricsStorage
+	bus     *events.Bus
+	guard   GuardFunc
+	tenants map[string]*sync.RWMutex
+	series  [stripes]sync.Mutex
+	mu      sync.Mutex
+}
+
+// New creates Storage.
+func New(s MetricsStorage, bus *events.Bus, opts ...func(*Storage)) *Storage {
+	st := &Storage{
+		MetricsStorage: s,
+		bus:            bus,
+		tenants:        make(map[string]*sync.RWMutex),
+	}
+	for _, opt := range opts {
+		opt(st)
+	}
+	return st
+}
+
+// WithGuard sets the check every change has to pass before it's applied.
+func WithGuard(guard GuardFunc) func(*Storage) {
+	return func(s *Storage) {
+		s.guard = guard
+	}
+}
+
+// Snapshot method runs f while no changes of the tenant of the context are applied,
+// passing the sequence number of the last published event, so f can read a state consistent with it.
+// Changes of the tenant wait for f to return.
+func (s *Storage) Snapshot(ctx context.Context, f func(seq uint64) error) error {
+	l := s.lock(tenant.FromContext(ctx))
+	l.Lock()
+	defer l.Unlock()
+	return f(s.bus.Seq())
+}
+
+// UpdateGauge method updates gauge metric value and publishes the update.
+func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
+	unlock, err := s.begin(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	val, err := s.MetricsStorage.UpdateGauge(ctx, name, v)
+	if err != nil {
+		return 0, err
+	}
+	s.publish(ctx, &events.Event{Op: events.OpUpdate, Metrics: []*models.Metrics{{ID: name, MType: models.Gauge, Value: &v}}})
+	return val, nil
+}
+
+// UpdateCounter method updates counter metric value and publishes its delta.
+func (s *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
+	unlock, err := s.begin(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	val, err := s.MetricsStorage.UpdateCounter(ctx, name, v)
+	if err != nil {
+		return 0, err
+	}
+	s.publish(ctx, &events.Event{Op: events.OpUpdate, Metrics: []*models.Metrics{{ID: name, MType: models.Counter, Delta: &v}}})
+	return val, nil
+}
+
+// SetCounter method sets counter metric value and publishes it.
+func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
+	unlock, err := s.begin(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	val, err := s.MetricsStorage.SetCounter(ctx, name, v)
+	if err != nil {
+		return 0, err
+	}
+	s.publish(ctx, &events.Event{Op: events.OpSet, Metrics: []*models.Metrics{{ID: name, MType: models.Counter, Delta: &v}}})
+	return val, nil
+}
+
+// InsertBatch method updates metrics of the batch and publishes them as one update.
+func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	unlock, err := s.begin(ctx, keys(batch)...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = s.MetricsStorage.InsertBatch(ctx, batch); err != nil {
+		return err
+	}
+	metrics := make([]*models.Metrics, 0, len(batch))
+	for _, m := range batch {
+		metrics = append(metrics, change(m))
+	}
+	s.publish(ctx, &events.Event{Op: events.OpUpdate, Metrics: metrics})
+	return nil
+}
+
+// UpdateHistogram method merges observations into the histogram and publishes them.
+func (s *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	unlock, err := s.begin(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	val, err := s.MetricsStorage.UpdateHistogram(ctx, name, v)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, &events.Event{Op: events.OpUpdate, Metrics: []*models.Metrics{{ID: name, MType: models.Histogram, Histogram: v.Copy()}}})
+	return val, nil
+}
+
+// UpdateSummary method merges the sketch into the summary and publishes it.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	unlock, err := s.begin(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	val, err := s.MetricsStorage.UpdateSummary(ctx, name, v)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, &events.Event{Op: events.OpUpdate, Metrics: []*models.Metrics{{ID: name, MType: models.Summary, Summary: v.Copy()}}})
+	return val, nil
+}
+
+// Delete method deletes the series and publishes the deletion.
+func (s *Storage) Delete(ctx context.Context, mType string, name string) error {
+	unlock, err := s.begin(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = s.MetricsStorage.Delete(ctx, mType, name); err != nil {
+		return err
+	}
+	s.publish(ctx, &events.Event{Op: events.OpDelete, Metrics: []*models.Metrics{{ID: name, MType: mType}}})
+	return nil
+}
+
+// DeleteBatch method deletes series of the batch and publishes the deletion.
+func (s *Storage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	unlock, err := s.begin(ctx, keys(batch)...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = s.MetricsStorage.DeleteBatch(ctx, batch); err != nil {
+		return err
+	}
+	metrics := make([]*models.Metrics, 0, len(batch))
+	for _, m := range batch {
+		metrics = append(metrics, &models.Metrics{ID: m.Key(), MType: m.MType})
+	}
+	s.publish(ctx, &events.Event{Op: events.OpDelete, Metrics: metrics})
+	return nil
+}
+
+// RegisterMetadata method saves metadata of the metric and publishes it.
+func (s *Storage) RegisterMetadata(ctx context.Context, meta *models.Metadata) error {
+	unlock, err := s.begin(ctx, meta.ID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = s.MetricsStorage.RegisterMetadata(ctx, meta); err != nil {
+		return err
+	}
+	m := *meta
+	s.publish(ctx, &events.Event{Op: events.OpMetadata, Metadata: &m})
+	return nil
+}
+
+// begin checks the change against the guard and locks changes of the series with provided keys.
+// Changes of the tenant of the context hold its read lock, so they wait only for snapshots of the tenant.
+func (s *Storage) begin(ctx context.Context, keys ...string) (func(), error) {
+	if s.guard != nil {
+		if err := s.guard(ctx); err != nil {
+			return nil, err
+		}
+	}
+	id := tenant.FromContext(ctx)
+	l := s.lock(id)
+	l.RLock()
+	// stripes are locked in the ascending order, so batches sharing them don't deadlock
+	locked := s.stripes(id, keys)
+	for _, i := range locked {
+		s.series[i].Lock()
+	}
+	return func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			s.series[locked[i]].Unlock()
+		}
+		l.RUnlock()
+	}, nil
+}
+
+// stripes returns sorted distinct locks of series of the tenant.
+func (s *Storage) stripes(id string, keys []string) []int {
+	seen := make(map[int]bool, len(keys))
+	res := make([]int, 0, len(keys))
+	for _, key := range keys {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(id))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(key))
+		i := int(h.Sum32() % stripes)
+		if !seen[i] {
+			seen[i] = true
+			res = append(res, i)
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+func (s *Storage) lock(id string) *sync.RWMutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.tenants[id]
+	if !ok {
+		l = &sync.RWMutex{}
+		s.tenants[id] = l
+	}
+	return l
+}
+
+func (s *Storage) publish(ctx context.Context, e *events.Event) {
+	e.Tenant = tenant.FromContext(ctx)
+	s.bus.Publish(e)
+}
+
+// keys returns series keys of metrics of the batch.
+func keys(batch []*models.Metrics) []string {
+	res := make([]string, 0, len(batch))
+	for _, m := range batch {
+		res = append(res, m.Key())
+	}
+	return res
+}
+
+// change returns the metric of the batch keyed by its series key with the value of its type only.
+func change(m *models.Metrics) *models.Metrics {
+	c := &models.Metrics{ID: m.Key(), MType: m.MType}
+	switch m.MType {
+	case models.Counter:
+		if m.Delta != nil {
+			d := *m.Delta
+			c.Delta = &d
+		}
+	case models.Gauge:
+		if m.Value != nil {
+			v := *m.Value
+			c.Value = &v
+		}
+	case models.Histogram:
+		if m.Histogram != nil {
+			c.Histogram = m.Histogram.Copy()
+		}
+	case models.Summary:
+		if sketch := m.SummarySketch(); sketch != nil {
+			c.Summary = sketch.Copy()
+		}
+	}
+	return c
+}
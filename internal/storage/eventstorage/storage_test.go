@@ -0,0 +1,163 @@
+package eventstorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/storage/storagetest"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+func newMemStorage() *memstorage.Storage {
+	return memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo(), memstorage.WithHistory(repos.NewHistoryRepo(0)))
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.MetricsStorage {
+		return New(newMemStorage(), events.NewBus())
+	})
+}
+
+func TestStorage_Publish(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+	s := New(newMemStorage(), bus)
+	ctx := tenant.WithTenant(context.Background(), "team-a")
+
+	_, err := s.UpdateCounter(ctx, "PollCount", 2)
+	require.NoError(t, err)
+	_, err = s.SetCounter(ctx, "PollCount", 10)
+	require.NoError(t, err)
+	value := 1.5
+	err = s.InsertBatch(ctx, []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &value, Labels: map[string]string{"host": "a"}},
+		{ID: "Latency", MType: models.Summary, Observations: []float64{1, 2}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, models.Counter, "PollCount"))
+	require.NoError(t, s.RegisterMetadata(ctx, &models.Metadata{ID: "Alloc", MType: models.Gauge}))
+	// failed changes are not published
+	require.Error(t, s.Delete(ctx, models.Counter, "PollCount"))
+
+	require.Len(t, sub.Events(), 5)
+	e := <-sub.Events()
+	assert.Equal(t, "team-a", e.Tenant)
+	assert.Equal(t, events.OpUpdate, e.Op)
+	assert.Equal(t, int64(2), *e.Metrics[0].Delta)
+
+	e = <-sub.Events()
+	assert.Equal(t, events.OpSet, e.Op)
+	assert.Equal(t, int64(10), *e.Metrics[0].Delta)
+
+	e = <-sub.Events()
+	assert.Equal(t, events.OpUpdate, e.Op)
+	require.Len(t, e.Metrics, 2)
+	assert.Equal(t, models.SeriesKey("Alloc", map[string]string{"host": "a"}), e.Metrics[0].ID)
+	assert.Nil(t, e.Metrics[0].Labels)
+	assert.Equal(t, uint64(2), e.Metrics[1].Summary.Count)
+
+	e = <-sub.Events()
+	assert.Equal(t, events.OpDelete, e.Op)
+	assert.Equal(t, &models.Metrics{ID: "PollCount", MType: models.Counter}, e.Metrics[0])
+
+	e = <-sub.Events()
+	assert.Equal(t, events.OpMetadata, e.Op)
+	assert.Equal(t, "Alloc", e.Metadata.ID)
+	assert.Equal(t, uint64(5), e.Seq)
+}
+
+func TestStorage_Guard(t *testing.T) {
+	bus := events.NewBus()
+	s := New(newMemStorage(), bus, WithGuard(func(context.Context) error {
+		return storage.ErrReadOnly
+	}))
+	ctx := context.Background()
+
+	_, err := s.UpdateGauge(ctx, "Alloc", 1)
+	assert.ErrorIs(t, err, storage.ErrReadOnly)
+	err = s.DeleteBatch(ctx, []*models.Metrics{{ID: "Alloc", MType: models.Gauge}})
+	assert.ErrorIs(t, err, storage.ErrReadOnly)
+	_, err = s.GetGauge(ctx, "Alloc")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	assert.Equal(t, uint64(0), bus.Seq())
+}
+
+func TestStorage_Snapshot(t *testing.T) {
+	bus := events.NewBus()
+	s := New(newMemStorage(), bus)
+	ctx := context.Background()
+
+	_, err := s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	err = s.Snapshot(ctx, func(seq uint64) error {
+		assert.Equal(t, uint64(1), seq)
+		gauges, err := s.GetAllGauge(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]float64{"Alloc": 1}, gauges)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// blockingStorage blocks updates of the gauge until it's released.
+type blockingStorage struct {
+	*memstorage.Storage
+	blocked string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStorage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
+	if name == s.blocked {
+		close(s.started)
+		<-s.release
+	}
+	return s.Storage.UpdateGauge(ctx, name, v)
+}
+
+func TestStorage_ConcurrentChanges(t *testing.T) {
+	bus := events.NewBus()
+	backend := &blockingStorage{Storage: newMemStorage(), blocked: "Slow", started: make(chan struct{}), release: make(chan struct{})}
+	s := New(backend, bus)
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		_, err := s.UpdateGauge(ctx, "Slow", 1)
+		assert.NoError(t, err)
+		close(done)
+	}()
+	<-backend.started
+
+	// changes of other series don't wait for the slow one
+	_, err := s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), bus.Seq())
+
+	// snapshots wait for changes being applied
+	snapshot := make(chan uint64)
+	go func() {
+		_ = s.Snapshot(ctx, func(seq uint64) error {
+			snapshot <- seq
+			return nil
+		})
+	}()
+	select {
+	case <-snapshot:
+		require.FailNow(t, "snapshot taken while the change is applied")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(backend.release)
+	<-done
+	assert.Equal(t, uint64(2), <-snapshot)
+}
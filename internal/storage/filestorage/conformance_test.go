@@ -0,0 +1,25 @@
+package filestorage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.MetricsStorage {
+		fileName := "./test_conformance.json"
+		s := NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName,
+			memstorage.WithHistory(repos.NewHistoryRepo(time.Hour)))
+		t.Cleanup(func() {
+			s.Close()
+			os.Remove(fileName)
+			os.Remove(WALPath(fileName))
+		})
+		return s
+	})
+}
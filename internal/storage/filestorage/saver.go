@@ -3,8 +3,6 @@ package filestorage
 
 import (
 	"context"
-	"encoding/json"
-	"os"
 	"time"
 
 	"go.uber.org/zap"
@@ -19,22 +17,28 @@ import (
 type MetricsStorage interface {
 	GetAllGauge(ctx context.Context) (map[string]float64, error)
 	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
 }
 
 // Saver consists data to save metrics dump
 type Saver struct {
 	Storage       MetricsStorage
 	Done          <-chan struct{}
+	Snapshots     *Snapshots
 	FileName      string
 	StoreInterval time.Duration
 }
 
-// NewSaver creates the Saver
+// NewSaver creates the Saver, dumps are written with NewSnapshots settings
 func NewSaver(filename string, storeInterval time.Duration, s MetricsStorage) *Saver {
 	return &Saver{
 		FileName:      filename,
 		StoreInterval: storeInterval,
 		Storage:       s,
+		Snapshots:     NewSnapshots(filename),
 	}
 }
 
@@ -62,53 +66,106 @@ func (s *Saver) Run() {
 }
 
 func (s *Saver) save() {
-	ctx := context.Background()
-	gMetrics, err := s.Storage.GetAllGauge(ctx)
+	err := s.Snapshots.Write(collectDump(context.Background(), s.Storage))
+	if err != nil {
+		logger.Log.Error("Failed to write metrics", zap.Error(err))
+	}
+}
+
+// collectDump returns values and metadata of all metrics of the storage in the dump format.
+func collectDump(ctx context.Context, s MetricsStorage) *models.MetricsDump {
+	gMetrics, err := s.GetAllGauge(ctx)
 	if err != nil {
 		logger.Log.Error("Failed to get gauge metrics", zap.Error(err))
 	}
-	cMetrics, err := s.Storage.GetAllCounter(ctx)
+	cMetrics, err := s.GetAllCounter(ctx)
 	if err != nil {
 		logger.Log.Error("Failed to get counter metrics", zap.Error(err))
 	}
+	hMetrics, err := s.GetAllHistogram(ctx)
+	if err != nil {
+		logger.Log.Error("Failed to get histogram metrics", zap.Error(err))
+	}
+	sMetrics, err := s.GetAllSummary(ctx)
+	if err != nil {
+		logger.Log.Error("Failed to get summary metrics", zap.Error(err))
+	}
 
-	WriteMetrics(cMetrics, gMetrics, s.FileName)
-}
-
-// WriteMetrics saves metrics values to file
-func WriteMetrics(cMetrics map[string]int64, gMetrics map[string]float64, fileName string) {
-	metrics := make([]*models.Metrics, len(gMetrics)+len(cMetrics))
-	i := 0
+	metrics := make([]*models.Metrics, 0, len(gMetrics)+len(cMetrics)+len(hMetrics)+len(sMetrics))
 	for k, v := range gMetrics {
 		val := v
-		metric := &models.Metrics{
-			ID:    k,
-			MType: models.Gauge,
-			Value: &val,
+		metric, err := dumpMetric(k, models.Gauge)
+		if err != nil {
+			logger.Log.Error("Failed to parse series key", zap.Error(err))
+			continue
 		}
-		metrics[i] = metric
-		i++
+		metric.Value = &val
+		metrics = append(metrics, metric)
 	}
 	for k, v := range cMetrics {
 		val := v
-		metric := &models.Metrics{
-			ID:    k,
-			MType: models.Counter,
-			Delta: &val,
+		metric, err := dumpMetric(k, models.Counter)
+		if err != nil {
+			logger.Log.Error("Failed to parse series key", zap.Error(err))
+			continue
 		}
-		metrics[i] = metric
-		i++
+		metric.Delta = &val
+		metrics = append(metrics, metric)
+	}
+	for k, v := range hMetrics {
+		metric, err := dumpMetric(k, models.Histogram)
+		if err != nil {
+			logger.Log.Error("Failed to parse series key", zap.Error(err))
+			continue
+		}
+		metric.Histogram = v
+		metrics = append(metrics, metric)
+	}
+	for k, v := range sMetrics {
+		metric, err := dumpMetric(k, models.Summary)
+		if err != nil {
+			logger.Log.Error("Failed to parse series key", zap.Error(err))
+			continue
+		}
+		metric.Summary = v
+		metrics = append(metrics, metric)
 	}
 
-	metricsDump := &models.MetricsDump{Metrics: metrics}
+	updated := make(map[string]map[string]time.Time, len(models.Types))
+	for _, mType := range models.Types {
+		updated[mType], err = s.GetUpdated(ctx, mType)
+		if err != nil {
+			logger.Log.Error("Failed to get metrics update time", zap.String("type", mType), zap.Error(err))
+		}
+	}
+	for _, metric := range metrics {
+		if t, ok := updated[metric.MType][metric.Key()]; ok {
+			metric.Updated = &t
+		}
+	}
 
-	data, err := json.MarshalIndent(metricsDump, "", "    ")
+	metadata, err := s.GetAllMetadata(ctx)
 	if err != nil {
-		logger.Log.Error("Failed to marshal metrics", zap.Error(err))
+		logger.Log.Error("Failed to get metrics metadata", zap.Error(err))
+	}
+	dump := &models.MetricsDump{
+		Metrics:  metrics,
+		Metadata: make([]*models.Metadata, 0, len(metadata)),
+	}
+	for _, meta := range metadata {
+		dump.Metadata = append(dump.Metadata, meta)
 	}
+	return dump
+}
 
-	err = os.WriteFile(fileName, data, 0666)
+func dumpMetric(key string, mType string) (*models.Metrics, error) {
+	name, labels, err := models.ParseSeriesKey(key)
 	if err != nil {
-		logger.Log.Error("Failed to write metrics", zap.Error(err))
+		return nil, err
 	}
+	return &models.Metrics{
+		ID:     name,
+		MType:  mType,
+		Labels: labels,
+	}, nil
 }
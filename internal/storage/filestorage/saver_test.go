@@ -2,7 +2,6 @@ package filestorage
 
 import (
 	"context"
-	"encoding/json"
 	"os"
 	"reflect"
 	"testing"
@@ -63,10 +62,7 @@ func TestSaver(t *testing.T) {
 	close(done)
 	<-saverShutdown
 
-	savedData, err := os.ReadFile(fileName)
-	require.NoError(t, err)
-	dump := &models.MetricsDump{}
-	err = json.Unmarshal(savedData, &dump)
+	dump, err := NewSnapshots(fileName).Read()
 	require.NoError(t, err)
 
 	gotCMetrics := make(map[string]int64)
@@ -85,3 +81,24 @@ func TestSaver(t *testing.T) {
 	assert.True(t, cEqual)
 	assert.True(t, gEqual)
 }
+
+func TestStorage_Delete(t *testing.T) {
+	fileName := "./test_delete.json"
+	defer os.Remove(fileName)
+	defer os.Remove(WALPath(fileName))
+
+	ctx := context.Background()
+	s := NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	defer s.Close()
+	_, err := s.UpdateGauge(ctx, "Alloc", 1.5)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Typo", 2)
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, models.Gauge, "Typo"))
+	require.NoError(t, s.Compact(ctx))
+
+	dump, err := NewSnapshots(fileName).Read()
+	require.NoError(t, err)
+	require.Len(t, dump.Metrics, 1)
+	assert.Equal(t, "Alloc", dump.Metrics[0].ID)
+}
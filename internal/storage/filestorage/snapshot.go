@@ -0,0 +1,222 @@
+package filestorage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// snapshotVersion - version of the snapshot format, dumps without the header are of version 1
+const snapshotVersion = 2
+
+// compressionGzip - compression of gzipped snapshots
+const compressionGzip = "gzip"
+
+// ErrCorruptedSnapshot - represents that snapshot is truncated or its checksum does not match
+var ErrCorruptedSnapshot = errors.New("corrupted snapshot")
+
+// snapshotHeader - the first line of the snapshot describing the payload following it
+type snapshotHeader struct {
+	Created     time.Time `json:"created"`
+	Checksum    string    `json:"checksum"`
+	Compression string    `json:"compression,omitempty"`
+	Version     int       `json:"version"`
+}
+
+// Snapshots - location and format of metrics dumps.
+// The newest dump is written to FileName, Keep-1 previous dumps are kept in FileName.1, FileName.2 and so on.
+type Snapshots struct {
+	FileName string
+	Keep     int
+	Compress bool
+}
+
+// NewSnapshots creates Snapshots keeping only the newest uncompressed dump.
+func NewSnapshots(fileName string) *Snapshots {
+	return &Snapshots{FileName: fileName, Keep: 1}
+}
+
+// Write method writes the dump atomically: the snapshot is written and synced to a temporary file,
+// previous snapshots are rotated and the temporary file is renamed to FileName.
+func (s *Snapshots) Write(dump *models.MetricsDump) error {
+	data, err := encodeSnapshot(dump, s.Compress, time.Now())
+	if err != nil {
+		return err
+	}
+
+	tmp := s.FileName + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = s.rotate(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, s.FileName); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(s.FileName))
+}
+
+// Read method returns the newest valid dump, older dumps are used when newer ones are corrupted.
+// Error wrapping fs.ErrNotExist is returned if there are no dumps at all.
+func (s *Snapshots) Read() (*models.MetricsDump, error) {
+	var lastErr error
+	for i := 0; ; i++ {
+		path := s.path(i)
+		dump, err := readSnapshot(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			if i == 0 {
+				continue
+			}
+			break
+		}
+		if err == nil {
+			if lastErr != nil {
+				logger.Log.Warn("Restoring from older snapshot", zap.String("file", path))
+			}
+			return dump, nil
+		}
+		logger.Log.Error("Skipping invalid snapshot", zap.String("file", path), zap.Error(err))
+		lastErr = err
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("no snapshots of %s: %w", s.FileName, fs.ErrNotExist)
+}
+
+func (s *Snapshots) path(i int) string {
+	if i == 0 {
+		return s.FileName
+	}
+	return s.FileName + "." + strconv.Itoa(i)
+}
+
+// rotate shifts previous snapshots making room for the new one and removes snapshots over Keep.
+func (s *Snapshots) rotate() error {
+	keep := s.Keep
+	if keep < 1 {
+		keep = 1
+	}
+	if err := os.Remove(s.path(keep)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := keep - 1; i >= 1; i-- {
+		err := os.Rename(s.path(i-1), s.path(i))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeSnapshot(dump *models.MetricsDump, compress bool, created time.Time) ([]byte, error) {
+	payload, err := json.MarshalIndent(dump, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	header := snapshotHeader{Version: snapshotVersion, Created: created}
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(payload); err != nil {
+			return nil, err
+		}
+		if err = zw.Close(); err != nil {
+			return nil, err
+		}
+		payload = buf.Bytes()
+		header.Compression = compressionGzip
+	}
+	header.Checksum = checksum(payload)
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+	return append(data, payload...), nil
+}
+
+// readSnapshot reads the snapshot verifying its checksum, dumps without the header are read as is.
+func readSnapshot(path string) (*models.MetricsDump, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &models.MetricsDump{}
+	header := &snapshotHeader{}
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 || json.Unmarshal(data[:i], header) != nil || header.Version == 0 {
+		if err = json.Unmarshal(data, dump); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorruptedSnapshot, err)
+		}
+		return dump, nil
+	}
+	if header.Version > snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+
+	payload := data[i+1:]
+	if checksum(payload) != header.Checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptedSnapshot)
+	}
+	if header.Compression == compressionGzip {
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorruptedSnapshot, err)
+		}
+		payload, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorruptedSnapshot, err)
+		}
+	}
+	if err = json.Unmarshal(payload, dump); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorruptedSnapshot, err)
+	}
+	return dump, nil
+}
+
+func checksum(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// syncDir makes the rename of a file in the directory durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,120 @@
+package filestorage
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+func testDump(seq uint64) *models.MetricsDump {
+	value := float64(seq)
+	return &models.MetricsDump{
+		Metrics: []*models.Metrics{{ID: "Alloc", MType: models.Gauge, Value: &value}},
+		Seq:     seq,
+	}
+}
+
+func removeSnapshots(s *Snapshots) {
+	for i := 0; i <= s.Keep; i++ {
+		os.Remove(s.path(i))
+	}
+	os.Remove(s.FileName + ".tmp")
+}
+
+func TestSnapshots_Write(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress bool
+	}{
+		{name: "plain", compress: false},
+		{name: "gzip", compress: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Snapshots{FileName: "./test_snapshot.json", Keep: 1, Compress: tt.compress}
+			defer removeSnapshots(s)
+
+			require.NoError(t, s.Write(testDump(1)))
+			data, err := os.ReadFile(s.FileName)
+			require.NoError(t, err)
+			header := &snapshotHeader{}
+			require.NoError(t, json.Unmarshal(data[:bytes.IndexByte(data, '\n')], header))
+			assert.Equal(t, snapshotVersion, header.Version)
+			assert.False(t, header.Created.IsZero())
+			assert.Equal(t, tt.compress, header.Compression == compressionGzip)
+
+			dump, err := s.Read()
+			require.NoError(t, err)
+			assert.Equal(t, testDump(1), dump)
+		})
+	}
+}
+
+func TestSnapshots_Rotation(t *testing.T) {
+	s := &Snapshots{FileName: "./test_snapshot_rotation.json", Keep: 3}
+	defer removeSnapshots(s)
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		require.NoError(t, s.Write(testDump(seq)))
+	}
+	for i, want := range []uint64{5, 4, 3} {
+		dump, err := readSnapshot(s.path(i))
+		require.NoError(t, err)
+		assert.Equal(t, want, dump.Seq)
+	}
+	_, err := os.Stat(s.path(3))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestSnapshots_ReadFallback(t *testing.T) {
+	s := &Snapshots{FileName: "./test_snapshot_fallback.json", Keep: 3}
+	defer removeSnapshots(s)
+
+	for seq := uint64(1); seq <= 3; seq++ {
+		require.NoError(t, s.Write(testDump(seq)))
+	}
+
+	// torn newest snapshot
+	data, err := os.ReadFile(s.path(0))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(s.path(0), data[:len(data)-10], 0666))
+	_, err = readSnapshot(s.path(0))
+	assert.ErrorIs(t, err, ErrCorruptedSnapshot)
+	dump, err := s.Read()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), dump.Seq)
+
+	// newest snapshot lost during rotation
+	require.NoError(t, os.Remove(s.path(0)))
+	dump, err = s.Read()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), dump.Seq)
+
+	require.NoError(t, os.WriteFile(s.path(1), []byte("garbage"), 0666))
+	require.NoError(t, os.WriteFile(s.path(2), []byte("garbage"), 0666))
+	_, err = s.Read()
+	assert.ErrorIs(t, err, ErrCorruptedSnapshot)
+}
+
+func TestSnapshots_ReadLegacy(t *testing.T) {
+	s := NewSnapshots("./test_snapshot_legacy.json")
+	defer removeSnapshots(s)
+
+	_, err := s.Read()
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	data, err := json.MarshalIndent(testDump(0), "", "    ")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(s.FileName, data, 0666))
+	dump, err := s.Read()
+	require.NoError(t, err)
+	assert.Equal(t, testDump(0), dump)
+}
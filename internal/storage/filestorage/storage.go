@@ -2,16 +2,24 @@ package filestorage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/vindosVP/metrics/internal/models"
-	"github.com/vindosVP/metrics/internal/repos"
 	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
 	"github.com/vindosVP/metrics/pkg/logger"
 )
 
+// compactThreshold - number of write-ahead log records after which the log is compacted into the dump
+const compactThreshold = 1000
+
 // Counter consists methods to work with counter metrics repository.
 //
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Counter
@@ -20,6 +28,7 @@ type Counter interface {
 	Get(ctx context.Context, name string) (int64, error)
 	GetAll(ctx context.Context) (map[string]int64, error)
 	Set(ctx context.Context, name string, v int64) (int64, error)
+	Delete(ctx context.Context, name string) error
 }
 
 // Gauge consists methods to work with gauge metrics repository.
@@ -29,109 +38,313 @@ type Gauge interface {
 	Update(ctx context.Context, name string, v float64) (float64, error)
 	Get(ctx context.Context, name string) (float64, error)
 	GetAll(ctx context.Context) (map[string]float64, error)
+	Delete(ctx context.Context, name string) error
 }
 
-// NewFileStorage creates Storage.
-func NewFileStorage(gRepo Gauge, cRepo Counter, fileName string) *Storage {
+// NewFileStorage creates Storage, dumps are written with NewSnapshots settings.
+func NewFileStorage(gRepo Gauge, cRepo Counter, fileName string, opts ...func(*memstorage.Storage)) *Storage {
 	return &Storage{
-		gRepo:    gRepo,
-		cRepo:    cRepo,
-		fileName: fileName,
+		Storage:   memstorage.New(gRepo, cRepo, opts...),
+		Snapshots: NewSnapshots(fileName),
 	}
 }
 
-// Storage consists counter repository, gauge repository and dump filename.
+// Storage consists in-memory storage, dump settings and write-ahead log.
+// Reads are served by the in-memory storage, every write is appended to the write-ahead log and synced
+// before it's applied to the in-memory storage, the log is compacted into the dump every compactThreshold records.
 type Storage struct {
-	gRepo    Gauge
-	cRepo    Counter
-	fileName string
+	*memstorage.Storage
+	Snapshots *Snapshots
+	wal       *os.File
+	seq       uint64
+	records   int
+	mu        sync.Mutex
 }
 
-// InsertBatch method saves provided metrics values to the storage and writes storage dump to the file.
+// InsertBatch method appends provided metrics values to the write-ahead log and saves them to the storage.
 func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.Storage.CheckBatch(ctx, batch); err != nil {
+		return err
+	}
+	records := make([]*models.WALRecord, 0, len(batch))
 	for _, metric := range batch {
-		switch metric.MType {
-		case models.Counter:
-			val := *metric.Delta
-			_, err := s.cRepo.Update(ctx, metric.ID, val)
-			if err != nil {
-				return err
-			}
-		case models.Gauge:
-			val := *metric.Value
-			_, err := s.gRepo.Update(ctx, metric.ID, val)
-			if err != nil {
-				return err
-			}
+		record := &models.Metrics{
+			ID:        metric.ID,
+			MType:     metric.MType,
+			Labels:    metric.Labels,
+			Delta:     metric.Delta,
+			Value:     metric.Value,
+			Histogram: metric.Histogram,
+			Summary:   metric.SummarySketch(),
 		}
+		records = append(records, &models.WALRecord{Op: models.WALUpdate, Metric: record})
 	}
-	s.dump(ctx)
-	return nil
+	return s.write(ctx, func() error {
+		return s.Storage.InsertBatch(ctx, batch)
+	}, records...)
 }
 
-// UpdateGauge method updates gauge metric value and writes storage dump to the file.
+// UpdateGauge method appends the update to the write-ahead log and updates gauge metric value.
 func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
-	val, err := s.gRepo.Update(ctx, name, v)
-	s.dump(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var val float64
+	err := s.writeMetric(ctx, models.WALUpdate, name, &models.Metrics{MType: models.Gauge, Value: &v}, func() (err error) {
+		val, err = s.Storage.UpdateGauge(ctx, name, v)
+		return err
+	})
 	return val, err
 }
 
-// UpdateCounter method updates counter metric value and writes storage dump to the file.
+// UpdateCounter method appends the update to the write-ahead log and updates counter metric value.
 func (s *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
-	val, err := s.cRepo.Update(ctx, name, v)
-	s.dump(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var val int64
+	err := s.writeMetric(ctx, models.WALUpdate, name, &models.Metrics{MType: models.Counter, Delta: &v}, func() (err error) {
+		val, err = s.Storage.UpdateCounter(ctx, name, v)
+		return err
+	})
+	return val, err
+}
+
+// SetCounter method appends the update to the write-ahead log and sets counter metric value.
+func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var val int64
+	err := s.writeMetric(ctx, models.WALSet, name, &models.Metrics{MType: models.Counter, Delta: &v}, func() (err error) {
+		val, err = s.Storage.SetCounter(ctx, name, v)
+		return err
+	})
 	return val, err
 }
 
-// GetGauge method returns gauge metric value.
-func (s *Storage) GetGauge(ctx context.Context, name string) (float64, error) {
-	val, err := s.gRepo.Get(ctx, name)
-	if errors.Is(err, repos.ErrMetricNotRegistered) {
-		return 0, storage.ErrMetricNotRegistered
+// UpdateHistogram method appends the update to the write-ahead log and merges observations into histogram metric value.
+func (s *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.Storage.CheckBatch(ctx, []*models.Metrics{{ID: name, MType: models.Histogram, Histogram: v}}); err != nil {
+		return nil, err
 	}
-	if err != nil {
-		return 0, err
+	var val *models.HistogramValue
+	err := s.writeMetric(ctx, models.WALUpdate, name, &models.Metrics{MType: models.Histogram, Histogram: v}, func() (err error) {
+		val, err = s.Storage.UpdateHistogram(ctx, name, v)
+		return err
+	})
+	return val, err
+}
+
+// UpdateSummary method appends the update to the write-ahead log and merges observations into summary metric value.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.Storage.CheckBatch(ctx, []*models.Metrics{{ID: name, MType: models.Summary, Summary: v}}); err != nil {
+		return nil, err
 	}
-	return val, nil
+	var val *models.SummaryValue
+	err := s.writeMetric(ctx, models.WALUpdate, name, &models.Metrics{MType: models.Summary, Summary: v}, func() (err error) {
+		val, err = s.Storage.UpdateSummary(ctx, name, v)
+		return err
+	})
+	return val, err
 }
 
-// GetCounter method returns counter metric value.
-func (s *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
-	val, err := s.cRepo.Get(ctx, name)
-	if errors.Is(err, repos.ErrMetricNotRegistered) {
-		return 0, storage.ErrMetricNotRegistered
+// Delete method appends the removal to the write-ahead log and removes the metric.
+func (s *Storage) Delete(ctx context.Context, mType string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.exists(ctx, mType, name); err != nil {
+		return err
 	}
-	if err != nil {
-		return 0, err
+	return s.writeMetric(ctx, models.WALDelete, name, &models.Metrics{MType: mType}, func() error {
+		return s.Storage.Delete(ctx, mType, name)
+	})
+}
+
+// DeleteBatch method appends the removals to the write-ahead log and removes all provided metrics.
+func (s *Storage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]*models.WALRecord, 0, len(batch))
+	for _, metric := range batch {
+		records = append(records, &models.WALRecord{
+			Op:     models.WALDelete,
+			Metric: &models.Metrics{ID: metric.ID, MType: metric.MType, Labels: metric.Labels},
+		})
 	}
-	return val, nil
+	return s.write(ctx, func() error {
+		return s.Storage.DeleteBatch(ctx, batch)
+	}, records...)
 }
 
-// GetAllGauge method returns values of all collected gauge metrics.
-func (s *Storage) GetAllGauge(ctx context.Context) (map[string]float64, error) {
-	return s.gRepo.GetAll(ctx)
+// RegisterMetadata method appends metadata of the metric to the write-ahead log and saves it.
+func (s *Storage) RegisterMetadata(ctx context.Context, meta *models.Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(ctx, func() error {
+		return s.Storage.RegisterMetadata(ctx, meta)
+	}, &models.WALRecord{Op: models.WALMetadata, Metadata: meta})
 }
 
-// GetAllCounter method returns values of all collected counter metrics.
-func (s *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
-	return s.cRepo.GetAll(ctx)
+// Compact method writes the storage dump to the file and truncates the write-ahead log.
+func (s *Storage) Compact(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact(ctx)
 }
 
-// SetCounter method sets counter metric value and writes storage dump to the file.
-func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
-	val, err := s.cRepo.Set(ctx, name, v)
-	s.dump(ctx)
-	return val, err
+// Close method closes the write-ahead log.
+func (s *Storage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wal == nil {
+		return nil
+	}
+	err := s.wal.Close()
+	s.wal = nil
+	return err
+}
+
+// exists returns storage.ErrMetricNotRegistered if there is no series of the type.
+func (s *Storage) exists(ctx context.Context, mType string, key string) error {
+	var err error
+	switch mType {
+	case models.Counter:
+		_, err = s.Storage.GetCounter(ctx, key)
+	case models.Gauge:
+		_, err = s.Storage.GetGauge(ctx, key)
+	case models.Histogram:
+		_, err = s.Storage.GetHistogram(ctx, key)
+	case models.Summary:
+		_, err = s.Storage.GetSummary(ctx, key)
+	default:
+		err = storage.ErrMetricNotRegistered
+	}
+	return err
+}
+
+// writeMetric writes the record of the series like write does.
+func (s *Storage) writeMetric(ctx context.Context, op string, key string, metric *models.Metrics, apply func() error) error {
+	name, labels, err := models.ParseSeriesKey(key)
+	if err != nil {
+		return err
+	}
+	metric.ID = name
+	metric.Labels = labels
+	return s.write(ctx, apply, &models.WALRecord{Op: op, Metric: metric})
+}
+
+// write appends records to the write-ahead log, only then applies the write to the in-memory storage
+// and compacts the log when it grows over compactThreshold.
+// Writes failed to be persisted are not applied, writes failed to be applied after they were persisted
+// fail the same way when the log is replayed.
+func (s *Storage) write(ctx context.Context, apply func() error, records ...*models.WALRecord) error {
+	if err := s.append(records...); err != nil {
+		return fmt.Errorf("failed to write write-ahead log: %w", err)
+	}
+	if err := apply(); err != nil {
+		return err
+	}
+	if s.records < compactThreshold {
+		return nil
+	}
+	// the log keeps the records until the dump is written, so failed compaction loses nothing
+	if err := s.compact(ctx); err != nil {
+		logger.Log.Error("Failed to compact write-ahead log", zap.Error(err))
+	}
+	return nil
+}
+
+// append writes numbered records to the write-ahead log with a single write and syncs the log.
+func (s *Storage) append(records ...*models.WALRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	seq := s.seq
+	data := make([]byte, 0)
+	for _, record := range records {
+		seq++
+		record.Seq = seq
+		if record.Metric != nil {
+			record.Metric.Updated = &now
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+	if _, err := s.wal.Write(data); err != nil {
+		return err
+	}
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	s.seq = seq
+	s.records += len(records)
+	return nil
 }
 
-func (s *Storage) dump(ctx context.Context) {
-	cMetrics, err := s.GetAllCounter(ctx)
+// open opens the write-ahead log and continues numbering of persisted records.
+func (s *Storage) open() error {
+	if s.wal != nil {
+		return nil
+	}
+	seq, err := lastSeq(s.Snapshots)
+	if err != nil {
+		return err
+	}
+	wal, err := os.OpenFile(WALPath(s.Snapshots.FileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
-		logger.Log.Error("Failed to get counters", zap.Error(err))
+		return err
+	}
+	s.wal = wal
+	s.seq = seq
+	return nil
+}
+
+// compact writes the dump including all numbered records and only then truncates the log,
+// so records left by a crash between these steps are skipped on replay.
+func (s *Storage) compact(ctx context.Context) error {
+	if err := s.open(); err != nil {
+		return err
 	}
-	gMetrics, err := s.GetAllGauge(ctx)
+	dump := collectDump(ctx, s.Storage)
+	dump.Seq = s.seq
+	if err := s.Snapshots.Write(dump); err != nil {
+		return err
+	}
+	if err := s.wal.Truncate(0); err != nil {
+		return err
+	}
+	s.records = 0
+	return s.wal.Sync()
+}
+
+// Checkpoint writes the dump of the storage including all records of the write-ahead log and removes the log.
+// It is used when the dump is written by the Saver, which keeps no log.
+func Checkpoint(ctx context.Context, s MetricsStorage, snapshots *Snapshots) error {
+	seq, err := lastSeq(snapshots)
 	if err != nil {
-		logger.Log.Error("Failed to get gauges", zap.Error(err))
+		return err
 	}
-	WriteMetrics(cMetrics, gMetrics, s.fileName)
+	dump := collectDump(ctx, s)
+	dump.Seq = seq
+	if err = snapshots.Write(dump); err != nil {
+		return err
+	}
+	err = os.Remove(WALPath(snapshots.FileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
 }
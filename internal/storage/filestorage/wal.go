@@ -0,0 +1,76 @@
+package filestorage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+// WALPath returns path of the write-ahead log kept next to the dump file.
+func WALPath(fileName string) string {
+	return fileName + ".wal"
+}
+
+// ErrCorruptedWAL - represents that a record in the middle of the write-ahead log is malformed
+var ErrCorruptedWAL = errors.New("corrupted write-ahead log")
+
+// ReadWAL returns records of the write-ahead log of the dump file, missing log has no records.
+// Malformed final record is the record torn by a crash during the write and is skipped,
+// malformed records followed by other ones mean the log is corrupted and fail reading with ErrCorruptedWAL.
+func ReadWAL(fileName string) ([]*models.WALRecord, error) {
+	data, err := os.ReadFile(WALPath(fileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte{'\n'})
+	records := make([]*models.WALRecord, 0, len(lines))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		record := &models.WALRecord{}
+		if err = json.Unmarshal(line, record); err != nil {
+			if i < len(lines)-1 {
+				return nil, fmt.Errorf("%w: record %d: %s", ErrCorruptedWAL, i+1, err)
+			}
+			logger.Log.Warn("Skipping torn write-ahead log tail", zap.Int("records", len(records)), zap.Error(err))
+			break
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// lastSeq returns the sequence number of the last write persisted in the dump or in the write-ahead log.
+func lastSeq(snapshots *Snapshots) (uint64, error) {
+	var seq uint64
+	dump, err := snapshots.Read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	if err == nil {
+		seq = dump.Seq
+	}
+	records, err := ReadWAL(snapshots.FileName)
+	if err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		if record.Seq > seq {
+			seq = record.Seq
+		}
+	}
+	return seq, nil
+}
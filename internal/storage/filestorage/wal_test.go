@@ -0,0 +1,167 @@
+package filestorage
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+)
+
+func TestStorage_WAL(t *testing.T) {
+	fileName := "./test_wal.json"
+	defer os.Remove(fileName)
+	defer os.Remove(WALPath(fileName))
+
+	ctx := context.Background()
+	s := NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	_, err := s.UpdateCounter(ctx, `PollCount{host="a"}`, 2)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Alloc", 1.5)
+	require.NoError(t, err)
+	require.NoError(t, s.InsertBatch(ctx, []*models.Metrics{{ID: "Latency", MType: models.Summary, Observations: []float64{1, 2}}}))
+	require.NoError(t, s.Delete(ctx, models.Gauge, "Alloc"))
+
+	_, err = os.Stat(fileName)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	records, err := ReadWAL(fileName)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	ops := make([]string, 0, len(records))
+	for i, record := range records {
+		assert.Equal(t, uint64(i+1), record.Seq)
+		assert.NotNil(t, record.Metric.Updated)
+		ops = append(ops, record.Op)
+	}
+	assert.Equal(t, []string{models.WALUpdate, models.WALUpdate, models.WALUpdate, models.WALDelete}, ops)
+	assert.Equal(t, map[string]string{"host": "a"}, records[0].Metric.Labels)
+	assert.Equal(t, uint64(2), records[2].Metric.Summary.Count)
+
+	require.NoError(t, s.Compact(ctx))
+	records, err = ReadWAL(fileName)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	dump, err := NewSnapshots(fileName).Read()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), dump.Seq)
+	assert.Len(t, dump.Metrics, 2)
+	require.NoError(t, s.Close())
+
+	reopened := NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	defer reopened.Close()
+	_, err = reopened.UpdateGauge(ctx, "Alloc", 3)
+	require.NoError(t, err)
+	records, err = ReadWAL(fileName)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, uint64(5), records[0].Seq)
+}
+
+func TestStorage_WALCompactThreshold(t *testing.T) {
+	fileName := "./test_wal_threshold.json"
+	defer os.Remove(fileName)
+	defer os.Remove(WALPath(fileName))
+
+	ctx := context.Background()
+	s := NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	defer s.Close()
+	for i := 0; i < compactThreshold+1; i++ {
+		_, err := s.UpdateCounter(ctx, "PollCount", 1)
+		require.NoError(t, err)
+	}
+
+	dump, err := NewSnapshots(fileName).Read()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(compactThreshold), dump.Seq)
+	require.Len(t, dump.Metrics, 1)
+	assert.Equal(t, int64(compactThreshold), *dump.Metrics[0].Delta)
+	records, err := ReadWAL(fileName)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestReadWAL(t *testing.T) {
+	fileName := "./test_read_wal.json"
+	defer os.Remove(WALPath(fileName))
+
+	records, err := ReadWAL(fileName)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	data := `{"op":"update","seq":1,"metric":{"id":"Alloc","type":"gauge","value":1}}
+{"op":"delete","seq":2,"metric":{"id":"Alloc","type":"gauge"}}
+{"op":"update","seq":3,"met`
+	require.NoError(t, os.WriteFile(WALPath(fileName), []byte(data), 0666))
+
+	records, err = ReadWAL(fileName)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, models.WALDelete, records[1].Op)
+
+	// records following the malformed one are not dropped
+	data = `{"op":"update","seq":1,"metric":{"id":"Alloc","type":"gauge","value":1}}
+{"op":"update","seq":2,"met
+{"op":"delete","seq":3,"metric":{"id":"Alloc","type":"gauge"}}
+`
+	require.NoError(t, os.WriteFile(WALPath(fileName), []byte(data), 0666))
+	_, err = ReadWAL(fileName)
+	assert.ErrorIs(t, err, ErrCorruptedWAL)
+}
+
+func TestStorage_WALFailure(t *testing.T) {
+	fileName := "./test_wal_failure.json"
+	defer os.Remove(fileName)
+	defer os.Remove(WALPath(fileName))
+
+	ctx := context.Background()
+	s := NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	_, err := s.UpdateCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+	// writes to the closed log fail and are not applied
+	require.NoError(t, s.wal.Close())
+
+	_, err = s.UpdateCounter(ctx, "PollCount", 2)
+	assert.Error(t, err)
+	_, err = s.UpdateGauge(ctx, "Alloc", 1.5)
+	assert.Error(t, err)
+	assert.Error(t, s.InsertBatch(ctx, []*models.Metrics{{ID: "PollCount", MType: models.Counter, Delta: new(int64)}}))
+	assert.Error(t, s.Delete(ctx, models.Counter, "PollCount"))
+
+	v, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	_, err = s.GetGauge(ctx, "Alloc")
+	assert.Error(t, err)
+
+	// rejected writes are not logged
+	s.wal = nil
+	defer s.Close()
+	assert.Error(t, s.Delete(ctx, models.Gauge, "Alloc"))
+	records, err := ReadWAL(fileName)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestCheckpoint(t *testing.T) {
+	fileName := "./test_checkpoint.json"
+	defer os.Remove(fileName)
+	defer os.Remove(WALPath(fileName))
+
+	ctx := context.Background()
+	s := NewFileStorage(repos.NewGaugeRepo(), repos.NewCounterRepo(), fileName)
+	_, err := s.UpdateGauge(ctx, "Alloc", 1.5)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	require.NoError(t, Checkpoint(ctx, s.Storage, NewSnapshots(fileName)))
+	_, err = os.Stat(WALPath(fileName))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	dump, err := NewSnapshots(fileName).Read()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), dump.Seq)
+	assert.Len(t, dump.Metrics, 1)
+}
@@ -0,0 +1,15 @@
+package memstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.MetricsStorage {
+		return New(repos.NewGaugeRepo(), repos.NewCounterRepo(), WithHistory(repos.NewHistoryRepo(time.Hour)))
+	})
+}
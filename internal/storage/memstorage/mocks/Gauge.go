@@ -13,6 +13,20 @@ type Gauge struct {
 	mock.Mock
 }
 
+// Delete provides a mock function with given fields: ctx, name
+func (_m *Gauge) Delete(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Get provides a mock function with given fields: ctx, name
 func (_m *Gauge) Get(ctx context.Context, name string) (float64, error) {
 	ret := _m.Called(ctx, name)
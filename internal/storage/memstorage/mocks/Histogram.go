@@ -0,0 +1,123 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "github.com/vindosVP/metrics/internal/models"
+)
+
+// Histogram is an autogenerated mock type for the Histogram type
+type Histogram struct {
+	mock.Mock
+}
+
+// Delete provides a mock function with given fields: ctx, name
+func (_m *Histogram) Delete(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, name
+func (_m *Histogram) Get(ctx context.Context, name string) (*models.HistogramValue, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *models.HistogramValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.HistogramValue, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.HistogramValue); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.HistogramValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAll provides a mock function with given fields: ctx
+func (_m *Histogram) GetAll(ctx context.Context) (map[string]*models.HistogramValue, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]*models.HistogramValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (map[string]*models.HistogramValue, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]*models.HistogramValue); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*models.HistogramValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, name, v
+func (_m *Histogram) Update(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	ret := _m.Called(ctx, name, v)
+
+	var r0 *models.HistogramValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.HistogramValue) (*models.HistogramValue, error)); ok {
+		return rf(ctx, name, v)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.HistogramValue) *models.HistogramValue); ok {
+		r0 = rf(ctx, name, v)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.HistogramValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, *models.HistogramValue) error); ok {
+		r1 = rf(ctx, name, v)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewHistogram interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewHistogram creates a new instance of Histogram. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewHistogram(t mockConstructorTestingTNewHistogram) *Histogram {
+	mock := &Histogram{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,87 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "github.com/vindosVP/metrics/internal/models"
+
+	time "time"
+)
+
+// History is an autogenerated mock type for the History type
+type History struct {
+	mock.Mock
+}
+
+// Append provides a mock function with given fields: ctx, mType, name, s
+func (_m *History) Append(ctx context.Context, mType string, name string, s *models.Sample) error {
+	ret := _m.Called(ctx, mType, name, s)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *models.Sample) error); ok {
+		r0 = rf(ctx, mType, name, s)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Delete provides a mock function with given fields: ctx, mType, name
+func (_m *History) Delete(ctx context.Context, mType string, name string) error {
+	ret := _m.Called(ctx, mType, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, mType, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, mType, name, from, to
+func (_m *History) Get(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	ret := _m.Called(ctx, mType, name, from, to)
+
+	var r0 []*models.Sample
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time) ([]*models.Sample, error)); ok {
+		return rf(ctx, mType, name, from, to)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time) []*models.Sample); ok {
+		r0 = rf(ctx, mType, name, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Sample)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, mType, name, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewHistory interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewHistory creates a new instance of History. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewHistory(t mockConstructorTestingTNewHistory) *History {
+	mock := &History{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,97 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "github.com/vindosVP/metrics/internal/models"
+)
+
+// Metadata is an autogenerated mock type for the Metadata type
+type Metadata struct {
+	mock.Mock
+}
+
+// Get provides a mock function with given fields: ctx, name
+func (_m *Metadata) Get(ctx context.Context, name string) (*models.Metadata, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *models.Metadata
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.Metadata, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Metadata); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Metadata)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAll provides a mock function with given fields: ctx
+func (_m *Metadata) GetAll(ctx context.Context) (map[string]*models.Metadata, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]*models.Metadata
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (map[string]*models.Metadata, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]*models.Metadata); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*models.Metadata)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Set provides a mock function with given fields: ctx, meta
+func (_m *Metadata) Set(ctx context.Context, meta *models.Metadata) error {
+	ret := _m.Called(ctx, meta)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Metadata) error); ok {
+		r0 = rf(ctx, meta)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewMetadata interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewMetadata creates a new instance of Metadata. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewMetadata(t mockConstructorTestingTNewMetadata) *Metadata {
+	mock := &Metadata{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
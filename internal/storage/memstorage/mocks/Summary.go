@@ -0,0 +1,123 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "github.com/vindosVP/metrics/internal/models"
+)
+
+// Summary is an autogenerated mock type for the Summary type
+type Summary struct {
+	mock.Mock
+}
+
+// Delete provides a mock function with given fields: ctx, name
+func (_m *Summary) Delete(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, name
+func (_m *Summary) Get(ctx context.Context, name string) (*models.SummaryValue, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *models.SummaryValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.SummaryValue, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.SummaryValue); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.SummaryValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAll provides a mock function with given fields: ctx
+func (_m *Summary) GetAll(ctx context.Context) (map[string]*models.SummaryValue, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]*models.SummaryValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (map[string]*models.SummaryValue, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]*models.SummaryValue); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*models.SummaryValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, name, v
+func (_m *Summary) Update(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	ret := _m.Called(ctx, name, v)
+
+	var r0 *models.SummaryValue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.SummaryValue) (*models.SummaryValue, error)); ok {
+		return rf(ctx, name, v)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.SummaryValue) *models.SummaryValue); ok {
+		r0 = rf(ctx, name, v)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.SummaryValue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, *models.SummaryValue) error); ok {
+		r1 = rf(ctx, name, v)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewSummary interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewSummary creates a new instance of Summary. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewSummary(t mockConstructorTestingTNewSummary) *Summary {
+	mock := &Summary{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
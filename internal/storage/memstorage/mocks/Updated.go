@@ -0,0 +1,109 @@
+// Code generated by mockery v2.28.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// Updated is an autogenerated mock type for the Updated type
+type Updated struct {
+	mock.Mock
+}
+
+// Delete provides a mock function with given fields: ctx, mType, name
+func (_m *Updated) Delete(ctx context.Context, mType string, name string) error {
+	ret := _m.Called(ctx, mType, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, mType, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, mType, name
+func (_m *Updated) Get(ctx context.Context, mType string, name string) (time.Time, error) {
+	ret := _m.Called(ctx, mType, name)
+
+	var r0 time.Time
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (time.Time, error)); ok {
+		return rf(ctx, mType, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) time.Time); ok {
+		r0 = rf(ctx, mType, name)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, mType, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAll provides a mock function with given fields: ctx, mType
+func (_m *Updated) GetAll(ctx context.Context, mType string) (map[string]time.Time, error) {
+	ret := _m.Called(ctx, mType)
+
+	var r0 map[string]time.Time
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (map[string]time.Time, error)); ok {
+		return rf(ctx, mType)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) map[string]time.Time); ok {
+		r0 = rf(ctx, mType)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]time.Time)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, mType)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Set provides a mock function with given fields: ctx, mType, name, t
+func (_m *Updated) Set(ctx context.Context, mType string, name string, t time.Time) error {
+	ret := _m.Called(ctx, mType, name, t)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) error); ok {
+		r0 = rf(ctx, mType, name, t)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewUpdated interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewUpdated creates a new instance of Updated. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewUpdated(t mockConstructorTestingTNewUpdated) *Updated {
+	mock := &Updated{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -4,6 +4,7 @@ package memstorage
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/vindosVP/metrics/internal/models"
 	"github.com/vindosVP/metrics/internal/repos"
@@ -18,6 +19,7 @@ type Counter interface {
 	Get(ctx context.Context, name string) (int64, error)
 	GetAll(ctx context.Context) (map[string]int64, error)
 	Set(ctx context.Context, name string, v int64) (int64, error)
+	Delete(ctx context.Context, name string) error
 }
 
 // Gauge consists methods to work with gauge metrics repository.
@@ -27,35 +29,156 @@ type Gauge interface {
 	Update(ctx context.Context, name string, v float64) (float64, error)
 	Get(ctx context.Context, name string) (float64, error)
 	GetAll(ctx context.Context) (map[string]float64, error)
+	Delete(ctx context.Context, name string) error
 }
 
-// Storage consists counter repository, gauge repository.
+// Histogram consists methods to work with histogram metrics repository.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Histogram
+type Histogram interface {
+	Update(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	Get(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAll(ctx context.Context) (map[string]*models.HistogramValue, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Summary consists methods to work with summary metrics repository.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Summary
+type Summary interface {
+	Update(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	Get(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAll(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Updated consists methods to work with repository of metrics last update time.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Updated
+type Updated interface {
+	Set(ctx context.Context, mType string, name string, t time.Time) error
+	Get(ctx context.Context, mType string, name string) (time.Time, error)
+	GetAll(ctx context.Context, mType string) (map[string]time.Time, error)
+	Delete(ctx context.Context, mType string, name string) error
+}
+
+// Metadata consists methods to work with metrics metadata repository.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Metadata
+type Metadata interface {
+	Set(ctx context.Context, meta *models.Metadata) error
+	Get(ctx context.Context, name string) (*models.Metadata, error)
+	GetAll(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// History consists methods to work with metrics history repository.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=History
+type History interface {
+	Append(ctx context.Context, mType string, name string, s *models.Sample) error
+	Get(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	Delete(ctx context.Context, mType string, name string) error
+}
+
+// Storage consists counter repository, gauge repository, histogram repository,
+// summary repository, last update time repository, metadata repository and optional history repository.
 type Storage struct {
-	gRepo Gauge
-	cRepo Counter
+	gRepo  Gauge
+	cRepo  Counter
+	hgRepo Histogram
+	sRepo  Summary
+	uRepo  Updated
+	mRepo  Metadata
+	hRepo  History
 }
 
 // New creates Storage.
-func New(gRepo Gauge, cRepo Counter) *Storage {
-	return &Storage{
-		gRepo: gRepo,
-		cRepo: cRepo,
+// Histograms, summaries, last update times and metadata are kept in new repos.HistogramRepo, repos.SummaryRepo,
+// repos.UpdatedRepo and repos.MetadataRepo unless WithHistograms, WithSummaries, WithUpdated
+// and WithMetadata options are provided.
+func New(gRepo Gauge, cRepo Counter, opts ...func(*Storage)) *Storage {
+	s := &Storage{
+		gRepo:  gRepo,
+		cRepo:  cRepo,
+		hgRepo: repos.NewHistogramRepo(),
+		sRepo:  repos.NewSummaryRepo(),
+		uRepo:  repos.NewUpdatedRepo(),
+		mRepo:  repos.NewMetadataRepo(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
+}
+
+// WithHistograms makes the Storage keep histograms in the provided repository.
+func WithHistograms(hgRepo Histogram) func(*Storage) {
+	return func(s *Storage) {
+		s.hgRepo = hgRepo
+	}
+}
+
+// WithSummaries makes the Storage keep summaries in the provided repository.
+func WithSummaries(sRepo Summary) func(*Storage) {
+	return func(s *Storage) {
+		s.sRepo = sRepo
+	}
+}
+
+// WithUpdated makes the Storage keep time of the last metrics update in the provided repository.
+func WithUpdated(uRepo Updated) func(*Storage) {
+	return func(s *Storage) {
+		s.uRepo = uRepo
+	}
+}
+
+// WithMetadata makes the Storage keep metrics metadata in the provided repository.
+func WithMetadata(mRepo Metadata) func(*Storage) {
+	return func(s *Storage) {
+		s.mRepo = mRepo
+	}
+}
+
+// WithHistory makes the Storage record every update to the history repository.
+func WithHistory(hRepo History) func(*Storage) {
+	return func(s *Storage) {
+		s.hRepo = hRepo
+	}
+}
+
+// WithoutHistory method returns the storage sharing repositories with s that doesn't record history,
+// so values restored from a dump aren't recorded as new samples.
+func (s *Storage) WithoutHistory() *Storage {
+	c := *s
+	c.hRepo = nil
+	return &c
 }
 
 // InsertBatch method saves provided metrics values to the storage.
+// The batch is checked before it is applied, so a failing batch leaves the storage unchanged.
 func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	if err := s.CheckBatch(ctx, batch); err != nil {
+		return err
+	}
 	for _, metric := range batch {
 		switch metric.MType {
 		case models.Counter:
-			val := *metric.Delta
-			_, err := s.cRepo.Update(ctx, metric.ID, val)
+			_, err := s.UpdateCounter(ctx, metric.Key(), *metric.Delta)
 			if err != nil {
 				return err
 			}
 		case models.Gauge:
-			val := *metric.Value
-			_, err := s.gRepo.Update(ctx, metric.ID, val)
+			_, err := s.UpdateGauge(ctx, metric.Key(), *metric.Value)
+			if err != nil {
+				return err
+			}
+		case models.Histogram:
+			_, err := s.UpdateHistogram(ctx, metric.Key(), metric.Histogram)
+			if err != nil {
+				return err
+			}
+		case models.Summary:
+			_, err := s.UpdateSummary(ctx, metric.Key(), metric.SummarySketch())
 			if err != nil {
 				return err
 			}
@@ -64,14 +187,51 @@ func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) erro
 	return nil
 }
 
+// CheckBatch method makes sure histograms and summaries of the batch can be merged with each other and stored values.
+func (s *Storage) CheckBatch(ctx context.Context, batch []*models.Metrics) error {
+	return storage.CheckBatch(ctx, s, batch)
+}
+
 // UpdateGauge method updates gauge metric value.
 func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
-	return s.gRepo.Update(ctx, name, v)
+	val, err := s.gRepo.Update(ctx, name, v)
+	if err != nil {
+		return 0, err
+	}
+	if err = s.touch(ctx, models.Gauge, name); err != nil {
+		return 0, err
+	}
+	return val, s.appendHistory(ctx, models.Gauge, name, &models.Sample{Value: &val})
 }
 
 // UpdateCounter method updates counter metric value.
 func (s *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
-	return s.cRepo.Update(ctx, name, v)
+	val, err := s.cRepo.Update(ctx, name, v)
+	if err != nil {
+		return 0, err
+	}
+	if err = s.touch(ctx, models.Counter, name); err != nil {
+		return 0, err
+	}
+	return val, s.appendHistory(ctx, models.Counter, name, &models.Sample{Delta: &val})
+}
+
+// UpdateHistogram method merges provided observations into the histogram metric.
+func (s *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	val, err := s.hgRepo.Update(ctx, name, v)
+	if err != nil {
+		return nil, err
+	}
+	return val, s.touch(ctx, models.Histogram, name)
+}
+
+// UpdateSummary method merges provided sketch into the summary metric.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	val, err := s.sRepo.Update(ctx, name, v)
+	if err != nil {
+		return nil, err
+	}
+	return val, s.touch(ctx, models.Summary, name)
 }
 
 // GetGauge method returns gauge metric value.
@@ -98,6 +258,40 @@ func (s *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
 	return val, nil
 }
 
+// GetHistogram method returns histogram metric value.
+func (s *Storage) GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error) {
+	val, err := s.hgRepo.Get(ctx, name)
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// GetAllHistogram method returns values of all collected histogram metrics.
+func (s *Storage) GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error) {
+	return s.hgRepo.GetAll(ctx)
+}
+
+// GetSummary method returns summary metric value.
+func (s *Storage) GetSummary(ctx context.Context, name string) (*models.SummaryValue, error) {
+	val, err := s.sRepo.Get(ctx, name)
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// GetAllSummary method returns values of all collected summary metrics.
+func (s *Storage) GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error) {
+	return s.sRepo.GetAll(ctx)
+}
+
 // GetAllGauge method returns values of all collected gauge metrics.
 func (s *Storage) GetAllGauge(ctx context.Context) (map[string]float64, error) {
 	return s.gRepo.GetAll(ctx)
@@ -110,5 +304,121 @@ func (s *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
 
 // SetCounter method sets counter metric value.
 func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
-	return s.cRepo.Set(ctx, name, v)
+	val, err := s.cRepo.Set(ctx, name, v)
+	if err != nil {
+		return 0, err
+	}
+	if err = s.touch(ctx, models.Counter, name); err != nil {
+		return 0, err
+	}
+	return val, s.appendHistory(ctx, models.Counter, name, &models.Sample{Delta: &val})
+}
+
+// GetHistory method returns metric samples collected between from and to.
+func (s *Storage) GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	if s.hRepo == nil {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	samples, err := s.hRepo.Get(ctx, mType, name, from, to)
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// Delete method removes the metric and its history.
+func (s *Storage) Delete(ctx context.Context, mType string, name string) error {
+	var err error
+	switch mType {
+	case models.Counter:
+		err = s.cRepo.Delete(ctx, name)
+	case models.Gauge:
+		err = s.gRepo.Delete(ctx, name)
+	case models.Histogram:
+		err = s.hgRepo.Delete(ctx, name)
+	case models.Summary:
+		err = s.sRepo.Delete(ctx, name)
+	default:
+		err = repos.ErrMetricNotRegistered
+	}
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return err
+	}
+	if err = s.uRepo.Delete(ctx, mType, name); err != nil {
+		return err
+	}
+	if s.hRepo == nil {
+		return nil
+	}
+	return s.hRepo.Delete(ctx, mType, name)
+}
+
+// DeleteBatch method removes all provided metrics, metrics which are not registered are skipped.
+func (s *Storage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	for _, metric := range batch {
+		err := s.Delete(ctx, metric.MType, metric.Key())
+		if err != nil && !errors.Is(err, storage.ErrMetricNotRegistered) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUpdated method returns time of the last update of all metrics with provided type.
+func (s *Storage) GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	return s.uRepo.GetAll(ctx, mType)
+}
+
+// GetUpdatedAt method returns time of the last update of the metric with provided type.
+func (s *Storage) GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error) {
+	t, err := s.uRepo.Get(ctx, mType, name)
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return time.Time{}, storage.ErrMetricNotRegistered
+	}
+	return t, err
+}
+
+// SetUpdated method sets time of the last metric update, it is used to restore metrics from the dump.
+func (s *Storage) SetUpdated(ctx context.Context, mType string, name string, t time.Time) error {
+	return s.uRepo.Set(ctx, mType, name, t)
+}
+
+// RegisterMetadata method saves metadata of the metric.
+func (s *Storage) RegisterMetadata(ctx context.Context, meta *models.Metadata) error {
+	return s.mRepo.Set(ctx, meta)
+}
+
+// GetMetadata method returns metadata of the metric.
+func (s *Storage) GetMetadata(ctx context.Context, name string) (*models.Metadata, error) {
+	meta, err := s.mRepo.Get(ctx, name)
+	if errors.Is(err, repos.ErrMetricNotRegistered) {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// GetAllMetadata method returns metadata of all registered metrics.
+func (s *Storage) GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error) {
+	return s.mRepo.GetAll(ctx)
+}
+
+func (s *Storage) touch(ctx context.Context, mType string, name string) error {
+	return s.uRepo.Set(ctx, mType, name, time.Now())
+}
+
+func (s *Storage) appendHistory(ctx context.Context, mType string, name string, sample *models.Sample) error {
+	if s.hRepo == nil {
+		return nil
+	}
+	sample.Timestamp = time.Now()
+	return s.hRepo.Append(ctx, mType, name, sample)
 }
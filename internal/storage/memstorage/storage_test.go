@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
 	"github.com/vindosVP/metrics/internal/storage/memstorage/mocks"
 )
 
@@ -374,3 +378,166 @@ func TestStorage_GetAllCounter(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_GetHistory(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+	val := 1.5
+	tests := []struct {
+		mockErr     error
+		errValue    error
+		mockSamples []*models.Sample
+		name        string
+		withHistory bool
+		wantErr     bool
+	}{
+		{
+			name:        "ok",
+			withHistory: true,
+			mockSamples: []*models.Sample{{Timestamp: time.Now(), Value: &val}},
+			wantErr:     false,
+		},
+		{
+			name:        "not registered",
+			withHistory: true,
+			mockErr:     repos.ErrMetricNotRegistered,
+			wantErr:     true,
+			errValue:    storage.ErrMetricNotRegistered,
+		},
+		{
+			name:        "unexpected error",
+			withHistory: true,
+			mockErr:     unexpectedError,
+			wantErr:     true,
+			errValue:    unexpectedError,
+		},
+		{
+			name:        "history disabled",
+			withHistory: false,
+			wantErr:     true,
+			errValue:    storage.ErrMetricNotRegistered,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCounter := mocks.NewCounter(t)
+			mockGauge := mocks.NewGauge(t)
+			opts := make([]func(*Storage), 0)
+			if tt.withHistory {
+				mockHistory := mocks.NewHistory(t)
+				mockHistory.On("Get", mock.Anything, models.Gauge, "Alloc", mock.Anything, mock.Anything).Return(tt.mockSamples, tt.mockErr)
+				opts = append(opts, WithHistory(mockHistory))
+			}
+			s := New(mockGauge, mockCounter, opts...)
+
+			got, err := s.GetHistory(context.Background(), models.Gauge, "Alloc", time.Time{}, time.Now())
+			if tt.wantErr {
+				assert.ErrorIs(t, err, tt.errValue)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.mockSamples, got)
+			}
+		})
+	}
+}
+
+func TestStorage_UpdateGaugeHistory(t *testing.T) {
+	mockCounter := mocks.NewCounter(t)
+	mockGauge := mocks.NewGauge(t)
+	mockHistory := mocks.NewHistory(t)
+	s := New(mockGauge, mockCounter, WithHistory(mockHistory))
+
+	mockGauge.On("Update", mock.Anything, "Alloc", 1.5).Return(1.5, nil)
+	mockHistory.On("Append", mock.Anything, models.Gauge, "Alloc", mock.MatchedBy(func(s *models.Sample) bool {
+		return s.Value != nil && *s.Value == 1.5 && !s.Timestamp.IsZero()
+	})).Return(nil)
+
+	val, err := s.UpdateGauge(context.Background(), "Alloc", 1.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, val)
+}
+
+func TestStorage_WithoutHistory(t *testing.T) {
+	ctx := context.Background()
+	s := New(repos.NewGaugeRepo(), repos.NewCounterRepo(), WithHistory(repos.NewHistoryRepo(time.Hour)))
+
+	_, err := s.WithoutHistory().UpdateGauge(ctx, "Alloc", 1.5)
+	assert.NoError(t, err)
+
+	val, err := s.GetGauge(ctx, "Alloc")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, val)
+	_, err = s.GetHistory(ctx, models.Gauge, "Alloc", time.Time{}, time.Now())
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+}
+
+func TestStorage_Delete(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+	tests := []struct {
+		mockErr    error
+		errValue   error
+		name       string
+		metricName string
+		wantErr    bool
+	}{
+		{
+			name:       "ok",
+			mockErr:    nil,
+			metricName: "Alloc",
+			wantErr:    false,
+			errValue:   nil},
+		{
+			name:       "not registered",
+			mockErr:    repos.ErrMetricNotRegistered,
+			metricName: "Alloc",
+			wantErr:    true,
+			errValue:   storage.ErrMetricNotRegistered},
+		{
+			name:       "error",
+			mockErr:    unexpectedError,
+			metricName: "Alloc",
+			wantErr:    true,
+			errValue:   unexpectedError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCounter := mocks.NewCounter(t)
+			mockGauge := mocks.NewGauge(t)
+			mockHistory := mocks.NewHistory(t)
+			s := New(mockGauge, mockCounter, WithHistory(mockHistory))
+			ctx := context.Background()
+			mockGauge.On("Delete", mock.Anything, tt.metricName).Return(tt.mockErr)
+			if !tt.wantErr {
+				mockHistory.On("Delete", mock.Anything, models.Gauge, tt.metricName).Return(nil)
+			}
+			err := s.Delete(ctx, models.Gauge, tt.metricName)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, tt.errValue)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStorage_DeleteBatch(t *testing.T) {
+	ctx := context.Background()
+	s := New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	_, err := s.UpdateGauge(ctx, `Alloc{host="a"}`, 1)
+	assert.NoError(t, err)
+	_, err = s.UpdateCounter(ctx, "PollCount", 1)
+	assert.NoError(t, err)
+
+	err = s.DeleteBatch(ctx, []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Labels: map[string]string{"host": "a"}},
+		{ID: "Unknown", MType: models.Counter},
+	})
+	assert.NoError(t, err)
+
+	_, err = s.GetGauge(ctx, `Alloc{host="a"}`)
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	_, err = s.GetCounter(ctx, "PollCount")
+	assert.NoError(t, err)
+}
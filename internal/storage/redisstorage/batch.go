@@ -0,0 +1,67 @@
+package redisstorage
+
+import (
+	"sort"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// aggregatedBatch - metrics of a batch with duplicates merged:
+// counter deltas are summed, the last gauge value wins, histograms and summaries are merged.
+type aggregatedBatch struct {
+	counters   map[string]int64
+	gauges     map[string]float64
+	histograms map[string]*models.HistogramValue
+	summaries  map[string]*models.SummaryValue
+}
+
+func aggregate(batch []*models.Metrics) (*aggregatedBatch, error) {
+	a := &aggregatedBatch{
+		counters:   make(map[string]int64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string]*models.HistogramValue),
+		summaries:  make(map[string]*models.SummaryValue),
+	}
+	for _, metric := range batch {
+		key := metric.Key()
+		switch metric.MType {
+		case models.Counter:
+			a.counters[key] += *metric.Delta
+		case models.Gauge:
+			a.gauges[key] = *metric.Value
+		case models.Histogram:
+			current, ok := a.histograms[key]
+			if !ok {
+				a.histograms[key] = metric.Histogram.Copy()
+				continue
+			}
+			if err := current.Merge(metric.Histogram); err != nil {
+				return nil, err
+			}
+		case models.Summary:
+			sketch := metric.SummarySketch()
+			if sketch == nil {
+				continue
+			}
+			current, ok := a.summaries[key]
+			if !ok {
+				a.summaries[key] = sketch.Copy()
+				continue
+			}
+			if err := current.Merge(sketch); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return a, nil
+}
+
+// sortedIDs returns sorted ids of the metrics.
+func sortedIDs[V any](metrics map[string]V) []string {
+	ids := make([]string, 0, len(metrics))
+	for id := range metrics {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,557 @@
+// Package redisstorage is a metrics storage working with redis, so several servers can share the metrics.
+//
+// Every metric type is kept in a hash keyed by series: counters are added with HINCRBY, gauges are set with HSET,
+// histograms and summaries are stored as json and merged by the application under WATCH of version keys of their series,
+// which are incremented on every write, so writers of different series don't retry.
+// Update times are kept in hashes per type and history in a sorted set per series scored by the sample time.
+package redisstorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+)
+
+// maxWatchRetries - number of attempts to merge a histogram or a summary changed concurrently by another writer.
+const maxWatchRetries = 50
+
+// metricTypes - types of metrics kept in the storage.
+var metricTypes = map[string]bool{
+	models.Counter:   true,
+	models.Gauge:     true,
+	models.Histogram: true,
+	models.Summary:   true,
+}
+
+// ErrConflict - represents that the metric kept being changed by other writers while it was merged.
+var ErrConflict = errors.New("metric is changed concurrently, try again")
+
+// Storage consists of the redis client and the prefix of all keys of the storage.
+type Storage struct {
+	db     redis.UniversalClient
+	prefix string
+}
+
+// New creates the Storage keeping metrics under keys starting with the prefix.
+func New(client redis.UniversalClient, prefix string) *Storage {
+	return &Storage{
+		db:     client,
+		prefix: prefix,
+	}
+}
+
+// key returns the key of the storage with the parts joined by colons.
+func (s *Storage) key(parts ...string) string {
+	return s.prefix + ":" + strings.Join(parts, ":")
+}
+
+// values returns the key of the hash with values of the metric type.
+func (s *Storage) values(mType string) string {
+	return s.key(mType)
+}
+
+// updated returns the key of the hash with update times of the metric type.
+func (s *Storage) updated(mType string) string {
+	return s.key("updated", mType)
+}
+
+// history returns the key of the sorted set with samples of the series.
+func (s *Storage) history(mType string, name string) string {
+	return s.key("history", mType, name)
+}
+
+// version returns the key incremented on every write of the histogram or the summary series, writers merging it watch the key.
+func (s *Storage) version(mType string, name string) string {
+	return s.key("version", mType, name)
+}
+
+// sample is a member of the history sorted set,
+// the time is kept in the member as well, so equal values written at different times are different members.
+type sample struct {
+	Delta     *int64   `json:"d,omitempty"`
+	Value     *float64 `json:"v,omitempty"`
+	Timestamp int64    `json:"t"`
+}
+
+func (s *Storage) addSample(ctx context.Context, p redis.Pipeliner, mType string, name string, now time.Time, smp *sample) {
+	smp.Timestamp = now.UnixNano()
+	data, _ := json.Marshal(smp)
+	p.ZAdd(ctx, s.history(mType, name), redis.Z{Score: float64(smp.Timestamp), Member: data})
+}
+
+func (s *Storage) touch(ctx context.Context, p redis.Pipeliner, mType string, name string, now time.Time) {
+	p.HSet(ctx, s.updated(mType), name, now.UnixNano())
+}
+
+// UpdateGauge method updates gauge metric value.
+// new value replaces the old one.
+func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
+	now := time.Now()
+	_, err := s.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, s.values(models.Gauge), name, v)
+		s.touch(ctx, p, models.Gauge, name, now)
+		s.addSample(ctx, p, models.Gauge, name, now, &sample{Value: &v})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+// UpdateCounter method updates counter metric value.
+// new value adds to the old one, the new total is returned.
+func (s *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
+	now := time.Now()
+	var total *redis.IntCmd
+	_, err := s.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		total = p.HIncrBy(ctx, s.values(models.Counter), name, v)
+		s.touch(ctx, p, models.Counter, name, now)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total.Val(), s.addCounterSamples(ctx, now, map[string]int64{name: total.Val()})
+}
+
+// addCounterSamples appends totals of counters to their history,
+// totals are known only after the increment, so they are written by a separate round trip.
+func (s *Storage) addCounterSamples(ctx context.Context, now time.Time, totals map[string]int64) error {
+	if len(totals) == 0 {
+		return nil
+	}
+	_, err := s.db.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for name, total := range totals {
+			total := total
+			s.addSample(ctx, p, models.Counter, name, now, &sample{Delta: &total})
+		}
+		return nil
+	})
+	return err
+}
+
+// SetCounter method sets counter metric value.
+// new value replaces the old one.
+func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
+	now := time.Now()
+	_, err := s.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, s.values(models.Counter), name, v)
+		s.touch(ctx, p, models.Counter, name, now)
+		s.addSample(ctx, p, models.Counter, name, now, &sample{Delta: &v})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+// GetGauge method returns value of gauge metric
+func (s *Storage) GetGauge(ctx context.Context, name string) (float64, error) {
+	v, err := s.db.HGet(ctx, s.values(models.Gauge), name).Float64()
+	if errors.Is(err, redis.Nil) {
+		return 0, storage.ErrMetricNotRegistered
+	}
+	return v, err
+}
+
+// GetCounter method returns value of counter metric
+func (s *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
+	v, err := s.db.HGet(ctx, s.values(models.Counter), name).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, storage.ErrMetricNotRegistered
+	}
+	return v, err
+}
+
+// GetAllGauge method returns values of all collected gauge metrics
+func (s *Storage) GetAllGauge(ctx context.Context) (map[string]float64, error) {
+	all, err := s.db.HGetAll(ctx, s.values(models.Gauge)).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]float64, len(all))
+	for name, v := range all {
+		if res[name], err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("invalid value of gauge %s: %w", name, err)
+		}
+	}
+	return res, nil
+}
+
+// GetAllCounter method returns values of all collected counter metrics
+func (s *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
+	all, err := s.db.HGetAll(ctx, s.values(models.Counter)).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]int64, len(all))
+	for name, v := range all {
+		if res[name], err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid value of counter %s: %w", name, err)
+		}
+	}
+	return res, nil
+}
+
+// InsertBatch method saves provided metrics values in one pipelined transaction.
+// Histograms and summaries of the batch are merged with the stored ones first, so the batch is not written if any of them mismatches.
+func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	agg, err := aggregate(batch)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	totals := make(map[string]*redis.IntCmd, len(agg.counters))
+	write := func(p redis.Pipeliner, histograms map[string]*models.HistogramValue, summaries map[string]*models.SummaryValue) {
+		for name, delta := range agg.counters {
+			totals[name] = p.HIncrBy(ctx, s.values(models.Counter), name, delta)
+			s.touch(ctx, p, models.Counter, name, now)
+		}
+		for name, v := range agg.gauges {
+			v := v
+			p.HSet(ctx, s.values(models.Gauge), name, v)
+			s.touch(ctx, p, models.Gauge, name, now)
+			s.addSample(ctx, p, models.Gauge, name, now, &sample{Value: &v})
+		}
+		setJSON(ctx, s, p, models.Histogram, histograms, now)
+		setJSON(ctx, s, p, models.Summary, summaries, now)
+	}
+
+	if len(agg.histograms) == 0 && len(agg.summaries) == 0 {
+		// nothing is merged, so nothing is watched
+		_, err = s.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			write(p, nil, nil)
+			return nil
+		})
+	} else {
+		keys := make([]string, 0, len(agg.histograms)+len(agg.summaries))
+		for _, name := range sortedIDs(agg.histograms) {
+			keys = append(keys, s.version(models.Histogram, name))
+		}
+		for _, name := range sortedIDs(agg.summaries) {
+			keys = append(keys, s.version(models.Summary, name))
+		}
+		err = s.watch(ctx, func(tx *redis.Tx) error {
+			histograms, err := mergeStored(ctx, tx, s.values(models.Histogram), agg.histograms)
+			if err != nil {
+				return err
+			}
+			summaries, err := mergeStored(ctx, tx, s.values(models.Summary), agg.summaries)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				write(p, histograms, summaries)
+				return nil
+			})
+			return err
+		}, keys...)
+	}
+	if err != nil {
+		return err
+	}
+	values := make(map[string]int64, len(totals))
+	for name, total := range totals {
+		values[name] = total.Val()
+	}
+	return s.addCounterSamples(ctx, now, values)
+}
+
+// watch runs f in an optimistic transaction watching the keys, f is retried if any of them is changed concurrently.
+func (s *Storage) watch(ctx context.Context, f func(tx *redis.Tx) error, keys ...string) error {
+	for i := 0; i < maxWatchRetries; i++ {
+		err := s.db.Watch(ctx, f, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return ErrConflict
+}
+
+// mergeable is a metric value merged with the stored one on update.
+type mergeable[T any] interface {
+	*T
+	Merge(o *T) error
+	Copy() *T
+}
+
+// mergeStored returns values merged with the ones stored in the hash.
+func mergeStored[T any, PT mergeable[T]](ctx context.Context, tx *redis.Tx, hash string, values map[string]PT) (map[string]PT, error) {
+	if len(values) == 0 {
+		return values, nil
+	}
+	names := sortedIDs(values)
+	stored, err := tx.HMGet(ctx, hash, names...).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]PT, len(values))
+	for i, name := range names {
+		data, ok := stored[i].(string)
+		if !ok {
+			res[name] = values[name].Copy()
+			continue
+		}
+		current := PT(new(T))
+		if err = json.Unmarshal([]byte(data), current); err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", name, err)
+		}
+		if err = current.Merge(values[name]); err != nil {
+			return nil, err
+		}
+		res[name] = current
+	}
+	return res, nil
+}
+
+func setJSON[V any](ctx context.Context, s *Storage, p redis.Pipeliner, mType string, values map[string]V, now time.Time) {
+	for name, v := range values {
+		data, _ := json.Marshal(v)
+		p.HSet(ctx, s.values(mType), name, data)
+		p.Incr(ctx, s.version(mType, name))
+		s.touch(ctx, p, mType, name, now)
+	}
+}
+
+// GetHistory method returns metric samples collected between from and to.
+func (s *Storage) GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	key := s.history(mType, name)
+	n, err := s.db.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	members, err := s.db.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.UnixNano(), 10),
+		Max: strconv.FormatInt(to.UnixNano(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*models.Sample, 0, len(members))
+	for _, member := range members {
+		smp := &sample{}
+		if err = json.Unmarshal([]byte(member), smp); err != nil {
+			return nil, fmt.Errorf("invalid sample of %s: %w", name, err)
+		}
+		res = append(res, &models.Sample{Timestamp: time.Unix(0, smp.Timestamp), Delta: smp.Delta, Value: smp.Value})
+	}
+	return res, nil
+}
+
+// UpdateHistogram method merges provided observations into the histogram metric.
+func (s *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	return update(ctx, s, models.Histogram, name, v)
+}
+
+// UpdateSummary method merges provided sketch into the summary metric.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	return update(ctx, s, models.Summary, name, v)
+}
+
+func update[T any, PT mergeable[T]](ctx context.Context, s *Storage, mType string, name string, v PT) (PT, error) {
+	now := time.Now()
+	var res PT
+	err := s.watch(ctx, func(tx *redis.Tx) error {
+		merged, err := mergeStored(ctx, tx, s.values(mType), map[string]PT{name: v})
+		if err != nil {
+			return err
+		}
+		res = merged[name]
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			setJSON(ctx, s, p, mType, merged, now)
+			return nil
+		})
+		return err
+	}, s.version(mType, name))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetHistogram method returns value of histogram metric
+func (s *Storage) GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error) {
+	value := &models.HistogramValue{}
+	if err := s.getJSON(ctx, models.Histogram, name, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetAllHistogram method returns values of all collected histogram metrics
+func (s *Storage) GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error) {
+	return getAllJSON[models.HistogramValue](ctx, s, models.Histogram)
+}
+
+// GetSummary method returns value of summary metric
+func (s *Storage) GetSummary(ctx context.Context, name string) (*models.SummaryValue, error) {
+	value := &models.SummaryValue{}
+	if err := s.getJSON(ctx, models.Summary, name, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetAllSummary method returns values of all collected summary metrics
+func (s *Storage) GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error) {
+	return getAllJSON[models.SummaryValue](ctx, s, models.Summary)
+}
+
+func (s *Storage) getJSON(ctx context.Context, mType string, name string, v any) error {
+	data, err := s.db.HGet(ctx, s.values(mType), name).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func getAllJSON[T any](ctx context.Context, s *Storage, mType string) (map[string]*T, error) {
+	all, err := s.db.HGetAll(ctx, s.values(mType)).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*T, len(all))
+	for name, data := range all {
+		value := new(T)
+		if err = json.Unmarshal([]byte(data), value); err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", name, err)
+		}
+		res[name] = value
+	}
+	return res, nil
+}
+
+// Delete method removes the metric and its history.
+func (s *Storage) Delete(ctx context.Context, mType string, name string) error {
+	if !metricTypes[mType] {
+		return storage.ErrMetricNotRegistered
+	}
+	var deleted *redis.IntCmd
+	_, err := s.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		deleted = p.HDel(ctx, s.values(mType), name)
+		p.HDel(ctx, s.updated(mType), name)
+		p.Del(ctx, s.history(mType, name), s.version(mType, name))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if deleted.Val() == 0 {
+		return storage.ErrMetricNotRegistered
+	}
+	return nil
+}
+
+// DeleteBatch method removes all provided metrics, metrics which are not registered are skipped.
+func (s *Storage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	_, err := s.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for _, metric := range batch {
+			if !metricTypes[metric.MType] {
+				continue
+			}
+			key := metric.Key()
+			p.HDel(ctx, s.values(metric.MType), key)
+			p.HDel(ctx, s.updated(metric.MType), key)
+			p.Del(ctx, s.history(metric.MType, key), s.version(metric.MType, key))
+		}
+		return nil
+	})
+	return err
+}
+
+// GetUpdatedAt method returns time of the last update of the metric with provided type.
+func (s *Storage) GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error) {
+	ts, err := s.db.HGet(ctx, s.updated(mType), name).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ts), nil
+}
+
+// GetUpdated method returns time of the last update of all metrics with provided type.
+func (s *Storage) GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	all, err := s.db.HGetAll(ctx, s.updated(mType)).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]time.Time, len(all))
+	for name, v := range all {
+		ts, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid update time of %s: %w", name, err)
+		}
+		res[name] = time.Unix(0, ts)
+	}
+	return res, nil
+}
+
+// RegisterMetadata method saves metadata of the metric, previously registered metadata is replaced.
+func (s *Storage) RegisterMetadata(ctx context.Context, meta *models.Metadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return s.db.HSet(ctx, s.key("metadata"), meta.ID, data).Err()
+}
+
+// GetMetadata method returns metadata of the metric.
+func (s *Storage) GetMetadata(ctx context.Context, name string) (*models.Metadata, error) {
+	data, err := s.db.HGet(ctx, s.key("metadata"), name).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	meta := &models.Metadata{}
+	return meta, json.Unmarshal(data, meta)
+}
+
+// GetAllMetadata method returns metadata of all registered metrics.
+func (s *Storage) GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error) {
+	all, err := s.db.HGetAll(ctx, s.key("metadata")).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*models.Metadata, len(all))
+	for name, data := range all {
+		meta := &models.Metadata{}
+		if err = json.Unmarshal([]byte(data), meta); err != nil {
+			return nil, fmt.Errorf("invalid metadata of %s: %w", name, err)
+		}
+		res[name] = meta
+	}
+	return res, nil
+}
+
+// TrimHistory method deletes samples collected before the provided time.
+func (s *Storage) TrimHistory(ctx context.Context, before time.Time) error {
+	iter := s.db.Scan(ctx, 0, s.key("history", "*"), 0).Iterator()
+	max := "(" + strconv.FormatInt(before.UnixNano(), 10)
+	for iter.Next(ctx) {
+		if err := s.db.ZRemRangeByScore(ctx, iter.Val(), "-inf", max).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
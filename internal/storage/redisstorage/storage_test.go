@@ -0,0 +1,134 @@
+package redisstorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/storagetest"
+)
+
+func newClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+	return mr, client
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.MetricsStorage {
+		_, client := newClient(t)
+		return New(client, "metrics")
+	})
+}
+
+func TestStorage_Shared(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newClient(t)
+	replica := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer replica.Close()
+	s := New(client, "metrics:default")
+	other := New(replica, "metrics:default")
+	tenant := New(client, "metrics:team-a")
+
+	_, err := s.UpdateCounter(ctx, "PollCount", 2)
+	require.NoError(t, err)
+	total, err := other.UpdateCounter(ctx, "PollCount", 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total, "replicas must share counters")
+	assert.Equal(t, "5", mr.HGet("metrics:default:counter", "PollCount"))
+
+	_, err = tenant.GetCounter(ctx, "PollCount")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered, "prefixes must not share metrics")
+}
+
+func TestStorage_InsertBatch(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newClient(t)
+	s := New(client, "metrics")
+	delta := int64(1)
+	value := 1.5
+	batch := []*models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: &delta},
+		{ID: "PollCount", MType: models.Counter, Delta: &delta},
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+	}
+	require.NoError(t, s.InsertBatch(ctx, batch))
+	assert.Equal(t, "2", mr.HGet("metrics:counter", "PollCount"))
+	assert.Equal(t, "1.5", mr.HGet("metrics:gauge", "Alloc"))
+
+	require.NoError(t, s.TrimHistory(ctx, time.Now().Add(time.Second)))
+	_, err := s.GetHistory(ctx, models.Gauge, "Alloc", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+}
+
+func TestStorage_WatchSeries(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newClient(t)
+	writer := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer writer.Close()
+	s := New(client, "metrics")
+	other := New(writer, "metrics")
+	h := func() *models.HistogramValue {
+		return &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1, Sum: 0.5}
+	}
+	delta := int64(1)
+
+	// transactions merging the series are aborted by writes of the series only
+	tests := []struct {
+		write   func() error
+		name    string
+		aborted bool
+	}{
+		{
+			name: "other histogram",
+			write: func() error {
+				_, err := other.UpdateHistogram(ctx, "Other", h())
+				return err
+			},
+		},
+		{
+			name: "counter batch",
+			write: func() error {
+				return other.InsertBatch(ctx, []*models.Metrics{{ID: "PollCount", MType: models.Counter, Delta: &delta}})
+			},
+		},
+		{
+			name: "same histogram",
+			write: func() error {
+				_, err := other.UpdateHistogram(ctx, "Latency", h())
+				return err
+			},
+			aborted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.Watch(ctx, func(tx *redis.Tx) error {
+				require.NoError(t, tt.write())
+				_, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+					p.Incr(ctx, s.version(models.Histogram, "Latency"))
+					return nil
+				})
+				return err
+			}, s.version(models.Histogram, "Latency"))
+			if tt.aborted {
+				assert.ErrorIs(t, err, redis.TxFailedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	require.NoError(t, s.Delete(ctx, models.Histogram, "Latency"))
+	assert.False(t, mr.Exists(s.version(models.Histogram, "Latency")))
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// FindSeries returns the key and the value of the only series with provided name satisfying all matchers.
+// The series identified by the name and equality matchers is looked up directly,
+// otherwise all series are fetched and filtered.
+func FindSeries[V any](
+	ctx context.Context,
+	name string,
+	matchers []*models.LabelMatcher,
+	get func(ctx context.Context, key string) (V, error),
+	getAll func(ctx context.Context) (map[string]V, error),
+) (string, V, error) {
+	var zero V
+	if labels, ok := equalLabels(matchers); ok {
+		key := models.SeriesKey(name, labels)
+		v, err := get(ctx, key)
+		if err == nil {
+			return key, v, nil
+		}
+		if !errors.Is(err, ErrMetricNotRegistered) {
+			return "", zero, err
+		}
+	}
+
+	all, err := getAll(ctx)
+	if err != nil {
+		return "", zero, err
+	}
+	selected := models.SelectSeries(all, name, matchers)
+	if len(selected) == 0 {
+		return "", zero, ErrMetricNotRegistered
+	}
+	if len(selected) > 1 {
+		return "", zero, ErrAmbiguousSeries
+	}
+	for key, v := range selected {
+		return key, v, nil
+	}
+	return "", zero, ErrMetricNotRegistered
+}
+
+func equalLabels(matchers []*models.LabelMatcher) (map[string]string, bool) {
+	labels := make(map[string]string, len(matchers))
+	for _, m := range matchers {
+		if m.Type != models.MatchEqual {
+			return nil, false
+		}
+		labels[m.Name] = m.Value
+	}
+	return labels, true
+}
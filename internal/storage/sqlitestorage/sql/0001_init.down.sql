@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS metadata;
+DROP TABLE IF EXISTS summaries;
+DROP TABLE IF EXISTS histograms;
+DROP INDEX IF EXISTS history_type_id_ts_idx;
+DROP TABLE IF EXISTS history;
+DROP TABLE IF EXISTS counters;
+DROP TABLE IF EXISTS gauges;
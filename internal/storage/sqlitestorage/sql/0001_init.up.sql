@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS gauges (id TEXT NOT NULL PRIMARY KEY, value REAL NOT NULL, updated_at INTEGER NOT NULL);
+CREATE TABLE IF NOT EXISTS counters (id TEXT NOT NULL PRIMARY KEY, value INTEGER NOT NULL, updated_at INTEGER NOT NULL);
+CREATE TABLE IF NOT EXISTS history (type TEXT NOT NULL, id TEXT NOT NULL, ts INTEGER NOT NULL, delta INTEGER, value REAL);
+CREATE INDEX IF NOT EXISTS history_type_id_ts_idx ON history (type, id, ts);
+CREATE TABLE IF NOT EXISTS histograms (id TEXT NOT NULL PRIMARY KEY, data TEXT NOT NULL, updated_at INTEGER NOT NULL);
+CREATE TABLE IF NOT EXISTS summaries (id TEXT NOT NULL PRIMARY KEY, data TEXT NOT NULL, updated_at INTEGER NOT NULL);
+CREATE TABLE IF NOT EXISTS metadata (id TEXT NOT NULL PRIMARY KEY, type TEXT NOT NULL, unit TEXT NOT NULL, help TEXT NOT NULL);
@@ -0,0 +1,591 @@
+// Package sqlitestorage is a metrics storage working with an embedded sqlite database file.
+// It uses the cgo-free sqlite driver and mirrors the queries of dbstorage: gauges are upserted,
+// counters are upserted adding the delta to the stored value, and every write appends the resulting value to the history.
+// sqlite can't modify rows in common table expressions, so the history is appended by a separate statement of the same transaction.
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	// registers the cgo-free sqlite driver
+	_ "modernc.org/sqlite"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/dbstorage/migrations"
+)
+
+// Timestamps are stored as unix nanoseconds, every write query returns the resulting value for the history.
+const (
+	updateGaugeQuery = `insert into gauges (id, value, updated_at) values ($1, $2, $3)
+		on conflict (id) do update set value = excluded.value, updated_at = excluded.updated_at returning value`
+	updateCounterQuery = `insert into counters as t (id, value, updated_at) values ($1, $2, $3)
+		on conflict (id) do update set value = t.value + excluded.value, updated_at = excluded.updated_at returning value`
+	setCounterQuery = `insert into counters (id, value, updated_at) values ($1, $2, $3)
+		on conflict (id) do update set value = excluded.value, updated_at = excluded.updated_at returning value`
+	insertGaugeSampleQuery   = "insert into history (type, id, ts, value) values ('gauge', $1, $2, $3)"
+	insertCounterSampleQuery = "insert into history (type, id, ts, delta) values ('counter', $1, $2, $3)"
+)
+
+// Histograms and summaries are stored as json and merged by the application in the write transaction.
+const (
+	selectHistogramQuery = "select data from histograms where id = $1"
+	setHistogramQuery    = `insert into histograms (id, data, updated_at) values ($1, $2, $3)
+		on conflict (id) do update set data = excluded.data, updated_at = excluded.updated_at`
+	selectSummaryQuery = "select data from summaries where id = $1"
+	setSummaryQuery    = `insert into summaries (id, data, updated_at) values ($1, $2, $3)
+		on conflict (id) do update set data = excluded.data, updated_at = excluded.updated_at`
+)
+
+// metricTables maps metric types to the tables storing their values.
+var metricTables = map[string]string{
+	models.Counter:   "counters",
+	models.Gauge:     "gauges",
+	models.Histogram: "histograms",
+	models.Summary:   "summaries",
+}
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// Storage consists of the sqlite database handle.
+type Storage struct {
+	db *sql.DB
+}
+
+// querier is implemented by both the database handle and transactions.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// New opens the sqlite database file, creating it if needed, and applies pending migrations.
+// Transactions take the write lock on begin and wait for other writers instead of failing,
+// the write-ahead journal lets reads run alongside them.
+func New(ctx context.Context, fileName string) (*Storage, error) {
+	dsn := "file:" + fileName + "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	s := &Storage{db: db}
+	if err = s.migrate(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close method closes the database.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies migrations newer than the user_version of the database.
+func (s *Storage) migrate(ctx context.Context) error {
+	all, err := migrations.Load(sqlFiles)
+	if err != nil {
+		return err
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var version int
+		if err := tx.QueryRowContext(ctx, "pragma user_version").Scan(&version); err != nil {
+			return fmt.Errorf("failed to get schema version: %w", err)
+		}
+		for _, m := range all {
+			if m.Version <= version {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("pragma user_version = %d", m.Version)); err != nil {
+				return fmt.Errorf("failed to set schema version: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertBatch method saves provided metrics values to the database in one transaction.
+func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		for _, metric := range batch {
+			key := metric.Key()
+			var err error
+			switch metric.MType {
+			case models.Gauge:
+				_, err = updateGauge(ctx, tx, key, *metric.Value, now)
+			case models.Counter:
+				_, err = updateCounter(ctx, tx, updateCounterQuery, key, *metric.Delta, now)
+			case models.Histogram:
+				_, err = updateHistogram(ctx, tx, key, metric.Histogram, now)
+			case models.Summary:
+				if sketch := metric.SummarySketch(); sketch != nil {
+					_, err = updateSummary(ctx, tx, key, sketch, now)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateGauge method updates gauge metric value.
+// new value replaces the old one.
+func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
+	var res float64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		res, err = updateGauge(ctx, tx, name, v, time.Now())
+		return err
+	})
+	return res, err
+}
+
+// UpdateCounter method updates counter metric value.
+// new value adds to the old one, the new total is returned.
+func (s *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
+	var res int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		res, err = updateCounter(ctx, tx, updateCounterQuery, name, v, time.Now())
+		return err
+	})
+	return res, err
+}
+
+// SetCounter method sets counter metric value.
+// new value replaces the old one.
+func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
+	var res int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		res, err = updateCounter(ctx, tx, setCounterQuery, name, v, time.Now())
+		return err
+	})
+	return res, err
+}
+
+func updateGauge(ctx context.Context, q querier, name string, v float64, now time.Time) (float64, error) {
+	var value float64
+	if err := q.QueryRowContext(ctx, updateGaugeQuery, name, v, now.UnixNano()).Scan(&value); err != nil {
+		return 0, err
+	}
+	_, err := q.ExecContext(ctx, insertGaugeSampleQuery, name, now.UnixNano(), value)
+	return value, err
+}
+
+func updateCounter(ctx context.Context, q querier, query string, name string, v int64, now time.Time) (int64, error) {
+	var total int64
+	if err := q.QueryRowContext(ctx, query, name, v, now.UnixNano()).Scan(&total); err != nil {
+		return 0, err
+	}
+	_, err := q.ExecContext(ctx, insertCounterSampleQuery, name, now.UnixNano(), total)
+	return total, err
+}
+
+// GetGauge method returns value of gauge metric
+func (s *Storage) GetGauge(ctx context.Context, name string) (float64, error) {
+	var value float64
+	err := s.db.QueryRowContext(ctx, "select value from gauges where id = $1", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// GetCounter method returns value of counter metric
+func (s *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
+	var value int64
+	err := s.db.QueryRowContext(ctx, "select value from counters where id = $1", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// GetAllGauge method returns values of all collected gauge metrics
+func (s *Storage) GetAllGauge(ctx context.Context) (map[string]float64, error) {
+	res := make(map[string]float64)
+	err := s.scanAll(ctx, "select id, value from gauges order by id", func(rows *sql.Rows) error {
+		var id string
+		var value float64
+		if err := rows.Scan(&id, &value); err != nil {
+			return err
+		}
+		res[id] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetAllCounter method returns values of all collected counter metrics
+func (s *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
+	res := make(map[string]int64)
+	err := s.scanAll(ctx, "select id, value from counters order by id", func(rows *sql.Rows) error {
+		var id string
+		var value int64
+		if err := rows.Scan(&id, &value); err != nil {
+			return err
+		}
+		res[id] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// scanAll runs the query calling scan for every row.
+func (s *Storage) scanAll(ctx context.Context, query string, scan func(rows *sql.Rows) error, args ...any) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetHistory method returns metric samples collected between from and to.
+func (s *Storage) GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	var exists bool
+	row := s.db.QueryRowContext(ctx, "select exists(select 1 from history where type = $1 and id = $2)", mType, name)
+	if err := row.Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.ErrMetricNotRegistered
+	}
+
+	res := make([]*models.Sample, 0)
+	query := "select ts, delta, value from history where type = $1 and id = $2 and ts >= $3 and ts <= $4 order by ts"
+	err := s.scanAll(ctx, query, func(rows *sql.Rows) error {
+		var ts int64
+		var delta sql.NullInt64
+		var value sql.NullFloat64
+		if err := rows.Scan(&ts, &delta, &value); err != nil {
+			return err
+		}
+		sample := &models.Sample{Timestamp: time.Unix(0, ts)}
+		if delta.Valid {
+			sample.Delta = &delta.Int64
+		}
+		if value.Valid {
+			sample.Value = &value.Float64
+		}
+		res = append(res, sample)
+		return nil
+	}, mType, name, from.UnixNano(), to.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateHistogram method merges provided observations into the histogram metric.
+func (s *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	var res *models.HistogramValue
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		res, err = updateHistogram(ctx, tx, name, v, time.Now())
+		return err
+	})
+	return res, err
+}
+
+func updateHistogram(ctx context.Context, q querier, name string, v *models.HistogramValue, now time.Time) (*models.HistogramValue, error) {
+	current := &models.HistogramValue{}
+	err := scanJSON(q.QueryRowContext(ctx, selectHistogramQuery, name), current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		current = v.Copy()
+	case err != nil:
+		return nil, err
+	default:
+		if err = current.Merge(v); err != nil {
+			return nil, err
+		}
+	}
+	if err = setJSON(ctx, q, setHistogramQuery, name, current, now); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// GetHistogram method returns value of histogram metric
+func (s *Storage) GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error) {
+	value := &models.HistogramValue{}
+	err := scanJSON(s.db.QueryRowContext(ctx, selectHistogramQuery, name), value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetAllHistogram method returns values of all collected histogram metrics
+func (s *Storage) GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error) {
+	res := make(map[string]*models.HistogramValue)
+	err := s.scanAll(ctx, "select id, data from histograms order by id", func(rows *sql.Rows) error {
+		var id string
+		value := &models.HistogramValue{}
+		if err := scanJSONRow(rows, &id, value); err != nil {
+			return err
+		}
+		res[id] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateSummary method merges provided sketch into the summary metric.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	var res *models.SummaryValue
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		res, err = updateSummary(ctx, tx, name, v, time.Now())
+		return err
+	})
+	return res, err
+}
+
+func updateSummary(ctx context.Context, q querier, name string, v *models.SummaryValue, now time.Time) (*models.SummaryValue, error) {
+	current := &models.SummaryValue{}
+	err := scanJSON(q.QueryRowContext(ctx, selectSummaryQuery, name), current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		current = v.Copy()
+	case err != nil:
+		return nil, err
+	default:
+		if err = current.Merge(v); err != nil {
+			return nil, err
+		}
+	}
+	if err = setJSON(ctx, q, setSummaryQuery, name, current, now); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// GetSummary method returns value of summary metric
+func (s *Storage) GetSummary(ctx context.Context, name string) (*models.SummaryValue, error) {
+	value := &models.SummaryValue{}
+	err := scanJSON(s.db.QueryRowContext(ctx, selectSummaryQuery, name), value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetAllSummary method returns values of all collected summary metrics
+func (s *Storage) GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error) {
+	res := make(map[string]*models.SummaryValue)
+	err := s.scanAll(ctx, "select id, data from summaries order by id", func(rows *sql.Rows) error {
+		var id string
+		value := &models.SummaryValue{}
+		if err := scanJSONRow(rows, &id, value); err != nil {
+			return err
+		}
+		res[id] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func scanJSON(row *sql.Row, v any) error {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func scanJSONRow(rows *sql.Rows, id *string, v any) error {
+	var data []byte
+	if err := rows.Scan(id, &data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func setJSON(ctx context.Context, q querier, query string, name string, v any, now time.Time) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, query, name, string(data), now.UnixNano())
+	return err
+}
+
+// Delete method removes the metric and its history.
+func (s *Storage) Delete(ctx context.Context, mType string, name string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return deleteMetric(ctx, tx, mType, name)
+	})
+}
+
+// DeleteBatch method removes all provided metrics, metrics which are not registered are skipped.
+func (s *Storage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, metric := range batch {
+			err := deleteMetric(ctx, tx, metric.MType, metric.Key())
+			if err != nil && !errors.Is(err, storage.ErrMetricNotRegistered) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func deleteMetric(ctx context.Context, tx *sql.Tx, mType string, name string) error {
+	table, ok := metricTables[mType]
+	if !ok {
+		return storage.ErrMetricNotRegistered
+	}
+	res, err := tx.ExecContext(ctx, fmt.Sprintf("delete from %s where id = $1", table), name)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrMetricNotRegistered
+	}
+	_, err = tx.ExecContext(ctx, "delete from history where type = $1 and id = $2", mType, name)
+	return err
+}
+
+// GetUpdatedAt method returns time of the last update of the metric with provided type.
+func (s *Storage) GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error) {
+	table, ok := metricTables[mType]
+	if !ok {
+		return time.Time{}, storage.ErrMetricNotRegistered
+	}
+	var updated int64
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("select updated_at from %s where id = $1", table), name).Scan(&updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, updated), nil
+}
+
+// GetUpdated method returns time of the last update of all metrics with provided type.
+func (s *Storage) GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	res := make(map[string]time.Time)
+	table, ok := metricTables[mType]
+	if !ok {
+		return res, nil
+	}
+	err := s.scanAll(ctx, fmt.Sprintf("select id, updated_at from %s", table), func(rows *sql.Rows) error {
+		var id string
+		var updated int64
+		if err := rows.Scan(&id, &updated); err != nil {
+			return err
+		}
+		res[id] = time.Unix(0, updated)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RegisterMetadata method saves metadata of the metric, previously registered metadata is replaced.
+func (s *Storage) RegisterMetadata(ctx context.Context, meta *models.Metadata) error {
+	query := `insert into metadata (id, type, unit, help) values ($1, $2, $3, $4)
+		on conflict (id) do update set type = excluded.type, unit = excluded.unit, help = excluded.help`
+	_, err := s.db.ExecContext(ctx, query, meta.ID, meta.MType, meta.Unit, meta.Help)
+	return err
+}
+
+// GetMetadata method returns metadata of the metric.
+func (s *Storage) GetMetadata(ctx context.Context, name string) (*models.Metadata, error) {
+	meta := &models.Metadata{}
+	query := "select id, type, unit, help from metadata where id = $1"
+	err := s.db.QueryRowContext(ctx, query, name).Scan(&meta.ID, &meta.MType, &meta.Unit, &meta.Help)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrMetricNotRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// GetAllMetadata method returns metadata of all registered metrics.
+func (s *Storage) GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error) {
+	res := make(map[string]*models.Metadata)
+	err := s.scanAll(ctx, "select id, type, unit, help from metadata", func(rows *sql.Rows) error {
+		meta := &models.Metadata{}
+		if err := rows.Scan(&meta.ID, &meta.MType, &meta.Unit, &meta.Help); err != nil {
+			return err
+		}
+		res[meta.ID] = meta
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TrimHistory method deletes samples collected before the provided time.
+func (s *Storage) TrimHistory(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, "delete from history where ts < $1", before.UnixNano())
+	return err
+}
@@ -0,0 +1,68 @@
+package sqlitestorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/storagetest"
+)
+
+func newStorage(t *testing.T, fileName string) *Storage {
+	s, err := New(context.Background(), fileName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, s.Close())
+	})
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.MetricsStorage {
+		return newStorage(t, filepath.Join(t.TempDir(), "metrics.db"))
+	})
+}
+
+func TestStorage_Reopen(t *testing.T) {
+	ctx := context.Background()
+	fileName := filepath.Join(t.TempDir(), "metrics.db")
+	s, err := New(ctx, fileName)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Alloc", 1.5)
+	require.NoError(t, err)
+	_, err = s.UpdateCounter(ctx, "PollCount", 3)
+	require.NoError(t, err)
+	_, err = s.UpdateHistogram(ctx, "Latency", &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1, Sum: 0.5})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s = newStorage(t, fileName)
+	g, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, g)
+	c, err := s.UpdateCounter(ctx, "PollCount", 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), c)
+	h, err := s.GetHistogram(ctx, "Latency")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 0}, h.Counts)
+}
+
+func TestStorage_TrimHistory(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage(t, filepath.Join(t.TempDir(), "metrics.db"))
+	_, err := s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	require.NoError(t, s.TrimHistory(ctx, time.Now().Add(time.Second)))
+	_, err = s.GetHistory(ctx, models.Gauge, "Alloc", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	g, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), g)
+}
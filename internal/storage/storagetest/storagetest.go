@@ -0,0 +1,438 @@
+// Package storagetest is a conformance test suite for metrics storages,
+// every storage implementation is expected to pass it.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+)
+
+// MetricsStorage consists methods covered by the suite.
+type MetricsStorage interface {
+	UpdateGauge(ctx context.Context, name string, v float64) (float64, error)
+	UpdateCounter(ctx context.Context, name string, v int64) (int64, error)
+	SetCounter(ctx context.Context, name string, v int64) (int64, error)
+	GetGauge(ctx context.Context, name string) (float64, error)
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetCounter(ctx context.Context, name string) (int64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// Run runs the suite, newStorage must return an empty storage on every call.
+func Run(t *testing.T, newStorage func(t *testing.T) MetricsStorage) {
+	tests := []struct {
+		test func(t *testing.T, s MetricsStorage)
+		name string
+	}{
+		{name: "gauge", test: testGauge},
+		{name: "counter", test: testCounter},
+		{name: "histogram", test: testHistogram},
+		{name: "summary", test: testSummary},
+		{name: "not registered", test: testNotRegistered},
+		{name: "empty", test: testEmpty},
+		{name: "batch", test: testBatch},
+		{name: "batch atomicity", test: testBatchAtomicity},
+		{name: "history", test: testHistory},
+		{name: "delete", test: testDelete},
+		{name: "metadata", test: testMetadata},
+		{name: "concurrent counter updates", test: testConcurrentCounter},
+		{name: "concurrent batches", test: testConcurrentBatches},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+func testGauge(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	v, err := s.UpdateGauge(ctx, "Alloc", 1.5)
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, v)
+	v, err = s.UpdateGauge(ctx, "Alloc", -3.25)
+	require.NoError(t, err)
+	assert.Equal(t, -3.25, v, "gauge update must return the new value")
+
+	v, err = s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, -3.25, v)
+	all, err := s.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Alloc": -3.25}, all)
+}
+
+func testCounter(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	v, err := s.UpdateCounter(ctx, "PollCount", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), v)
+	v, err = s.UpdateCounter(ctx, "PollCount", 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), v, "counter update must return the new total, not the delta")
+	v, err = s.UpdateCounter(ctx, "PollCount", -10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-2), v)
+
+	v, err = s.SetCounter(ctx, "PollCount", 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), v)
+	v, err = s.SetCounter(ctx, "Requests", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), v)
+
+	v, err = s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), v)
+	all, err := s.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 100, "Requests": 7}, all)
+}
+
+func testHistogram(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	h := &models.HistogramValue{Bounds: []float64{1, 5}, Counts: []uint64{1, 2, 0}, Count: 3, Sum: 6}
+	v, err := s.UpdateHistogram(ctx, "Latency", h)
+	require.NoError(t, err)
+	assert.Equal(t, h, v)
+
+	v, err = s.UpdateHistogram(ctx, "Latency", &models.HistogramValue{Bounds: []float64{1, 5}, Counts: []uint64{0, 1, 1}, Count: 2, Sum: 10})
+	require.NoError(t, err)
+	want := &models.HistogramValue{Bounds: []float64{1, 5}, Counts: []uint64{1, 3, 1}, Count: 5, Sum: 16}
+	assert.Equal(t, want, v, "histogram update must return the merged histogram")
+	assert.Equal(t, []uint64{1, 2, 0}, h.Counts, "provided histogram must not be modified")
+
+	_, err = s.UpdateHistogram(ctx, "Latency", &models.HistogramValue{Bounds: []float64{2}, Counts: []uint64{1, 0}, Count: 1, Sum: 1})
+	assert.ErrorIs(t, err, models.ErrBucketsMismatch)
+
+	v, err = s.GetHistogram(ctx, "Latency")
+	require.NoError(t, err)
+	assert.Equal(t, want, v)
+	all, err := s.GetAllHistogram(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*models.HistogramValue{"Latency": want}, all)
+}
+
+func testSummary(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	first := models.NewSummaryValue(models.DefaultSummaryAccuracy)
+	second := models.NewSummaryValue(models.DefaultSummaryAccuracy)
+	for i := 1; i <= 100; i++ {
+		first.Observe(float64(i))
+		second.Observe(float64(i + 100))
+	}
+	_, err := s.UpdateSummary(ctx, "Latency", first)
+	require.NoError(t, err)
+	v, err := s.UpdateSummary(ctx, "Latency", second)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(200), v.Count, "summary update must return the merged summary")
+	assert.Equal(t, float64(1), v.Min)
+	assert.Equal(t, float64(200), v.Max)
+
+	_, err = s.UpdateSummary(ctx, "Latency", models.NewSummaryValue(models.DefaultSummaryAccuracy/2))
+	assert.ErrorIs(t, err, models.ErrSketchMismatch)
+
+	got, err := s.GetSummary(ctx, "Latency")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(200), got.Count)
+	median, err := got.Quantile(0.5)
+	require.NoError(t, err)
+	assert.InEpsilon(t, 100, median, 2*models.DefaultSummaryAccuracy)
+	all, err := s.GetAllSummary(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+}
+
+func testNotRegistered(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	_, err := s.GetGauge(ctx, "Unknown")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	_, err = s.GetCounter(ctx, "Unknown")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	_, err = s.GetHistogram(ctx, "Unknown")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	_, err = s.GetSummary(ctx, "Unknown")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	_, err = s.GetMetadata(ctx, "Unknown")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	_, err = s.GetHistory(ctx, models.Gauge, "Unknown", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	assert.ErrorIs(t, s.Delete(ctx, models.Gauge, "Unknown"), storage.ErrMetricNotRegistered)
+	assert.ErrorIs(t, s.Delete(ctx, "unknown", "Unknown"), storage.ErrMetricNotRegistered)
+
+	// metrics of different types are independent
+	_, err = s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	_, err = s.GetCounter(ctx, "Alloc")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+}
+
+func testEmpty(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	gauges, err := s.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, gauges)
+	assert.Empty(t, gauges)
+	counters, err := s.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, counters)
+	assert.Empty(t, counters)
+	histograms, err := s.GetAllHistogram(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, histograms)
+	summaries, err := s.GetAllSummary(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, summaries)
+	metadata, err := s.GetAllMetadata(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, metadata)
+	require.NoError(t, s.InsertBatch(ctx, []*models.Metrics{}))
+}
+
+func testBatch(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	_, err := s.SetCounter(ctx, "PollCount", 10)
+	require.NoError(t, err)
+
+	batch := []*models.Metrics{
+		counter("PollCount", 1),
+		gauge("Alloc", 1.5),
+		counter("PollCount", 2),
+		gauge("Alloc", 2.5),
+		counter("Requests", 4),
+		histogram("Latency", 0.5),
+		histogram("Latency", 3),
+		{ID: "Requests", MType: models.Counter, Labels: map[string]string{"code": "200"}, Delta: ptr(int64(1))},
+	}
+	require.NoError(t, s.InsertBatch(ctx, batch))
+
+	counters, err := s.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 13, "Requests": 4, `Requests{code="200"}`: 1}, counters,
+		"counters of a batch must be summed")
+	gauges, err := s.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Alloc": 2.5}, gauges, "the last gauge of a batch must win")
+	h, err := s.GetHistogram(ctx, "Latency")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 1, 0}, h.Counts)
+	assert.Equal(t, uint64(2), h.Count)
+}
+
+func testBatchAtomicity(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	_, err := s.UpdateHistogram(ctx, "Latency", histogram("Latency", 0.5).Histogram)
+	require.NoError(t, err)
+
+	batch := []*models.Metrics{
+		counter("PollCount", 1),
+		gauge("Alloc", 1.5),
+		{ID: "Latency", MType: models.Histogram, Histogram: &models.HistogramValue{Bounds: []float64{2}, Counts: []uint64{1, 0}, Count: 1, Sum: 1}},
+		counter("Requests", 1),
+	}
+	assert.ErrorIs(t, s.InsertBatch(ctx, batch), models.ErrBucketsMismatch)
+
+	counters, err := s.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, counters, "failed batch must not be applied partially")
+	gauges, err := s.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, gauges, "failed batch must not be applied partially")
+	h, err := s.GetHistogram(ctx, "Latency")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), h.Count)
+}
+
+func testHistory(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	from := time.Now().Add(-time.Minute)
+	for _, v := range []float64{1, 2, 3} {
+		_, err := s.UpdateGauge(ctx, "Alloc", v)
+		require.NoError(t, err)
+	}
+	_, err := s.UpdateCounter(ctx, "PollCount", 2)
+	require.NoError(t, err)
+	_, err = s.UpdateCounter(ctx, "PollCount", 3)
+	require.NoError(t, err)
+	to := time.Now().Add(time.Minute)
+
+	samples, err := s.GetHistory(ctx, models.Gauge, "Alloc", from, to)
+	require.NoError(t, err)
+	require.Len(t, samples, 3)
+	for i, want := range []float64{1, 2, 3} {
+		require.NotNil(t, samples[i].Value)
+		assert.Equal(t, want, *samples[i].Value)
+		if i > 0 {
+			assert.False(t, samples[i].Timestamp.Before(samples[i-1].Timestamp), "samples must be ordered by time")
+		}
+	}
+
+	samples, err = s.GetHistory(ctx, models.Counter, "PollCount", from, to)
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	require.NotNil(t, samples[1].Delta)
+	assert.Equal(t, int64(5), *samples[1].Delta, "counter samples must hold the total")
+
+	samples, err = s.GetHistory(ctx, models.Gauge, "Alloc", to, to.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, samples)
+}
+
+func testDelete(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	_, err := s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Typo", 1)
+	require.NoError(t, err)
+	_, err = s.UpdateCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Delete(ctx, models.Gauge, "Typo"))
+	_, err = s.GetGauge(ctx, "Typo")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	_, err = s.GetHistory(ctx, models.Gauge, "Typo", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered, "history must be deleted with the metric")
+	assert.ErrorIs(t, s.Delete(ctx, models.Gauge, "Typo"), storage.ErrMetricNotRegistered)
+
+	batch := []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge},
+		{ID: "PollCount", MType: models.Counter},
+		{ID: "Unknown", MType: models.Counter},
+	}
+	require.NoError(t, s.DeleteBatch(ctx, batch), "unknown metrics must be skipped by DeleteBatch")
+	gauges, err := s.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, gauges)
+	counters, err := s.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, counters)
+}
+
+func testMetadata(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	meta := &models.Metadata{ID: "Alloc", MType: models.Gauge, Unit: models.UnitBytes, Help: "allocated heap"}
+	require.NoError(t, s.RegisterMetadata(ctx, meta))
+	got, err := s.GetMetadata(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, meta, got)
+
+	replaced := &models.Metadata{ID: "Alloc", MType: models.Gauge, Help: "heap"}
+	require.NoError(t, s.RegisterMetadata(ctx, replaced))
+	all, err := s.GetAllMetadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*models.Metadata{"Alloc": replaced}, all)
+}
+
+func testConcurrentCounter(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	const workers, updates = 8, 25
+
+	results := make(chan int64, workers*updates)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				v, err := s.UpdateCounter(ctx, "PollCount", 1)
+				assert.NoError(t, err)
+				results <- v
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	got := make([]int64, 0, workers*updates)
+	for v := range results {
+		got = append(got, v)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	want := make([]int64, 0, workers*updates)
+	for i := 1; i <= workers*updates; i++ {
+		want = append(want, int64(i))
+	}
+	assert.Equal(t, want, got, "every concurrent update must return a distinct total")
+
+	v, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(workers*updates), v)
+}
+
+func testConcurrentBatches(t *testing.T, s MetricsStorage) {
+	ctx := context.Background()
+	const workers, batches = 8, 10
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < batches; j++ {
+				// names are listed in different orders by different workers
+				batch := make([]*models.Metrics, 0, 10)
+				for k := 0; k < 10; k++ {
+					batch = append(batch, counter(fmt.Sprintf("Counter%d", (k+worker)%10), 1))
+				}
+				batch = append(batch, gauge(fmt.Sprintf("Gauge%d", worker), float64(j)))
+				assert.NoError(t, s.InsertBatch(ctx, batch))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	counters, err := s.GetAllCounter(ctx)
+	require.NoError(t, err)
+	require.Len(t, counters, 10)
+	for name, v := range counters {
+		assert.Equal(t, int64(workers*batches), v, name)
+	}
+	gauges, err := s.GetAllGauge(ctx)
+	require.NoError(t, err)
+	require.Len(t, gauges, workers)
+	for name, v := range gauges {
+		assert.Equal(t, float64(batches-1), v, name)
+	}
+}
+
+func counter(name string, delta int64) *models.Metrics {
+	return &models.Metrics{ID: name, MType: models.Counter, Delta: &delta}
+}
+
+func gauge(name string, value float64) *models.Metrics {
+	return &models.Metrics{ID: name, MType: models.Gauge, Value: &value}
+}
+
+// histogram returns the histogram metric with bounds 1 and 5 holding one observation.
+func histogram(name string, observation float64) *models.Metrics {
+	h := models.NewHistogramValue([]float64{1, 5})
+	h.Observe(observation)
+	return &models.Metrics{ID: name, MType: models.Histogram, Histogram: h}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,308 @@
+// Package tenantstorage is a metrics storage routing every call to the storage of the tenant of the context.
+package tenantstorage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+// MetricsStorage consists methods to save and get data from the storage of one tenant
+type MetricsStorage interface {
+	UpdateGauge(ctx context.Context, name string, v float64) (float64, error)
+	UpdateCounter(ctx context.Context, name string, v int64) (int64, error)
+	SetCounter(ctx context.Context, name string, v int64) (int64, error)
+	GetGauge(ctx context.Context, name string) (float64, error)
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetCounter(ctx context.Context, name string) (int64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// OpenFunc creates the storage of the tenant, restoring previously saved metrics.
+type OpenFunc func(id string) (MetricsStorage, error)
+
+// Storage consists storages of all tenants.
+// Storage of the tenant is opened on its first write,
+// reads of tenants without storage are served by an empty one, so they don't create storages.
+type Storage struct {
+	tenants map[string]MetricsStorage
+	open    OpenFunc
+	empty   MetricsStorage
+	limit   int
+	mu      sync.RWMutex
+}
+
+// New creates Storage.
+func New(open OpenFunc, opts ...func(*Storage)) *Storage {
+	s := &Storage{
+		tenants: make(map[string]MetricsStorage),
+		open:    open,
+		empty:   memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo()),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithLimit limits the number of tenants writes open storages for, 0 is unlimited.
+// Storages opened with Open are counted but never rejected.
+func WithLimit(limit int) func(*Storage) {
+	return func(s *Storage) {
+		s.limit = limit
+	}
+}
+
+// Open method opens the storage of the tenant if it is not opened yet.
+func (s *Storage) Open(id string) (MetricsStorage, error) {
+	return s.openTenant(id, false)
+}
+
+// openTenant opens the storage of the tenant if it is not opened yet,
+// failing with storage.ErrTenantLimit if the limit is checked and reached.
+func (s *Storage) openTenant(id string, limited bool) (MetricsStorage, error) {
+	s.mu.RLock()
+	st, ok := s.tenants[id]
+	s.mu.RUnlock()
+	if ok {
+		return st, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok = s.tenants[id]; ok {
+		return st, nil
+	}
+	if limited && s.limit > 0 && len(s.tenants) >= s.limit {
+		return nil, fmt.Errorf("failed to open storage of tenant %s: %w", id, storage.ErrTenantLimit)
+	}
+	st, err := s.open(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage of tenant %s: %w", id, err)
+	}
+	s.tenants[id] = st
+	return st, nil
+}
+
+// Tenants method returns sorted tenants with opened storages.
+func (s *Storage) Tenants() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.tenants))
+	for id := range s.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Stats method returns numbers of series stored for every tenant.
+func (s *Storage) Stats(ctx context.Context) ([]*models.TenantStats, error) {
+	ids := s.Tenants()
+	stats := make([]*models.TenantStats, 0, len(ids))
+	for _, id := range ids {
+		series, err := s.series(tenant.WithTenant(ctx, id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to count series of tenant %s: %w", id, err)
+		}
+		stats = append(stats, &models.TenantStats{Tenant: id, Series: series})
+	}
+	return stats, nil
+}
+
+func (s *Storage) series(ctx context.Context) (int, error) {
+	st := s.get(ctx)
+	counters, err := st.GetAllCounter(ctx)
+	if err != nil {
+		return 0, err
+	}
+	gauges, err := st.GetAllGauge(ctx)
+	if err != nil {
+		return 0, err
+	}
+	histograms, err := st.GetAllHistogram(ctx)
+	if err != nil {
+		return 0, err
+	}
+	summaries, err := st.GetAllSummary(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(counters) + len(gauges) + len(histograms) + len(summaries), nil
+}
+
+// get returns the storage of the tenant of the context to read from.
+func (s *Storage) get(ctx context.Context) MetricsStorage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if st, ok := s.tenants[tenant.FromContext(ctx)]; ok {
+		return st
+	}
+	return s.empty
+}
+
+// write returns the storage of the tenant of the context to write to, opening it if needed.
+func (s *Storage) write(ctx context.Context) (MetricsStorage, error) {
+	return s.openTenant(tenant.FromContext(ctx), true)
+}
+
+// UpdateGauge method updates gauge metric value of the tenant.
+func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
+	st, err := s.write(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return st.UpdateGauge(ctx, name, v)
+}
+
+// UpdateCounter method updates counter metric value of the tenant.
+func (s *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
+	st, err := s.write(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return st.UpdateCounter(ctx, name, v)
+}
+
+// SetCounter method sets counter metric value of the tenant.
+func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
+	st, err := s.write(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return st.SetCounter(ctx, name, v)
+}
+
+// InsertBatch method saves provided metrics values of the tenant.
+func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	st, err := s.write(ctx)
+	if err != nil {
+		return err
+	}
+	return st.InsertBatch(ctx, batch)
+}
+
+// UpdateHistogram method merges provided observations into the histogram metric of the tenant.
+func (s *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	st, err := s.write(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return st.UpdateHistogram(ctx, name, v)
+}
+
+// UpdateSummary method merges provided sketch into the summary metric of the tenant.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	st, err := s.write(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return st.UpdateSummary(ctx, name, v)
+}
+
+// RegisterMetadata method saves metadata of the metric of the tenant.
+func (s *Storage) RegisterMetadata(ctx context.Context, meta *models.Metadata) error {
+	st, err := s.write(ctx)
+	if err != nil {
+		return err
+	}
+	return st.RegisterMetadata(ctx, meta)
+}
+
+// GetGauge method returns gauge metric value of the tenant.
+func (s *Storage) GetGauge(ctx context.Context, name string) (float64, error) {
+	return s.get(ctx).GetGauge(ctx, name)
+}
+
+// GetAllGauge method returns values of all gauge metrics of the tenant.
+func (s *Storage) GetAllGauge(ctx context.Context) (map[string]float64, error) {
+	return s.get(ctx).GetAllGauge(ctx)
+}
+
+// GetCounter method returns counter metric value of the tenant.
+func (s *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
+	return s.get(ctx).GetCounter(ctx, name)
+}
+
+// GetAllCounter method returns values of all counter metrics of the tenant.
+func (s *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
+	return s.get(ctx).GetAllCounter(ctx)
+}
+
+// GetHistory method returns metric samples of the tenant collected between from and to.
+func (s *Storage) GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error) {
+	return s.get(ctx).GetHistory(ctx, mType, name, from, to)
+}
+
+// GetHistogram method returns histogram metric value of the tenant.
+func (s *Storage) GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error) {
+	return s.get(ctx).GetHistogram(ctx, name)
+}
+
+// GetAllHistogram method returns values of all histogram metrics of the tenant.
+func (s *Storage) GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error) {
+	return s.get(ctx).GetAllHistogram(ctx)
+}
+
+// GetSummary method returns summary metric value of the tenant.
+func (s *Storage) GetSummary(ctx context.Context, name string) (*models.SummaryValue, error) {
+	return s.get(ctx).GetSummary(ctx, name)
+}
+
+// GetAllSummary method returns values of all summary metrics of the tenant.
+func (s *Storage) GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error) {
+	return s.get(ctx).GetAllSummary(ctx)
+}
+
+// Delete method removes the metric of the tenant.
+func (s *Storage) Delete(ctx context.Context, mType string, name string) error {
+	return s.get(ctx).Delete(ctx, mType, name)
+}
+
+// DeleteBatch method removes all provided metrics of the tenant.
+func (s *Storage) DeleteBatch(ctx context.Context, batch []*models.Metrics) error {
+	return s.get(ctx).DeleteBatch(ctx, batch)
+}
+
+// GetUpdated method returns time of the last update of all metrics of the tenant with provided type.
+func (s *Storage) GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	return s.get(ctx).GetUpdated(ctx, mType)
+}
+
+// GetUpdatedAt method returns update time of the metric of the tenant.
+func (s *Storage) GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error) {
+	return s.get(ctx).GetUpdatedAt(ctx, mType, name)
+}
+
+// GetMetadata method returns metadata of the metric of the tenant.
+func (s *Storage) GetMetadata(ctx context.Context, name string) (*models.Metadata, error) {
+	return s.get(ctx).GetMetadata(ctx, name)
+}
+
+// GetAllMetadata method returns metadata of all metrics of the tenant.
+func (s *Storage) GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error) {
+	return s.get(ctx).GetAllMetadata(ctx)
+}
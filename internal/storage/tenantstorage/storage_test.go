@@ -0,0 +1,124 @@
+package tenantstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/storage/storagetest"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+func newMemStorage(string) (MetricsStorage, error) {
+	return memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo()), nil
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.MetricsStorage {
+		return New(func(string) (MetricsStorage, error) {
+			return memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo(),
+				memstorage.WithHistory(repos.NewHistoryRepo(0))), nil
+		})
+	})
+}
+
+func TestStorage_Isolation(t *testing.T) {
+	s := New(newMemStorage)
+	teamA := tenant.WithTenant(context.Background(), "team-a")
+	teamB := tenant.WithTenant(context.Background(), "team-b")
+
+	_, err := s.UpdateGauge(teamA, "Alloc", 1.5)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(teamB, "Alloc", 3)
+	require.NoError(t, err)
+	_, err = s.UpdateCounter(teamB, "PollCount", 1)
+	require.NoError(t, err)
+
+	v, err := s.GetGauge(teamA, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, v)
+	_, err = s.GetCounter(teamA, "PollCount")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	_, err = s.GetGauge(context.Background(), "Alloc")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+
+	require.NoError(t, s.Delete(teamA, models.Gauge, "Alloc"))
+	v, err = s.GetGauge(teamB, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), v)
+}
+
+func TestStorage_ReadsDontOpen(t *testing.T) {
+	opened := make([]string, 0)
+	s := New(func(id string) (MetricsStorage, error) {
+		opened = append(opened, id)
+		return newMemStorage(id)
+	})
+	ctx := tenant.WithTenant(context.Background(), "team-a")
+
+	gauges, err := s.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, gauges)
+	assert.ErrorIs(t, s.Delete(ctx, models.Gauge, "Alloc"), storage.ErrMetricNotRegistered)
+	assert.Empty(t, opened)
+	assert.Empty(t, s.Tenants())
+
+	_, err = s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Alloc", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-a"}, opened)
+	assert.Equal(t, []string{"team-a"}, s.Tenants())
+}
+
+func TestStorage_OpenError(t *testing.T) {
+	s := New(func(string) (MetricsStorage, error) {
+		return nil, errors.New("disk is full")
+	})
+	_, err := s.UpdateGauge(context.Background(), "Alloc", 1)
+	assert.Error(t, err)
+	assert.Empty(t, s.Tenants())
+}
+
+func TestStorage_Limit(t *testing.T) {
+	s := New(newMemStorage, WithLimit(2))
+	_, err := s.Open(tenant.Default)
+	require.NoError(t, err)
+
+	_, err = s.UpdateGauge(tenant.WithTenant(context.Background(), "team-a"), "Alloc", 1)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(tenant.WithTenant(context.Background(), "team-b"), "Alloc", 1)
+	assert.ErrorIs(t, err, storage.ErrTenantLimit)
+	// opened tenants keep accepting writes
+	_, err = s.UpdateGauge(tenant.WithTenant(context.Background(), "team-a"), "Alloc", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{tenant.Default, "team-a"}, s.Tenants())
+
+	// tenants opened explicitly are not limited
+	_, err = s.Open("team-b")
+	require.NoError(t, err)
+}
+
+func TestStorage_Stats(t *testing.T) {
+	s := New(newMemStorage)
+	ctx := context.Background()
+	teamA := tenant.WithTenant(ctx, "team-a")
+	require.NoError(t, s.InsertBatch(teamA, []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: new(float64)},
+		{ID: "PollCount", MType: models.Counter, Delta: new(int64)},
+		{ID: "PollCount", MType: models.Counter, Labels: map[string]string{"host": "a"}, Delta: new(int64)},
+	}))
+	_, err := s.Open(tenant.Default)
+	require.NoError(t, err)
+
+	stats, err := s.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*models.TenantStats{{Tenant: tenant.Default, Series: 0}, {Tenant: "team-a", Series: 3}}, stats)
+}
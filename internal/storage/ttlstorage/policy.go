@@ -0,0 +1,74 @@
+package ttlstorage
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/models"
+)
+
+// Rule - TTL of metrics with names matching the Pattern.
+// Pattern syntax is the one of path.Match, zero TTL means that metrics never expire.
+type Rule struct {
+	Pattern string
+	TTL     time.Duration
+}
+
+// Policy - TTL of metrics, the first matching rule wins, Default is used when no rule matches.
+type Policy struct {
+	Rules   []Rule
+	Default time.Duration
+}
+
+// ParseRules parses rules in "pattern=seconds,pattern=seconds" format.
+func ParseRules(val string) ([]Rule, error) {
+	rules := make([]Rule, 0)
+	for _, pair := range strings.Split(val, ",") {
+		if pair == "" {
+			continue
+		}
+		pattern, seconds, ok := strings.Cut(pair, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid ttl rule %q", pair)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid ttl rule %q: %w", pair, err)
+		}
+		ttl, err := strconv.Atoi(seconds)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid ttl rule %q", pair)
+		}
+		rules = append(rules, Rule{Pattern: pattern, TTL: time.Duration(ttl) * time.Second})
+	}
+	return rules, nil
+}
+
+// TTL method returns TTL of the series.
+func (p *Policy) TTL(key string) time.Duration {
+	name, _, err := models.ParseSeriesKey(key)
+	if err != nil {
+		name = key
+	}
+	for _, rule := range p.Rules {
+		if ok, _ := path.Match(rule.Pattern, name); ok {
+			return rule.TTL
+		}
+	}
+	return p.Default
+}
+
+// Expired method reports whether the series was not updated for longer than its TTL.
+func (p *Policy) Expired(key string, updated time.Time, now time.Time) bool {
+	ttl := p.TTL(key)
+	return ttl != 0 && now.Sub(updated) > ttl
+}
+
+// Stale method reports whether the series was not updated for longer than a half of its TTL,
+// such series are going to expire unless they are updated soon.
+func (p *Policy) Stale(key string, updated time.Time, now time.Time) bool {
+	ttl := p.TTL(key)
+	return ttl != 0 && now.Sub(updated) > ttl/2
+}
@@ -0,0 +1,121 @@
+package ttlstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		val     string
+		want    []Rule
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			val:  "",
+			want: []Rule{},
+		},
+		{
+			name: "rules",
+			val:  "Temp*=60,PollCount=0",
+			want: []Rule{{Pattern: "Temp*", TTL: time.Minute}, {Pattern: "PollCount", TTL: 0}},
+		},
+		{
+			name:    "no ttl",
+			val:     "Temp*",
+			wantErr: true,
+		},
+		{
+			name:    "negative ttl",
+			val:     "Temp*=-1",
+			wantErr: true,
+		},
+		{
+			name:    "bad pattern",
+			val:     "Temp[=60",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(tt.val)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rules)
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	now := time.Now()
+	p := &Policy{
+		Rules: []Rule{
+			{Pattern: "PollCount", TTL: 0},
+			{Pattern: "Temp*", TTL: time.Minute},
+			{Pattern: "*", TTL: time.Hour},
+		},
+		Default: time.Second,
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		age         time.Duration
+		wantTTL     time.Duration
+		wantStale   bool
+		wantExpired bool
+	}{
+		{
+			name:    "never expires",
+			key:     "PollCount",
+			age:     24 * time.Hour,
+			wantTTL: 0,
+		},
+		{
+			name:    "fresh",
+			key:     `Temperature{room="kitchen"}`,
+			age:     10 * time.Second,
+			wantTTL: time.Minute,
+		},
+		{
+			name:      "stale",
+			key:       `Temperature{room="kitchen"}`,
+			age:       40 * time.Second,
+			wantTTL:   time.Minute,
+			wantStale: true,
+		},
+		{
+			name:        "expired",
+			key:         "Temperature",
+			age:         2 * time.Minute,
+			wantTTL:     time.Minute,
+			wantStale:   true,
+			wantExpired: true,
+		},
+		{
+			name:    "first match wins",
+			key:     "Alloc",
+			age:     time.Minute,
+			wantTTL: time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantTTL, p.TTL(tt.key))
+			assert.Equal(t, tt.wantStale, p.Stale(tt.key, now.Add(-tt.age), now))
+			assert.Equal(t, tt.wantExpired, p.Expired(tt.key, now.Add(-tt.age), now))
+		})
+	}
+
+	assert.Equal(t, time.Second, (&Policy{Default: time.Second}).TTL("Alloc"))
+}
@@ -0,0 +1,319 @@
+// Package ttlstorage is a metrics storage wrapper hiding and purging series
+// which were not updated for longer than their TTL.
+package ttlstorage
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+// stripes - number of locks of series, expiry of the series is checked and the series is written under its lock
+const stripes = 256
+
+// MetricsStorage consists methods to save and get data from the wrapped storage
+type MetricsStorage interface {
+	UpdateGauge(ctx context.Context, name string, v float64) (float64, error)
+	UpdateCounter(ctx context.Context, name string, v int64) (int64, error)
+	SetCounter(ctx context.Context, name string, v int64) (int64, error)
+	GetGauge(ctx context.Context, name string) (float64, error)
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetCounter(ctx context.Context, name string) (int64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	InsertBatch(ctx context.Context, batch []*models.Metrics) error
+	GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]*models.Sample, error)
+	UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error)
+	GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error)
+	GetSummary(ctx context.Context, name string) (*models.SummaryValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Delete(ctx context.Context, mType string, name string) error
+	DeleteBatch(ctx context.Context, batch []*models.Metrics) error
+	GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error)
+	GetUpdatedAt(ctx context.Context, mType string, name string) (time.Time, error)
+	RegisterMetadata(ctx context.Context, meta *models.Metadata) error
+	GetMetadata(ctx context.Context, name string) (*models.Metadata, error)
+	GetAllMetadata(ctx context.Context) (map[string]*models.Metadata, error)
+}
+
+// Storage consists wrapped storage and TTL policy.
+// Reads skip expired series, writes merging into the stored value delete the expired series first,
+// so it starts over instead of continuing from its old value.
+// Writes and purges of the series hold its lock, so the series updated meanwhile is never deleted.
+type Storage struct {
+	MetricsStorage
+	policy *Policy
+	now    func() time.Time
+	series [stripes]sync.Mutex
+}
+
+// New creates Storage.
+func New(s MetricsStorage, policy *Policy) *Storage {
+	return &Storage{
+		MetricsStorage: s,
+		policy:         policy,
+		now:            time.Now,
+	}
+}
+
+// UpdateGauge method replaces the value of the gauge.
+func (s *Storage) UpdateGauge(ctx context.Context, name string, v float64) (float64, error) {
+	defer s.lock(ctx, models.Gauge, name)()
+	return s.MetricsStorage.UpdateGauge(ctx, name, v)
+}
+
+// SetCounter method replaces the value of the counter.
+func (s *Storage) SetCounter(ctx context.Context, name string, v int64) (int64, error) {
+	defer s.lock(ctx, models.Counter, name)()
+	return s.MetricsStorage.SetCounter(ctx, name, v)
+}
+
+// UpdateCounter method adds the value to the counter, the expired counter starts from zero.
+func (s *Storage) UpdateCounter(ctx context.Context, name string, v int64) (int64, error) {
+	defer s.lock(ctx, models.Counter, name)()
+	if err := s.reset(ctx, models.Counter, name); err != nil {
+		return 0, err
+	}
+	return s.MetricsStorage.UpdateCounter(ctx, name, v)
+}
+
+// UpdateHistogram method merges observations into the histogram, the expired histogram starts empty.
+func (s *Storage) UpdateHistogram(ctx context.Context, name string, v *models.HistogramValue) (*models.HistogramValue, error) {
+	defer s.lock(ctx, models.Histogram, name)()
+	if err := s.reset(ctx, models.Histogram, name); err != nil {
+		return nil, err
+	}
+	return s.MetricsStorage.UpdateHistogram(ctx, name, v)
+}
+
+// UpdateSummary method merges the sketch into the summary, the expired summary starts empty.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, v *models.SummaryValue) (*models.SummaryValue, error) {
+	defer s.lock(ctx, models.Summary, name)()
+	if err := s.reset(ctx, models.Summary, name); err != nil {
+		return nil, err
+	}
+	return s.MetricsStorage.UpdateSummary(ctx, name, v)
+}
+
+// InsertBatch method deletes expired series merged by the batch and then inserts it.
+// Gauges are replaced, so they are inserted as is.
+func (s *Storage) InsertBatch(ctx context.Context, batch []*models.Metrics) error {
+	series := make([]*models.Metrics, 0, len(batch))
+	for _, m := range batch {
+		series = append(series, &models.Metrics{ID: m.Key(), MType: m.MType})
+	}
+	defer s.lockBatch(ctx, series)()
+
+	expired := make([]*models.Metrics, 0)
+	seen := make(map[string]bool)
+	for _, m := range batch {
+		key := m.Key()
+		if m.MType == models.Gauge || seen[m.MType+key] {
+			continue
+		}
+		seen[m.MType+key] = true
+		ok, err := s.expired(ctx, m.MType, key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			expired = append(expired, &models.Metrics{ID: m.ID, MType: m.MType, Labels: m.Labels})
+		}
+	}
+	if len(expired) > 0 {
+		if err := s.MetricsStorage.DeleteBatch(ctx, expired); err != nil {
+			return err
+		}
+	}
+	return s.MetricsStorage.InsertBatch(ctx, batch)
+}
+
+// GetGauge method returns gauge metric value if it is not expired.
+func (s *Storage) GetGauge(ctx context.Context, name string) (float64, error) {
+	return get(ctx, s, models.Gauge, name, s.MetricsStorage.GetGauge)
+}
+
+// GetCounter method returns counter metric value if it is not expired.
+func (s *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
+	return get(ctx, s, models.Counter, name, s.MetricsStorage.GetCounter)
+}
+
+// GetHistogram method returns histogram metric value if it is not expired.
+func (s *Storage) GetHistogram(ctx context.Context, name string) (*models.HistogramValue, error) {
+	return get(ctx, s, models.Histogram, name, s.MetricsStorage.GetHistogram)
+}
+
+// GetSummary method returns summary metric value if it is not expired.
+func (s *Storage) GetSummary(ctx context.Context, name string) (*models.SummaryValue, error) {
+	return get(ctx, s, models.Summary, name, s.MetricsStorage.GetSummary)
+}
+
+// GetAllGauge method returns values of all not expired gauge metrics.
+func (s *Storage) GetAllGauge(ctx context.Context) (map[string]float64, error) {
+	return getAll(ctx, s, models.Gauge, s.MetricsStorage.GetAllGauge)
+}
+
+// GetAllCounter method returns values of all not expired counter metrics.
+func (s *Storage) GetAllCounter(ctx context.Context) (map[string]int64, error) {
+	return getAll(ctx, s, models.Counter, s.MetricsStorage.GetAllCounter)
+}
+
+// GetAllHistogram method returns values of all not expired histogram metrics.
+func (s *Storage) GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error) {
+	return getAll(ctx, s, models.Histogram, s.MetricsStorage.GetAllHistogram)
+}
+
+// GetAllSummary method returns values of all not expired summary metrics.
+func (s *Storage) GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error) {
+	return getAll(ctx, s, models.Summary, s.MetricsStorage.GetAllSummary)
+}
+
+// Stale method reports whether the series is going to expire soon.
+func (s *Storage) Stale(key string, updated time.Time) bool {
+	return s.policy.Stale(key, updated, s.now())
+}
+
+// Purge method deletes expired series from the wrapped storage.
+// Expiry of the series is checked again under their locks, so series updated meanwhile are kept.
+func (s *Storage) Purge(ctx context.Context) error {
+	now := s.now()
+	candidates := make([]*models.Metrics, 0)
+	for _, mType := range models.Types {
+		updated, err := s.MetricsStorage.GetUpdated(ctx, mType)
+		if err != nil {
+			return err
+		}
+		for key, t := range updated {
+			if s.policy.Expired(key, t, now) {
+				candidates = append(candidates, &models.Metrics{ID: key, MType: mType})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	defer s.lockBatch(ctx, candidates)()
+	batch := make([]*models.Metrics, 0, len(candidates))
+	for _, c := range candidates {
+		expired, err := s.expired(ctx, c.MType, c.ID)
+		if err != nil {
+			return err
+		}
+		if !expired {
+			continue
+		}
+		name, labels, err := models.ParseSeriesKey(c.ID)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, &models.Metrics{ID: name, MType: c.MType, Labels: labels})
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.MetricsStorage.DeleteBatch(ctx, batch)
+}
+
+// lock locks the series of the tenant of the context and returns the function unlocking it.
+func (s *Storage) lock(ctx context.Context, mType string, key string) func() {
+	return s.lockBatch(ctx, []*models.Metrics{{ID: key, MType: mType}})
+}
+
+// lockBatch locks series of the tenant of the context keyed by ids of metrics and returns the function unlocking them.
+func (s *Storage) lockBatch(ctx context.Context, series []*models.Metrics) func() {
+	id := tenant.FromContext(ctx)
+	seen := make(map[int]bool, len(series))
+	locked := make([]int, 0, len(series))
+	for _, m := range series {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(id))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(m.MType))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(m.ID))
+		i := int(h.Sum32() % stripes)
+		if !seen[i] {
+			seen[i] = true
+			locked = append(locked, i)
+		}
+	}
+	// stripes are locked in the ascending order, so batches sharing them don't deadlock
+	sort.Ints(locked)
+	for _, i := range locked {
+		s.series[i].Lock()
+	}
+	return func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			s.series[locked[i]].Unlock()
+		}
+	}
+}
+
+// expired reports whether the series is expired, series without update time never expire.
+func (s *Storage) expired(ctx context.Context, mType string, key string) (bool, error) {
+	t, err := s.MetricsStorage.GetUpdatedAt(ctx, mType, key)
+	if errors.Is(err, storage.ErrMetricNotRegistered) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return s.policy.Expired(key, t, s.now()), nil
+}
+
+// reset deletes the series if it's expired, the series must be locked.
+func (s *Storage) reset(ctx context.Context, mType string, key string) error {
+	expired, err := s.expired(ctx, mType, key)
+	if err != nil || !expired {
+		return err
+	}
+	err = s.MetricsStorage.Delete(ctx, mType, key)
+	// the series may be purged meanwhile
+	if errors.Is(err, storage.ErrMetricNotRegistered) {
+		return nil
+	}
+	return err
+}
+
+func get[V any](ctx context.Context, s *Storage, mType string, name string, fn func(context.Context, string) (V, error)) (V, error) {
+	val, err := fn(ctx, name)
+	if err != nil {
+		return val, err
+	}
+	expired, err := s.expired(ctx, mType, name)
+	if err != nil {
+		return val, err
+	}
+	if expired {
+		var empty V
+		return empty, storage.ErrMetricNotRegistered
+	}
+	return val, nil
+}
+
+func getAll[V any](ctx context.Context, s *Storage, mType string, fn func(context.Context) (map[string]V, error)) (map[string]V, error) {
+	values, err := fn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.MetricsStorage.GetUpdated(ctx, mType)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	for key := range values {
+		if t, ok := updated[key]; ok && s.policy.Expired(key, t, now) {
+			delete(values, key)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,153 @@
+package ttlstorage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	ms := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	s := New(ms, &Policy{Default: time.Minute})
+
+	_, err := s.UpdateGauge(ctx, "Fresh", 1)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Expired", 2)
+	require.NoError(t, err)
+	_, err = s.UpdateCounter(ctx, "Expired", 3)
+	require.NoError(t, err)
+	require.NoError(t, ms.SetUpdated(ctx, models.Gauge, "Expired", now.Add(-2*time.Minute)))
+	require.NoError(t, ms.SetUpdated(ctx, models.Counter, "Expired", now.Add(-2*time.Minute)))
+
+	_, err = s.GetGauge(ctx, "Expired")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	_, err = s.GetCounter(ctx, "Expired")
+	assert.ErrorIs(t, err, storage.ErrMetricNotRegistered)
+	v, err := s.GetGauge(ctx, "Fresh")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), v)
+
+	gauges, err := s.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Fresh": 1}, gauges)
+
+	require.NoError(t, s.Purge(ctx))
+	gauges, err = ms.GetAllGauge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Fresh": 1}, gauges)
+	counters, err := ms.GetAllCounter(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, counters)
+
+	_, err = s.UpdateGauge(ctx, "Expired", 4)
+	require.NoError(t, err)
+	v, err = s.GetGauge(ctx, "Expired")
+	require.NoError(t, err)
+	assert.Equal(t, float64(4), v)
+}
+
+func TestStorage_UpdateExpired(t *testing.T) {
+	ctx := context.Background()
+	old := time.Now().Add(-2 * time.Minute)
+	ms := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	s := New(ms, &Policy{Default: time.Minute})
+
+	for _, name := range []string{"Expired", "Batched", "Fresh"} {
+		_, err := s.UpdateCounter(ctx, name, 10)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ms.SetUpdated(ctx, models.Counter, "Expired", old))
+	require.NoError(t, ms.SetUpdated(ctx, models.Counter, "Batched", old))
+
+	v, err := s.UpdateCounter(ctx, "Expired", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v, "expired counter must start over")
+	v, err = s.UpdateCounter(ctx, "Fresh", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), v)
+
+	delta := int64(2)
+	require.NoError(t, s.InsertBatch(ctx, []*models.Metrics{
+		{ID: "Batched", MType: models.Counter, Delta: &delta},
+		{ID: "Batched", MType: models.Counter, Delta: &delta},
+	}))
+	v, err = s.GetCounter(ctx, "Batched")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), v, "expired counter must start over")
+}
+
+// updatedLater is the storage whose series are updated right after their update times are read by Purge.
+type updatedLater struct {
+	*memstorage.Storage
+}
+
+func (s *updatedLater) GetUpdated(ctx context.Context, mType string) (map[string]time.Time, error) {
+	updated, err := s.Storage.GetUpdated(ctx, mType)
+	if err != nil {
+		return nil, err
+	}
+	for key := range updated {
+		if err = s.SetUpdated(ctx, mType, key, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+func TestStorage_PurgeUpdated(t *testing.T) {
+	ctx := context.Background()
+	ms := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	s := New(&updatedLater{Storage: ms}, &Policy{Default: time.Minute})
+
+	_, err := s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	require.NoError(t, ms.SetUpdated(ctx, models.Gauge, "Alloc", time.Now().Add(-2*time.Minute)))
+
+	require.NoError(t, s.Purge(ctx))
+	v, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err, "series updated after its expiry was read must be kept")
+	assert.Equal(t, float64(1), v)
+}
+
+func TestStorage_UpdateExpiredConcurrently(t *testing.T) {
+	ctx := context.Background()
+	ms := memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo())
+	s := New(ms, &Policy{Default: time.Minute})
+	_, err := s.UpdateCounter(ctx, "PollCount", 100)
+	require.NoError(t, err)
+	require.NoError(t, ms.SetUpdated(ctx, models.Counter, "PollCount", time.Now().Add(-2*time.Minute)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.UpdateCounter(ctx, "PollCount", 1)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	v, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), v, "increments must not be deleted by resets of other updates")
+}
+
+func TestStorage_Stale(t *testing.T) {
+	now := time.Now()
+	s := New(nil, &Policy{Default: time.Minute})
+	s.now = func() time.Time { return now }
+
+	assert.False(t, s.Stale("Alloc", now.Add(-10*time.Second)))
+	assert.True(t, s.Stale("Alloc", now.Add(-40*time.Second)))
+}
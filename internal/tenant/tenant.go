@@ -0,0 +1,132 @@
+// Package tenant identifies tenants sharing the server, every tenant sees only its own metrics.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vindosVP/metrics/pkg/utils"
+)
+
+const (
+	// Default - tenant of requests without tenant identifier,
+	// its metrics are stored exactly as before tenants were introduced.
+	Default = "default"
+	// Header - HTTP header with the tenant identifier.
+	Header = "X-Tenant-ID"
+	// MetadataKey - gRPC metadata key with the tenant identifier.
+	MetadataKey = "x-tenant-id"
+	// HashMetadataKey - gRPC metadata key with the hash of the tenant identifier made with the key the call is signed with.
+	HashMetadataKey = "hashsha256"
+)
+
+var (
+	// ErrInvalidTenant - represents that tenant identifier is malformed.
+	ErrInvalidTenant = errors.New("tenant must consist of 1 to 64 letters, digits, '_' or '-'")
+	// ErrTenantMismatch - represents that the request is signed with a key of another tenant.
+	ErrTenantMismatch = errors.New("request is signed with a key of another tenant")
+	// ErrUnsigned - represents that the request of the tenant is not signed with its key.
+	ErrUnsigned = errors.New("request of the tenant must be signed with its key")
+)
+
+var tenantRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type ctxKey struct{}
+
+// Valid reports whether the tenant identifier is well-formed.
+func Valid(id string) bool {
+	return tenantRe.MatchString(id)
+}
+
+// WithTenant returns the context of the tenant.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant of the context, Default if it's not set.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(ctxKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+// Keys maps tenants to the keys their agents sign requests with.
+type Keys map[string]string
+
+// ParseKeys parses keys in the tenant=key,... form.
+func ParseKeys(s string) (Keys, error) {
+	keys := make(Keys)
+	if strings.TrimSpace(s) == "" {
+		return keys, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		id, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tenant key %q, expected tenant=key", pair)
+		}
+		if !Valid(id) {
+			return nil, fmt.Errorf("invalid tenant %q: %w", id, ErrInvalidTenant)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// Signer returns the tenant whose key produces the hash of data.
+func (k Keys) Signer(data []byte, hash string) (string, bool) {
+	ids := make([]string, 0, len(k))
+	for id := range k {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		h, err := utils.Sha256Hash(data, k[id])
+		if err == nil && h == hash {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// Sign returns the hash of the tenant identifier made with the key, gRPC calls are signed with it.
+func Sign(id string, key string) string {
+	// writes to the hmac never fail
+	hash, _ := utils.Sha256Hash([]byte(id), key)
+	return hash
+}
+
+// Resolve returns the tenant of the request from the provided identifier and the tenant of the signing key,
+// both are optional, but must agree if provided.
+func Resolve(id string, signer string) (string, error) {
+	if id != "" && !Valid(id) {
+		return "", ErrInvalidTenant
+	}
+	switch {
+	case id != "" && signer != "" && id != signer:
+		return "", ErrTenantMismatch
+	case id != "":
+		return id, nil
+	case signer != "":
+		return signer, nil
+	default:
+		return Default, nil
+	}
+}
+
+// Resolve method returns the tenant of the request like Resolve does,
+// but if any keys are configured, requests of tenants other than Default must be signed with the key of the tenant.
+func (k Keys) Resolve(id string, signer string) (string, error) {
+	res, err := Resolve(id, signer)
+	if err != nil {
+		return "", err
+	}
+	if len(k) > 0 && res != Default && signer == "" {
+		return "", ErrUnsigned
+	}
+	return res, nil
+}
@@ -0,0 +1,113 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/pkg/utils"
+)
+
+func TestFromContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, Default, FromContext(ctx))
+	assert.Equal(t, "team-a", FromContext(WithTenant(ctx, "team-a")))
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		want    Keys
+		name    string
+		keys    string
+		wantErr bool
+	}{
+		{name: "empty", keys: "", want: Keys{}},
+		{name: "keys", keys: "team-a=secret, team_b=other", want: Keys{"team-a": "secret", "team_b": "other"}},
+		{name: "no key", keys: "team-a", wantErr: true},
+		{name: "empty key", keys: "team-a=", wantErr: true},
+		{name: "invalid tenant", keys: "team.a=secret", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKeys(tt.keys)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKeys_Signer(t *testing.T) {
+	keys := Keys{"team-a": "secret", "team-b": "other"}
+	data := []byte(`{"id":"Alloc"}`)
+	hash, err := utils.Sha256Hash(data, "other")
+	require.NoError(t, err)
+
+	id, ok := keys.Signer(data, hash)
+	assert.True(t, ok)
+	assert.Equal(t, "team-b", id)
+	_, ok = keys.Signer(data, "invalid")
+	assert.False(t, ok)
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		id      string
+		signer  string
+		want    string
+	}{
+		{name: "default", want: Default},
+		{name: "header", id: "team-a", want: "team-a"},
+		{name: "key", signer: "team-a", want: "team-a"},
+		{name: "header and key", id: "team-a", signer: "team-a", want: "team-a"},
+		{name: "mismatch", id: "team-a", signer: "team-b", wantErr: ErrTenantMismatch},
+		{name: "invalid", id: "team a", wantErr: ErrInvalidTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.id, tt.signer)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKeys_Resolve(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		keys    Keys
+		name    string
+		id      string
+		signer  string
+		want    string
+	}{
+		{name: "no keys", id: "team-a", want: "team-a"},
+		{name: "default", keys: Keys{"team-a": "secret"}, want: Default},
+		{name: "signed", keys: Keys{"team-a": "secret"}, id: "team-a", signer: "team-a", want: "team-a"},
+		{name: "unsigned", keys: Keys{"team-a": "secret"}, id: "team-a", wantErr: ErrUnsigned},
+		{name: "tenant without key", keys: Keys{"team-a": "secret"}, id: "team-b", wantErr: ErrUnsigned},
+		{name: "mismatch", keys: Keys{"team-a": "secret"}, id: "team-b", signer: "team-a", wantErr: ErrTenantMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keys.Resolve(tt.id, tt.signer)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package watch
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/pkg/logger"
+)
+
+const (
+	// recorderBuffer - number of events kept for the recorder before it's considered lagging
+	recorderBuffer = 1000
+
+	defaultSize = 120
+)
+
+// Scalar returns the value of the metric charted on sparklines,
+// histograms and summaries are charted by the mean of their observations.
+func Scalar(m *models.Metrics) float64 {
+	switch {
+	case m.Delta != nil:
+		return float64(*m.Delta)
+	case m.Value != nil:
+		return *m.Value
+	case m.Histogram != nil && m.Histogram.Count > 0:
+		return m.Histogram.Sum / float64(m.Histogram.Count)
+	case m.Summary != nil && m.Summary.Count > 0:
+		return m.Summary.Sum / float64(m.Summary.Count)
+	default:
+		return 0
+	}
+}
+
+// ring keeps the last values of the series.
+type ring struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func (r *ring) add(v float64) {
+	r.values[r.next] = v
+	r.next = (r.next + 1) % len(r.values)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns values from the oldest to the latest.
+func (r *ring) list() []float64 {
+	if !r.full {
+		return append([]float64(nil), r.values[:r.next]...)
+	}
+	return append(append([]float64(nil), r.values[r.next:]...), r.values[:r.next]...)
+}
+
+// recording consists current values of series of the tenant and their recent values.
+type recording struct {
+	values map[string]*models.Metrics
+	recent map[string]*ring
+	seq    uint64
+	synced bool
+}
+
+// Recorder keeps recent values of series of every tenant changed since it was started.
+type Recorder struct {
+	s       SnapshotStorage
+	bus     *events.Bus
+	tenants map[string]*recording
+	size    int
+	mu      sync.RWMutex
+}
+
+// NewRecorder creates Recorder of changes of the storage published to the bus.
+func NewRecorder(s SnapshotStorage, bus *events.Bus, opts ...func(*Recorder)) *Recorder {
+	r := &Recorder{s: s, bus: bus, tenants: make(map[string]*recording), size: defaultSize}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithSize sets the number of recent values kept for every series.
+func WithSize(size int) func(*Recorder) {
+	return func(r *Recorder) {
+		r.size = size
+	}
+}
+
+// Size method returns the number of recent values kept for every series.
+func (r *Recorder) Size() int {
+	return r.size
+}
+
+// Recent method returns recent values of series of the tenant of the context by series keys,
+// values are ordered from the oldest to the latest.
+func (r *Recorder) Recent(ctx context.Context) map[string][]float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make(map[string][]float64)
+	if rec, ok := r.tenants[tenant.FromContext(ctx)]; ok {
+		for key, values := range rec.recent {
+			res[key] = values.list()
+		}
+	}
+	return res
+}
+
+// Run method records changes until done is closed.
+func (r *Recorder) Run(done <-chan struct{}) {
+	for {
+		sub := r.bus.Subscribe(recorderBuffer)
+		if !r.consume(sub, done) {
+			sub.Close()
+			return
+		}
+		// current values are read again from the storage, changes published while the recorder lagged are lost
+		logger.Log.Warn("Recorder lagged behind metric updates, resubscribing", zap.Error(sub.Err()))
+		r.mu.Lock()
+		for _, rec := range r.tenants {
+			rec.synced = false
+		}
+		r.mu.Unlock()
+	}
+}
+
+// consume records events of the subscription until it's dropped, returns false once done is closed.
+func (r *Recorder) consume(sub *events.Subscription, done <-chan struct{}) bool {
+	for {
+		select {
+		case <-done:
+			return false
+		case e, ok := <-sub.Events():
+			if !ok {
+				return true
+			}
+			r.record(e)
+		}
+	}
+}
+
+func (r *Recorder) record(e *events.Event) {
+	if e.Op == events.OpMetadata {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.tenants[e.Tenant]
+	if !ok {
+		rec = &recording{recent: make(map[string]*ring)}
+		r.tenants[e.Tenant] = rec
+	}
+	if !rec.synced {
+		if err := r.sync(e.Tenant, rec); err != nil {
+			logger.Log.Error("Failed to read metrics to record", zap.String("tenant", e.Tenant), zap.Error(err))
+			return
+		}
+	}
+	// changes up to the snapshot are already in it
+	if e.Seq <= rec.seq {
+		return
+	}
+	for _, m := range e.Metrics {
+		u := apply(rec.values, e.Op, m)
+		if u.Deleted {
+			delete(rec.recent, m.ID)
+			continue
+		}
+		r.add(rec, u.Metric)
+	}
+}
+
+// sync reads current values of series of the tenant, they are recorded as the first values of new series.
+func (r *Recorder) sync(id string, rec *recording) error {
+	ctx := tenant.WithTenant(context.Background(), id)
+	values := make(map[string]*models.Metrics)
+	err := r.s.Snapshot(ctx, func(seq uint64) error {
+		rec.seq = seq
+		return read(ctx, r.s, &Filter{}, values)
+	})
+	if err != nil {
+		return err
+	}
+	rec.values = values
+	rec.synced = true
+	for key := range rec.recent {
+		if _, ok := values[key]; !ok {
+			delete(rec.recent, key)
+		}
+	}
+	for key, m := range values {
+		if _, ok := rec.recent[key]; !ok {
+			r.add(rec, m)
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) add(rec *recording, m *models.Metrics) {
+	values, ok := rec.recent[m.ID]
+	if !ok {
+		values = &ring{values: make([]float64, r.size)}
+		rec.recent[m.ID] = values
+	}
+	values.add(Scalar(m))
+}
@@ -0,0 +1,282 @@
+// Package watch streams current values of metrics to subscribers as changes are applied to the storage.
+//
+// Every subscriber starts with the snapshot of values consistent with the event bus
+// and then receives values updated by published changes through its own buffer.
+// Subscribers not keeping up with changes are dropped instead of slowing down writes.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+// defaultBuffer - number of changes kept for the subscriber before it's dropped
+const defaultBuffer = 1024
+
+// SnapshotStorage consists methods to read a snapshot of values consistent with the event bus
+type SnapshotStorage interface {
+	GetAllGauge(ctx context.Context) (map[string]float64, error)
+	GetAllCounter(ctx context.Context) (map[string]int64, error)
+	GetAllHistogram(ctx context.Context) (map[string]*models.HistogramValue, error)
+	GetAllSummary(ctx context.Context) (map[string]*models.SummaryValue, error)
+	Snapshot(ctx context.Context, f func(seq uint64) error) error
+}
+
+// Filter selects watched series by metric name patterns and types, empty lists select all of them.
+// Pattern syntax is the one of path.Match.
+type Filter struct {
+	Names []string
+	Types []string
+}
+
+// Validate method checks name patterns and types of the filter.
+func (f *Filter) Validate() error {
+	for _, name := range f.Names {
+		if _, err := path.Match(name, ""); err != nil {
+			return fmt.Errorf("invalid name pattern %q: %w", name, err)
+		}
+	}
+	for _, t := range f.Types {
+		if !slices.Contains(models.Types, t) {
+			return fmt.Errorf("invalid type %q", t)
+		}
+	}
+	return nil
+}
+
+func (f *Filter) types() []string {
+	if len(f.Types) == 0 {
+		return models.Types
+	}
+	return f.Types
+}
+
+// matches method reports whether the series of the type is selected by the filter.
+func (f *Filter) matches(mType string, key string) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, mType) {
+		return false
+	}
+	if len(f.Names) == 0 {
+		return true
+	}
+	name, _, err := models.ParseSeriesKey(key)
+	if err != nil {
+		name = key
+	}
+	for _, pattern := range f.Names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Update - current value of the series, its ID is the series key.
+// Updates of the snapshot have no sequence number, the snapshot ends with the update without a metric.
+type Update struct {
+	Time     time.Time
+	Metric   *models.Metrics
+	Seq      uint64
+	Snapshot bool
+	Deleted  bool
+}
+
+// Hub serves subscribers of changes of the storage published to the bus.
+type Hub struct {
+	s      SnapshotStorage
+	bus    *events.Bus
+	buffer int
+}
+
+// NewHub creates Hub.
+func NewHub(s SnapshotStorage, bus *events.Bus, opts ...func(*Hub)) *Hub {
+	h := &Hub{s: s, bus: bus, buffer: defaultBuffer}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// WithBuffer sets the number of changes kept for every subscriber before it's dropped.
+func WithBuffer(buffer int) func(*Hub) {
+	return func(h *Hub) {
+		h.buffer = buffer
+	}
+}
+
+// Watch method sends the snapshot and then updates of series of the tenant of the context selected by the filter
+// until the context is done, send fails or the subscriber lags behind changes, then events.ErrLagged is returned.
+func (h *Hub) Watch(ctx context.Context, f *Filter, send func(u *Update) error) error {
+	sub := h.bus.Subscribe(h.buffer)
+	defer sub.Close()
+
+	var seq uint64
+	values := make(map[string]*models.Metrics)
+	err := h.s.Snapshot(ctx, func(s uint64) error {
+		seq = s
+		return read(ctx, h.s, f, values)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if err = h.sendSnapshot(values, send); err != nil {
+		return err
+	}
+
+	id := tenant.FromContext(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-sub.Events():
+			if !ok {
+				return sub.Err()
+			}
+			// changes up to the snapshot are already in it
+			if e.Tenant != id || e.Seq <= seq || e.Op == events.OpMetadata {
+				continue
+			}
+			for _, m := range e.Metrics {
+				if !f.matches(m.MType, m.ID) {
+					continue
+				}
+				u := apply(values, e.Op, m)
+				u.Time = e.Time
+				u.Seq = e.Seq
+				if err = send(u); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// read puts values of series selected by the filter into values.
+func read(ctx context.Context, s SnapshotStorage, f *Filter, values map[string]*models.Metrics) error {
+	for _, mType := range f.types() {
+		switch mType {
+		case models.Counter:
+			all, err := s.GetAllCounter(ctx)
+			if err != nil {
+				return err
+			}
+			for key, v := range all {
+				delta := v
+				values[key] = &models.Metrics{ID: key, MType: mType, Delta: &delta}
+			}
+		case models.Gauge:
+			all, err := s.GetAllGauge(ctx)
+			if err != nil {
+				return err
+			}
+			for key, v := range all {
+				value := v
+				values[key] = &models.Metrics{ID: key, MType: mType, Value: &value}
+			}
+		case models.Histogram:
+			all, err := s.GetAllHistogram(ctx)
+			if err != nil {
+				return err
+			}
+			for key, v := range all {
+				values[key] = &models.Metrics{ID: key, MType: mType, Histogram: v.Copy()}
+			}
+		case models.Summary:
+			all, err := s.GetAllSummary(ctx)
+			if err != nil {
+				return err
+			}
+			for key, v := range all {
+				values[key] = &models.Metrics{ID: key, MType: mType, Summary: v.Copy()}
+			}
+		}
+	}
+	for key, m := range values {
+		if !f.matches(m.MType, key) {
+			delete(values, key)
+		}
+	}
+	return nil
+}
+
+func (h *Hub) sendSnapshot(values map[string]*models.Metrics, send func(u *Update) error) error {
+	snapshot := make([]*models.Metrics, 0, len(values))
+	for _, m := range values {
+		snapshot = append(snapshot, m)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].MType != snapshot[j].MType {
+			return snapshot[i].MType < snapshot[j].MType
+		}
+		return snapshot[i].ID < snapshot[j].ID
+	})
+	for _, m := range snapshot {
+		if err := send(&Update{Metric: copyMetric(m), Snapshot: true}); err != nil {
+			return err
+		}
+	}
+	return send(&Update{Snapshot: true})
+}
+
+// apply applies the change of the series to values and returns its current value.
+// Series are keyed by series keys only, as the storage doesn't keep series of different types with the same key.
+func apply(values map[string]*models.Metrics, op events.Op, m *models.Metrics) *Update {
+	if op == events.OpDelete {
+		delete(values, m.ID)
+		return &Update{Metric: &models.Metrics{ID: m.ID, MType: m.MType}, Deleted: true}
+	}
+	cur, ok := values[m.ID]
+	if !ok || cur.MType != m.MType {
+		cur = &models.Metrics{ID: m.ID, MType: m.MType}
+		values[m.ID] = cur
+	}
+	switch m.MType {
+	case models.Counter:
+		v := *m.Delta
+		if op == events.OpUpdate && cur.Delta != nil {
+			v += *cur.Delta
+		}
+		cur.Delta = &v
+	case models.Gauge:
+		v := *m.Value
+		cur.Value = &v
+	case models.Histogram:
+		// merges can't fail for changes the storage applied, a failed one means the snapshot missed a reset
+		if cur.Histogram == nil || cur.Histogram.Merge(m.Histogram) != nil {
+			cur.Histogram = m.Histogram.Copy()
+		}
+	case models.Summary:
+		if cur.Summary == nil || cur.Summary.Merge(m.Summary) != nil {
+			cur.Summary = m.Summary.Copy()
+		}
+	}
+	return &Update{Metric: copyMetric(cur)}
+}
+
+// copyMetric returns the copy of the value safe to pass to the subscriber while values keep changing.
+func copyMetric(m *models.Metrics) *models.Metrics {
+	c := &models.Metrics{ID: m.ID, MType: m.MType}
+	if m.Delta != nil {
+		delta := *m.Delta
+		c.Delta = &delta
+	}
+	if m.Value != nil {
+		value := *m.Value
+		c.Value = &value
+	}
+	if m.Histogram != nil {
+		c.Histogram = m.Histogram.Copy()
+	}
+	if m.Summary != nil {
+		c.Summary = m.Summary.Copy()
+	}
+	return c
+}
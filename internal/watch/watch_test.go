@@ -0,0 +1,247 @@
+package watch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/repos"
+	"github.com/vindosVP/metrics/internal/storage/eventstorage"
+	"github.com/vindosVP/metrics/internal/storage/memstorage"
+	"github.com/vindosVP/metrics/internal/storage/tenantstorage"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+func newStorage() (*eventstorage.Storage, *events.Bus) {
+	bus := events.NewBus()
+	tenants := tenantstorage.New(func(string) (tenantstorage.MetricsStorage, error) {
+		return memstorage.New(repos.NewGaugeRepo(), repos.NewCounterRepo()), nil
+	})
+	return eventstorage.New(tenants, bus), bus
+}
+
+// watch runs the watcher until the test ends, updates are passed to the returned channel.
+func watch(t *testing.T, ctx context.Context, h *Hub, f *Filter) (<-chan *Update, <-chan error) {
+	ctx, cancel := context.WithCancel(ctx)
+	updates := make(chan *Update, 100)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- h.Watch(ctx, f, func(u *Update) error {
+			updates <- u
+			return nil
+		})
+	}()
+	t.Cleanup(cancel)
+	return updates, errs
+}
+
+func next(t *testing.T, updates <-chan *Update) *Update {
+	select {
+	case u := <-updates:
+		return u
+	case <-time.After(time.Second):
+		require.FailNow(t, "no update received")
+		return nil
+	}
+}
+
+func TestFilter_Validate(t *testing.T) {
+	tests := []struct {
+		filter  *Filter
+		name    string
+		wantErr bool
+	}{
+		{name: "empty", filter: &Filter{}},
+		{name: "valid", filter: &Filter{Names: []string{"Alloc*"}, Types: []string{models.Gauge}}},
+		{name: "invalid pattern", filter: &Filter{Names: []string{"["}}, wantErr: true},
+		{name: "invalid type", filter: &Filter{Types: []string{"meter"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFilter_matches(t *testing.T) {
+	f := &Filter{Names: []string{"Alloc*"}, Types: []string{models.Gauge}}
+	assert.True(t, f.matches(models.Gauge, "Alloc"))
+	assert.True(t, f.matches(models.Gauge, models.SeriesKey("AllocBytes", map[string]string{"host": "a"})))
+	assert.False(t, f.matches(models.Counter, "Alloc"))
+	assert.False(t, f.matches(models.Gauge, "Frees"))
+	assert.True(t, (&Filter{}).matches(models.Counter, "PollCount"))
+}
+
+func TestHub_Watch(t *testing.T) {
+	ctx := context.Background()
+	s, bus := newStorage()
+	_, err := s.UpdateCounter(ctx, "PollCount", 2)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Alloc", 1.5)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Frees", 3)
+	require.NoError(t, err)
+
+	updates, _ := watch(t, ctx, NewHub(s, bus), &Filter{Names: []string{"PollCount", "Alloc", "Latency"}})
+
+	u := next(t, updates)
+	assert.True(t, u.Snapshot)
+	assert.Equal(t, "PollCount", u.Metric.ID)
+	assert.Equal(t, int64(2), *u.Metric.Delta)
+	u = next(t, updates)
+	assert.Equal(t, "Alloc", u.Metric.ID)
+	assert.Equal(t, 1.5, *u.Metric.Value)
+	u = next(t, updates)
+	assert.True(t, u.Snapshot)
+	assert.Nil(t, u.Metric)
+
+	// updates carry current values
+	_, err = s.UpdateCounter(ctx, "PollCount", 3)
+	require.NoError(t, err)
+	_, err = s.UpdateGauge(ctx, "Frees", 4)
+	require.NoError(t, err)
+	_, err = s.SetCounter(ctx, "PollCount", 10)
+	require.NoError(t, err)
+	h := models.NewHistogramValue([]float64{1})
+	h.Observe(0.5)
+	_, err = s.UpdateHistogram(ctx, "Latency", h)
+	require.NoError(t, err)
+	_, err = s.UpdateHistogram(ctx, "Latency", h)
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, models.Gauge, "Alloc"))
+	// other tenants are not watched
+	_, err = s.UpdateGauge(tenant.WithTenant(ctx, "team-a"), "Alloc", 2)
+	require.NoError(t, err)
+
+	u = next(t, updates)
+	assert.False(t, u.Snapshot)
+	assert.Equal(t, uint64(4), u.Seq)
+	assert.Equal(t, int64(5), *u.Metric.Delta)
+	u = next(t, updates)
+	assert.Equal(t, int64(10), *u.Metric.Delta)
+	u = next(t, updates)
+	assert.Equal(t, uint64(1), u.Metric.Histogram.Count)
+	u = next(t, updates)
+	assert.Equal(t, uint64(2), u.Metric.Histogram.Count)
+	assert.Equal(t, uint64(1), h.Count)
+	u = next(t, updates)
+	assert.True(t, u.Deleted)
+	assert.Equal(t, "Alloc", u.Metric.ID)
+	assert.Empty(t, updates)
+}
+
+func TestHub_Watch_Lagged(t *testing.T) {
+	ctx := context.Background()
+	s, bus := newStorage()
+	blocked := make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		errs <- NewHub(s, bus, WithBuffer(1)).Watch(ctx, &Filter{}, func(u *Update) error {
+			if u.Metric == nil {
+				return nil
+			}
+			<-blocked
+			return nil
+		})
+	}()
+	// the watcher subscribes asynchronously
+	time.Sleep(50 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		_, err := s.UpdateCounter(ctx, "PollCount", 1)
+		require.NoError(t, err)
+	}
+	close(blocked)
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, events.ErrLagged)
+	case <-time.After(time.Second):
+		require.FailNow(t, "lagging watcher was not dropped")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	teamA := tenant.WithTenant(ctx, "team-a")
+	s, bus := newStorage()
+	_, err := s.UpdateCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+
+	r := NewRecorder(s, bus, WithSize(3))
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		r.Run(done)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		close(done)
+		<-stopped
+	})
+	// the recorder subscribes asynchronously
+	time.Sleep(50 * time.Millisecond)
+
+	// values before the first change are read from the storage
+	_, err = s.UpdateGauge(ctx, "Alloc", 1)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(r.Recent(ctx)) == 2 }, time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		_, err = s.UpdateCounter(ctx, "PollCount", 2)
+		require.NoError(t, err)
+	}
+	h := models.NewHistogramValue([]float64{1})
+	h.Observe(0.5)
+	h.Observe(1.5)
+	_, err = s.UpdateHistogram(ctx, "Latency", h)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(r.Recent(ctx)) == 3 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string][]float64{
+		"Alloc":     {1},
+		"PollCount": {3, 5, 7},
+		"Latency":   {1},
+	}, r.Recent(ctx))
+	assert.Equal(t, 3, r.Size())
+
+	// other tenants are recorded separately
+	_, err = s.UpdateGauge(teamA, "Frees", 3)
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, models.Gauge, "Alloc"))
+	require.Eventually(t, func() bool { return len(r.Recent(ctx)) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string][]float64{"Frees": {3}}, r.Recent(teamA))
+}
+
+func TestScalar(t *testing.T) {
+	delta := int64(3)
+	value := 1.5
+	h := models.NewHistogramValue([]float64{1})
+	h.Observe(1)
+	h.Observe(2)
+	tests := []struct {
+		metric *models.Metrics
+		name   string
+		want   float64
+	}{
+		{name: "counter", metric: &models.Metrics{MType: models.Counter, Delta: &delta}, want: 3},
+		{name: "gauge", metric: &models.Metrics{MType: models.Gauge, Value: &value}, want: 1.5},
+		{name: "histogram", metric: &models.Metrics{MType: models.Histogram, Histogram: h}, want: 1.5},
+		{name: "empty summary", metric: &models.Metrics{MType: models.Summary, Summary: models.NewSummaryValue(models.DefaultSummaryAccuracy)}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Scalar(tt.metric))
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// Package webhooks posts updates of metrics to URLs subscribed to them.
+//
+// Updates are taken from the event bus, so writes through any API are delivered.
+// Deliveries are asynchronous and retried with exponential backoff,
+// events still failing after the last attempt are recorded to the dead-letter log.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/pkg/logger"
+	"github.com/vindosVP/metrics/pkg/utils"
+)
+
+const (
+	// busBuffer - number of events kept for the dispatcher before it's considered lagging
+	busBuffer = 1000
+	// queueSize - number of deliveries waiting for workers, events are dead-lettered when it's full
+	queueSize = 1000
+	// workers - number of concurrent deliveries
+	workers = 4
+	// deliveryTimeout - time given to the subscriber to accept the event
+	deliveryTimeout = 5 * time.Second
+
+	defaultAttempts = 5
+	defaultBackoff  = time.Second
+)
+
+var (
+	errQueueFull = errors.New("delivery queue is full")
+	errStopped   = errors.New("server stopped")
+)
+
+// Payload - event posted to the subscriber, counters of updates carry their deltas and counters of sets their values.
+type Payload struct {
+	Time         time.Time         `json:"time"`
+	Subscription string            `json:"subscription"`
+	Tenant       string            `json:"tenant"`
+	Op           string            `json:"op"`
+	Metrics      []*models.Metrics `json:"metrics"`
+	Seq          uint64            `json:"seq"`
+}
+
+// DeadLetter - record of the event which was not delivered.
+type DeadLetter struct {
+	Time         time.Time       `json:"time"`
+	Subscription string          `json:"subscription"`
+	URL          string          `json:"url"`
+	Error        string          `json:"error"`
+	Payload      json.RawMessage `json:"payload"`
+	Attempts     int             `json:"attempts"`
+}
+
+type delivery struct {
+	sub  *Subscription
+	body []byte
+}
+
+// Dispatcher delivers events of metric updates to subscribers.
+type Dispatcher struct {
+	bus        *events.Bus
+	registry   *Registry
+	client     *http.Client
+	queue      chan *delivery
+	deadLetter io.Writer
+	active     func() bool
+	backoff    time.Duration
+	attempts   int
+	mu         sync.Mutex
+}
+
+// New creates Dispatcher of events of the bus to subscriptions of the registry.
+func New(bus *events.Bus, registry *Registry, opts ...func(*Dispatcher)) *Dispatcher {
+	d := &Dispatcher{
+		bus:      bus,
+		registry: registry,
+		client:   &http.Client{Timeout: deliveryTimeout},
+		queue:    make(chan *delivery, queueSize),
+		active:   func() bool { return true },
+		backoff:  defaultBackoff,
+		attempts: defaultAttempts,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// WithDeadLetter sets the writer events failed to be delivered are recorded to as json lines,
+// they are logged if it's not set.
+func WithDeadLetter(w io.Writer) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.deadLetter = w
+	}
+}
+
+// WithRetries sets the number of delivery attempts and the delay before the first retry, it doubles on every retry.
+func WithRetries(attempts int, backoff time.Duration) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.attempts = attempts
+		d.backoff = backoff
+	}
+}
+
+// WithActive sets the function reporting whether events should be delivered, e.g. on the replication leader only.
+func WithActive(active func() bool) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.active = active
+	}
+}
+
+// Run method delivers events until done is closed, deliveries not completed by then are dead-lettered.
+func (d *Dispatcher) Run(done <-chan struct{}) {
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dl := range d.queue {
+				d.deliver(dl, done)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(d.queue)
+
+	for {
+		sub := d.bus.Subscribe(busBuffer)
+		if !d.consume(sub, done) {
+			sub.Close()
+			return
+		}
+		// events published while the dispatcher lagged are lost
+		logger.Log.Warn("Webhook dispatcher lagged behind metric updates, resubscribing", zap.Error(sub.Err()))
+	}
+}
+
+// consume dispatches events of the subscription until it's dropped, returns false once done is closed.
+func (d *Dispatcher) consume(sub *events.Subscription, done <-chan struct{}) bool {
+	for {
+		select {
+		case <-done:
+			return false
+		case e, ok := <-sub.Events():
+			if !ok {
+				return true
+			}
+			d.dispatch(e)
+		}
+	}
+}
+
+// dispatch queues deliveries of the event to subscriptions of its tenant matching updated metrics.
+func (d *Dispatcher) dispatch(e *events.Event) {
+	if (e.Op != events.OpUpdate && e.Op != events.OpSet) || !d.active() {
+		return
+	}
+	for _, sub := range d.registry.tenant(e.Tenant) {
+		metrics := make([]*models.Metrics, 0)
+		for _, m := range e.Metrics {
+			if sub.matches(m.ID) {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+		body, err := json.Marshal(&Payload{
+			Time:         e.Time,
+			Subscription: sub.ID,
+			Tenant:       e.Tenant,
+			Op:           e.Op.String(),
+			Metrics:      metrics,
+			Seq:          e.Seq,
+		})
+		if err != nil {
+			logger.Log.Error("Failed to marshal webhook payload", zap.String("subscription", sub.ID), zap.Error(err))
+			continue
+		}
+		dl := &delivery{sub: sub, body: body}
+		select {
+		case d.queue <- dl:
+		default:
+			d.dead(dl, 0, errQueueFull)
+		}
+	}
+}
+
+// deliver posts the event retrying with exponential backoff.
+func (d *Dispatcher) deliver(dl *delivery, done <-chan struct{}) {
+	backoff := d.backoff
+	var err error
+	for attempt := 1; attempt <= d.attempts; attempt++ {
+		if err = d.post(dl); err == nil {
+			return
+		}
+		if attempt == d.attempts {
+			break
+		}
+		select {
+		case <-done:
+			d.dead(dl, attempt, fmt.Errorf("%w: %v", errStopped, err))
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	d.dead(dl, d.attempts, err)
+}
+
+func (d *Dispatcher) post(dl *delivery) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, dl.sub.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if dl.sub.Secret != "" {
+		hash, err := utils.Sha256Hash(dl.body, dl.sub.Secret)
+		if err != nil {
+			return fmt.Errorf("failed to sign event: %w", err)
+		}
+		req.Header.Set("HashSHA256", hash)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// dead records the event to the dead-letter log.
+func (d *Dispatcher) dead(dl *delivery, attempts int, err error) {
+	logger.Log.Error("Failed to deliver webhook event",
+		zap.String("subscription", dl.sub.ID),
+		zap.String("url", dl.sub.URL),
+		zap.Int("attempts", attempts),
+		zap.Error(err))
+	if d.deadLetter == nil {
+		return
+	}
+	line, mErr := json.Marshal(&DeadLetter{
+		Time:         time.Now(),
+		Subscription: dl.sub.ID,
+		URL:          dl.sub.URL,
+		Error:        err.Error(),
+		Payload:      dl.body,
+		Attempts:     attempts,
+	})
+	if mErr != nil {
+		logger.Log.Error("Failed to marshal dead letter", zap.Error(mErr))
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, wErr := d.deadLetter.Write(append(line, '\n')); wErr != nil {
+		logger.Log.Error("Failed to write dead letter", zap.Error(wErr))
+	}
+}
@@ -0,0 +1,237 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/tenant"
+)
+
+var (
+	// ErrSubscriptionNotFound - represents that the tenant has no subscription with the id
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+	// ErrInvalidSubscription - represents that the subscription has no valid url or pattern
+	ErrInvalidSubscription = errors.New("invalid webhook subscription")
+)
+
+// Subscription - URL notified about updates of metrics with names matching the Pattern.
+// Pattern syntax is the one of path.Match, events are signed with the Secret if it's set.
+type Subscription struct {
+	ID      string `json:"id"`
+	Tenant  string `json:"tenant"`
+	URL     string `json:"url"`
+	Pattern string `json:"pattern"`
+	Secret  string `json:"secret,omitempty"`
+}
+
+// Validate method checks that the subscription has an absolute http url and a valid pattern.
+func (s *Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http url", ErrInvalidSubscription)
+	}
+	if _, err = path.Match(s.Pattern, ""); err != nil || s.Pattern == "" {
+		return fmt.Errorf("%w: invalid pattern", ErrInvalidSubscription)
+	}
+	return nil
+}
+
+// matches method reports whether the series is covered by the subscription.
+func (s *Subscription) matches(key string) bool {
+	name, _, err := models.ParseSeriesKey(key)
+	if err != nil {
+		name = key
+	}
+	ok, _ := path.Match(s.Pattern, name)
+	return ok
+}
+
+// Replicas consists methods to copy changes of subscriptions to other nodes
+type Replicas interface {
+	PutWebhook(ctx context.Context, sub *Subscription) error
+	RemoveWebhook(ctx context.Context, id string) error
+}
+
+// Registry keeps subscriptions of tenants in memory.
+// Subscriptions are copied to replicas if they are set, so every node of the cluster delivers updates it applies.
+type Registry struct {
+	subs     map[string]map[string]*Subscription
+	replicas Replicas
+	guard    func(ctx context.Context) error
+	mu       sync.RWMutex
+}
+
+// NewRegistry creates Registry.
+func NewRegistry(opts ...func(*Registry)) *Registry {
+	r := &Registry{subs: make(map[string]map[string]*Subscription)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithReplicas makes the Registry copy added and removed subscriptions to the replicas.
+func WithReplicas(replicas Replicas) func(*Registry) {
+	return func(r *Registry) {
+		r.replicas = replicas
+	}
+}
+
+// WithGuard sets the check subscriptions have to pass before they are added or removed.
+func WithGuard(guard func(ctx context.Context) error) func(*Registry) {
+	return func(r *Registry) {
+		r.guard = guard
+	}
+}
+
+// Add method registers the subscription for the tenant of the context and returns it with the assigned id,
+// secrets are never returned. The subscription is not registered if it fails to be copied to the replicas.
+func (r *Registry) Add(ctx context.Context, sub *Subscription) (*Subscription, error) {
+	if err := sub.Validate(); err != nil {
+		return nil, err
+	}
+	if r.guard != nil {
+		if err := r.guard(ctx); err != nil {
+			return nil, err
+		}
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate subscription id: %w", err)
+	}
+	res := &Subscription{ID: id, Tenant: tenant.FromContext(ctx), URL: sub.URL, Pattern: sub.Pattern, Secret: sub.Secret}
+
+	r.put(res)
+	if r.replicas != nil {
+		if err = r.replicas.PutWebhook(ctx, res); err != nil {
+			r.Delete(res.Tenant, id)
+			// replicas which got the subscription drop it
+			_ = r.replicas.RemoveWebhook(ctx, id)
+			return nil, fmt.Errorf("failed to copy subscription to replicas: %w", err)
+		}
+	}
+	c := *res
+	c.Secret = ""
+	return &c, nil
+}
+
+// Put method saves the subscription copied from another node as is.
+func (r *Registry) Put(sub *Subscription) error {
+	if err := sub.Validate(); err != nil {
+		return err
+	}
+	if sub.ID == "" || !tenant.Valid(sub.Tenant) {
+		return fmt.Errorf("%w: invalid id or tenant", ErrInvalidSubscription)
+	}
+	c := *sub
+	r.put(&c)
+	return nil
+}
+
+func (r *Registry) put(sub *Subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.subs[sub.Tenant] == nil {
+		r.subs[sub.Tenant] = make(map[string]*Subscription)
+	}
+	r.subs[sub.Tenant][sub.ID] = sub
+}
+
+// Delete method removes the subscription of the tenant removed on another node if it exists.
+func (r *Registry) Delete(tenantID string, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.subs[tenantID], id)
+}
+
+// All method returns copies of subscriptions of all tenants with their secrets, so they can be copied to other nodes.
+func (r *Registry) All() []*Subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make([]*Subscription, 0)
+	for _, subs := range r.subs {
+		for _, sub := range subs {
+			c := *sub
+			res = append(res, &c)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// Replace method replaces all subscriptions with the ones copied from another node.
+func (r *Registry) Replace(subs []*Subscription) {
+	replaced := make(map[string]map[string]*Subscription)
+	for _, sub := range subs {
+		if replaced[sub.Tenant] == nil {
+			replaced[sub.Tenant] = make(map[string]*Subscription)
+		}
+		c := *sub
+		replaced[sub.Tenant][sub.ID] = &c
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subs = replaced
+}
+
+// List method returns subscriptions of the tenant of the context ordered by ids without their secrets.
+func (r *Registry) List(ctx context.Context) []*Subscription {
+	subs := r.tenant(tenant.FromContext(ctx))
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+	return subs
+}
+
+// Remove method removes the subscription of the tenant of the context here and from the replicas.
+func (r *Registry) Remove(ctx context.Context, id string) error {
+	if r.guard != nil {
+		if err := r.guard(ctx); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	subs := r.subs[tenant.FromContext(ctx)]
+	_, ok := subs[id]
+	delete(subs, id)
+	r.mu.Unlock()
+	if !ok {
+		return ErrSubscriptionNotFound
+	}
+	if r.replicas != nil {
+		if err := r.replicas.RemoveWebhook(ctx, id); err != nil {
+			return fmt.Errorf("failed to remove subscription from replicas: %w", err)
+		}
+	}
+	return nil
+}
+
+// tenant returns copies of subscriptions of the tenant.
+func (r *Registry) tenant(id string) []*Subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make([]*Subscription, 0, len(r.subs[id]))
+	for _, sub := range r.subs[id] {
+		c := *sub
+		res = append(res, &c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
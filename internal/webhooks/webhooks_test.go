@@ -0,0 +1,240 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vindosVP/metrics/internal/events"
+	"github.com/vindosVP/metrics/internal/models"
+	"github.com/vindosVP/metrics/internal/tenant"
+	"github.com/vindosVP/metrics/pkg/utils"
+)
+
+// subscriber collects events posted to it and whether they were signed with the secret,
+// the first failures requests are rejected.
+type subscriber struct {
+	payloads []*Payload
+	signed   []bool
+	failures int
+	mu       sync.Mutex
+}
+
+func (s *subscriber) serve(t *testing.T) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		p := &Payload{}
+		require.NoError(t, json.Unmarshal(body, p))
+		hash, err := utils.Sha256Hash(body, "secret")
+		require.NoError(t, err)
+		s.payloads = append(s.payloads, p)
+		s.signed = append(s.signed, req.Header.Get("HashSHA256") == hash)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func (s *subscriber) received() []*Payload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Payload(nil), s.payloads...)
+}
+
+// syncBuffer is the dead-letter log safe for concurrent reads in tests.
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func run(t *testing.T, d *Dispatcher) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		d.Run(done)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		close(done)
+		<-stopped
+	})
+	// the dispatcher subscribes asynchronously
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestSubscription_Validate(t *testing.T) {
+	tests := []struct {
+		sub     *Subscription
+		name    string
+		wantErr bool
+	}{
+		{name: "valid", sub: &Subscription{URL: "http://localhost:8080/hook", Pattern: "Alloc*"}},
+		{name: "https", sub: &Subscription{URL: "https://example.com/hook", Pattern: "*"}},
+		{name: "relative url", sub: &Subscription{URL: "/hook", Pattern: "*"}, wantErr: true},
+		{name: "other scheme", sub: &Subscription{URL: "ftp://example.com", Pattern: "*"}, wantErr: true},
+		{name: "no pattern", sub: &Subscription{URL: "http://localhost/hook"}, wantErr: true},
+		{name: "invalid pattern", sub: &Subscription{URL: "http://localhost/hook", Pattern: "["}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sub.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSubscription)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	teamA := tenant.WithTenant(ctx, "team-a")
+	r := NewRegistry()
+
+	sub, err := r.Add(ctx, &Subscription{URL: "http://localhost/hook", Pattern: "Alloc", Secret: "secret"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, sub.ID)
+	assert.Equal(t, tenant.Default, sub.Tenant)
+	assert.Empty(t, sub.Secret)
+	_, err = r.Add(ctx, &Subscription{URL: "localhost", Pattern: "Alloc"})
+	assert.ErrorIs(t, err, ErrInvalidSubscription)
+
+	assert.Equal(t, []*Subscription{sub}, r.List(ctx))
+	assert.Empty(t, r.List(teamA))
+	assert.Equal(t, "secret", r.tenant(tenant.Default)[0].Secret)
+
+	assert.ErrorIs(t, r.Remove(teamA, sub.ID), ErrSubscriptionNotFound)
+	require.NoError(t, r.Remove(ctx, sub.ID))
+	assert.Empty(t, r.List(ctx))
+}
+
+// replicas records subscriptions copied to them, putting fails with err.
+type replicas struct {
+	err     error
+	put     []string
+	removed []string
+}
+
+func (r *replicas) PutWebhook(_ context.Context, sub *Subscription) error {
+	r.put = append(r.put, sub.ID)
+	return r.err
+}
+
+func (r *replicas) RemoveWebhook(_ context.Context, id string) error {
+	r.removed = append(r.removed, id)
+	return nil
+}
+
+func TestRegistry_replicas(t *testing.T) {
+	ctx := context.Background()
+	rs := &replicas{}
+	r := NewRegistry(WithReplicas(rs))
+
+	sub, err := r.Add(ctx, &Subscription{URL: "http://localhost/hook", Pattern: "Alloc", Secret: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{sub.ID}, rs.put)
+	require.NoError(t, r.Remove(ctx, sub.ID))
+	assert.Equal(t, []string{sub.ID}, rs.removed)
+
+	// the subscription which replicas failed to get is not registered
+	rs.err = errors.New("node is unavailable")
+	_, err = r.Add(ctx, &Subscription{URL: "http://localhost/hook", Pattern: "Alloc"})
+	assert.Error(t, err)
+	assert.Empty(t, r.All())
+	assert.Len(t, rs.removed, 2)
+
+	// subscriptions copied from other nodes keep their ids, tenants and secrets
+	copied := &Subscription{ID: "1", Tenant: "team-a", URL: "http://localhost/hook", Pattern: "*", Secret: "secret"}
+	require.NoError(t, r.Put(copied))
+	assert.Equal(t, []*Subscription{copied}, r.All())
+	assert.ErrorIs(t, r.Put(&Subscription{URL: "http://localhost/hook", Pattern: "*"}), ErrInvalidSubscription)
+	r.Delete("team-a", "1")
+	assert.Empty(t, r.All())
+	r.Replace([]*Subscription{copied})
+	assert.Equal(t, []*Subscription{copied}, r.All())
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	bus := events.NewBus()
+	r := NewRegistry()
+	s := &subscriber{failures: 1}
+	_, err := r.Add(ctx, &Subscription{URL: s.serve(t), Pattern: "Alloc*", Secret: "secret"})
+	require.NoError(t, err)
+	run(t, New(bus, r, WithRetries(3, time.Millisecond)))
+
+	alloc := 1.5
+	delta := int64(2)
+	bus.Publish(&events.Event{Tenant: tenant.Default, Op: events.OpUpdate, Metrics: []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &alloc},
+		{ID: "PollCount", MType: models.Counter, Delta: &delta},
+	}})
+	// other tenants, operations and metrics are not delivered
+	bus.Publish(&events.Event{Tenant: "team-a", Op: events.OpUpdate, Metrics: []*models.Metrics{{ID: "Alloc", MType: models.Gauge, Value: &alloc}}})
+	bus.Publish(&events.Event{Tenant: tenant.Default, Op: events.OpDelete, Metrics: []*models.Metrics{{ID: "Alloc", MType: models.Gauge}}})
+	bus.Publish(&events.Event{Tenant: tenant.Default, Op: events.OpUpdate, Metrics: []*models.Metrics{{ID: "PollCount", MType: models.Counter, Delta: &delta}}})
+
+	require.Eventually(t, func() bool { return len(s.received()) == 1 }, time.Second, 10*time.Millisecond)
+	p := s.received()[0]
+	assert.Equal(t, "update", p.Op)
+	assert.Equal(t, tenant.Default, p.Tenant)
+	assert.Equal(t, uint64(1), p.Seq)
+	require.Len(t, p.Metrics, 1)
+	assert.Equal(t, "Alloc", p.Metrics[0].ID)
+	assert.Equal(t, []bool{true}, s.signed)
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	ctx := context.Background()
+	bus := events.NewBus()
+	r := NewRegistry()
+	s := &subscriber{failures: 3}
+	sub, err := r.Add(ctx, &Subscription{URL: s.serve(t), Pattern: "*"})
+	require.NoError(t, err)
+	deadLetter := &syncBuffer{}
+	run(t, New(bus, r, WithRetries(3, time.Millisecond), WithDeadLetter(deadLetter)))
+
+	v := int64(5)
+	bus.Publish(&events.Event{Tenant: tenant.Default, Op: events.OpSet, Metrics: []*models.Metrics{{ID: "PollCount", MType: models.Counter, Delta: &v}}})
+
+	require.Eventually(t, func() bool { return deadLetter.String() != "" }, time.Second, 10*time.Millisecond)
+	dl := &DeadLetter{}
+	require.NoError(t, json.Unmarshal([]byte(deadLetter.String()), dl))
+	assert.Equal(t, sub.ID, dl.Subscription)
+	assert.Equal(t, 3, dl.Attempts)
+	assert.Contains(t, dl.Error, "503")
+	p := &Payload{}
+	require.NoError(t, json.Unmarshal(dl.Payload, p))
+	assert.Equal(t, "set", p.Op)
+	assert.Empty(t, s.received())
+}